@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultAgentListen = ":47800"
+
+// AgentSettings configures the LAN listener used in agent and kiosk mode.
+// Fleet servers start the application by POSTing to /start, and stop the
+// launcher by POSTing to /stop, with
+//
+//	X-WAP-Timestamp: <unix seconds>
+//	X-WAP-Nonce:     <random, 16 to 128 characters, new for each request>
+//	X-WAP-Signature: hex(HMAC-SHA256(token, "<command>\n" + timestamp + "\n" + nonce))
+type AgentSettings struct {
+	Listen string `json:"listen"`
+	Token  string `json:"token"` // may be secret://name
+}
+
+// agentMaxClockSkew bounds how old a signed request may be, so a captured
+// request cannot be replayed later. Within the window each nonce is
+// accepted once.
+const agentMaxClockSkew = 2 * time.Minute
+
+const (
+	agentMinNonce = 16
+	agentMaxNonce = 128
+)
+
+type agentServer struct {
+	config  *AppConfig
+	token   []byte
+	server  *http.Server
+	startCh chan struct{}
+
+	mu      sync.Mutex
+	running bool
+
+	nonceMu sync.Mutex
+	nonces  map[string]time.Time // when each accepted nonce was seen
+}
+
+func startAgent(config *AppConfig) (*agentServer, error) {
+	settings := config.Settings.Agent
+	if settings.Token == "" {
+		return nil, errors.New("agent and kiosk mode require agent.token in launcher.json")
+	}
+	token, err := resolveSecretValue(config, "agent.token", settings.Token)
+	if err != nil {
+		return nil, err
+	}
+	listen := settings.Listen
+	if listen == "" {
+		listen = defaultAgentListen
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+
+	agent := &agentServer{config: config, token: []byte(token), startCh: make(chan struct{}, 1), nonces: map[string]time.Time{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", agent.handleStart)
+	mux.HandleFunc("/stop", agent.handleStop)
+	agent.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go agent.server.Serve(listener)
+	consoleSuccessf("Agent listening on %s", listener.Addr())
+	logInfo("agent", "agent listening", "addr", listener.Addr().String())
+	return agent, nil
+}
+
+func (a *agentServer) Close() {
+	a.server.Close()
+}
+
+// waitForStart blocks until an authenticated start command arrives. It
+// returns false if the launcher is exiting instead.
+func (a *agentServer) waitForStart() bool {
+	consolePrintln("Waiting for start command...")
+	select {
+	case <-a.startCh:
+		return true
+	case <-launcherExiting():
+		return false
+	}
+}
+
+func (a *agentServer) setRunning(running bool) {
+	a.mu.Lock()
+	a.running = running
+	a.mu.Unlock()
+}
+
+func (a *agentServer) handleStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := a.verifySignature(r, "start"); err != nil {
+		logWarn("agent", "rejected start command", "remote", r.RemoteAddr, "error", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if hours := a.config.Settings.OperatingHours; hours != nil {
+		if _, _, open := hours.currentWindow(time.Now()); !open {
+			writeAgentStatus(w, http.StatusConflict, "outside-operating-hours")
+			return
+		}
+	}
+
+	a.mu.Lock()
+	running := a.running
+	a.mu.Unlock()
+	if running {
+		writeAgentStatus(w, http.StatusOK, "already-running")
+		return
+	}
+
+	logInfo("agent", "start command accepted", "remote", r.RemoteAddr)
+	select {
+	case a.startCh <- struct{}{}:
+	default:
+		// a start is already pending
+	}
+	writeAgentStatus(w, http.StatusAccepted, "starting")
+}
+
+// handleStop ends the running session and makes the launcher exit
+func (a *agentServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := a.verifySignature(r, "stop"); err != nil {
+		logWarn("agent", "rejected stop command", "remote", r.RemoteAddr, "error", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	logInfo("agent", "stop command accepted", "remote", r.RemoteAddr)
+	requestLauncherExit()
+	writeAgentStatus(w, http.StatusAccepted, "stopping")
+}
+
+func (a *agentServer) verifySignature(r *http.Request, command string) error {
+	timestamp := r.Header.Get("X-WAP-Timestamp")
+	nonce := r.Header.Get("X-WAP-Nonce")
+	signature, err := hex.DecodeString(r.Header.Get("X-WAP-Signature"))
+	if timestamp == "" || err != nil || len(signature) == 0 {
+		return errors.New("missing or malformed signature headers")
+	}
+	if len(nonce) < agentMinNonce || len(nonce) > agentMaxNonce {
+		return errors.New("missing or malformed nonce")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("malformed timestamp")
+	}
+	skew := time.Since(time.Unix(seconds, 0))
+	if skew > agentMaxClockSkew || skew < -agentMaxClockSkew {
+		return fmt.Errorf("timestamp outside allowed window (skew %s)", skew.Round(time.Second))
+	}
+
+	mac := hmac.New(sha256.New, a.token)
+	mac.Write([]byte(command + "\n" + timestamp + "\n" + nonce))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return errors.New("signature mismatch")
+	}
+	return a.useNonce(nonce)
+}
+
+// useNonce accepts each nonce once. A nonce is remembered for twice the
+// clock skew, the longest its timestamp can be valid.
+func (a *agentServer) useNonce(nonce string) error {
+	a.nonceMu.Lock()
+	defer a.nonceMu.Unlock()
+	now := time.Now()
+	for seen, at := range a.nonces {
+		if now.Sub(at) > 2*agentMaxClockSkew {
+			delete(a.nonces, seen)
+		}
+	}
+	if _, ok := a.nonces[nonce]; ok {
+		return errors.New("replayed request")
+	}
+	a.nonces[nonce] = now
+	return nil
+}
+
+func writeAgentStatus(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AlertSettings raise alerts on log lines that point at a degradation the
+// child survives, such as running out of memory in a request handler:
+//
+//	"alerts": {"rules": [
+//	  {"name": "out_of_memory", "pattern": "MemoryError|OutOfMemoryError"},
+//	  {"name": "db_locked", "pattern": "database is locked", "services": ["backend"]}
+//	]}
+type AlertSettings struct {
+	Rules           []AlertRule `json:"rules"`
+	CooldownMinutes int         `json:"cooldown_minutes"` // between notifications per rule, default 10
+}
+
+type AlertRule struct {
+	Name     string   `json:"name"`
+	Pattern  string   `json:"pattern"`  // regular expression matched against each line
+	Services []string `json:"services"` // backend and/or frontend, default both
+	Message  string   `json:"message"`  // notification text, default the matched line
+}
+
+const defaultAlertCooldown = 10
+
+type compiledAlert struct {
+	AlertRule
+	re *regexp.Regexp
+}
+
+// Alert rules and where notifications go, set up once by loadAlerts
+var alerts struct {
+	mu       sync.Mutex
+	rules    []compiledAlert
+	cooldown time.Duration
+	raised   map[string]time.Time
+	notify   func(title, text string)
+}
+
+// compileAlertRules returns the usable rules and an error for each rule
+// that is not
+func compileAlertRules(settings AlertSettings) ([]compiledAlert, []error) {
+	var rules []compiledAlert
+	var errs []error
+	for _, rule := range settings.Rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err == nil && rule.Pattern == "" {
+			err = errors.New("empty pattern")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert rule %q: %w", rule.Name, err))
+			continue
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Pattern
+		}
+		rules = append(rules, compiledAlert{AlertRule: rule, re: re})
+	}
+	return rules, errs
+}
+
+// loadAlerts compiles the configured rules. Broken rules are logged and
+// skipped so a typo does not stop the application from starting.
+func loadAlerts(config *AppConfig) {
+	rules, errs := compileAlertRules(config.Settings.Alerts)
+	for _, err := range errs {
+		logWarn("alerts", "ignoring alert rule", "error", err)
+	}
+	minutes := config.Settings.Alerts.CooldownMinutes
+	if minutes <= 0 {
+		minutes = defaultAlertCooldown
+	}
+
+	alerts.mu.Lock()
+	alerts.rules = rules
+	alerts.cooldown = time.Duration(minutes) * time.Minute
+	alerts.raised = map[string]time.Time{}
+	alerts.mu.Unlock()
+}
+
+// setAlertNotifier sets where alert notifications are shown, normally the
+// tray; nil turns them off
+func setAlertNotifier(notify func(title, text string)) {
+	alerts.mu.Lock()
+	alerts.notify = notify
+	alerts.mu.Unlock()
+}
+
+// alertNotifier returns where notifications are shown, nil if nowhere
+func alertNotifier() func(title, text string) {
+	alerts.mu.Lock()
+	defer alerts.mu.Unlock()
+	return alerts.notify
+}
+
+// checkAlerts matches a line of a child's output against the rules. Every
+// match is counted; the notification is rate limited per rule.
+func checkAlerts(config *AppConfig, control *controlServer, service, line string) {
+	alerts.mu.Lock()
+	var matched []compiledAlert
+	for _, rule := range alerts.rules {
+		if len(rule.Services) > 0 && !slices.Contains(rule.Services, service) {
+			continue
+		}
+		if rule.re.MatchString(line) {
+			matched = append(matched, rule)
+		}
+	}
+	if len(matched) == 0 {
+		alerts.mu.Unlock()
+		return
+	}
+	var raise []compiledAlert
+	for _, rule := range matched {
+		if last, ok := alerts.raised[rule.Name]; !ok || time.Since(last) >= alerts.cooldown {
+			alerts.raised[rule.Name] = time.Now()
+			raise = append(raise, rule)
+		}
+	}
+	notify := alerts.notify
+	alerts.mu.Unlock()
+
+	for _, rule := range matched {
+		control.serviceAlert(service, rule.Name)
+	}
+	for _, rule := range raise {
+		message := rule.Message
+		if message == "" {
+			message = shortLine(line)
+		}
+		logWarn("alerts", "log alert", "rule", rule.Name, "service", service, "line", shortLine(line))
+		reportEvent(eventTypeWarning, eventIDLogAlert, "Alert "+rule.Name+" ("+service+"): "+message)
+		emitDegraded(service, "alert:"+rule.Name, message)
+		if notify != nil {
+			notify(config.AppName+" - "+rule.Name, message)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Antivirus products regularly mistake the embedded Python for malware and
+// quarantine python.exe, DLLs or .pyd files, or lock them while scanning.
+// When verification finds program files missing or unreadable, the
+// launcher looks for Microsoft Defender detections in the install folder,
+// explains what happened and offers to add the folder to Defender's
+// exclusions (as an elevated step) before repairing.
+type AntivirusSettings struct {
+	ExclusionDisabled bool `json:"exclusion_disabled"` // never offer a Defender exclusion
+}
+
+const (
+	defenderExclusionCommand = "__defender-exclusion"
+	defenderQueryTimeout     = 20 * time.Second
+)
+
+// antivirusSuspects returns the program files that vanished or cannot be
+// opened, the marks of an antivirus product
+func antivirusSuspects(problems []verifyProblem) []verifyProblem {
+	var suspects []verifyProblem
+	for _, p := range problems {
+		if !criticalExtensions[strings.ToLower(filepath.Ext(p.Path))] {
+			continue
+		}
+		problem := strings.ToLower(p.Problem)
+		if problem == "missing" || strings.Contains(problem, "access is denied") || strings.Contains(problem, "used by another process") {
+			suspects = append(suspects, p)
+		}
+	}
+	return suspects
+}
+
+func powershell(ctx context.Context, script string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	return cmd
+}
+
+// defenderDetections returns the files below dir that Microsoft Defender
+// detected as threats. Other antivirus products, or a Defender that does
+// not answer, give nothing.
+func defenderDetections(dir string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), defenderQueryTimeout)
+	defer cancel()
+	out, err := powershell(ctx, "Get-MpThreatDetection | ForEach-Object { $_.Resources }").Output()
+	if err != nil {
+		logDebug("antivirus", "could not query Defender detections", "error", err)
+		return nil
+	}
+	prefix := strings.ToLower(filepath.Clean(dir)) + `\`
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		// Resources look like "file:_C:\Program Files\WAP\bin\python311.dll"
+		_, path, ok := strings.Cut(strings.TrimSpace(line), "file:_")
+		if ok && strings.HasPrefix(strings.ToLower(path), prefix) {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// adviseAntivirus explains antivirus interference and offers the
+// exclusion. Repair follows either way.
+func adviseAntivirus(config *AppConfig, suspects []verifyProblem) {
+	detections := defenderDetections(config.BinDir)
+	logWarn("antivirus", "program files missing or locked, antivirus suspected", "count", len(suspects), "defender_detections", len(detections))
+
+	var message strings.Builder
+	if len(detections) > 0 {
+		message.WriteString(tr("Microsoft Defender quarantined %d file(s) of %s:", len(detections), config.AppName) + "\n\n")
+		for i, path := range detections {
+			if i == 5 {
+				message.WriteString(tr("... and %d more", len(detections)-i) + "\n")
+				break
+			}
+			rel, _ := filepath.Rel(config.BinDir, path)
+			fmt.Fprintf(&message, "%s\n", rel)
+		}
+		message.WriteString("\n" + tr("This is a false positive: the files are part of the bundled Python runtime."))
+	} else {
+		message.WriteString(tr("%d program file(s) of %s are missing or cannot be opened, for example %s. Antivirus software that quarantined or is scanning them is the usual cause.",
+			len(suspects), config.AppName, suspects[0].Path))
+	}
+	reportEvent(eventTypeWarning, eventIDIntegrity, message.String())
+	if machineOutput() {
+		consolePrintln(message.String())
+		return
+	}
+
+	if config.Settings.Antivirus.ExclusionDisabled {
+		messageBox(config.AppName, message.String()+"\n\n"+tr("Ask your administrator to exclude %s from antivirus scanning.", config.BinDir),
+			mbOK|mbIconWarning|mbTopmost)
+		return
+	}
+	answer := messageBox(config.AppName,
+		message.String()+"\n\n"+tr("Add %s to the Microsoft Defender exclusions so this does not happen again? Windows will ask for administrator permission. If you use another antivirus product, add the exclusion there instead.", config.BinDir),
+		mbYesNo|mbIconWarning|mbTopmost)
+	if answer != idYes {
+		return
+	}
+	if err := runElevatedStep(defenderExclusionCommand, config.BinDir); err != nil {
+		logWarn("antivirus", "failed to add Defender exclusion", "path", config.BinDir, "error", err)
+		showError("Failed to add the Defender exclusion", err)
+		return
+	}
+	logInfo("antivirus", "added Defender exclusion", "path", config.BinDir)
+	consoleSuccessf(tr("%s is excluded from Microsoft Defender scans"), config.BinDir)
+}
+
+// runDefenderExclusionCommand is the elevated half: "__defender-exclusion PATH"
+func runDefenderExclusionCommand(config *AppConfig, args []string) int {
+	if len(args) != 1 {
+		return exitUsage
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	script := "Add-MpPreference -ExclusionPath '" + strings.ReplaceAll(args[0], "'", "''") + "'"
+	if out, err := powershell(ctx, script).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %s\n", err, out)
+		return exitLauncherError
+	}
+	return exitOK
+}
+
+func doctorAntivirus(config *AppConfig) doctorResult {
+	if detections := defenderDetections(config.BinDir); len(detections) > 0 {
+		return doctorResult{detail: fmt.Sprintf("Microsoft Defender detected %d file(s), e.g. %s; restore them and run \"launcher repair\"", len(detections), detections[0])}
+	}
+	return doctorResult{ok: true, detail: "no Microsoft Defender detections in " + config.BinDir}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A backend left running by an earlier session, kept warm or orphaned by a
+// launcher that crashed, is reused instead of starting a second python.exe
+// on the same port. It has to identify itself through /info as this
+// installation's backend first, so an unrelated server that happens to
+// answer on the port is never attached to.
+const backendAppName = "wap"
+
+// backendInfo is the backend's answer to GET /info
+type backendInfo struct {
+	App         string `json:"app"`
+	Version     string `json:"version"`
+	PID         int    `json:"pid"`
+	LauncherPID int    `json:"launcher_pid"` // the launcher that started it
+	DataDir     string `json:"data_dir"`
+	Python      string `json:"python"`
+}
+
+func fetchBackendInfo(baseURL string) (*backendInfo, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(baseURL + "/info")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s/info returned %s", baseURL, resp.Status)
+	}
+	var info backendInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("%s/info: %w", baseURL, err)
+	}
+	return &info, nil
+}
+
+// mismatch returns why info does not describe a backend this launcher may
+// use, or "" if it does
+func (info *backendInfo) mismatch(config *AppConfig) string {
+	switch {
+	case info.App != backendAppName:
+		return "not a WAP backend"
+	case info.PID <= 0:
+		return "no process ID"
+	case info.Version != installedVersion(config):
+		return "version " + info.Version
+	case !strings.EqualFold(filepath.Clean(info.DataDir), filepath.Clean(config.DataDir)):
+		return "data directory " + info.DataDir
+	case !sameFile(info.Python, backendProgram(config)):
+		return "python " + info.Python
+	}
+	return ""
+}
+
+// ownedByOtherLauncher reports whether the launcher that started the
+// backend is still running, in which case the backend is not free to take
+func (info *backendInfo) ownedByOtherLauncher() bool {
+	if info.LauncherPID == 0 || info.LauncherPID == os.Getpid() {
+		return false
+	}
+	_, err := processCreated(info.LauncherPID)
+	return err == nil
+}
+
+// attachRunningBackend makes the session use a backend that is already
+// running and returns false if a new one has to be started
+func attachRunningBackend(config *AppConfig, session *Session) bool {
+	if warmBackendEnabled(config) && adoptWarmBackend(config, session) {
+		return true
+	}
+
+	info, err := fetchBackendInfo(config.BackendURL)
+	if err != nil {
+		// Usually nothing is listening, which is what a fresh start wants
+		return false
+	}
+	if reason := info.mismatch(config); reason != "" {
+		logWarn("backend", "another server answers on the backend port", "url", config.BackendURL, "reason", reason)
+		return false
+	}
+	if info.ownedByOtherLauncher() {
+		logWarn("backend", "backend on the port belongs to a running launcher", "pid", info.PID, "launcher_pid", info.LauncherPID)
+		return false
+	}
+	if _, err := processCreated(info.PID); err != nil {
+		return false
+	}
+	process, err := os.FindProcess(info.PID)
+	if err != nil {
+		logWarn("backend", "cannot attach to running backend", "pid", info.PID, "error", err)
+		return false
+	}
+
+	session.adoptBackend(process)
+	if warmBackendEnabled(config) {
+		recordWarmBackend(config, info.PID)
+	}
+	consoleSuccessf("Reusing the running backend (PID %d) at %s", info.PID, config.BackendURL)
+	logInfo("backend", "attached to running backend", "child_pid", info.PID, "url", config.BackendURL, "version", info.Version)
+	return true
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// "launcher autostart on" (or the tray's "Start at login") adds the
+// launcher to the user's Run key with --minimized: at login the backend
+// starts and warms up without a splash or window, and the app opens from
+// the tray or when the user starts the application.
+const autostartRunKey = `Software\Microsoft\Windows\CurrentVersion\Run`
+
+var procRegDeleteKeyValueW = advapi32.NewProc("RegDeleteKeyValueW")
+
+// autostartValueName keeps the profiles' entries apart
+func autostartValueName(config *AppConfig) string {
+	return appUserModelID(config)
+}
+
+func autostartEnabled(config *AppConfig) bool {
+	command, err := readRegistryString(syscall.HKEY_CURRENT_USER, autostartRunKey, autostartValueName(config))
+	return err == nil && command != ""
+}
+
+// setAutostart adds or removes the Run entry
+func setAutostart(config *AppConfig, enabled bool) error {
+	if !enabled {
+		ret, _, _ := procRegDeleteKeyValueW.Call(uintptr(syscall.HKEY_CURRENT_USER),
+			uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(autostartRunKey))),
+			uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(autostartValueName(config)))))
+		if ret != 0 && syscall.Errno(ret) != syscall.ERROR_FILE_NOT_FOUND {
+			return syscall.Errno(ret)
+		}
+		logInfo("launcher", "autostart turned off")
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf(`"%s"`, exe)
+	if config.Profile != "" {
+		command += fmt.Sprintf(` --%s "%s"`, profileFlagName, config.Profile)
+	}
+	command += " --minimized"
+	if err := setRegistryString(syscall.HKEY_CURRENT_USER, autostartRunKey, autostartValueName(config), command); err != nil {
+		return err
+	}
+	logInfo("launcher", "autostart turned on", "command", command)
+	return nil
+}
+
+// toggleAutostart is the tray's "Start at login"
+func toggleAutostart(config *AppConfig, tray *Tray) {
+	enabled := !autostartEnabled(config)
+	if err := setAutostart(config, enabled); err != nil {
+		logError("launcher", "failed to change autostart", "error", err)
+		tray.Notify(config.AppName, tr("Could not change whether %s starts at login: %v", config.AppName, err))
+		return
+	}
+	if enabled {
+		tray.Notify(config.AppName, tr("%s will start in the background when you sign in.", config.AppName))
+	} else {
+		tray.Notify(config.AppName, tr("%s will no longer start when you sign in.", config.AppName))
+	}
+}
+
+func runAutostartCommand(config *AppConfig, args []string) int {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "on", "off":
+		if err := setAutostart(config, action == "on"); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitLauncherError
+		}
+		fmt.Printf("✓ Autostart turned %s\n", action)
+	case "status":
+		if autostartEnabled(config) {
+			fmt.Printf("%s starts in the background at login\n", config.AppName)
+		} else {
+			fmt.Printf("%s does not start at login\n", config.AppName)
+		}
+	default:
+		printCommandHelp(os.Stderr, findCommand("autostart"))
+		return exitUsage
+	}
+	return exitOK
+}
+
+// forwardShow asks the launcher already running to show the app. It
+// returns false when that launcher cannot.
+func forwardShow(config *AppConfig) bool {
+	if err := sendServiceCommand(config, serviceCommand{Action: "show"}); err != nil {
+		logWarn("launcher", "failed to show the running application", "error", err)
+		return false
+	}
+	logInfo("launcher", "showed the running application")
+	return true
+}
+
+// requestShow opens the app of a session started with --minimized
+func (s *Session) requestShow() {
+	select {
+	case s.showCh <- struct{}{}:
+	default:
+	}
+}
+
+// waitForShow waits until the app is asked for; false if the session
+// stops first
+func (s *Session) waitForShow() bool {
+	s.mu.Lock()
+	s.awaitingShow = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.awaitingShow = false
+		s.mu.Unlock()
+	}()
+	consolePrintln("The backend is ready; the application opens when it is needed")
+	select {
+	case <-s.showCh:
+		return true
+	case <-s.Stopping():
+		return false
+	}
+}
+
+// show brings the app to the front, or opens it when it was started
+// minimized and is not open yet
+func (s *Session) show() error {
+	s.mu.Lock()
+	waiting, frontend := s.awaitingShow, s.frontend
+	s.mu.Unlock()
+	if waiting {
+		s.requestShow()
+		return nil
+	}
+	if _, ok := s.config.Frontend.(browserRunner); ok {
+		return openURL(browserURL(s.config))
+	}
+	if frontend == nil {
+		return errors.New("the application is still starting")
+	}
+	windows := processWindows(frontend.Process.Pid)
+	if len(windows) == 0 {
+		return errors.New("the application has no window yet")
+	}
+	procSetForegroundWindow.Call(windows[0])
+	return nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BackendSettings choose what runs the backend:
+//
+//	"backend": {"runner": "embedded"}
+//	"backend": {"runner": "python", "python": "C:\\venvs\\wap\\Scripts\\python.exe"}
+//	"backend": {"runner": "executable", "executable": "bin\\backend\\wap_backend.exe", "args": ["--serve"]}
+//	"backend": {"runner": "node"}
+//	"backend": {"runner": "interpreter", "interpreter": "bin\\deno\\deno.exe", "script": "bin\\server\\main.ts", "args": ["run", "-A"], "health_path": "/healthz"}
+//	"backend": {"runner": "remote", "url": "https://wap.example.com"}
+//
+// embedded, the default, is bin\embedded_python\python.exe running
+// start_server.py. python runs start_server.py with a system Python or a
+// virtualenv, with its environment unchanged. executable is a compiled
+// backend (PyInstaller, Nuitka, ...), relative paths being relative to the
+// install folder; it gets the same WAP_* variables and --backend-args.
+// interpreter runs script with any runtime: interpreter, then args, the
+// script and --backend-args, in the script's folder. node is interpreter
+// with bin\node\node.exe and bin\server\server.js unless they are set.
+// remote uses a central server, like --backend-url. Every local backend
+// answers health_path (default /health) with 200 once it is ready.
+type BackendSettings struct {
+	Runner      string   `json:"runner"`
+	Python      string   `json:"python"`
+	Executable  string   `json:"executable"`
+	Interpreter string   `json:"interpreter"`
+	Script      string   `json:"script"`
+	Args        []string `json:"args"`
+	URL         string   `json:"url"`
+	HealthPath  string   `json:"health_path"`
+}
+
+// Backend runners
+const (
+	runnerEmbedded    = "embedded"
+	runnerPython      = "python"
+	runnerExecutable  = "executable"
+	runnerInterpreter = "interpreter"
+	runnerNode        = "node"
+	runnerRemote      = "remote"
+
+	defaultHealthPath = "/health"
+	defaultNodeExe    = `bin\node\node.exe`
+	defaultNodeScript = `bin\server\server.js`
+)
+
+// BackendRunner knows how to start one kind of backend. startBackend adds
+// what every backend gets: the WAP_* variables, secrets, the sandbox and
+// the log files.
+type BackendRunner interface {
+	Name() string
+	// Command returns the backend process, not started, with its program,
+	// arguments, directory and base environment
+	Command(config *AppConfig) (*exec.Cmd, error)
+	// Files are the files the backend cannot start without
+	Files(config *AppConfig) []requiredFile
+}
+
+// requiredFile is checked by validateEnvironment
+type requiredFile struct {
+	path string
+	name string
+}
+
+// selectBackendRunner picks the runner launcher.json asks for. --backend-url
+// and --dev --python override it in applyOptions.
+func selectBackendRunner(config *AppConfig) error {
+	settings := config.Settings.Backend
+	switch strings.ToLower(settings.Runner) {
+	case "", runnerEmbedded:
+		config.Backend = embeddedRunner{}
+	case runnerPython:
+		if settings.Python == "" {
+			return fmt.Errorf("backend.python is required with the python runner")
+		}
+		usePython(config, installPath(config, settings.Python))
+	case runnerExecutable:
+		if settings.Executable == "" {
+			return fmt.Errorf("backend.executable is required with the executable runner")
+		}
+		config.Backend = executableRunner{path: installPath(config, settings.Executable), args: settings.Args}
+	case runnerNode:
+		config.Backend = interpreterRunner{
+			name:        runnerNode,
+			interpreter: installPath(config, cmp.Or(settings.Interpreter, defaultNodeExe)),
+			script:      installPath(config, cmp.Or(settings.Script, defaultNodeScript)),
+			args:        settings.Args,
+		}
+	case runnerInterpreter:
+		if settings.Interpreter == "" || settings.Script == "" {
+			return fmt.Errorf("backend.interpreter and backend.script are required with the interpreter runner")
+		}
+		config.Backend = interpreterRunner{
+			name:        runnerInterpreter,
+			interpreter: installPath(config, settings.Interpreter),
+			script:      installPath(config, settings.Script),
+			args:        settings.Args,
+		}
+	case runnerRemote:
+		if settings.URL == "" {
+			return fmt.Errorf("backend.url is required with the remote runner")
+		}
+		useRemoteBackend(config, settings.URL)
+	default:
+		return fmt.Errorf("unknown backend.runner %q (embedded, python, executable, node, interpreter or remote)", settings.Runner)
+	}
+	return nil
+}
+
+// healthURL is the backend's readiness endpoint below baseURL
+func healthURL(config *AppConfig, baseURL string) string {
+	path := cmp.Or(config.Settings.Backend.HealthPath, defaultHealthPath)
+	return baseURL + "/" + strings.TrimLeft(path, "/")
+}
+
+// usePython runs start_server.py with a Python other than the embedded one
+func usePython(config *AppConfig, python string) {
+	config.PythonExe = python
+	config.PythonDir = filepath.Dir(python)
+	config.ExternalPython = true
+	config.Backend = pythonRunner{}
+}
+
+// useRemoteBackend points the launcher at a central server
+func useRemoteBackend(config *AppConfig, url string) {
+	config.BackendURL = strings.TrimRight(url, "/")
+	config.RemoteBackend = true
+	config.Backend = remoteRunner{}
+}
+
+func installPath(config *AppConfig, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(config.RootDir, path)
+}
+
+// bundledPython reports whether the backend runs on the embedded
+// interpreter, whose runtime and packages the launcher checks
+func bundledPython(config *AppConfig) bool {
+	_, ok := config.Backend.(embeddedRunner)
+	return ok
+}
+
+// launcherPython reports whether the launcher has a Python for its own
+// scripts (data migrations, the database check). A compiled backend may
+// ship without one.
+func launcherPython(config *AppConfig) bool {
+	switch config.Backend.(type) {
+	case remoteRunner:
+		return false
+	case executableRunner, interpreterRunner:
+		_, err := os.Stat(config.PythonExe)
+		return err == nil
+	}
+	return true
+}
+
+// backendProgram is the file the backend runs as, for the warm backend and
+// attach checks
+func backendProgram(config *AppConfig) string {
+	switch r := config.Backend.(type) {
+	case executableRunner:
+		return r.path
+	case interpreterRunner:
+		return r.interpreter
+	}
+	return config.PythonExe
+}
+
+// embeddedRunner runs start_server.py with bin\embedded_python
+type embeddedRunner struct{}
+
+func (embeddedRunner) Name() string { return runnerEmbedded }
+
+func (embeddedRunner) Command(config *AppConfig) (*exec.Cmd, error) {
+	return pythonCommand(config)
+}
+
+func (embeddedRunner) Files(config *AppConfig) []requiredFile {
+	return []requiredFile{
+		{config.PythonExe, "Python executable"},
+		{config.BackendScript, "Python backend script (start_server.py)"},
+		{config.PythonDir, "Python backend"},
+	}
+}
+
+// pythonRunner runs start_server.py with a system Python or virtualenv
+type pythonRunner struct{}
+
+func (pythonRunner) Name() string { return runnerPython }
+
+func (pythonRunner) Command(config *AppConfig) (*exec.Cmd, error) {
+	return pythonCommand(config)
+}
+
+func (pythonRunner) Files(config *AppConfig) []requiredFile {
+	return []requiredFile{
+		{config.PythonExe, "Python executable"},
+		{config.BackendScript, "Python backend script (start_server.py)"},
+	}
+}
+
+// pythonCommand is start_server.py run by config.PythonExe
+func pythonCommand(config *AppConfig) (*exec.Cmd, error) {
+	consoleDetailf("Python executable: %s", config.PythonExe)
+	consoleDetailf("Start script: %s", config.BackendScript)
+	if _, err := os.Stat(config.BackendScript); os.IsNotExist(err) {
+		return nil, fmt.Errorf("start_server.py not found at: %s", config.BackendScript)
+	}
+
+	// Short names keep deep install folders within CreateProcess's limits
+	cmd := exec.Command(shortPath(config.PythonExe), backendArgs(config)...)
+	cmd.Dir = shortPath(config.BackendDir)
+	cmd.Env = pythonEnviron(config)
+	return cmd, nil
+}
+
+// executableRunner runs a compiled backend
+type executableRunner struct {
+	path string
+	args []string
+}
+
+func (executableRunner) Name() string { return runnerExecutable }
+
+func (r executableRunner) Command(config *AppConfig) (*exec.Cmd, error) {
+	consoleDetailf("Backend executable: %s", r.path)
+	args := append(append([]string{}, r.args...), config.BackendArgs...)
+	cmd := exec.Command(shortPath(r.path), args...)
+	cmd.Dir = shortPath(filepath.Dir(r.path))
+	cmd.Env = os.Environ()
+	return cmd, nil
+}
+
+func (r executableRunner) Files(config *AppConfig) []requiredFile {
+	return []requiredFile{{r.path, "Backend executable"}}
+}
+
+// interpreterRunner runs a script with another runtime, e.g. Node
+type interpreterRunner struct {
+	name        string
+	interpreter string
+	script      string
+	args        []string
+}
+
+func (r interpreterRunner) Name() string { return r.name }
+
+func (r interpreterRunner) Command(config *AppConfig) (*exec.Cmd, error) {
+	consoleDetailf("Interpreter: %s", r.interpreter)
+	consoleDetailf("Start script: %s", r.script)
+	if _, err := os.Stat(r.script); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s not found at: %s", filepath.Base(r.script), r.script)
+	}
+	args := append(append([]string{}, r.args...), shortPath(r.script))
+	args = append(args, config.BackendArgs...)
+	cmd := exec.Command(shortPath(r.interpreter), args...)
+	cmd.Dir = shortPath(filepath.Dir(r.script))
+	cmd.Env = os.Environ()
+	return cmd, nil
+}
+
+func (r interpreterRunner) Files(config *AppConfig) []requiredFile {
+	return []requiredFile{
+		{r.interpreter, "Backend interpreter"},
+		{r.script, "Backend script"},
+	}
+}
+
+// remoteRunner is a central server the launcher does not start
+type remoteRunner struct{}
+
+func (remoteRunner) Name() string { return runnerRemote }
+
+func (remoteRunner) Command(config *AppConfig) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("the backend at %s is remote and not started by the launcher", config.BackendURL)
+}
+
+func (remoteRunner) Files(config *AppConfig) []requiredFile { return nil }
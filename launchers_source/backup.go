@@ -0,0 +1,363 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackupSettings controls the automatic backups of DataDir. A backup is
+// taken whenever the application closes normally and, with interval_hours,
+// also while it runs. The newest "keep" archives are kept.
+type BackupSettings struct {
+	Disabled      bool `json:"disabled"`
+	IntervalHours int  `json:"interval_hours"` // 0: only on exit
+	Keep          int  `json:"keep"`           // default 7
+}
+
+const (
+	backupPrefix      = "data-"
+	backupSuffix      = ".zip"
+	backupTimeFormat  = "20060102-150405"
+	defaultBackupKeep = 7
+)
+
+// backupMu keeps the scheduled and the exit backup from overlapping
+var backupMu sync.Mutex
+
+// backupsDir is next to the data, or next to the launcher for portable
+// installs because bin/ is replaced by updates
+func backupsDir(config *AppConfig) string {
+	if config.Portable {
+		return filepath.Join(config.RootDir, backupsDirName)
+	}
+	return filepath.Join(config.StateDir, backupsDirName)
+}
+
+// listBackups returns the backup archives, newest first
+func listBackups(config *AppConfig) ([]string, error) {
+	entries, err := os.ReadDir(backupsDir(config))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupPrefix) && strings.HasSuffix(e.Name(), backupSuffix) {
+			names = append(names, filepath.Join(backupsDir(config), e.Name()))
+		}
+	}
+	// The timestamp in the name sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+// backupData zips DataDir into a new archive, verifies it and removes
+// archives beyond the retention count. It returns the archive path.
+func backupData(config *AppConfig) (_ string, err error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	if _, err := os.Stat(config.DataDir); err != nil {
+		return "", fmt.Errorf("nothing to back up: %w", err)
+	}
+	dir := backupsDir(config)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	onTaskbar := startTaskbarProgress("backup")
+	defer func() { onTaskbar.End(err) }()
+	path := filepath.Join(dir, backupPrefix+time.Now().Format(backupTimeFormat)+backupSuffix)
+	partial := path + ".partial"
+	count, err := writeDataArchive(config.DataDir, partial, onTaskbar.Set)
+	if err == nil {
+		err = verifyBackup(partial, count)
+	}
+	if err == nil {
+		err = os.Rename(partial, path)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return "", err
+	}
+	logInfo("backup", "data backed up", "path", path, "files", count)
+	pruneBackups(config)
+	return path, nil
+}
+
+// writeDataArchive zips the files below dir and returns how many.
+// progress is called with the bytes read so far.
+func writeDataArchive(dir, path string, progress func(done, total int64)) (int, error) {
+	var total, done int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	archive := zip.NewWriter(out)
+	count := 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+		w, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		n, err := io.Copy(w, src)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		count++
+		done += n
+		progress(done, total)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := archive.Close(); err != nil {
+		return 0, err
+	}
+	return count, out.Close()
+}
+
+// verifyBackup reads every entry back, which checks its CRC. want is the
+// expected number of files, or -1 when unknown.
+func verifyBackup(path string, want int) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("backup %s is unreadable: %w", path, err)
+	}
+	defer reader.Close()
+	files := 0
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("backup %s: %s: %w", path, f.Name, err)
+		}
+		_, err = io.Copy(io.Discard, src)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("backup %s: %s: %w", path, f.Name, err)
+		}
+		files++
+	}
+	if want >= 0 && files != want {
+		return fmt.Errorf("backup %s has %d files, expected %d", path, files, want)
+	}
+	return nil
+}
+
+// pruneBackups keeps the newest backup.keep archives
+func pruneBackups(config *AppConfig) {
+	keep := config.Settings.Backup.Keep
+	if keep <= 0 {
+		keep = defaultBackupKeep
+	}
+	backups, err := listBackups(config)
+	if err != nil || len(backups) <= keep {
+		return
+	}
+	for _, path := range backups[keep:] {
+		if err := os.Remove(path); err != nil {
+			logWarn("backup", "failed to remove old backup", "path", path, "error", err)
+		} else {
+			logInfo("backup", "removed old backup", "path", path)
+		}
+	}
+}
+
+// backupOnExit runs after the backend has stopped, so the data is at rest
+func backupOnExit(config *AppConfig) {
+	if config.Settings.Backup.Disabled || config.RemoteBackend {
+		return
+	}
+	consolePrintln("Backing up data...")
+	path, err := backupData(config)
+	if err != nil {
+		consoleWarnf("data backup failed: %v", err)
+		logWarn("backup", "backup on exit failed", "error", err)
+		return
+	}
+	consoleSuccessf("Data backed up to %s", path)
+}
+
+// startScheduledBackups backs up every backup.interval_hours while the
+// launcher runs
+func startScheduledBackups(config *AppConfig) {
+	hours := config.Settings.Backup.IntervalHours
+	if config.Settings.Backup.Disabled || config.RemoteBackend || hours <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(hours) * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-launcherExiting():
+				return
+			case <-ticker.C:
+			}
+			path, err := backupData(config)
+			if err != nil {
+				logWarn("backup", "scheduled backup failed", "error", err)
+				notifyToast(config, toastBackupFailed, tr("Backup failed"),
+					tr("%s could not back up its data: %v", config.AppName, err))
+				continue
+			}
+			notifyToast(config, toastBackupCompleted, tr("Backup completed"),
+				tr("%s backed up its data to %s", config.AppName, path))
+		}
+	}()
+}
+
+// restoreData replaces DataDir with the contents of archive. The current
+// data is backed up first, so a restore can itself be undone.
+func restoreData(config *AppConfig, archive string) (string, error) {
+	if err := verifyBackup(archive, -1); err != nil {
+		return "", err
+	}
+	// Unpack before the safety backup, whose pruning may remove archive
+	restoring := config.DataDir + ".restoring"
+	previous := config.DataDir + ".previous"
+	os.RemoveAll(restoring)
+	os.RemoveAll(previous)
+	if err := extractZip(archive, restoring, extractOptions{}); err != nil {
+		os.RemoveAll(restoring)
+		return "", err
+	}
+	var safety string
+	if entries, err := os.ReadDir(config.DataDir); err == nil && len(entries) > 0 {
+		var err error
+		if safety, err = backupData(config); err != nil {
+			os.RemoveAll(restoring)
+			return "", fmt.Errorf("failed to back up the current data first: %w", err)
+		}
+	}
+	if _, err := os.Stat(config.DataDir); errors.Is(err, os.ErrNotExist) {
+		return safety, os.Rename(restoring, config.DataDir)
+	}
+	if err := swapDirs(restoring, config.DataDir, previous); err != nil {
+		os.RemoveAll(restoring)
+		return "", err
+	}
+	os.RemoveAll(previous)
+	return safety, nil
+}
+
+// resolveBackupArg accepts a path or the name of an archive in the
+// backups directory
+func resolveBackupArg(config *AppConfig, arg string) string {
+	if _, err := os.Stat(arg); err == nil {
+		return arg
+	}
+	name := arg
+	if !strings.HasSuffix(name, backupSuffix) {
+		name += backupSuffix
+	}
+	return filepath.Join(backupsDir(config), name)
+}
+
+// runBackupCommand implements "launcher backup now|list"
+func runBackupCommand(config *AppConfig, args []string) int {
+	action := "now"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "now":
+		path, err := backupData(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Backup failed: %v\n", err)
+			return exitLauncherError
+		}
+		fmt.Printf("✓ Data backed up to %s\n", path)
+	case "list":
+		backups, err := listBackups(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitLauncherError
+		}
+		if len(backups) == 0 {
+			fmt.Printf("No backups in %s\n", backupsDir(config))
+		}
+		for _, path := range backups {
+			size := int64(0)
+			if info, err := os.Stat(path); err == nil {
+				size = info.Size()
+			}
+			fmt.Printf("%s  %s\n", filepath.Base(path), formatBytes(size))
+		}
+	default:
+		printCommandHelp(os.Stderr, findCommand("backup"))
+		return exitUsage
+	}
+	return exitOK
+}
+
+// runRestoreCommand implements "launcher restore <archive>"
+func runRestoreCommand(config *AppConfig, args []string) int {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		printCommandHelp(os.Stderr, findCommand("restore"))
+		return exitUsage
+	}
+	if _, err := fetchLauncherStatus(config); err == nil {
+		fmt.Fprintln(os.Stderr, "Close the application before restoring its data")
+		return exitLauncherError
+	}
+	archive := resolveBackupArg(config, args[0])
+	safety, err := restoreData(config, archive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		return exitLauncherError
+	}
+	if safety != "" {
+		fmt.Printf("✓ Previous data saved to %s\n", safety)
+	}
+	fmt.Printf("✓ Data restored from %s\n", archive)
+	logInfo("backup", "data restored", "archive", archive)
+	return exitOK
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"slices"
+	"syscall"
+	"unsafe"
+)
+
+// The backend has no authentication of its own, so it must only be
+// reachable from this machine; LAN mode exposes it through the proxy
+// instead. The launcher asks it to listen on 127.0.0.1 (WAP_HOST) and,
+// once it answers, checks the TCP table to make sure it did. A backend
+// found listening on another address is restarted with --host 127.0.0.1,
+// and if that does not help the user is warned.
+var (
+	iphlpapi = syscall.NewLazyDLL("iphlpapi.dll")
+
+	procGetExtendedTcpTable = iphlpapi.NewProc("GetExtendedTcpTable")
+)
+
+const (
+	backendHost = "127.0.0.1"
+
+	afInet                   = 2
+	afInet6                  = 23
+	tcpTableOwnerPIDListener = 3
+	errorInsufficientBuffer  = 122
+
+	tcpRowOwnerPIDSize  = 24 // MIB_TCPROW_OWNER_PID
+	tcp6RowOwnerPIDSize = 56 // MIB_TCP6ROW_OWNER_PID
+)
+
+// listenSocket is a listening TCP socket from the system table
+type listenSocket struct {
+	addr net.IP
+	port int
+	pid  int
+}
+
+// tcpTable returns the raw GetExtendedTcpTable listener table of a family
+func tcpTable(family uint32) ([]byte, error) {
+	size := uint32(16 * 1024)
+	for attempt := 0; attempt < 4; attempt++ {
+		buf := make([]byte, size)
+		r, _, _ := procGetExtendedTcpTable.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)),
+			0, uintptr(family), tcpTableOwnerPIDListener, 0)
+		switch r {
+		case 0:
+			return buf, nil
+		case errorInsufficientBuffer:
+			continue // size now holds what is needed; the table may grow meanwhile
+		default:
+			return nil, fmt.Errorf("GetExtendedTcpTable: %w", syscall.Errno(r))
+		}
+	}
+	return nil, fmt.Errorf("GetExtendedTcpTable: %w", syscall.Errno(errorInsufficientBuffer))
+}
+
+// listeningSockets returns the IPv4 and IPv6 sockets listening on port
+func listeningSockets(port int) ([]listenSocket, error) {
+	var sockets []listenSocket
+	for _, family := range []uint32{afInet, afInet6} {
+		table, err := tcpTable(family)
+		if err != nil {
+			return nil, err
+		}
+		count := int(binary.LittleEndian.Uint32(table))
+		rowSize := tcpRowOwnerPIDSize
+		if family == afInet6 {
+			rowSize = tcp6RowOwnerPIDSize
+		}
+		for i := 0; i < count && 4+(i+1)*rowSize <= len(table); i++ {
+			row := table[4+i*rowSize : 4+(i+1)*rowSize]
+			var s listenSocket
+			if family == afInet {
+				// dwState, dwLocalAddr, dwLocalPort, ..., dwOwningPid; the
+				// address and port are in network byte order
+				s.addr = net.IP(slices.Clone(row[4:8]))
+				s.port = int(binary.BigEndian.Uint16(row[8:10]))
+				s.pid = int(binary.LittleEndian.Uint32(row[20:24]))
+			} else {
+				// ucLocalAddr[16], dwLocalScopeId, dwLocalPort, ...
+				s.addr = net.IP(slices.Clone(row[0:16]))
+				s.port = int(binary.BigEndian.Uint16(row[20:22]))
+				s.pid = int(binary.LittleEndian.Uint32(row[52:56]))
+			}
+			if s.port == port {
+				sockets = append(sockets, s)
+			}
+		}
+	}
+	return sockets, nil
+}
+
+// exposedBackendAddress returns the first non-loopback address the
+// backend listens on, "" if it is local only
+func exposedBackendAddress(port int) (string, error) {
+	sockets, err := listeningSockets(port)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range sockets {
+		if !s.addr.IsLoopback() {
+			return fmt.Sprintf("%s (PID %d)", net.JoinHostPort(s.addr.String(), fmt.Sprint(port)), s.pid), nil
+		}
+	}
+	return "", nil
+}
+
+// checkBackendBinding makes sure the backend the session started is not
+// reachable from the network. interactive allows a dialog.
+func checkBackendBinding(session *Session, interactive bool) {
+	config := session.config
+	if config.RemoteBackend || config.Settings.LAN.Enabled {
+		return
+	}
+	port := backendPort(config)
+	exposed, err := exposedBackendAddress(port)
+	if err != nil {
+		logWarn("security", "failed to check the backend's listening address", "error", err)
+		return
+	}
+	if exposed == "" {
+		logDebug("security", "backend listens on loopback only", "port", port)
+		return
+	}
+
+	if !slices.Contains(config.BackendArgs, "--host") {
+		logWarn("security", "backend listens on the network, restarting it on "+backendHost, "address", exposed)
+		consolePrintf("Backend listens on %s, restarting it on %s...\n", exposed, backendHost)
+		config.BackendArgs = append(config.BackendArgs, "--host", backendHost)
+		if err := session.restartBackend(); err != nil {
+			logError("security", "failed to restart the backend on "+backendHost, "error", err)
+		} else if exposed, err = exposedBackendAddress(port); err == nil && exposed == "" {
+			consoleSuccessf("Backend now listens on %s only", backendHost)
+			logInfo("security", "backend restarted on "+backendHost)
+			return
+		}
+	}
+
+	message := tr("The %s backend listens on %s and can be reached by other computers on the network. It has no password of its own.\n\nUpdate the application, or enable LAN mode if network access is intended.",
+		config.AppName, exposed)
+	consoleErrorf("Backend is reachable from the network at %s", exposed)
+	logError("security", "backend is exposed to the network", "address", exposed)
+	reportEvent(eventTypeWarning, eventIDBackendExposed, message)
+	if notify := alertNotifier(); notify != nil {
+		notify(config.AppName, tr("The backend can be reached from the network"))
+	}
+	if interactive && !machineOutput() {
+		messageBox(config.AppName, message, mbOK|mbIconWarning|mbTopmost)
+	}
+}
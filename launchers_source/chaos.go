@@ -0,0 +1,39 @@
+package main
+
+import "os/exec"
+
+// chaos is set by chaos_build.go in resilience test builds
+// (go build -tags chaos). Release builds leave it nil and every hook below
+// does nothing.
+var chaos chaosHooks
+
+type chaosHooks interface {
+	begin(config *AppConfig)
+	backendStarted(cmd *exec.Cmd, exited <-chan struct{})
+	dropHealthCheck() bool
+	slowWrite()
+}
+
+func chaosBegin(config *AppConfig) {
+	if chaos != nil {
+		chaos.begin(config)
+	}
+}
+
+func chaosBackendStarted(cmd *exec.Cmd, exited <-chan struct{}) {
+	if chaos != nil {
+		chaos.backendStarted(cmd, exited)
+	}
+}
+
+// chaosDropHealthCheck reports whether a health check should fail
+func chaosDropHealthCheck() bool {
+	return chaos != nil && chaos.dropHealthCheck()
+}
+
+// chaosSlowWrite delays a disk write
+func chaosSlowWrite() {
+	if chaos != nil {
+		chaos.slowWrite()
+	}
+}
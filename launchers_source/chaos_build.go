@@ -0,0 +1,121 @@
+//go:build chaos
+
+package main
+
+import (
+	"math/rand"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Chaos builds break things on purpose to prove the supervisor, the proxy
+// and the frontend cope:
+//
+//	go build -tags chaos -o wap_launcher_chaos.exe
+//
+// The bounds come from chaos.json next to the launcher; missing values
+// use the defaults below. Never ship this build.
+const chaosFileName = "chaos.json"
+
+type chaosSettings struct {
+	// The backend is killed or hung at a random time in this window after
+	// it starts, in seconds; 0 and 0 leave it alone
+	FaultAfterMin int `json:"fault_after_min"`
+	FaultAfterMax int `json:"fault_after_max"`
+	// Share of faults that hang (suspend) the backend instead of killing it
+	HangRate    float64 `json:"hang_rate"`
+	HangSeconds int     `json:"hang_seconds"`
+	// Share of health checks that fail without reaching the backend
+	HealthDropRate float64 `json:"health_drop_rate"`
+	// Disk writes are delayed by up to this long
+	WriteDelayMaxMs int `json:"write_delay_max_ms"`
+}
+
+var defaultChaosSettings = chaosSettings{
+	FaultAfterMin:   30,
+	FaultAfterMax:   300,
+	HangRate:        0.3,
+	HangSeconds:     20,
+	HealthDropRate:  0.1,
+	WriteDelayMaxMs: 200,
+}
+
+const processSuspendResume = 0x0800
+
+var (
+	procNtSuspendProcess = ntdll.NewProc("NtSuspendProcess")
+	procNtResumeProcess  = ntdll.NewProc("NtResumeProcess")
+)
+
+type chaosMonkey struct {
+	settings chaosSettings
+}
+
+func init() {
+	chaos = &chaosMonkey{settings: defaultChaosSettings}
+}
+
+func (m *chaosMonkey) begin(config *AppConfig) {
+	if err := readJSONFile(filepath.Join(config.RootDir, chaosFileName), &m.settings); err != nil {
+		logInfo("chaos", "no chaos.json, using default bounds", "error", err)
+	}
+	consolePrintln("⚠ CHAOS BUILD: the backend will be killed and hung on purpose")
+	logWarn("chaos", "chaos mode enabled", "fault_after_min", m.settings.FaultAfterMin, "fault_after_max", m.settings.FaultAfterMax,
+		"hang_rate", m.settings.HangRate, "health_drop_rate", m.settings.HealthDropRate, "write_delay_max_ms", m.settings.WriteDelayMaxMs)
+}
+
+func (m *chaosMonkey) backendStarted(cmd *exec.Cmd, exited <-chan struct{}) {
+	s := m.settings
+	if s.FaultAfterMax <= 0 {
+		return
+	}
+	after := time.Duration(s.FaultAfterMin) * time.Second
+	if s.FaultAfterMax > s.FaultAfterMin {
+		after += time.Duration(rand.Intn(s.FaultAfterMax-s.FaultAfterMin)) * time.Second
+	}
+
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-time.After(after):
+		}
+		pid := cmd.Process.Pid
+		if rand.Float64() >= s.HangRate {
+			logWarn("chaos", "killing the backend", "child_pid", pid)
+			cmd.Process.Kill()
+			return
+		}
+
+		handle, err := syscall.OpenProcess(processSuspendResume, false, uint32(pid))
+		if err != nil {
+			logWarn("chaos", "cannot hang the backend", "child_pid", pid, "error", err)
+			return
+		}
+		defer syscall.CloseHandle(handle)
+		logWarn("chaos", "hanging the backend", "child_pid", pid, "seconds", s.HangSeconds)
+		procNtSuspendProcess.Call(uintptr(handle))
+		select {
+		case <-exited:
+		case <-time.After(time.Duration(s.HangSeconds) * time.Second):
+		}
+		procNtResumeProcess.Call(uintptr(handle))
+		logWarn("chaos", "resumed the backend", "child_pid", pid)
+	}()
+}
+
+func (m *chaosMonkey) dropHealthCheck() bool {
+	if rand.Float64() >= m.settings.HealthDropRate {
+		return false
+	}
+	logWarn("chaos", "dropping a health check")
+	return true
+}
+
+func (m *chaosMonkey) slowWrite() {
+	if m.settings.WriteDelayMaxMs > 0 {
+		time.Sleep(time.Duration(rand.Intn(m.settings.WriteDelayMaxMs)) * time.Millisecond)
+	}
+}
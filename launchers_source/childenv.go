@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/template"
+)
+
+// backend_env and frontend_env in launcher.json set variables for one
+// child only. Besides the machine facts their values may use what is only
+// known when the child starts:
+//
+//	"backend_env": {"UPLOAD_DIR": "{{data_dir}}\\uploads", "SELF_URL": "http://127.0.0.1:{{port}}"}
+//	"frontend_env": {"API_TOKEN": "{{token}}"}
+//
+// {{port}} is the port the child serves on or talks to, {{token}} the token
+// it authenticates with: the control token for the backend, the proxy
+// token for the app (empty without the proxy).
+var childEnvSettings = map[string]bool{
+	"settings.backend_env":  true,
+	"settings.frontend_env": true,
+	"settings.sidecars":     true,
+}
+
+// childEnvValues are the runtime values available to backend_env and
+// frontend_env templates
+type childEnvValues struct {
+	Port       string
+	Token      string
+	BackendURL string
+	DataDir    string
+	LogDir     string
+}
+
+func childEnvFuncs(values childEnvValues) template.FuncMap {
+	funcs := template.FuncMap{
+		"port":        func() string { return values.Port },
+		"token":       func() string { return values.Token },
+		"backend_url": func() string { return values.BackendURL },
+		"data_dir":    func() string { return values.DataDir },
+		"log_dir":     func() string { return values.LogDir },
+	}
+	for name, fn := range templateFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// serviceEnv renders a backend_env or frontend_env map into sorted
+// KEY=value entries
+func serviceEnv(name string, vars map[string]string, values childEnvValues) ([]string, error) {
+	funcs := childEnvFuncs(values)
+	env := make([]string, 0, len(vars))
+	for key, value := range vars {
+		tmpl, err := template.New(key).Funcs(funcs).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("template in %s.%s: %w", name, key, err)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, currentMachineFacts()); err != nil {
+			return nil, fmt.Errorf("template in %s.%s: %w", name, key, err)
+		}
+		env = append(env, key+"="+out.String())
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// validateChildEnv catches template mistakes when the settings load
+// rather than when a child starts
+func validateChildEnv(settings Settings) error {
+	for name, vars := range map[string]map[string]string{"backend_env": settings.BackendEnv, "frontend_env": settings.FrontendEnv} {
+		for key, value := range vars {
+			if _, err := template.New(key).Funcs(childEnvFuncs(childEnvValues{})).Parse(value); err != nil {
+				return fmt.Errorf("template in %s.%s: %w", name, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// backendEnv is what the launcher adds to the backend's environment: the
+// shared env, backend_env, then WAP_PORT, WAP_HOST and the data and log
+// directories, which always win. port is a
+// string so the launch plan can show "<dynamic>".
+func backendEnv(config *AppConfig, port, token string) ([]string, error) {
+	extra, err := serviceEnv("backend_env", config.Settings.BackendEnv, childEnvValues{
+		Port:       port,
+		Token:      token,
+		BackendURL: config.BackendURL,
+		DataDir:    config.DataDir,
+		LogDir:     config.LogDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	env := append(envList(config.Settings.Env), extra...)
+	env = append(env, profileEnv(config)...)
+	return append(env, "WAP_PORT="+port, "WAP_HOST="+backendHost, "WAP_DATA_DIR="+extendedPath(config.DataDir), "WAP_LOG_DIR="+extendedPath(config.LogDir),
+		"WAP_VERSION="+installedVersion(config), "WAP_LAUNCHER_PID="+strconv.Itoa(os.Getpid()), "WAP_LOCALE="+locale()), nil
+}
+
+// frontendEnv is what the launcher adds to the Flutter app's environment
+func frontendEnv(config *AppConfig) ([]string, error) {
+	values := childEnvValues{
+		Port:       strconv.Itoa(backendPort(config)),
+		BackendURL: config.BackendURL,
+		DataDir:    config.DataDir,
+		LogDir:     config.LogDir,
+	}
+	var wapEnv []string
+	if config.Proxy != nil {
+		values.Port = strconv.Itoa(urlPort(config.Proxy.url))
+		values.BackendURL = config.Proxy.url
+		values.Token = config.Proxy.token
+		wapEnv = config.Proxy.env()
+	} else {
+		wapEnv = []string{"WAP_BACKEND_URL=" + config.BackendURL}
+		if config.RemoteBackend {
+			wapEnv = append(wapEnv, "WAP_BACKEND_REMOTE=1")
+		}
+	}
+	// The app may start before the backend answers (startup.concurrent)
+	wapEnv = append(wapEnv, "WAP_BACKEND_HEALTH_URL="+healthURL(config, values.BackendURL), "WAP_LOCALE="+locale(),
+		"WAP_SESSION_FILE="+sessionFilePath(config), "WAP_APP_USER_MODEL_ID="+appUserModelID(config))
+	if config.Settings.Startup.Concurrent {
+		wapEnv = append(wapEnv, "WAP_BACKEND_STARTING=1")
+	}
+
+	extra, err := serviceEnv("frontend_env", config.Settings.FrontendEnv, values)
+	if err != nil {
+		return nil, err
+	}
+	env := append(envList(config.Settings.Env), extra...)
+	env = append(env, profileEnv(config)...)
+	return append(env, wapEnv...), nil
+}
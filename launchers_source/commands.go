@@ -0,0 +1,272 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a launcher subcommand such as "launcher logs"
+type command struct {
+	name    string
+	summary string
+	usage   string                 // arguments shown after the name, e.g. "[--dry-run]"
+	args    func() []string        // values completed for the first argument
+	flags   func(fs *flag.FlagSet) // registers the flags, for help and completion
+	hidden  bool                   // not listed in help
+	run     func(config *AppConfig, args []string) int
+}
+
+// commands is filled in init because help and completion refer back to it
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "logs", summary: "Show or follow the backend, flutter, launcher or proxy log", usage: "[backend|flutter|launcher|proxy|backend-stderr|...] [--tail N] [--follow]",
+			args: logTargetNames, flags: func(fs *flag.FlagSet) { new(logsOptions).register(fs) }, run: runLogsCommand},
+		{name: "repair", summary: "Verify all application files and restore damaged ones", run: runRepairCommand},
+		{name: "repair-python", summary: "Verify the backend's Python packages and reinstall broken ones offline", usage: "[--check]",
+			flags: func(fs *flag.FlagSet) { new(repairPythonOptions).register(fs) }, run: runRepairPythonCommand},
+		{name: "update", summary: "Check for, download and stage a new version", usage: "[check] [--channel stable|beta]",
+			args: fixedArgs("check"), flags: func(fs *flag.FlagSet) { new(updateOptions).register(fs) }, run: runUpdateCommand},
+		{name: "config", summary: "Show the effective configuration and where each value comes from", usage: "show [--origins]",
+			args: fixedArgs("show"), flags: func(fs *flag.FlagSet) { new(configOptions).register(fs) }, run: runConfigCommand},
+		{name: "doctor", summary: "Diagnose the installation, file integrity and disk health", run: runDoctorCommand},
+		{name: "top", summary: "Live view of the running services with restart and stop keys", usage: "[--interval 1s]",
+			flags: func(fs *flag.FlagSet) { new(topOptions).register(fs) }, run: runTopCommand},
+		{name: "wait", summary: "Wait until the application is ready, healthy or stopped, for scripts", usage: "[--for ready|healthy|stopped] [--timeout 2m]",
+			flags: func(fs *flag.FlagSet) { new(waitOptions).register(fs) }, run: runWaitCommand},
+		{name: "trace", summary: "Capture a time-limited diagnostic trace of the launcher and backend", usage: "[start|stop|status] [--for 15m]",
+			args: fixedArgs("start", "stop", "status"), flags: func(fs *flag.FlagSet) { new(traceOptions).register(fs) }, run: runTraceCommand},
+		{name: "secret", summary: "Store, show or delete a secret used as secret://name in the configuration", usage: "set|get|delete NAME [--value V]",
+			args: fixedArgs("set", "get", "delete"), flags: func(fs *flag.FlagSet) { new(secretOptions).register(fs) }, run: runSecretCommand},
+		{name: "backup", summary: "Back up the application data now, or list the backups", usage: "[now|list]",
+			args: fixedArgs("now", "list"), run: runBackupCommand},
+		{name: "restore", summary: "Replace the application data with a backup", usage: "ARCHIVE", run: runRestoreCommand},
+		{name: "list", summary: "List the running instances of all users and profiles on this machine", usage: "[--json]",
+			flags: func(fs *flag.FlagSet) { new(listOptions).register(fs) }, run: runListCommand},
+		{name: "stop", summary: "Close the running application and its backend", usage: "[--timeout 30s]",
+			flags: func(fs *flag.FlagSet) { new(stopOptions).register(fs) }, run: runStopCommand},
+		{name: "restart-backend", summary: "Restart the running application's backend and wait until it is healthy", usage: "[--timeout 2m]",
+			flags: func(fs *flag.FlagSet) { new(restartBackendOptions).register(fs) }, run: runRestartBackendCommand},
+		{name: "stop-backend", summary: "Stop the backend kept running between launches", usage: "[--force]",
+			flags: func(fs *flag.FlagSet) { new(stopBackendOptions).register(fs) }, run: runStopBackendCommand},
+		{name: "telemetry", summary: "Turn anonymous startup reports on or off", usage: "[on|off|status]",
+			args: fixedArgs("on", "off", "status"), run: runTelemetryCommand},
+		{name: "autostart", summary: "Start the backend in the background at login, or stop doing so", usage: "[on|off|status]",
+			args: fixedArgs("on", "off", "status"), run: runAutostartCommand},
+		{name: "firewall", summary: "Add or remove the Windows Firewall rule for LAN mode", usage: "[status|add|remove]",
+			args: fixedArgs("status", "add", "remove"), run: runFirewallCommand},
+		{name: "gc", summary: "Remove unused store blobs, stale updates, old backups and logs", usage: "[--dry-run] [--previous]",
+			flags: func(fs *flag.FlagSet) { new(gcOptions).register(fs) }, run: runGCCommand},
+		{name: "register-protocol", summary: "Make wap:// links open the application, for the current user", usage: "[--remove]",
+			flags: func(fs *flag.FlagSet) { new(registerProtocolOptions).register(fs) }, run: runRegisterProtocolCommand},
+		{name: "register-file-type", summary: "Make files of an extension open the application, for the current user", usage: "[--remove] [.EXT ...]",
+			flags: func(fs *flag.FlagSet) { new(registerFileTypeOptions).register(fs) }, run: runRegisterFileTypeCommand},
+		{name: "install-shortcuts", summary: "Create the desktop shortcut and Start Menu entry, for installs without an installer", usage: "[--desktop=false] [--start-menu=false]",
+			flags: func(fs *flag.FlagSet) { new(shortcutOptions).register(fs) }, run: runInstallShortcutsCommand},
+		{name: "remove-shortcuts", summary: "Remove the desktop shortcut and Start Menu entry", usage: "[--desktop=false] [--start-menu=false]",
+			flags: func(fs *flag.FlagSet) { new(shortcutOptions).register(fs) }, run: runRemoveShortcutsCommand},
+		{name: "plan", summary: "Print the resolved launch plan (--json for tools)", usage: "[--json] [launch flags]",
+			flags: func(fs *flag.FlagSet) { new(planOptions).register(fs) }, run: runPlanCommand},
+		{name: "manifest", summary: "Write bin/manifest.json for the current bin directory", usage: "[version]", run: runManifestCommand},
+		{name: "help", summary: "Show help for the launcher or one command", usage: "[command]",
+			args: commandNames, run: runHelpCommand},
+		{name: "completion", summary: "Print a bash, zsh or PowerShell completion script", usage: "bash|zsh|powershell",
+			args: fixedArgs(completionShells...), run: runCompletionCommand},
+		{name: "__complete", hidden: true, run: runCompleteCommand},
+		{name: warmWatchCommand, hidden: true, run: runWarmWatchCommand},
+		{name: firewallCommand, hidden: true, run: runFirewallWorker},
+		{name: installUpdateCommand, hidden: true, run: runInstallUpdateCommand},
+		{name: defenderExclusionCommand, hidden: true, run: runDefenderExclusionCommand},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// newCommandFlagSet is the flag set every command parses its arguments
+// with; -h prints the command's help
+func newCommandFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("launcher "+name, flag.ContinueOnError)
+	fs.Usage = func() { printCommandHelp(os.Stderr, findCommand(name)) }
+	return fs
+}
+
+// isHelpFlag matches the flags asking for general help instead of
+// starting the application
+func isHelpFlag(arg string) bool {
+	switch arg {
+	case "-h", "-help", "--help":
+		return true
+	}
+	return false
+}
+
+func fixedArgs(values ...string) func() []string {
+	return func() []string { return values }
+}
+
+func commandNames() []string {
+	var names []string
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, cmd.name)
+		}
+	}
+	return names
+}
+
+func logTargetNames() []string {
+	names := make([]string, 0, len(logTargets))
+	for name := range logTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// commandFlags returns a fresh flag set with cmd's flags registered
+func commandFlags(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	return fs
+}
+
+func launchFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("launcher", flag.ContinueOnError)
+	defineLaunchFlags(fs, &Options{})
+	return fs
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintln(w, "Usage: launcher [flags]                start the application")
+	fmt.Fprintln(w, "       launcher <command> [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+		}
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	printFlags(w, launchFlags())
+	fmt.Fprintln(w, "\nRun \"launcher help <command>\" for a command's flags, or \"launcher --help-all\" for everything.")
+}
+
+// printHelpAll documents every flag and command in one page
+func printHelpAll(w io.Writer) {
+	fmt.Fprintln(w, "Usage: launcher [flags]")
+	fmt.Fprintln(w, "\nStart the application.")
+	fmt.Fprintln(w, "\nFlags:")
+	printFlags(w, launchFlags())
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			fmt.Fprintln(w)
+			printCommandHelp(w, cmd)
+		}
+	}
+}
+
+func printCommandHelp(w io.Writer, cmd *command) {
+	fmt.Fprintf(w, "Usage: launcher %s %s\n", cmd.name, cmd.usage)
+	fmt.Fprintf(w, "\n%s.\n", cmd.summary)
+	fs := commandFlags(cmd)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		printFlags(w, fs)
+	}
+}
+
+// printFlags lists flags GNU style, which is how the docs write them
+func printFlags(w io.Writer, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		left := "--" + f.Name
+		if name != "" {
+			left += " " + name
+		}
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" && f.DefValue != "-1" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		fmt.Fprintf(w, "  %-22s %s\n", left, usage)
+	})
+}
+
+// runHelpCommand implements "launcher help [command]"
+func runHelpCommand(config *AppConfig, args []string) int {
+	if len(args) == 0 {
+		printHelp(os.Stdout)
+		return exitOK
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil || cmd.hidden {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		printHelp(os.Stderr)
+		return exitUsage
+	}
+	printCommandHelp(os.Stdout, cmd)
+	return exitOK
+}
+
+// completeWords returns the candidates for the last of words, the
+// arguments typed after the program name so far
+func completeWords(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	var candidates []string
+	if len(words) == 1 {
+		if strings.HasPrefix(current, "-") {
+			candidates = flagNames(launchFlags())
+			candidates = append(candidates, "--help", "--help-all")
+		} else {
+			candidates = commandNames()
+		}
+	} else if cmd := findCommand(words[0]); cmd != nil {
+		switch {
+		case strings.HasPrefix(current, "-"):
+			candidates = flagNames(commandFlags(cmd))
+		case len(words) == 2 && cmd.args != nil:
+			candidates = cmd.args()
+		}
+	} else if strings.HasPrefix(current, "-") {
+		candidates = flagNames(launchFlags())
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, "--"+f.Name) })
+	return names
+}
+
+// runCompleteCommand is called by the completion scripts
+func runCompleteCommand(config *AppConfig, args []string) int {
+	for _, match := range completeWords(args) {
+		fmt.Println(match)
+	}
+	return exitOK
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var completionShells = []string{"bash", "zsh", "powershell"}
+
+// The scripts ask the launcher itself for candidates through the hidden
+// __complete command, so they never go stale as commands are added.
+const bashCompletion = `# {{name}} completion for bash. Add to ~/.bashrc:
+#   source <({{name}} completion bash)
+_{{func}}_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($("${COMP_WORDS[0]}" __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _{{func}}_complete {{name}} {{name}}.exe
+`
+
+const zshCompletion = `#compdef {{name}} {{name}}.exe
+# {{name}} completion for zsh. Add to ~/.zshrc:
+#   source <({{name}} completion zsh)
+_{{func}}_complete() {
+    local -a candidates
+    candidates=("${(@f)$("${words[1]}" __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _{{func}}_complete {{name}} {{name}}.exe
+`
+
+const powershellCompletion = `# {{name}} completion for PowerShell. Add to $PROFILE:
+#   {{name}} completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName '{{name}}', '{{name}}.exe' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $exe = $commandAst.CommandElements[0].Extent.Text
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.Extent.Text })
+    if ($wordToComplete -eq '') { $words += '' }
+    & $exe __complete @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
+
+// runCompletionCommand implements "launcher completion bash|zsh|powershell"
+func runCompletionCommand(config *AppConfig, args []string) int {
+	if len(args) != 1 {
+		printCommandHelp(os.Stderr, findCommand("completion"))
+		return exitUsage
+	}
+
+	var script string
+	switch args[0] {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "powershell", "pwsh":
+		script = powershellCompletion
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown shell %q (expected bash, zsh or powershell)\n", args[0])
+		return exitUsage
+	}
+
+	// Complete the launcher under whatever name it was installed as
+	name := "launcher"
+	if exe, err := os.Executable(); err == nil {
+		name = strings.TrimSuffix(filepath.Base(exe), filepath.Ext(exe))
+	}
+	funcName := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	fmt.Print(strings.NewReplacer("{{name}}", name, "{{func}}", funcName).Replace(script))
+	return exitOK
+}
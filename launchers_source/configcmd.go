@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Settings and environment variables whose values are never printed
+var secretNameParts = []string{"token", "password", "secret", "apikey", "api_key"}
+
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range secretNameParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+type configOptions struct {
+	origins bool
+}
+
+func (o *configOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.origins, "origins", false, "list every setting with the file it comes from")
+}
+
+// runConfigCommand implements "launcher config show [--origins]"
+func runConfigCommand(config *AppConfig, args []string) int {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "usage: launcher config show [--origins]")
+		return exitUsage
+	}
+	var opts configOptions
+	fs := newCommandFlagSet("config")
+	opts.register(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+
+	effective, err := maskedSettings(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+
+	if !opts.origins {
+		out, _ := json.MarshalIndent(effective, "", "  ")
+		fmt.Println(string(out))
+		return exitOK
+	}
+
+	_, origins, err := loadSettingsLayers(config.RootDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfigInvalid
+	}
+	values := map[string]string{}
+	flattenSettings(effective, "", values)
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Printf("%-36s %-30s %s\n", path, values[path], settingOrigin(origins, path))
+	}
+	return exitOK
+}
+
+// settingOrigin returns the file that set path or its closest parent
+func settingOrigin(origins map[string]string, path string) string {
+	for {
+		if origin, ok := origins[path]; ok {
+			return origin
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return originDefault
+		}
+		path = path[:i]
+	}
+}
+
+func flattenSettings(value interface{}, prefix string, out map[string]string) {
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		for key, v := range m {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenSettings(v, path, out)
+		}
+		return
+	}
+	data, _ := json.Marshal(value)
+	out[prefix] = string(data)
+}
+
+// maskedSettings returns the effective settings with secret values masked.
+// They include defaults, so they go through the struct.
+func maskedSettings(config *AppConfig) (map[string]interface{}, error) {
+	data, err := json.Marshal(config.Settings)
+	if err != nil {
+		return nil, err
+	}
+	var effective map[string]interface{}
+	json.Unmarshal(data, &effective)
+	maskSecrets(effective)
+	return effective, nil
+}
+
+func maskSecrets(value interface{}) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, v := range m {
+		if s, isString := v.(string); isString && s != "" && isSecretName(key) {
+			m[key] = "********"
+		}
+		maskSecrets(v)
+	}
+}
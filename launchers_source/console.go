@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The launcher is linked as a GUI application so double-clicking it does
+// not flash a console window:
+//
+//	go build -ldflags "-H windowsgui"
+//
+// Output goes to the console of the terminal it was started from, or to a
+// new console with --console. Redirected output is left alone.
+
+var (
+	procAttachConsole = kernel32.NewProc("AttachConsole")
+	procAllocConsole  = kernel32.NewProc("AllocConsole")
+)
+
+const attachParentProcess = ^uintptr(0) // (DWORD)-1
+
+// Console verbosity: --quiet prints errors only, --verbose adds details
+// such as child command lines
+const (
+	verbosityQuiet   = -1
+	verbosityNormal  = 0
+	verbosityVerbose = 1
+)
+
+// ANSI colors, used when stdout is a console that understands them and
+// neither --no-color nor NO_COLOR is set
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiDim    = "\x1b[2m"
+)
+
+// Startup phases reported in --json-progress mode
+const (
+	phasePayload       = "payload"
+	phaseVerify        = "verify"
+	phaseValidate      = "validate"
+	phaseMigrate       = "migrate"
+	phaseSteps         = "steps"
+	phaseBackendStart  = "backend_start"
+	phaseBackendHealth = "backend_health"
+	phaseFrontendStart = "frontend_start"
+	phaseRunning       = "running"
+	phaseExit          = "exit"
+)
+
+// Phase statuses
+const (
+	phaseStarted  = "started"
+	phaseProgress = "progress"
+	phaseDone     = "done"
+	phaseFailed   = "failed"
+)
+
+// progressEvent is one line of --json-progress output:
+//
+//	{"time":"...","phase":"backend_health","status":"progress","percent":40,"message":"Loading models 40%"}
+type progressEvent struct {
+	Time     string  `json:"time"`
+	Phase    string  `json:"phase"`
+	Status   string  `json:"status"`
+	Percent  float64 `json:"percent"`
+	Message  string  `json:"message,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	ExitCode *int    `json:"exit_code,omitempty"`
+}
+
+// console is the launcher's stdout. In JSON mode the human-oriented
+// messages are dropped and only progress events are written.
+var console struct {
+	mu        sync.Mutex
+	json      bool
+	phase     string
+	attached  bool // stdout reaches a console or a redirect
+	verbosity int
+	color     bool
+}
+
+// setupConsole connects stdout to a console. It runs before anything is
+// printed; force comes from --console.
+func setupConsole(force bool) {
+	if stdHandleValid(syscall.STD_OUTPUT_HANDLE) {
+		console.attached = true
+		return
+	}
+	if ret, _, _ := procAttachConsole.Call(attachParentProcess); ret == 0 {
+		if !force {
+			return
+		}
+		if ret, _, _ := procAllocConsole.Call(); ret == 0 {
+			return
+		}
+	}
+
+	if out, err := os.OpenFile("CONOUT$", os.O_RDWR, 0); err == nil {
+		os.Stdout = out
+		os.Stderr = out
+		console.attached = true
+	}
+	if in, err := os.OpenFile("CONIN$", os.O_RDWR, 0); err == nil {
+		os.Stdin = in
+	}
+}
+
+func stdHandleValid(which int) bool {
+	handle, err := syscall.GetStdHandle(which)
+	return err == nil && handle != 0 && handle != syscall.InvalidHandle
+}
+
+// hasConsole reports whether anyone can see what is printed
+func hasConsole() bool {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	return console.attached
+}
+
+// wantsConsoleFlag looks for --console (or --headless, which has no other
+// UI) before the flags are parsed
+func wantsConsoleFlag(args []string) bool {
+	for _, arg := range args {
+		switch strings.TrimLeft(arg, "-") {
+		case "console", "headless", "profile-startup":
+			return true
+		}
+	}
+	return false
+}
+
+func setJSONProgress(enabled bool) {
+	console.mu.Lock()
+	console.json = enabled
+	console.mu.Unlock()
+}
+
+func jsonProgress() bool {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	return console.json
+}
+
+// machineOutput reports whether stdout is reserved for JSON lines
+func machineOutput() bool {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	return console.json || events.enabled
+}
+
+// setConsoleStyle applies --quiet, --verbose and --no-color
+func setConsoleStyle(verbosity int, noColor bool) {
+	color := !noColor && os.Getenv("NO_COLOR") == "" && enableConsoleColor()
+	console.mu.Lock()
+	console.verbosity = verbosity
+	console.color = color
+	console.mu.Unlock()
+}
+
+// enableConsoleColor turns on ANSI escape handling for a console on
+// stdout. Redirected output gets no colors. Unlike setConsoleMode (top.go)
+// it works on os.Stdout, which setupConsole may have reopened.
+func enableConsoleColor() bool {
+	handle := syscall.Handle(os.Stdout.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	ret, _, _ := procSetConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing))
+	return ret != 0
+}
+
+// consoleShows reports whether text of the given verbosity is printed
+func consoleShows(verbosity int) bool {
+	if machineOutput() {
+		return false
+	}
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	return console.verbosity >= verbosity
+}
+
+func consoleVerbosity() int {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	return console.verbosity
+}
+
+// consoleLine prints one line of the given verbosity, colored if enabled
+func consoleLine(verbosity int, color, text string) {
+	if !consoleShows(verbosity) {
+		return
+	}
+	console.mu.Lock()
+	colored := console.color && color != ""
+	console.mu.Unlock()
+	if colored {
+		text = color + text + ansiReset
+	}
+	fmt.Println(text)
+}
+
+func consolePrintf(format string, args ...interface{}) {
+	if consoleShows(verbosityNormal) {
+		fmt.Printf(format, args...)
+	}
+}
+
+func consolePrintln(args ...interface{}) {
+	if consoleShows(verbosityNormal) {
+		fmt.Println(args...)
+	}
+}
+
+// consoleSuccessf prints a step that completed
+func consoleSuccessf(format string, args ...interface{}) {
+	consoleLine(verbosityNormal, ansiGreen, "✓ "+fmt.Sprintf(format, args...))
+}
+
+// consoleWarnf prints a problem the launcher continues after
+func consoleWarnf(format string, args ...interface{}) {
+	consoleLine(verbosityNormal, ansiYellow, tr("Warning")+": "+fmt.Sprintf(format, args...))
+}
+
+// consoleErrorf prints a failure; it is shown even with --quiet
+func consoleErrorf(format string, args ...interface{}) {
+	consoleLine(verbosityQuiet, ansiRed, "❌ "+fmt.Sprintf(format, args...))
+}
+
+// consoleChildLine mirrors a line of child output. Each line is one write,
+// so the backend's and the app's lines interleave without tearing.
+func consoleChildLine(at time.Time, name, stream, line string) {
+	if !consoleShows(verbosityNormal) {
+		return
+	}
+	prefix := fmt.Sprintf("%s [%s]", at.Format("15:04:05.000"), name)
+	console.mu.Lock()
+	colored := console.color
+	console.mu.Unlock()
+	if colored {
+		prefix = ansiDim + prefix + ansiReset
+		if stream == streamStderr && stderrErrorLine.MatchString(line) {
+			line = ansiRed + line + ansiReset
+		}
+	}
+	fmt.Println(prefix + " " + line)
+}
+
+// consoleDetailf prints what only --verbose wants to see
+func consoleDetailf(format string, args ...interface{}) {
+	consoleLine(verbosityVerbose, ansiDim, fmt.Sprintf(format, args...))
+}
+
+// emitPhase writes a progress event in JSON mode and is a no-op otherwise
+func emitPhase(phase, status string, percent float64, message string) {
+	recordPhase(phase, status)
+	writeProgressEvent(progressEvent{Phase: phase, Status: status, Percent: percent, Message: message})
+}
+
+// emitPhaseFailed reports a failure of the phase that was started last
+func emitPhaseFailed(err string) {
+	console.mu.Lock()
+	phase := console.phase
+	console.mu.Unlock()
+	recordPhase(phase, phaseFailed)
+	writeProgressEvent(progressEvent{Phase: phase, Status: phaseFailed, Error: err})
+}
+
+func emitExit(code int) {
+	writeProgressEvent(progressEvent{Phase: phaseExit, Status: phaseDone, Percent: 100, ExitCode: &code})
+	emitLauncherStopped(code)
+}
+
+func writeProgressEvent(event progressEvent) {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	if event.Status == phaseStarted {
+		console.phase = event.Phase
+	}
+	if !console.json {
+		return
+	}
+	event.Time = time.Now().Format(time.RFC3339Nano)
+	if data, err := json.Marshal(event); err == nil {
+		os.Stdout.Write(append(data, '\n'))
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Boot stages shown while the application starts. The backend may report
+// its own stages (any name) through the control channel.
+const (
+	stageExtracting     = "extracting"
+	stageStartingServer = "starting_backend"
+	stageMigrating      = "migrating_data"
+	stagePreparing      = "preparing"
+	stageLoadingModels  = "loading_models"
+	stageAlmostReady    = "almost_ready"
+	stageReady          = "ready"
+)
+
+var stageLabels = map[string]string{
+	stageExtracting:     "Extracting application files...",
+	stageStartingServer: "Starting the backend...",
+	stageMigrating:      "Migrating data...",
+	stagePreparing:      "Preparing...",
+	stageLoadingModels:  "Loading models...",
+	stageAlmostReady:    "Almost ready...",
+	stageReady:          "Ready",
+}
+
+type bootStage struct {
+	Name    string    `json:"stage"`
+	Message string    `json:"message,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+func (s bootStage) label() string {
+	if s.Message != "" {
+		return s.Message
+	}
+	if label, ok := stageLabels[s.Name]; ok {
+		return tr(label)
+	}
+	return s.Name
+}
+
+// controlServer is the launcher's localhost-only control channel. Children
+// find it through WAP_CONTROL_URL and authenticate with WAP_CONTROL_TOKEN.
+type controlServer struct {
+	url        string
+	token      string
+	server     *http.Server
+	statusPath string
+	infoPath   string
+
+	mu             sync.Mutex
+	stage          bootStage
+	backendURL     string // where the launcher itself reaches the backend
+	tasks          map[string]progressUpdate
+	services       map[string]*serviceStatus
+	serviceHandler func(serviceCommand) error
+	listeners      map[int]func(string)
+	listenerID     int
+	traceRoot      string
+	trace          *traceInfo
+	traceFile      *os.File
+	traceTimer     *time.Timer
+	metricsToken   string
+	healthPath     string            // the backend's health endpoint, for /metrics
+	instance       *instanceEntry    // this launcher in "launcher list"
+	session        *sessionPublisher // session.json, see sessionfile.go
+}
+
+// startControlServer opens the channel on port, 0 for any; every stage or
+// progress change is also written to statusPath (status.json).
+func startControlServer(statusPath string, port int) (*controlServer, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open control channel: %w", err)
+	}
+
+	c := &controlServer{
+		url:        "http://" + listener.Addr().String(),
+		token:      token,
+		statusPath: statusPath,
+		tasks:      map[string]progressUpdate{},
+		services:   map[string]*serviceStatus{},
+		listeners:  map[int]func(string){},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stage", c.authorized(c.handleStage))
+	mux.HandleFunc("/progress", c.authorized(c.handleProgress))
+	mux.HandleFunc("/status", c.authorized(c.handleStatus))
+	mux.HandleFunc("/service", c.authorized(c.handleService))
+	mux.HandleFunc("/trace", c.authorized(c.handleTrace))
+	mux.HandleFunc("/metrics", c.metricsAuthorized(c.handleMetrics))
+	c.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go c.server.Serve(listener)
+	logInfo("control", "control channel listening", "url", c.url)
+	return c, nil
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (c *controlServer) Close() {
+	c.StopTrace()
+	c.server.Close()
+	if c.infoPath != "" {
+		os.Remove(c.infoPath)
+	}
+	c.instance.remove()
+	c.session.remove()
+}
+
+// env returns the variables passed to child processes
+func (c *controlServer) env() []string {
+	return []string{"WAP_CONTROL_URL=" + c.url, "WAP_CONTROL_TOKEN=" + c.token}
+}
+
+// OnStatus registers a callback receiving a one-line summary of the boot
+// stage and task progress on every change. The returned function removes it.
+func (c *controlServer) OnStatus(listener func(summary string)) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listenerID++
+	id := c.listenerID
+	c.listeners[id] = listener
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.listeners, id)
+	}
+}
+
+// SetBackendURL publishes the backend address in the status for tools
+// such as "launcher wait --for healthy"
+func (c *controlServer) SetBackendURL(url string) {
+	c.mu.Lock()
+	c.backendURL = url
+	c.mu.Unlock()
+	c.notify()
+}
+
+func (c *controlServer) SetStage(name, message string) {
+	stage := bootStage{Name: name, Message: message, Updated: time.Now()}
+
+	c.mu.Lock()
+	changed := c.stage.Name != name
+	c.stage = stage
+	c.mu.Unlock()
+
+	if changed {
+		logInfo("control", "boot stage", "stage", name, "message", message)
+		if name == stageReady {
+			emitEvent(lifecycleEvent{Event: eventReady})
+			c.instance.update(instanceRunning)
+		}
+	}
+	c.notify()
+}
+
+func (c *controlServer) notify() {
+	c.mu.Lock()
+	summary := progressSummary(c.stage, c.tasks)
+	listeners := make([]func(string), 0, len(c.listeners))
+	for _, listener := range c.listeners {
+		listeners = append(listeners, listener)
+	}
+	c.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(summary)
+	}
+	c.writeStatusFile()
+	c.writeSessionFile()
+}
+
+func (c *controlServer) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-WAP-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// handleStage accepts {"stage": "loading_models", "message": "..."}
+func (c *controlServer) handleStage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var stage bootStage
+	if err := json.NewDecoder(r.Body).Decode(&stage); err != nil || stage.Name == "" {
+		http.Error(w, "expected {\"stage\": ...}", http.StatusBadRequest)
+		return
+	}
+	c.SetStage(stage.Name, stage.Message)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *controlServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.status())
+}
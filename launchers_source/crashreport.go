@@ -0,0 +1,241 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CrashReportSettings: when the backend or the app exits abnormally a
+// crash bundle is saved to logs\crashes: the exit code, the end of every
+// log, the effective configuration with secrets masked and OS facts. With
+// upload_url set the bundle is POSTed there as application/zip once the
+// user agrees ("ask", the default), always ("always") or never ("never").
+type CrashReportSettings struct {
+	Disabled  bool   `json:"disabled"`
+	UploadURL string `json:"upload_url"` // HTTPS
+	Upload    string `json:"upload"`     // ask, always or never
+	Token     string `json:"token"`      // sent as a bearer token, may be secret://
+	Keep      int    `json:"keep"`       // bundles kept, default 10
+}
+
+const (
+	crashDirName        = "crashes"
+	crashLogLines       = 200
+	defaultCrashKeep    = 10
+	crashUploadTimeout  = 30 * time.Second
+	crashBundlePrefix   = "crash-"
+	crashBundleDateTime = "20060102-150405"
+)
+
+// pendingCrashReports lets the launcher finish saving (and uploading)
+// bundles before it exits
+var pendingCrashReports sync.WaitGroup
+
+// crashLogs are the logs whose end goes into a bundle, if present
+var crashLogs = []string{launcherLogName, backendLogName, frontendLogName, proxyAccessLogName}
+
+// crashInfo is crash.json in the bundle
+type crashInfo struct {
+	Time            time.Time `json:"time"`
+	Service         string    `json:"service"`
+	ExitCode        int       `json:"exit_code"` // -1 if unknown
+	Error           string    `json:"error"`
+	LauncherVersion string    `json:"launcher_version"`
+	Commit          string    `json:"commit"`
+	AppVersion      string    `json:"app_version"`
+	OSVersion       string    `json:"os_version"`
+	Cores           int       `json:"cores"`
+	RAMMB           int64     `json:"ram_mb"`
+	SafeMode        bool      `json:"safe_mode"`
+}
+
+func crashDir(config *AppConfig) string {
+	return filepath.Join(config.LogDir, crashDirName)
+}
+
+// reportCrash saves a bundle for a child that exited with err and offers to
+// upload it. It does not block the caller.
+func reportCrash(config *AppConfig, service string, err error) {
+	if config.Settings.CrashReports.Disabled {
+		return
+	}
+	pendingCrashReports.Add(1)
+	go func() {
+		defer pendingCrashReports.Done()
+		path, bundleErr := writeCrashBundle(config, service, err)
+		if bundleErr != nil {
+			logWarn("crash", "failed to write crash bundle", "service", service, "error", bundleErr)
+			return
+		}
+		logInfo("crash", "crash bundle saved", "service", service, "path", path)
+		pruneCrashBundles(config)
+		offerCrashUpload(config, path)
+	}()
+}
+
+func writeCrashBundle(config *AppConfig, service string, err error) (string, error) {
+	if mkErr := os.MkdirAll(crashDir(config), 0755); mkErr != nil {
+		return "", mkErr
+	}
+	now := time.Now()
+	facts := currentMachineFacts()
+	info := crashInfo{
+		Time:            now,
+		Service:         service,
+		ExitCode:        -1,
+		LauncherVersion: launcherVersion,
+		Commit:          gitCommit,
+		AppVersion:      installedVersion(config),
+		OSVersion:       facts.OSVersion,
+		Cores:           facts.Cores,
+		RAMMB:           facts.RAMMB,
+		SafeMode:        config.SafeMode,
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		info.ExitCode = exitErr.ExitCode()
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	add := func(name string, data []byte) error {
+		w, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	data, _ := json.MarshalIndent(info, "", "  ")
+	if err := add("crash.json", data); err != nil {
+		return "", err
+	}
+	if settings, err := maskedSettings(config); err == nil {
+		data, _ := json.MarshalIndent(settings, "", "  ")
+		if err := add("config.json", data); err != nil {
+			return "", err
+		}
+	}
+	for _, name := range crashLogs {
+		file, err := os.Open(filepath.Join(config.LogDir, name))
+		if err != nil {
+			continue
+		}
+		var tail bytes.Buffer
+		printLastLines(file, crashLogLines, &tail)
+		file.Close()
+		if err := add("logs/"+name, tail.Bytes()); err != nil {
+			return "", err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(crashDir(config), fmt.Sprintf("%s%s-%s.zip", crashBundlePrefix, now.Format(crashBundleDateTime), service))
+	return path, os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func pruneCrashBundles(config *AppConfig) {
+	keep := config.Settings.CrashReports.Keep
+	if keep <= 0 {
+		keep = defaultCrashKeep
+	}
+	bundles, _ := filepath.Glob(filepath.Join(crashDir(config), crashBundlePrefix+"*.zip"))
+	if len(bundles) <= keep {
+		return
+	}
+	// The timestamp in the name sorts oldest first
+	sort.Strings(bundles)
+	for _, path := range bundles[:len(bundles)-keep] {
+		os.Remove(path)
+	}
+}
+
+// offerCrashUpload uploads the bundle if configured and allowed, otherwise
+// tells the user where it was saved
+func offerCrashUpload(config *AppConfig, path string) {
+	settings := config.Settings.CrashReports
+	mode := settings.Upload
+	if mode == "" {
+		mode = "ask"
+	}
+	upload := false
+	if settings.UploadURL != "" {
+		switch mode {
+		case "always":
+			upload = true
+		case "ask":
+			upload = !machineOutput() && messageBox(config.AppName,
+				tr("%s ran into a problem and saved a crash report.\n\nSend it to the developers? It contains recent log lines and the configuration without passwords or tokens.", config.AppName),
+				mbYesNo|mbIconInformation|mbTopmost) == idYes
+		case "never":
+		default:
+			logWarn("crash", "unknown crash_reports.upload, not uploading", "value", mode)
+		}
+	}
+	if upload {
+		if err := uploadCrashBundle(config, path); err != nil {
+			logWarn("crash", "crash report upload failed", "url", settings.UploadURL, "error", err)
+		} else {
+			logInfo("crash", "crash report uploaded", "url", settings.UploadURL)
+			return
+		}
+	}
+	consolePrintf("Crash report saved to %s\n", path)
+	if notify := alertNotifier(); notify != nil {
+		notify(config.AppName, tr("A crash report was saved to %s", path))
+	}
+}
+
+func uploadCrashBundle(config *AppConfig, path string) error {
+	settings := config.Settings.CrashReports
+	target, err := url.Parse(settings.UploadURL)
+	if err != nil || target.Scheme != "https" {
+		return fmt.Errorf("crash_reports.upload_url must be an https URL")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), crashUploadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/zip")
+	req.Header.Set("X-WAP-Version", installedVersion(config))
+	if settings.Token != "" {
+		token, err := resolveSecretValue(config, "crash_reports.token", settings.Token)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(token))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Before the backend starts, every SQLite database under DataDir gets a
+// PRAGMA quick_check through the embedded Python's sqlite3 module. Damage
+// is offered a restore from the newest backup instead of surfacing later
+// as obscure backend errors.
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// Opens each database read-only and prints "path<TAB>problem" for the
+// damaged ones
+const checkSQLiteScript = `import sys, sqlite3, pathlib
+for path in sys.argv[1:]:
+    try:
+        con = sqlite3.connect(pathlib.Path(path).as_uri() + '?mode=ro', uri=True)
+        rows = con.execute('PRAGMA quick_check').fetchall()
+        con.close()
+        if rows != [('ok',)]:
+            print(path + '\t' + str(rows[0][0]).replace('\n', ' '))
+    except Exception as e:
+        print(path + '\t' + str(e).replace('\n', ' '))
+`
+
+// dataProblem is a database that failed its integrity check
+type dataProblem struct {
+	Path    string
+	Problem string
+}
+
+// findSQLiteFiles returns the files below dir that start with the SQLite
+// header, whatever their extension
+func findSQLiteFiles(dir string) []string {
+	var files []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer file.Close()
+		header := make([]byte, len(sqliteHeader))
+		if _, err := io.ReadFull(file, header); err == nil && bytes.Equal(header, sqliteHeader) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// checkDataIntegrity runs quick_check on every database in DataDir
+func checkDataIntegrity(config *AppConfig) ([]dataProblem, error) {
+	files := findSQLiteFiles(config.DataDir)
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, config.PythonExe, append([]string{"-c", checkSQLiteScript}, files...)...)
+	cmd.Env = pythonEnviron(config)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check databases: %w", err)
+	}
+
+	var problems []dataProblem
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if path, problem, ok := strings.Cut(strings.TrimSpace(line), "\t"); ok {
+			problems = append(problems, dataProblem{Path: path, Problem: problem})
+		}
+	}
+	return problems, nil
+}
+
+// verifyDataBeforeStart runs the database check and, on damage, lets the
+// user restore the newest backup, start anyway or quit. It returns false
+// if the launcher should not continue.
+func verifyDataBeforeStart(config *AppConfig) bool {
+	consolePrintln("Checking application data...")
+	problems, err := checkDataIntegrity(config)
+	if err != nil {
+		// A broken check is not broken data
+		logWarn("data", "data integrity check failed", "error", err)
+		return true
+	}
+	if len(problems) == 0 {
+		return true
+	}
+
+	var details strings.Builder
+	for _, p := range problems {
+		rel, _ := filepath.Rel(config.DataDir, p.Path)
+		consoleErrorf("%s: %s", rel, p.Problem)
+		logError("data", "database failed integrity check", "path", p.Path, "problem", p.Problem)
+		fmt.Fprintf(&details, "%s: %s\n", rel, shortLine(p.Problem))
+	}
+	reportEvent(eventTypeError, eventIDDataCorrupt, fmt.Sprintf("Damaged application data in %s:\n%s", config.DataDir, details.String()))
+
+	backups, _ := listBackups(config)
+	if len(backups) == 0 {
+		answer := messageBox(config.AppName,
+			tr("The application data is damaged and there is no backup to restore:\n\n%s\nStart anyway?", details.String()),
+			mbYesNo|mbIconWarning|mbTopmost)
+		return answer == idYes
+	}
+
+	newest := backups[0]
+	answer := messageBox(config.AppName,
+		tr("The application data is damaged:\n\n%s\nRestore the most recent backup (%s)?\n\nYes restores the backup, No starts anyway, Cancel quits.",
+			details.String(), filepath.Base(newest)),
+		mbYesNoCancel|mbIconWarning|mbTopmost)
+	switch answer {
+	case idYes:
+	case idNo:
+		logWarn("data", "starting with damaged data")
+		return true
+	default:
+		return false
+	}
+
+	consolePrintf("Restoring %s...\n", filepath.Base(newest))
+	safety, err := restoreData(config, newest)
+	if err != nil {
+		showError("Restore failed", err)
+		return false
+	}
+	logInfo("data", "restored backup after integrity failure", "archive", newest, "damaged_copy", safety)
+	if problems, err := checkDataIntegrity(config); err == nil && len(problems) > 0 {
+		showError("The restored backup is damaged too",
+			fmt.Errorf("%s: %s\n\nRestore an older backup with \"launcher restore\"; the damaged data was saved to %s", problems[0].Path, problems[0].Problem, safety))
+		return false
+	}
+	consoleSuccessf("Data restored from %s", filepath.Base(newest))
+	return true
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DataDir carries a data_version.json with the version of its layout. The
+// backend ships one script per version step in python_backend\migrations:
+//
+//	migrations\0001_split_results.py
+//	migrations\0002_add_thumbnails.py
+//
+// The highest number is the version this build expects. Older data is
+// brought forward one script at a time before the backend starts; data
+// from a newer build is refused rather than risked.
+const (
+	dataVersionFileName = "data_version.json"
+	migrationsDirName   = "migrations"
+	dataMigrationLog    = "data_migration.log"
+	dataMigrationLimit  = 30 * time.Minute
+)
+
+var migrationScriptPattern = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.py$`)
+
+var errDataTooNew = errors.New("data is from a newer version")
+
+type dataVersion struct {
+	Version    int       `json:"version"`
+	AppVersion string    `json:"app_version,omitempty"`
+	Updated    time.Time `json:"updated"`
+}
+
+// dataMigration is one version step
+type dataMigration struct {
+	Version int
+	Name    string
+	Script  string
+}
+
+// dataMigrations lists the backend's migration scripts in version order
+func dataMigrations(config *AppConfig) ([]dataMigration, error) {
+	entries, err := os.ReadDir(filepath.Join(config.BackendDir, migrationsDirName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var migrations []dataMigration
+	seen := map[int]string{}
+	for _, e := range entries {
+		m := migrationScriptPattern.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, e.Name())
+		}
+		seen[version] = e.Name()
+		migrations = append(migrations, dataMigration{
+			Version: version,
+			Name:    m[2],
+			Script:  filepath.Join(config.BackendDir, migrationsDirName, e.Name()),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// expectedDataVersion is the version of the newest migration, 0 without any
+func expectedDataVersion(migrations []dataMigration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// readDataVersion returns the recorded version. Data without a version
+// file is version 0, and an empty DataDir is new (ok is false).
+func readDataVersion(config *AppConfig) (version int, ok bool, err error) {
+	var recorded dataVersion
+	err = readJSONFile(filepath.Join(config.DataDir, dataVersionFileName), &recorded)
+	if err == nil {
+		return recorded.Version, true, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return 0, false, err
+	}
+	entries, _ := os.ReadDir(config.DataDir)
+	return 0, len(entries) > 0, nil
+}
+
+func writeDataVersion(config *AppConfig, version int) error {
+	return writeJSONFile(filepath.Join(config.DataDir, dataVersionFileName), dataVersion{
+		Version:    version,
+		AppVersion: installedVersion(config),
+		Updated:    time.Now().UTC(),
+	})
+}
+
+// checkDataVersion returns the recorded and expected versions
+func checkDataVersion(config *AppConfig) (current, expected int, pending []dataMigration, err error) {
+	migrations, err := dataMigrations(config)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	expected = expectedDataVersion(migrations)
+	current, existing, err := readDataVersion(config)
+	if err != nil {
+		return 0, expected, nil, err
+	}
+	if !existing {
+		current = expected
+	}
+	if current > expected {
+		return current, expected, nil, errDataTooNew
+	}
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return current, expected, pending, nil
+}
+
+// migrateData brings DataDir to the version the backend expects, showing
+// each step on the splash
+func migrateData(config *AppConfig, control *controlServer) error {
+	current, expected, pending, err := checkDataVersion(config)
+	if errors.Is(err, errDataTooNew) {
+		return fmt.Errorf("the data in %s was written by a newer version of %s (data version %d, this version supports up to %d). Install the newer version again or restore a backup",
+			config.DataDir, config.AppName, current, expected)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the data version: %w", err)
+	}
+	if len(pending) == 0 {
+		if _, err := os.Stat(filepath.Join(config.DataDir, dataVersionFileName)); errors.Is(err, os.ErrNotExist) {
+			return writeDataVersion(config, current)
+		}
+		return nil
+	}
+
+	consolePrintf("Updating data from version %d to %d...\n", current, expected)
+	logInfo("data", "migrating data", "from", current, "to", expected, "steps", len(pending))
+	logFile, err := os.OpenFile(filepath.Join(config.LogDir, dataMigrationLog), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	defer logFile.Close()
+
+	for i, m := range pending {
+		message := fmt.Sprintf("Updating data (%d of %d)...", i+1, len(pending))
+		control.SetStage(stageMigrating, message)
+		emitPhase(phaseMigrate, phaseProgress, float64(i*100/len(pending)), m.Name)
+		consoleDetailf("  %04d %s", m.Version, m.Name)
+		fmt.Fprintf(logFile, "=== %s %04d_%s\n", time.Now().Format(time.RFC3339), m.Version, m.Name)
+
+		started := time.Now()
+		if err := runDataMigration(config, control, m, logFile); err != nil {
+			logError("data", "data migration failed", "version", m.Version, "name", m.Name, "error", err)
+			return fmt.Errorf("data migration %04d_%s failed, see %s: %w", m.Version, m.Name, logFile.Name(), err)
+		}
+		// Record every step, so a later failure resumes from there
+		if err := writeDataVersion(config, m.Version); err != nil {
+			return fmt.Errorf("failed to record data version %d: %w", m.Version, err)
+		}
+		logInfo("data", "data migration done", "version", m.Version, "name", m.Name, "duration_ms", time.Since(started).Milliseconds())
+	}
+	consoleSuccessf("Data updated to version %d", expected)
+	return nil
+}
+
+// runDataMigration runs one script with the backend's interpreter. It can
+// report finer progress through the control channel like the backend does.
+func runDataMigration(config *AppConfig, control *controlServer, m dataMigration, logFile *os.File) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dataMigrationLimit)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, config.PythonExe, m.Script)
+	cmd.Dir = config.BackendDir
+	cmd.Env = append(pythonEnviron(config), "WAP_DATA_DIR="+config.DataDir, "WAP_LOG_DIR="+config.LogDir)
+	cmd.Env = append(cmd.Env, control.env()...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	return cmd.Run()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Delta updates stage a release file by file. The new version's
+// manifest.json is downloaded first; files whose hash matches one already
+// installed (or already in the content store) are reused, and only the rest
+// are fetched from FilesURL + "/" + path.
+
+// stageDeltaUpdate builds bin.staged from the installed files plus the
+// changed ones, and returns the number of bytes downloaded.
+func stageDeltaUpdate(config *AppConfig, manifest *updateManifest, staged string, onTaskbar *taskbarProgress) (int64, error) {
+	dir := updatesDir(config)
+	manifestPath := filepath.Join(dir, "manifest-"+manifest.Version+".json")
+	if err := downloadFile(manifest.FileManifest, manifestPath); err != nil {
+		return 0, fmt.Errorf("failed to download file manifest: %w", err)
+	}
+	defer os.Remove(manifestPath)
+	if err := verifyFileSHA256(manifestPath, manifest.FileManifestSHA256); err != nil {
+		return 0, fmt.Errorf("file manifest: %w", err)
+	}
+	var target fileManifest
+	if err := readJSONFile(manifestPath, &target); err != nil {
+		return 0, fmt.Errorf("invalid file manifest: %w", err)
+	}
+
+	installed, _ := loadManifest(config.BinDir)
+	if installed == nil {
+		installed = &fileManifest{}
+	}
+	store := openContentStore(config)
+	seedStoreFromInstall(config, store)
+
+	var reuse, fetch []string
+	var fetchBytes int64
+	for rel, entry := range target.Files {
+		if _, err := safeJoin(staged, rel); err != nil {
+			return 0, err
+		}
+		if current, ok := installed.Files[rel]; ok && strings.EqualFold(current.SHA256, entry.SHA256) {
+			reuse = append(reuse, rel)
+		} else if store.wants(entry.Size, entry.SHA256) && store.has(entry.SHA256) {
+			reuse = append(reuse, rel)
+		} else {
+			fetch = append(fetch, rel)
+			fetchBytes += entry.Size
+		}
+	}
+	sort.Strings(fetch)
+	consolePrintf("Delta update: reusing %d file(s), downloading %d file(s) (%.1f MB)\n", len(reuse), len(fetch), float64(fetchBytes)/(1<<20))
+
+	// Installed files and store blobs are re-hashed before reuse; a damaged
+	// one is fetched. Blobs are hardlinks of installed files, so a file
+	// changed in place changes its blob too.
+	for _, rel := range reuse {
+		entry := target.Files[rel]
+		dst := filepath.Join(staged, filepath.FromSlash(rel))
+		if store.wants(entry.Size, entry.SHA256) && store.has(entry.SHA256) {
+			blob := store.blobPath(entry.SHA256)
+			if hash, err := fileSHA256(blob); err != nil || !strings.EqualFold(hash, entry.SHA256) {
+				logWarn("store", "store blob does not match its hash, downloading the file", "path", rel, "blob", blob)
+				os.Remove(blob)
+				fetch = append(fetch, rel)
+				fetchBytes += entry.Size
+				continue
+			}
+			if err := store.link(entry.SHA256, dst); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		src := filepath.Join(config.BinDir, filepath.FromSlash(rel))
+		if hash, err := fileSHA256(src); err != nil || !strings.EqualFold(hash, entry.SHA256) {
+			fetch = append(fetch, rel)
+			fetchBytes += entry.Size
+			continue
+		}
+		if err := copyFile(src, dst); err != nil {
+			return 0, err
+		}
+	}
+
+	base := strings.TrimRight(manifest.FilesURL, "/")
+	var fetched int64
+	for _, rel := range fetch {
+		entry := target.Files[rel]
+		dst := filepath.Join(staged, filepath.FromSlash(rel))
+		if err := downloadFile(fileURL(base, rel), dst); err != nil {
+			return 0, fmt.Errorf("failed to download %s: %w", rel, err)
+		}
+		if hash, err := fileSHA256(dst); err != nil || !strings.EqualFold(hash, entry.SHA256) {
+			return 0, fmt.Errorf("%s does not match the release manifest", rel)
+		}
+		if store.wants(entry.Size, entry.SHA256) {
+			if err := store.adopt(dst, entry.SHA256); err != nil {
+				logDebug("store", "could not add file to store", "path", rel, "error", err)
+			}
+		}
+		fetched += entry.Size
+		onTaskbar.Set(fetched, fetchBytes)
+	}
+
+	data, err := json.MarshalIndent(&target, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filepath.Join(staged, manifestName), data, 0644); err != nil {
+		return 0, err
+	}
+	return fetchBytes, nil
+}
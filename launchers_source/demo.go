@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DemoSettings restricts how long the application may be used. The same
+// block can come from launcher.json or from a license.json next to it.
+type DemoSettings struct {
+	Enabled        bool   `json:"enabled"`
+	SessionMinutes int    `json:"session_minutes"` // per-launch limit
+	Expires        string `json:"expires"`         // YYYY-MM-DD, inclusive
+	TrialDays      int    `json:"trial_days"`      // counted from first launch
+	UpgradeURL     string `json:"upgrade_url"`
+}
+
+type licenseFile struct {
+	Edition string       `json:"edition"` // "demo" or "full"
+	Demo    DemoSettings `json:"demo"`
+}
+
+type demoState struct {
+	FirstRun time.Time `json:"first_run"`
+}
+
+// resolveDemoSettings applies license.json on top of the config. A full
+// license always disables demo mode.
+func resolveDemoSettings(config *AppConfig) DemoSettings {
+	demo := config.Settings.Demo
+
+	data, err := os.ReadFile(filepath.Join(config.RootDir, "license.json"))
+	if err != nil {
+		return demo
+	}
+	var license licenseFile
+	if err := json.Unmarshal(data, &license); err != nil {
+		logWarn("demo", "ignoring invalid license.json", "error", err)
+		return demo
+	}
+	switch license.Edition {
+	case "full":
+		demo.Enabled = false
+	case "demo":
+		demo = license.Demo
+		demo.Enabled = true
+	}
+	return demo
+}
+
+// demoDeadline returns the moment the current session has to end
+func demoDeadline(config *AppConfig, demo DemoSettings, sessionStart time.Time) (time.Time, error) {
+	var deadline time.Time
+	earliest := func(t time.Time) {
+		if deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+
+	if demo.SessionMinutes > 0 {
+		earliest(sessionStart.Add(time.Duration(demo.SessionMinutes) * time.Minute))
+	}
+	if demo.Expires != "" {
+		day, err := time.ParseInLocation("2006-01-02", demo.Expires, time.Local)
+		if err != nil {
+			return deadline, fmt.Errorf("invalid demo expiry date %q: %w", demo.Expires, err)
+		}
+		earliest(day.AddDate(0, 0, 1))
+	}
+	if demo.TrialDays > 0 {
+		firstRun := demoFirstRun(config, sessionStart)
+		earliest(firstRun.AddDate(0, 0, demo.TrialDays))
+	}
+
+	return deadline, nil
+}
+
+func demoFirstRun(config *AppConfig, now time.Time) time.Time {
+	path := filepath.Join(config.BinDir, "demo_state.json")
+
+	var state demoState
+	if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &state) == nil && !state.FirstRun.IsZero() {
+		return state.FirstRun
+	}
+
+	state.FirstRun = now
+	if data, err := json.Marshal(state); err == nil {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			logWarn("demo", "failed to record trial start", "error", err)
+		}
+	}
+	return now
+}
+
+// startDemoTimer keeps the tray tooltip up to date and stops the session
+// when the demo time runs out.
+func startDemoTimer(session *Session, deadline time.Time) {
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				logInfo("demo", "demo time limit reached")
+				session.RequestStop(stopReasonDemoExpired)
+				return
+			}
+			if session.tray != nil {
+				session.tray.SetTooltip(tr("%s (demo) - %s remaining", session.config.AppName, formatRemaining(remaining)))
+			}
+
+			select {
+			case <-ticker.C:
+			case <-time.After(remaining):
+			case <-session.Stopping():
+				return
+			}
+		}
+	}()
+}
+
+func formatRemaining(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%d min", int(d.Minutes())+1)
+	}
+}
+
+func showUpgradePrompt(config *AppConfig, demo DemoSettings) {
+	text := tr("Your WAP demo has ended.")
+	if demo.UpgradeURL == "" {
+		messageBox(config.AppName, text+"\n\n"+tr("Please contact your administrator to upgrade to the full version."), mbOK|mbIconInformation|mbTopmost)
+		return
+	}
+
+	text += "\n\n" + tr("Would you like to open the upgrade page now?")
+	if messageBox(config.AppName, text, mbYesNo|mbIconInformation|mbTopmost) == idYes {
+		if err := openURL(demo.UpgradeURL); err != nil {
+			logWarn("demo", "failed to open upgrade page", "url", demo.UpgradeURL, "error", err)
+		}
+	}
+}
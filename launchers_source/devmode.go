@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --dev is for working on the backend: edits to python_backend are not
+// integrity failures, updates and the scrubber stay off, and the backend
+// restarts whenever a .py file changes while the Flutter app keeps running.
+const devPollInterval = time.Second
+
+// Directories under python_backend that never hold backend sources
+var devIgnoredDirs = map[string]bool{
+	"__pycache__":   true,
+	wheelsDirName:   true,
+	".git":          true,
+	".venv":         true,
+	"venv":          true,
+	".mypy_cache":   true,
+	".pytest_cache": true,
+}
+
+// resolveDevPython picks the interpreter for --dev: --python if given,
+// else the active virtualenv, else the embedded one (returns "")
+func resolveDevPython(python string) (string, error) {
+	if python == "" {
+		venv := os.Getenv("VIRTUAL_ENV")
+		if venv == "" {
+			return "", nil
+		}
+		python = filepath.Join(venv, "Scripts", "python.exe")
+	}
+	path, err := exec.LookPath(python)
+	if err != nil {
+		return "", fmt.Errorf("invalid --python %q: %w", python, err)
+	}
+	return filepath.Abs(path)
+}
+
+// backendSources returns the modification time of every .py file
+func backendSources(dir string) map[string]time.Time {
+	sources := map[string]time.Time{}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && devIgnoredDirs[d.Name()] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".py") {
+			if info, err := d.Info(); err == nil {
+				sources[path] = info.ModTime()
+			}
+		}
+		return nil
+	})
+	return sources
+}
+
+// changedSource returns a file that differs between two snapshots
+func changedSource(before, after map[string]time.Time) (string, bool) {
+	for path, modified := range after {
+		if previous, ok := before[path]; !ok || !previous.Equal(modified) {
+			return path, true
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// watchBackendSources restarts the backend after .py files change. A
+// change is acted on once the files have been quiet for one poll, so an
+// editor saving several files causes a single restart.
+func watchBackendSources(config *AppConfig, control *controlServer) {
+	go func() {
+		sources := backendSources(config.BackendDir)
+		pending := ""
+		ticker := time.NewTicker(devPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-launcherExiting():
+				return
+			case <-ticker.C:
+			}
+
+			current := backendSources(config.BackendDir)
+			if path, changed := changedSource(sources, current); changed {
+				sources = current
+				pending = path
+				continue
+			}
+			if pending == "" {
+				continue
+			}
+
+			rel, _ := filepath.Rel(config.BackendDir, pending)
+			pending = ""
+			consolePrintf("Backend source changed (%s), restarting the backend...\n", rel)
+			logInfo("dev", "backend source changed, restarting", "file", rel)
+			if err := control.runServiceCommand(serviceCommand{Service: serviceBackend, Action: "restart"}); err != nil {
+				logWarn("dev", "could not restart the backend", "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// diskHealth is what Windows storage management reports for one physical
+// disk. HealthStatus is "Healthy", "Warning" or "Unhealthy".
+type diskHealth struct {
+	Name           string `json:"name"`
+	HealthStatus   string `json:"health"`
+	Operational    string `json:"operational"`
+	PredictFailure bool   `json:"predict_failure"`
+	System         bool   `json:"system"`
+}
+
+func (d diskHealth) failing() bool {
+	return d.PredictFailure || (d.HealthStatus != "" && d.HealthStatus != "Healthy")
+}
+
+// diskHealthScript queries Get-PhysicalDisk and the SMART failure
+// prediction flag from WMI. The SMART class is keyed by PnP instance, so it
+// is matched to disks by device ID inside the instance name.
+const diskHealthScript = `
+$ErrorActionPreference = 'SilentlyContinue'
+$system = (Get-Partition -DriveLetter $env:SystemDrive[0] | Get-Disk).Number
+$smart = @(Get-CimInstance -Namespace root\wmi -ClassName MSStorageDriver_FailurePredictStatus)
+$disks = @(Get-PhysicalDisk | ForEach-Object {
+  $disk = $_
+  $predict = @($smart | Where-Object { $_.PredictFailure -and $_.InstanceName -like "*_$($disk.DeviceId)" }).Count -gt 0
+  [pscustomobject]@{
+    name = $disk.FriendlyName
+    health = [string]$disk.HealthStatus
+    operational = ($disk.OperationalStatus -join ', ')
+    predict_failure = $predict
+    system = ([string]$disk.DeviceId -eq [string]$system)
+  }
+})
+ConvertTo-Json -InputObject $disks -Compress
+`
+
+func checkDiskHealth() ([]diskHealth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", diskHealthScript)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disk health: %w", err)
+	}
+	var disks []diskHealth
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(output))), &disks); err != nil {
+		return nil, fmt.Errorf("unexpected disk health output: %w", err)
+	}
+	return disks, nil
+}
+
+// reportFailingDisks logs and raises a tray warning for disks that look
+// failing. It returns the failing disks.
+func reportFailingDisks(config *AppConfig, tray *Tray, disks []diskHealth) []diskHealth {
+	var failing []diskHealth
+	for _, disk := range disks {
+		if !disk.failing() {
+			continue
+		}
+		failing = append(failing, disk)
+		logError("disk", "disk reports a health problem", "disk", disk.Name, "health", disk.HealthStatus,
+			"operational", disk.Operational, "predict_failure", disk.PredictFailure, "system", disk.System)
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+
+	disk := failing[0]
+	message := tr("Disk %q reports %s. Back up data and replace the disk soon.", disk.Name, describeDiskProblem(disk))
+	if disk.System {
+		message = tr("The system disk (%s) reports %s. Back up data and replace the disk soon.", disk.Name, describeDiskProblem(disk))
+	}
+	reportEvent(eventTypeWarning, eventIDDiskHealth, message)
+	emitDegraded(serviceLauncher, "disk_failing", message)
+	if tray != nil {
+		tray.Notify(tr("%s - disk problem", config.AppName), message)
+	}
+	return failing
+}
+
+func describeDiskProblem(disk diskHealth) string {
+	if disk.PredictFailure {
+		return "an imminent failure (SMART)"
+	}
+	if disk.Operational != "" {
+		return fmt.Sprintf("health %q (%s)", disk.HealthStatus, disk.Operational)
+	}
+	return fmt.Sprintf("health %q", disk.HealthStatus)
+}
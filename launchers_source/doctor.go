@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// doctorResult is the outcome of one diagnostic check
+type doctorResult struct {
+	ok     bool
+	warn   bool // problem that does not stop the application
+	detail string
+}
+
+type doctorCheck struct {
+	name string
+	run  func(config *AppConfig) doctorResult
+}
+
+// doctorChecks run in order by "launcher doctor"
+var doctorChecks = []doctorCheck{
+	{name: "Prerequisites", run: doctorPrerequisites},
+	{name: "Required files", run: doctorRequiredFiles},
+	{name: "Data directories", run: doctorUserDirs},
+	{name: "Data version", run: doctorDataVersion},
+	{name: "Data integrity", run: doctorDataIntegrity},
+	{name: "File integrity", run: doctorIntegrity},
+	{name: "Antivirus", run: doctorAntivirus},
+	{name: "Python interpreter", run: doctorPython},
+	{name: "Python packages", run: doctorPythonDeps},
+	{name: "Alert rules", run: doctorAlerts},
+	{name: "Disk health", run: doctorDiskHealth},
+}
+
+func doctorRequiredFiles(config *AppConfig) doctorResult {
+	for _, path := range []string{config.AppExe, config.FlutterDLL, config.PythonExe, config.BackendScript} {
+		if _, err := os.Stat(path); err != nil {
+			return doctorResult{detail: "missing " + path}
+		}
+	}
+	return doctorResult{ok: true}
+}
+
+// doctorUserDirs checks that logs and data can be written
+func doctorUserDirs(config *AppConfig) doctorResult {
+	for _, dir := range []string{config.LogDir, config.DataDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return doctorResult{detail: err.Error()}
+		}
+		probe, err := os.CreateTemp(dir, ".doctor-*")
+		if err != nil {
+			return doctorResult{detail: fmt.Sprintf("%s is not writable: %v", dir, err)}
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+	mode := "per-user"
+	if config.Portable {
+		mode = "portable"
+	}
+	return doctorResult{ok: true, detail: fmt.Sprintf("%s, data in %s", mode, config.DataDir)}
+}
+
+func doctorDataVersion(config *AppConfig) doctorResult {
+	current, expected, pending, err := checkDataVersion(config)
+	switch {
+	case errors.Is(err, errDataTooNew):
+		return doctorResult{detail: fmt.Sprintf("data version %d is newer than this version supports (%d)", current, expected)}
+	case err != nil:
+		return doctorResult{detail: err.Error()}
+	case len(pending) > 0:
+		return doctorResult{ok: true, detail: fmt.Sprintf("version %d, %d migrations run on next start", current, len(pending))}
+	}
+	return doctorResult{ok: true, detail: fmt.Sprintf("version %d", current)}
+}
+
+func doctorDataIntegrity(config *AppConfig) doctorResult {
+	problems, err := checkDataIntegrity(config)
+	if err != nil {
+		return doctorResult{detail: err.Error()}
+	}
+	if len(problems) > 0 {
+		return doctorResult{detail: fmt.Sprintf("%d damaged, e.g. %s: %s (restore with \"launcher restore\")",
+			len(problems), filepath.Base(problems[0].Path), problems[0].Problem)}
+	}
+	return doctorResult{ok: true, detail: fmt.Sprintf("%d databases checked", len(findSQLiteFiles(config.DataDir)))}
+}
+
+func doctorPython(config *AppConfig) doctorResult {
+	python, err := checkPython(config)
+	if err != nil {
+		return doctorResult{detail: err.Error()}
+	}
+	return doctorResult{ok: true, detail: python.String()}
+}
+
+func doctorPythonDeps(config *AppConfig) doctorResult {
+	problems, err := checkPythonDeps(config)
+	if err != nil {
+		return doctorResult{detail: err.Error()}
+	}
+	if len(problems) > 0 {
+		return doctorResult{detail: fmt.Sprintf("%d broken, e.g. %s: %s (run \"launcher repair-python\")",
+			len(problems), problems[0].Requirement, problems[0].Problem)}
+	}
+	return doctorResult{ok: true}
+}
+
+func doctorAlerts(config *AppConfig) doctorResult {
+	rules, errs := compileAlertRules(config.Settings.Alerts)
+	if len(errs) > 0 {
+		return doctorResult{detail: errs[0].Error()}
+	}
+	return doctorResult{ok: true, detail: fmt.Sprintf("%d configured", len(rules))}
+}
+
+func doctorIntegrity(config *AppConfig) doctorResult {
+	problems, err := verifyInstall(config, true)
+	if err != nil {
+		return doctorResult{detail: err.Error()}
+	}
+	if len(problems) > 0 {
+		return doctorResult{detail: fmt.Sprintf("%d damaged file(s), e.g. %s (%s); run \"launcher repair\"", len(problems), problems[0].Path, problems[0].Problem)}
+	}
+	return doctorResult{ok: true}
+}
+
+func doctorDiskHealth(config *AppConfig) doctorResult {
+	disks, err := checkDiskHealth()
+	if err != nil {
+		return doctorResult{warn: true, detail: err.Error()}
+	}
+	failing := reportFailingDisks(config, nil, disks)
+	if len(failing) > 0 {
+		return doctorResult{detail: fmt.Sprintf("%s reports %s", failing[0].Name, describeDiskProblem(failing[0]))}
+	}
+	return doctorResult{ok: true, detail: fmt.Sprintf("%d disk(s) healthy", len(disks))}
+}
+
+// runDoctorCommand implements "launcher doctor"
+func runDoctorCommand(config *AppConfig, args []string) int {
+	fs := newCommandFlagSet("doctor")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	failed := 0
+	for _, check := range doctorChecks {
+		result := check.run(config)
+		switch {
+		case result.ok:
+			fmt.Printf("✓ %s", check.name)
+		case result.warn:
+			fmt.Printf("! %s", check.name)
+		default:
+			fmt.Printf("❌ %s", check.name)
+			failed++
+		}
+		if result.detail != "" {
+			fmt.Printf(": %s", result.detail)
+		}
+		fmt.Println()
+	}
+	if failed > 0 {
+		fmt.Printf("%d check(s) failed\n", failed)
+		return exitLauncherError
+	}
+	return exitOK
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// .env files configure the backend without touching its scripts, e.g. API
+// keys and feature flags. Two are read, the second overriding the first:
+//
+//  1. python_backend\.env, shipped with the application
+//  2. %APPDATA%\WAP\.env, the user's own overrides
+//
+// launcher.json env and backend_env still win over both.
+const dotEnvName = ".env"
+
+// dotEnvPaths lists the .env files in the order they are applied
+func dotEnvPaths(config *AppConfig) []string {
+	paths := []string{filepath.Join(config.BackendDir, dotEnvName)}
+	if appData := os.Getenv("APPDATA"); appData != "" {
+		paths = append(paths, filepath.Join(appData, "WAP", dotEnvName))
+	}
+	return paths
+}
+
+// loadDotEnv merges the existing .env files into sorted KEY=value entries
+func loadDotEnv(config *AppConfig) ([]string, error) {
+	vars := map[string]string{}
+	for _, path := range dotEnvPaths(config) {
+		file, err := parseDotEnv(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		logInfo("backend", "loaded .env file", "path", path, "variables", len(file))
+		for name, value := range file {
+			vars[name] = value
+		}
+	}
+	return envList(vars), nil
+}
+
+// parseDotEnv reads KEY=value lines. It understands the usual dialect:
+// comments, "export KEY=value", single quotes taken literally, double
+// quotes with \n, \t, \" and \\ escapes, and " #" comments after unquoted
+// values.
+func parseDotEnv(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", path, lineNo)
+		}
+		value, err := dotEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		vars[name] = value
+	}
+	return vars, scanner.Err()
+}
+
+func dotEnvValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", errors.New("unterminated single quote")
+		}
+		return raw[1 : end+1], nil
+	case strings.HasPrefix(raw, `"`):
+		var b strings.Builder
+		for i := 1; i < len(raw); i++ {
+			c := raw[i]
+			if c == '"' {
+				return b.String(), nil
+			}
+			if c == '\\' && i+1 < len(raw) {
+				i++
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(raw[i])
+				}
+				continue
+			}
+			b.WriteByte(c)
+		}
+		return "", errors.New("unterminated double quote")
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	return strings.TrimSpace(raw), nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Users who start the app with "Run as administrator" get the
+// administrator's profile: their data, logs and settings end up in another
+// %LOCALAPPDATA% and the next normal start looks empty. The launcher warns
+// and offers to start again unelevated through Explorer, which always
+// starts programs with the signed-in user's normal token. allow_elevated
+// in launcher.json turns this off for installs that need admin rights.
+const tokenElevation = 20 // TOKEN_INFORMATION_CLASS TokenElevation
+
+var procShellExecuteExW = shell32.NewProc("ShellExecuteExW")
+
+// isElevated reports whether the launcher runs with an elevated token
+func isElevated() bool {
+	var token syscall.Token
+	process, _ := syscall.GetCurrentProcess()
+	if err := syscall.OpenProcessToken(process, syscall.TOKEN_QUERY, &token); err != nil {
+		return false
+	}
+	defer token.Close()
+	var elevated, size uint32
+	if err := syscall.GetTokenInformation(token, tokenElevation, (*byte)(unsafe.Pointer(&elevated)), uint32(unsafe.Sizeof(elevated)), &size); err != nil {
+		return false
+	}
+	return elevated != 0
+}
+
+// checkElevation warns about an elevated start and offers to start again
+// as the normal user. It returns false if this launcher should exit.
+func checkElevation(config *AppConfig, exePath string, interactive bool) bool {
+	if config.Settings.AllowElevated || !isElevated() {
+		return true
+	}
+	logWarn("launcher", "launcher is running as administrator")
+	if !interactive || machineOutput() {
+		consoleWarnf("running as administrator; data is written to the administrator's profile")
+		return true
+	}
+
+	answer := messageBox(config.AppName,
+		tr("%s was started as administrator. Your data and settings would be saved in the administrator's profile instead of yours.\n\nStart it normally instead?\n\nYes restarts it normally, No continues as administrator, Cancel quits.", config.AppName),
+		mbYesNoCancel|mbIconWarning|mbTopmost)
+	switch answer {
+	case idYes:
+		// Command-line arguments cannot be passed through Explorer
+		if err := exec.Command("explorer.exe", exePath).Start(); err != nil {
+			showError("Failed to restart normally", err)
+			return false
+		}
+		logInfo("launcher", "restarted unelevated through explorer")
+		return false
+	case idNo:
+		logWarn("launcher", "continuing as administrator")
+		return true
+	default:
+		return false
+	}
+}
+
+// SHELLEXECUTEINFOW
+type shellExecuteInfo struct {
+	size       uint32
+	mask       uint32
+	hwnd       uintptr
+	verb       *uint16
+	file       *uint16
+	parameters *uint16
+	directory  *uint16
+	show       int32
+	instApp    uintptr
+	idList     uintptr
+	class      *uint16
+	keyClass   uintptr
+	hotKey     uint32
+	icon       uintptr
+	process    syscall.Handle
+}
+
+const (
+	seeMaskNoCloseProcess = 0x00000040
+	seeMaskNoAsync        = 0x00000100
+	errorCancelled        = 1223
+)
+
+// runElevated starts exe with the "runas" verb, which shows the UAC
+// prompt, and returns its exit code
+func runElevated(exe string, args []string) (uint32, error) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = syscall.EscapeArg(arg)
+	}
+	info := shellExecuteInfo{
+		mask:       seeMaskNoCloseProcess | seeMaskNoAsync,
+		verb:       syscall.StringToUTF16Ptr("runas"),
+		file:       syscall.StringToUTF16Ptr(exe),
+		parameters: syscall.StringToUTF16Ptr(strings.Join(quoted, " ")),
+		show:       swHide,
+	}
+	info.size = uint32(unsafe.Sizeof(info))
+	if ret, _, err := procShellExecuteExW.Call(uintptr(unsafe.Pointer(&info))); ret == 0 {
+		if errors.Is(err, syscall.Errno(errorCancelled)) {
+			return 0, errors.New("administrator permission was refused")
+		}
+		return 0, err
+	}
+	defer syscall.CloseHandle(info.process)
+	if _, err := syscall.WaitForSingleObject(info.process, syscall.INFINITE); err != nil {
+		return 0, err
+	}
+	var code uint32
+	if err := syscall.GetExitCodeProcess(info.process, &code); err != nil {
+		return 0, err
+	}
+	return code, nil
+}
+
+// canWrite reports whether this process can create files in dir, which is
+// false for a per-machine install in Program Files
+func canWrite(dir string) bool {
+	file, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return false
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return true
+}
+
+// runElevatedStep runs one launcher command as administrator and waits
+// for it, so a step that writes to Program Files does not need the whole
+// launcher elevated. Windows shows its UAC prompt.
+func runElevatedStep(args ...string) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logInfo("launcher", "running step as administrator", "command", strings.Join(args, " "))
+	code, err := runElevated(exePath, args)
+	if err != nil {
+		return err
+	}
+	if code != exitOK {
+		return fmt.Errorf("\"launcher %s\" as administrator failed with exit code %d", strings.Join(args, " "), code)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+	procRegCreateKeyExW       = advapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueExW        = advapi32.NewProc("RegSetValueExW")
+)
+
+const eventSourceName = "WAP Launcher"
+
+// Event types as defined by ReportEvent
+const (
+	eventTypeError   = 0x0001
+	eventTypeWarning = 0x0002
+	eventTypeInfo    = 0x0004
+)
+
+// Event IDs written to the Application log. They stay below 1000 so the
+// generic EventCreate.exe message file can render them.
+const (
+	eventIDStartupFailure  = 100
+	eventIDBackendCrash    = 200
+	eventIDLogAlert        = 210
+	eventIDShutdownAnomaly = 300
+	eventIDFrontendHang    = 310
+	eventIDResourceLimit   = 320
+	eventIDIntegrity       = 400
+	eventIDDiskHealth      = 410
+	eventIDDataCorrupt     = 420
+	eventIDBackendExposed  = 430
+)
+
+// registerEventSource adds the registry entry that lets Event Viewer show
+// our messages without the "description cannot be found" preamble. This
+// needs admin rights, so it normally succeeds only when run by the
+// installer or an elevated launcher; failures are ignored.
+func registerEventSource() {
+	keyPath := `SYSTEM\CurrentControlSet\Services\EventLog\Application\` + eventSourceName
+
+	var key syscall.Handle
+	ret, _, _ := procRegCreateKeyExW.Call(
+		uintptr(syscall.HKEY_LOCAL_MACHINE),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(keyPath))),
+		0, 0, 0,
+		uintptr(syscall.KEY_WRITE),
+		0,
+		uintptr(unsafe.Pointer(&key)),
+		0,
+	)
+	if ret != 0 {
+		return
+	}
+	defer syscall.RegCloseKey(key)
+
+	messageFile, _ := syscall.UTF16FromString(`%SystemRoot%\System32\EventCreate.exe`)
+	procRegSetValueExW.Call(uintptr(key),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr("EventMessageFile"))), 0,
+		syscall.REG_EXPAND_SZ,
+		uintptr(unsafe.Pointer(&messageFile[0])), uintptr(len(messageFile)*2))
+
+	types := uint32(eventTypeError | eventTypeWarning | eventTypeInfo)
+	procRegSetValueExW.Call(uintptr(key),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr("TypesSupported"))), 0,
+		syscall.REG_DWORD,
+		uintptr(unsafe.Pointer(&types)), unsafe.Sizeof(types))
+}
+
+// reportEvent writes a message to the Windows Application event log so
+// fleet monitoring picks up failures without reading files under bin/.
+func reportEvent(eventType uint16, eventID uint32, message string) {
+	source, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(eventSourceName))))
+	if source == 0 {
+		logWarn("eventlog", "failed to open event source", "error", err)
+		return
+	}
+	defer procDeregisterEventSource.Call(source)
+
+	messagePtr := syscall.StringToUTF16Ptr(message)
+	ret, _, err := procReportEventW.Call(
+		source,
+		uintptr(eventType),
+		0, // category
+		uintptr(eventID),
+		0, // user SID
+		1, // number of strings
+		0, // raw data size
+		uintptr(unsafe.Pointer(&messagePtr)),
+		0,
+	)
+	if ret == 0 {
+		logWarn("eventlog", "failed to write event", "event_id", eventID, "error", err)
+	}
+}
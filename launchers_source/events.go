@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Lifecycle events written by --events-json. Unlike --json-progress, which
+// follows startup phase by phase, these describe the state of the running
+// application for wrappers that watch it for its whole lifetime.
+const (
+	eventStarted  = "started"
+	eventReady    = "ready"
+	eventDegraded = "degraded"
+	eventStopped  = "stopped"
+)
+
+// lifecycleEvent is one line of --events-json output:
+//
+//	{"time":"...","event":"started","service":"backend","pid":4312}
+//	{"time":"...","event":"degraded","service":"backend","reason":"crashed"}
+//	{"time":"...","event":"stopped","service":"launcher","reason":"user","exit_code":0}
+type lifecycleEvent struct {
+	Time     string `json:"time"`
+	Event    string `json:"event"`
+	Service  string `json:"service,omitempty"` // launcher, backend or frontend
+	PID      int    `json:"pid,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+}
+
+const serviceLauncher = "launcher"
+
+var events struct {
+	enabled    bool
+	stopReason string
+	lastError  string
+}
+
+func setEventsJSON(enabled bool) {
+	console.mu.Lock()
+	events.enabled = enabled
+	console.mu.Unlock()
+}
+
+func emitEvent(event lifecycleEvent) {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	if !events.enabled {
+		return
+	}
+	event.Time = time.Now().Format(time.RFC3339Nano)
+	if data, err := json.Marshal(event); err == nil {
+		os.Stdout.Write(append(data, '\n'))
+	}
+}
+
+func emitDegraded(service, reason, message string) {
+	emitEvent(lifecycleEvent{Event: eventDegraded, Service: service, Reason: reason, Message: message})
+}
+
+// noteStopReason remembers why the launcher is shutting down for the
+// final stopped event
+func noteStopReason(reason string) {
+	console.mu.Lock()
+	events.stopReason = reason
+	console.mu.Unlock()
+}
+
+// noteError remembers the error that made the launcher give up
+func noteError(message string) {
+	console.mu.Lock()
+	events.lastError = message
+	console.mu.Unlock()
+}
+
+// lastNotedError returns the error passed to noteError, if any
+func lastNotedError() string {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	return events.lastError
+}
+
+// emitLauncherStopped is the last event. Failures are named after their
+// exit code; a normal exit carries the stop reason, if there was one.
+func emitLauncherStopped(code int) {
+	console.mu.Lock()
+	reason, message := events.stopReason, events.lastError
+	console.mu.Unlock()
+	if code != exitOK || reason == "" {
+		reason = exitCodeNames[code]
+	}
+	emitEvent(lifecycleEvent{Event: eventStopped, Service: serviceLauncher, PID: os.Getpid(), Reason: reason, Message: message, ExitCode: &code})
+}
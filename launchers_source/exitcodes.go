@@ -0,0 +1,81 @@
+package main
+
+// Launcher exit codes. Installers, kiosk wrappers and monitoring scripts
+// rely on these, so existing values must never change meaning; add new
+// ones at the end.
+//
+//	 0  success, the application ran and was closed normally
+//	 1  internal launcher error (or a subcommand failed)
+//	 2  invalid command line
+//	10  required application files are missing
+//	11  the Python backend could not be started
+//	12  the Python backend never became healthy
+//	13  the Flutter application could not be started
+//	14  an update failed to install or start and is being rolled back
+//	15  launcher.json or the demo configuration is invalid
+//	16  the bundled payload could not be extracted
+//	17  the demo period has ended
+//	18  agent mode could not be started
+//	19  damaged files were found and not repaired
+//	20  "launcher wait" timed out before the condition was met
+//	21  the data was written by a newer version of the application
+//	22  migrating the data to this version failed
+//	23  the Flutter application started twice without showing a window
+//	24  Windows is too old or a runtime prerequisite is missing
+//	25  the launcher is already running for this profile
+//	26  a pre_backend_start or post_healthy hook with on_failure "abort" failed
+//	27  a startup step compiled into the launcher failed
+//	28  a sidecar service the backend needs did not start
+//	29  the application did not finish starting within startup.timeout_seconds
+const (
+	exitOK               = 0
+	exitLauncherError    = 1
+	exitUsage            = 2
+	exitMissingFiles     = 10
+	exitBackendStart     = 11
+	exitBackendUnhealthy = 12
+	exitFrontendStart    = 13
+	exitUpdateFailed     = 14
+	exitConfigInvalid    = 15
+	exitPayloadFailed    = 16
+	exitDemoExpired      = 17
+	exitAgentFailed      = 18
+	exitIntegrityFailed  = 19
+	exitWaitTimeout      = 20
+	exitDataTooNew       = 21
+	exitDataMigration    = 22
+	exitFrontendHung     = 23
+	exitPrerequisites    = 24
+	exitAlreadyRunning   = 25
+	exitHookFailed       = 26
+	exitStepFailed       = 27
+	exitSidecarFailed    = 28
+	exitStartupTimeout   = 29
+)
+
+// exitCodeNames are the reasons reported with exit codes in --events-json
+var exitCodeNames = map[int]string{
+	exitOK:               "exited",
+	exitLauncherError:    "launcher_error",
+	exitUsage:            "usage",
+	exitMissingFiles:     "missing_files",
+	exitBackendStart:     "backend_start_failed",
+	exitBackendUnhealthy: "backend_unhealthy",
+	exitFrontendStart:    "frontend_start_failed",
+	exitUpdateFailed:     "update_failed",
+	exitConfigInvalid:    "config_invalid",
+	exitPayloadFailed:    "payload_failed",
+	exitDemoExpired:      "demo_expired",
+	exitAgentFailed:      "agent_failed",
+	exitIntegrityFailed:  "integrity_failed",
+	exitWaitTimeout:      "wait_timeout",
+	exitDataTooNew:       "data_too_new",
+	exitDataMigration:    "data_migration_failed",
+	exitFrontendHung:     "frontend_no_window",
+	exitPrerequisites:    "prerequisite_missing",
+	exitAlreadyRunning:   "already_running",
+	exitHookFailed:       "hook_failed",
+	exitStepFailed:       "step_failed",
+	exitSidecarFailed:    "sidecar_failed",
+	exitStartupTimeout:   "startup_timeout",
+}
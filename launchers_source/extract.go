@@ -0,0 +1,281 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// extractJournalName records finished entries so an interrupted first-run
+// extraction resumes instead of starting over
+const extractJournalName = ".extract-journal"
+
+type extractOptions struct {
+	Include  func(name string) bool  // nil extracts everything
+	Progress func(done, total int64) // called from one goroutine at a time
+	Journal  string                  // resume journal path, "" disables resume
+	Version  string                  // journals of other payload versions are discarded
+	Workers  int                     // defaults to the number of CPUs
+	Store    *contentStore           // large files are hardlinked from here
+}
+
+// extractZip unpacks archive into dest with a pool of workers. Every entry
+// is checked against its CRC by archive/zip and, when the archive carries a
+// manifest.json, against its SHA-256. Entries may not escape dest.
+func extractZip(archive, dest string, opts extractOptions) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("failed to open payload archive: %w", err)
+	}
+	defer reader.Close()
+
+	hashes, err := archiveManifestHashes(&reader.Reader)
+	if err != nil {
+		return err
+	}
+
+	finished := readExtractJournal(opts.Journal, opts.Version)
+	journal, err := openExtractJournal(opts.Journal, opts.Version, len(finished) > 0)
+	if err != nil {
+		return err
+	}
+	defer journal.close()
+
+	var files []*zip.File
+	var total int64
+	for _, f := range reader.File {
+		if opts.Include != nil && !opts.Include(f.Name) {
+			continue
+		}
+		total += int64(f.UncompressedSize64)
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, f)
+	}
+
+	var (
+		mu       sync.Mutex
+		done     int64
+		firstErr error
+	)
+	report := func(n int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		done += n
+		if opts.Progress != nil {
+			opts.Progress(done, total)
+		}
+	}
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	queue := make(chan *zip.File)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range queue {
+				if failed() {
+					continue
+				}
+				target, err := safeJoin(dest, f.Name)
+				if err != nil {
+					fail(err)
+					continue
+				}
+
+				// Resume: trust entries the journal says are complete if the
+				// file on disk still has the right size
+				if finished[f.Name] {
+					if info, err := os.Stat(target); err == nil && info.Size() == int64(f.UncompressedSize64) {
+						report(info.Size())
+						continue
+					}
+				}
+
+				var written int64
+				if hash := hashes[f.Name]; opts.Store.wants(int64(f.UncompressedSize64), hash) {
+					written, err = extractViaStore(opts.Store, f, target, hash)
+				} else {
+					written, err = extractVerifiedEntry(f, target, hash)
+				}
+				if err != nil {
+					fail(fmt.Errorf("failed to extract %s: %w", f.Name, err))
+					continue
+				}
+				journal.record(f.Name)
+				report(written)
+			}
+		}()
+	}
+
+	for _, f := range files {
+		queue <- f
+	}
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
+
+// archiveManifestHashes reads manifest.json from the archive, if any, and
+// maps archive entry names to their expected SHA-256
+func archiveManifestHashes(reader *zip.Reader) (map[string]string, error) {
+	hashes := map[string]string{}
+	for _, f := range reader.File {
+		if f.Name != manifestName {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+
+		var manifest fileManifest
+		if err := json.NewDecoder(src).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("invalid %s in payload: %w", manifestName, err)
+		}
+		for rel, entry := range manifest.Files {
+			hashes[rel] = entry.SHA256
+		}
+		break
+	}
+	return hashes, nil
+}
+
+func extractVerifiedEntry(f *zip.File, target, expectedSHA256 string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	tmp := target + ".partial"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(dst, hash), src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && expectedSHA256 != "" && !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), expectedSHA256) {
+		err = fmt.Errorf("checksum mismatch")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return written, err
+	}
+	return written, os.Rename(tmp, target)
+}
+
+// extractViaStore puts the entry into the content store, unless an
+// identical blob is already there, and links it into place.
+func extractViaStore(store *contentStore, f *zip.File, target, hash string) (int64, error) {
+	if !store.has(hash) {
+		err := store.put(hash, func(w io.Writer) error {
+			src, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			_, err = io.Copy(w, src)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int64(f.UncompressedSize64), store.link(hash, target)
+}
+
+type extractJournal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// The journal's first line is the payload version, followed by one
+// finished entry name per line.
+func readExtractJournal(path, version string) map[string]bool {
+	finished := map[string]bool{}
+	if path == "" {
+		return finished
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return finished
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || scanner.Text() != "version "+version {
+		return finished
+	}
+	for scanner.Scan() {
+		finished[scanner.Text()] = true
+	}
+	return finished
+}
+
+func openExtractJournal(path, version string, resume bool) (*extractJournal, error) {
+	if path == "" {
+		return &extractJournal{}, nil
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open extraction journal: %w", err)
+	}
+	if !resume {
+		fmt.Fprintln(file, "version "+version)
+	}
+	return &extractJournal{file: file}, nil
+}
+
+func (j *extractJournal) record(name string) {
+	if j.file == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fmt.Fprintln(j.file, name)
+}
+
+func (j *extractJournal) close() {
+	if j.file != nil {
+		j.file.Close()
+	}
+}
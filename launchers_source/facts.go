@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"unsafe"
+)
+
+var (
+	ntdll = syscall.NewLazyDLL("ntdll.dll")
+
+	procRtlGetVersion        = ntdll.NewProc("RtlGetVersion")
+	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
+)
+
+// machineFacts are the variables available to templates in launcher.json
+// values, e.g. "WORKERS": "{{.Cores}}" or "{{.Hostname}}-kiosk".
+type machineFacts struct {
+	Hostname  string
+	User      string
+	Cores     int
+	RAMMB     int64
+	RAMGB     int64
+	OSVersion string // e.g. 10.0.22631
+	DeviceID  string // Windows MachineGuid, stable across reboots
+}
+
+type osVersionInfo struct {
+	Size         uint32
+	MajorVersion uint32
+	MinorVersion uint32
+	BuildNumber  uint32
+	PlatformID   uint32
+	CSDVersion   [128]uint16
+}
+
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+var (
+	factsOnce sync.Once
+	facts     machineFacts
+)
+
+// currentMachineFacts gathers the facts once per process
+func currentMachineFacts() machineFacts {
+	factsOnce.Do(func() {
+		facts.Hostname, _ = os.Hostname()
+		facts.User = os.Getenv("USERNAME")
+		facts.Cores = runtime.NumCPU()
+
+		mem := memoryStatusEx{}
+		mem.Length = uint32(unsafe.Sizeof(mem))
+		if ret, _, _ := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&mem))); ret != 0 {
+			facts.RAMMB = int64(mem.TotalPhys >> 20)
+			facts.RAMGB = int64((mem.TotalPhys + 1<<29) >> 30)
+		}
+
+		version := osVersionInfo{}
+		version.Size = uint32(unsafe.Sizeof(version))
+		if ret, _, _ := procRtlGetVersion.Call(uintptr(unsafe.Pointer(&version))); ret == 0 {
+			facts.OSVersion = fmt.Sprintf("%d.%d.%d", version.MajorVersion, version.MinorVersion, version.BuildNumber)
+		}
+
+		facts.DeviceID = readMachineGUID()
+	})
+	return facts
+}
+
+func readMachineGUID() string {
+	var key syscall.Handle
+	path := syscall.StringToUTF16Ptr(`SOFTWARE\Microsoft\Cryptography`)
+	// KEY_WOW64_64KEY so a 32-bit launcher sees the real value
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, path, 0, syscall.KEY_READ|0x0100, &key); err != nil {
+		return ""
+	}
+	defer syscall.RegCloseKey(key)
+
+	buf := make([]uint16, 64)
+	size := uint32(len(buf) * 2)
+	var valueType uint32
+	if err := syscall.RegQueryValueEx(key, syscall.StringToUTF16Ptr("MachineGuid"), nil, &valueType, (*byte)(unsafe.Pointer(&buf[0])), &size); err != nil {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
+
+var templateFuncs = template.FuncMap{
+	"env":   os.Getenv,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"sub":   func(a, b int) int { return a - b },
+	"div":   func(a, b int) int { return a / max(b, 1) },
+	"max":   func(a, b int) int { return max(a, b) },
+	"min":   func(a, b int) int { return min(a, b) },
+}
+
+// expandTemplate renders s with the machine facts. Strings without "{{"
+// are returned unchanged.
+func expandTemplate(s string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := template.New("value").Funcs(templateFuncs).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, currentMachineFacts()); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// expandTemplates renders every string inside a decoded JSON document
+func expandTemplates(value interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		expanded, err := expandTemplate(v)
+		if err != nil {
+			return nil, fmt.Errorf("template in %s: %w", path, err)
+		}
+		return expanded, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// Rendered when the child starts, see childenv.go
+			if childEnvSettings[path+"."+key] {
+				continue
+			}
+			expanded, err := expandTemplates(v[key], path+"."+key)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = expanded
+		}
+	case []interface{}:
+		for i := range v {
+			expanded, err := expandTemplates(v[i], fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	}
+	return value, nil
+}
+
+// envList turns a name -> value map into sorted KEY=value entries
+func envList(vars map[string]string) []string {
+	env := make([]string, 0, len(vars))
+	for name, value := range vars {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
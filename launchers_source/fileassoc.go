@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// FileTypeSettings list the file types "launcher register-file-type"
+// associates with the application, so double-clicking one opens it:
+//
+//	"file_types": [{"extension": ".wapx", "description": "WAP project", "icon": "bin\\data\\project.ico"}]
+//
+// Windows starts the launcher with --open-file and the path. Like a link
+// (protocol.go), the path goes to the app already running as WM_COPYDATA,
+// with dwData copyDataOpenFile, or is the last argument of a new app.
+type FileTypeSettings struct {
+	Extension   string `json:"extension"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"` // relative to the install folder, default the launcher's icon
+}
+
+// copyDataOpenFile marks a file path in WM_COPYDATA ("WAPF")
+const copyDataOpenFile = 0x57415046
+
+var procSHChangeNotify = shell32.NewProc("SHChangeNotify")
+
+const shcneAssocChanged = 0x08000000
+
+// openFile shows a file in the running app
+func (s *Session) openFile(path string) error {
+	if _, ok := s.config.Frontend.(browserRunner); ok {
+		return fmt.Errorf("files cannot be opened with the browser runner")
+	}
+	if err := s.sendToApp(copyDataOpenFile, path); err != nil {
+		return err
+	}
+	logInfo("protocol", "opened a file in the application", "path", path)
+	return nil
+}
+
+// fileTypeProgID is the registry class the extension points at
+func fileTypeProgID(config *AppConfig, extension string) string {
+	return strings.ReplaceAll(config.AppName, " ", "") + strings.ToLower(extension)
+}
+
+type registerFileTypeOptions struct {
+	remove bool
+}
+
+func (o *registerFileTypeOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.remove, "remove", false, "remove the association instead")
+}
+
+// runRegisterFileTypeCommand associates the extensions given, or those in
+// file_types, for the current user
+func runRegisterFileTypeCommand(config *AppConfig, args []string) int {
+	var opts registerFileTypeOptions
+	fs := newCommandFlagSet("register-file-type")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	fileTypes := config.Settings.FileTypes
+	if fs.NArg() > 0 {
+		fileTypes = nil
+		for _, extension := range fs.Args() {
+			fileType := FileTypeSettings{Extension: extension}
+			for _, configured := range config.Settings.FileTypes {
+				if strings.EqualFold(configured.Extension, extension) {
+					fileType = configured
+				}
+			}
+			fileTypes = append(fileTypes, fileType)
+		}
+	}
+	if len(fileTypes) == 0 {
+		fmt.Fprintln(os.Stderr, "No file types: name an extension such as .wapx or set file_types in launcher.json")
+		return exitUsage
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+	for _, fileType := range fileTypes {
+		extension := strings.ToLower(fileType.Extension)
+		if !strings.HasPrefix(extension, ".") || len(extension) < 2 || strings.ContainsAny(extension, `\/ `) {
+			fmt.Fprintf(os.Stderr, "Invalid extension %q (expected e.g. .wapx)\n", fileType.Extension)
+			return exitUsage
+		}
+		if opts.remove {
+			err = unregisterFileType(config, extension)
+		} else {
+			err = registerFileType(config, exe, fileType)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to update the %s association: %v\n", extension, err)
+			return exitLauncherError
+		}
+		if opts.remove {
+			fmt.Printf("✓ %s files no longer open %s\n", extension, config.AppName)
+		} else {
+			fmt.Printf("✓ %s files now open %s\n", extension, config.AppName)
+		}
+	}
+	// Explorer picks up the new icons and verbs without a restart
+	procSHChangeNotify.Call(shcneAssocChanged, 0, 0, 0)
+	return exitOK
+}
+
+func registerFileType(config *AppConfig, exe string, fileType FileTypeSettings) error {
+	extension := strings.ToLower(fileType.Extension)
+	progID := fileTypeProgID(config, extension)
+	description := fileType.Description
+	if description == "" {
+		description = fmt.Sprintf("%s file", config.AppName)
+	}
+	icon := fmt.Sprintf(`"%s",0`, exe)
+	if fileType.Icon != "" {
+		icon = installPath(config, fileType.Icon)
+	}
+	command := fmt.Sprintf(`"%s"`, exe)
+	if config.Profile != "" {
+		command += fmt.Sprintf(` --%s "%s"`, profileFlagName, config.Profile)
+	}
+	command += ` --open-file "%1"`
+
+	key := registryClassesKey + progID
+	values := []struct{ key, name, value string }{
+		{key, "", description},
+		{key + `\DefaultIcon`, "", icon},
+		{key + `\shell\open\command`, "", command},
+		{registryClassesKey + extension, "", progID},
+		{registryClassesKey + extension + `\OpenWithProgids`, progID, ""},
+	}
+	for _, v := range values {
+		if err := setRegistryString(syscall.HKEY_CURRENT_USER, v.key, v.name, v.value); err != nil {
+			return err
+		}
+	}
+	logInfo("protocol", "registered a file type", "extension", extension, "prog_id", progID, "command", command)
+	return nil
+}
+
+// unregisterFileType removes the application's class and, when it still
+// points there, the extension's default
+func unregisterFileType(config *AppConfig, extension string) error {
+	progID := fileTypeProgID(config, extension)
+	if current, err := readRegistryString(syscall.HKEY_CURRENT_USER, registryClassesKey+extension, ""); err == nil && current == progID {
+		if err := deleteRegistryTree(syscall.HKEY_CURRENT_USER, registryClassesKey+extension); err != nil {
+			return err
+		}
+	}
+	logInfo("protocol", "unregistered a file type", "extension", extension, "prog_id", progID)
+	return deleteRegistryTree(syscall.HKEY_CURRENT_USER, registryClassesKey+progID)
+}
+
+func readRegistryString(root syscall.Handle, path, name string) (string, error) {
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(root, syscall.StringToUTF16Ptr(path), 0, syscall.KEY_READ, &key); err != nil {
+		return "", err
+	}
+	defer syscall.RegCloseKey(key)
+	var kind, size uint32
+	if err := syscall.RegQueryValueEx(key, syscall.StringToUTF16Ptr(name), nil, &kind, nil, &size); err != nil {
+		return "", err
+	}
+	if kind != syscall.REG_SZ || size < 2 {
+		return "", nil
+	}
+	buffer := make([]uint16, size/2)
+	if err := syscall.RegQueryValueEx(key, syscall.StringToUTF16Ptr(name), nil, &kind, (*byte)(unsafe.Pointer(&buffer[0])), &size); err != nil {
+		return "", err
+	}
+	return syscall.UTF16ToString(buffer), nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// In LAN mode Windows Firewall either pops up its own question when the
+// proxy starts listening or silently drops the tablets' connections. With
+// the user's consent the launcher adds an inbound rule for the LAN port
+// instead (private and domain networks only) and removes it again when LAN
+// mode is turned off or by "launcher firewall remove" on uninstall.
+// lan.firewall is "ask" (the default), "allow" to add the rule without
+// asking, or "off" to leave the firewall alone. netsh needs admin rights,
+// so the change runs as an elevated step (elevation.go).
+const (
+	firewallAsk   = "ask"
+	firewallAllow = "allow"
+	firewallOff   = "off"
+
+	firewallStateName = "firewall.json"
+	firewallCommand   = "__firewall"
+)
+
+// firewallState remembers the rule the launcher added, or that the user
+// declined one for the port
+type firewallState struct {
+	Rule     string `json:"rule,omitempty"`
+	Port     int    `json:"port"`
+	Declined bool   `json:"declined,omitempty"`
+}
+
+func firewallStatePath(config *AppConfig) string {
+	return filepath.Join(config.StateDir, firewallStateName)
+}
+
+func firewallPolicy(config *AppConfig) string {
+	switch policy := strings.ToLower(config.Settings.LAN.Firewall); policy {
+	case firewallAllow, firewallOff:
+		return policy
+	default:
+		return firewallAsk
+	}
+}
+
+func firewallRuleName(config *AppConfig) string {
+	return config.AppName + " LAN access"
+}
+
+// firewallRuleExists asks netsh, which needs no admin rights to read
+func firewallRuleExists(name string) bool {
+	cmd := exec.Command("netsh", "advfirewall", "firewall", "show", "rule", "name="+name)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	return cmd.Run() == nil
+}
+
+// ensureFirewallRule makes sure LAN clients can reach port, asking first
+// unless lan.firewall says otherwise
+func ensureFirewallRule(config *AppConfig, port int) {
+	policy := firewallPolicy(config)
+	if policy == firewallOff {
+		return
+	}
+	var state firewallState
+	readJSONFile(firewallStatePath(config), &state)
+	name := firewallRuleName(config)
+	if state.Port == port && (state.Declined || (state.Rule == name && firewallRuleExists(name))) {
+		return
+	}
+
+	if policy == firewallAsk {
+		if machineOutput() {
+			logInfo("lan", "not asking about the firewall rule without a UI", "port", port)
+			return
+		}
+		answer := messageBox(config.AppName,
+			tr("Other devices connect to %s on port %d. Windows Firewall may block them.\n\nAdd a firewall rule that allows this port on private networks? Windows will ask for administrator permission.", config.AppName, port),
+			mbYesNo|mbIconQuestion|mbTopmost)
+		if answer != idYes {
+			logInfo("lan", "user declined the firewall rule", "port", port)
+			writeJSONFile(firewallStatePath(config), firewallState{Port: port, Declined: true})
+			return
+		}
+	}
+
+	if err := addFirewallRule(config, port); err != nil {
+		logWarn("lan", "failed to add the firewall rule", "port", port, "error", err)
+		consoleErrorf("Could not add the firewall rule: %v", err)
+		return
+	}
+	consoleSuccessf("Firewall allows port %d on private networks", port)
+}
+
+// addFirewallRule replaces the launcher's rule with one for port
+func addFirewallRule(config *AppConfig, port int) error {
+	name := firewallRuleName(config)
+	if err := runElevatedStep(firewallCommand, "add", name, strconv.Itoa(port)); err != nil {
+		return err
+	}
+	logInfo("lan", "added firewall rule", "rule", name, "port", port)
+	return writeJSONFile(firewallStatePath(config), firewallState{Rule: name, Port: port})
+}
+
+// removeFirewallRule deletes the rule the launcher added, if any
+func removeFirewallRule(config *AppConfig) error {
+	var state firewallState
+	readJSONFile(firewallStatePath(config), &state)
+	if state.Rule != "" && firewallRuleExists(state.Rule) {
+		if err := runElevatedStep(firewallCommand, "remove", state.Rule); err != nil {
+			return err
+		}
+		logInfo("lan", "removed firewall rule", "rule", state.Rule, "port", state.Port)
+	}
+	if err := os.Remove(firewallStatePath(config)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeUnusedFirewallRule cleans up after LAN mode was turned off
+func removeUnusedFirewallRule(config *AppConfig) {
+	var state firewallState
+	if readJSONFile(firewallStatePath(config), &state) != nil || state.Rule == "" || machineOutput() {
+		return
+	}
+	if err := removeFirewallRule(config); err != nil {
+		logWarn("lan", "failed to remove the firewall rule after LAN mode was turned off", "rule", state.Rule, "error", err)
+	}
+}
+
+// runFirewallWorker is the elevated half: "__firewall add NAME PORT" or
+// "__firewall remove NAME"
+func runFirewallWorker(config *AppConfig, args []string) int {
+	netsh := func(args ...string) error {
+		cmd := exec.Command("netsh", append([]string{"advfirewall", "firewall"}, args...)...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+		return cmd.Run()
+	}
+	switch {
+	case len(args) == 3 && args[0] == "add":
+		exePath, err := os.Executable()
+		if err != nil {
+			return exitLauncherError
+		}
+		netsh("delete", "rule", "name="+args[1])
+		if err := netsh("add", "rule", "name="+args[1], "dir=in", "action=allow", "protocol=TCP",
+			"localport="+args[2], "program="+exePath, "profile=private,domain"); err != nil {
+			return exitLauncherError
+		}
+	case len(args) == 2 && args[0] == "remove":
+		if err := netsh("delete", "rule", "name="+args[1]); err != nil {
+			return exitLauncherError
+		}
+	default:
+		return exitUsage
+	}
+	return exitOK
+}
+
+// runFirewallCommand implements "launcher firewall [status|add|remove]"
+func runFirewallCommand(config *AppConfig, args []string) int {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "add":
+		if err := addFirewallRule(config, lanPort(config)); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to add the firewall rule: %v\n", err)
+			return exitLauncherError
+		}
+		fmt.Printf("✓ Firewall allows port %d on private networks\n", lanPort(config))
+	case "remove":
+		if err := removeFirewallRule(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to remove the firewall rule: %v\n", err)
+			return exitLauncherError
+		}
+		fmt.Println("✓ No firewall rule is left")
+	case "status":
+		var state firewallState
+		readJSONFile(firewallStatePath(config), &state)
+		switch {
+		case state.Rule != "" && firewallRuleExists(state.Rule):
+			fmt.Printf("Firewall rule \"%s\" allows port %d\n", state.Rule, state.Port)
+		case state.Declined:
+			fmt.Printf("A firewall rule for port %d was declined\n", state.Port)
+		default:
+			fmt.Println("No firewall rule was added")
+		}
+	default:
+		printCommandHelp(os.Stderr, findCommand("firewall"))
+		return exitUsage
+	}
+	return exitOK
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// FrontendRestartSettings restarts only wap.exe when it crashes while the
+// backend is still healthy, so the backend and its in-memory state survive.
+// on_crash is "off" (the default, the launcher exits as before), "ask"
+// (a dialog offers the restart) or "auto".
+type FrontendRestartSettings struct {
+	OnCrash     string `json:"on_crash"`
+	MaxRestarts int    `json:"max_restarts"` // per launch, default 3
+}
+
+const defaultFrontendRestarts = 3
+
+// checkBackendHealth asks the health endpoint once
+func checkBackendHealth(config *AppConfig) bool {
+	return backendHealthy(healthURL(config, config.BackendURL))
+}
+
+func backendHealthy(url string) bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// restartCrashedFrontend decides whether the app that just exited with err
+// is started again. restarts is how many crash restarts this launch made.
+func restartCrashedFrontend(config *AppConfig, err error, restarts int) bool {
+	settings := config.Settings.FrontendRestart
+	switch settings.OnCrash {
+	case "", "off":
+		return false
+	case "ask", "auto":
+	default:
+		logWarn("frontend", "unknown frontend_restart.on_crash, not restarting", "value", settings.OnCrash)
+		return false
+	}
+	limit := settings.MaxRestarts
+	if limit <= 0 {
+		limit = defaultFrontendRestarts
+	}
+	if restarts >= limit {
+		logWarn("frontend", "flutter application keeps crashing, not restarting it again", "restarts", restarts)
+		notifyWebhook(webhookCrashLoop, fmt.Sprintf("The application crashed again after %d restarts and was not restarted", restarts), frontendLogName)
+		return false
+	}
+	if !checkBackendHealth(config) {
+		logWarn("frontend", "backend is not healthy, not restarting the flutter application")
+		return false
+	}
+
+	if settings.OnCrash == "ask" && !machineOutput() {
+		answer := messageBox(config.AppName,
+			tr("%s closed unexpectedly (%v).\n\nRestart it? The background service keeps running, so your work in progress is kept.", config.AppName, err),
+			mbYesNo|mbIconWarning|mbTopmost)
+		if answer != idYes {
+			return false
+		}
+	}
+	logInfo("frontend", "restarting crashed flutter application", "mode", settings.OnCrash, "restart", restarts+1)
+	return true
+}
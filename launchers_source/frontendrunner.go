@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// FrontendSettings choose the client shell the launcher supervises:
+//
+//	"frontend": {"runner": "flutter"}
+//	"frontend": {"runner": "executable", "executable": "bin\\shell\\WAP.exe", "args": ["--no-sandbox"]}
+//	"frontend": {"runner": "browser", "url": "/app"}
+//
+// flutter, the default, is bin\wap.exe. executable is any other desktop
+// shell, such as an Electron or Tauri binary, relative paths being
+// relative to the install folder; it gets the same WAP_* variables,
+// --app-args and window watchdog. browser opens the default browser at
+// the backend (url, when set, is a path below it or a full URL) and keeps
+// the backend running until Exit is chosen in the tray.
+type FrontendSettings struct {
+	Runner     string   `json:"runner"`
+	Executable string   `json:"executable"`
+	Args       []string `json:"args"`
+	URL        string   `json:"url"`
+}
+
+// Frontend runners
+const (
+	runnerFlutter = "flutter"
+	runnerBrowser = "browser"
+)
+
+// FrontendRunner knows how to start one kind of client. launchFrontend
+// adds the environment, secrets, logs and supervision.
+type FrontendRunner interface {
+	Name() string
+	// Command returns the app's process, not started, with its program,
+	// arguments and directory; nil for a client that is not a process of
+	// its own, such as a browser tab
+	Command(config *AppConfig, kiosk bool) (*exec.Cmd, error)
+	// Files are the files the app cannot start without
+	Files(config *AppConfig) []requiredFile
+}
+
+// selectFrontendRunner picks the runner launcher.json asks for
+func selectFrontendRunner(config *AppConfig) error {
+	settings := config.Settings.Frontend
+	switch strings.ToLower(settings.Runner) {
+	case "", runnerFlutter:
+		config.Frontend = flutterRunner{}
+	case runnerExecutable:
+		if settings.Executable == "" {
+			return fmt.Errorf("frontend.executable is required with the executable runner")
+		}
+		config.Frontend = shellRunner{path: installPath(config, settings.Executable), args: settings.Args}
+	case runnerBrowser:
+		config.Frontend = browserRunner{}
+	default:
+		return fmt.Errorf("unknown frontend.runner %q (flutter, executable or browser)", settings.Runner)
+	}
+	return nil
+}
+
+// frontendProgram is the file the app runs as, "" for the browser
+func frontendProgram(config *AppConfig) string {
+	switch r := config.Frontend.(type) {
+	case shellRunner:
+		return r.path
+	case browserRunner:
+		return ""
+	}
+	return config.AppExe
+}
+
+// flutterRunner runs bin\wap.exe
+type flutterRunner struct{}
+
+func (flutterRunner) Name() string { return runnerFlutter }
+
+func (flutterRunner) Command(config *AppConfig, kiosk bool) (*exec.Cmd, error) {
+	cmd := exec.Command(shortPath(config.AppExe), frontendArgs(config, kiosk)...)
+	cmd.Dir = shortPath(config.BinDir)
+	return cmd, nil
+}
+
+func (flutterRunner) Files(config *AppConfig) []requiredFile {
+	return []requiredFile{
+		{config.AppExe, "Main application (wap.exe)"},
+		{config.FlutterDLL, "Flutter DLL (flutter_windows.dll)"},
+	}
+}
+
+// shellRunner runs another desktop shell, e.g. Electron or Tauri
+type shellRunner struct {
+	path string
+	args []string
+}
+
+func (shellRunner) Name() string { return runnerExecutable }
+
+func (r shellRunner) Command(config *AppConfig, kiosk bool) (*exec.Cmd, error) {
+	args := append(append([]string{}, r.args...), frontendArgs(config, kiosk)...)
+	cmd := exec.Command(shortPath(r.path), args...)
+	cmd.Dir = shortPath(filepath.Dir(r.path))
+	return cmd, nil
+}
+
+func (r shellRunner) Files(config *AppConfig) []requiredFile {
+	return []requiredFile{{r.path, "Main application"}}
+}
+
+// browserRunner opens the backend in the default browser
+type browserRunner struct{}
+
+func (browserRunner) Name() string { return runnerBrowser }
+
+func (browserRunner) Command(config *AppConfig, kiosk bool) (*exec.Cmd, error) {
+	return nil, nil
+}
+
+func (browserRunner) Files(config *AppConfig) []requiredFile { return nil }
+
+// browserURL is where the browser runner points the browser. It is the
+// backend itself even behind the proxy, which wants a token header a
+// browser does not send.
+func browserURL(config *AppConfig) string {
+	base := config.BackendURL
+	url := config.Settings.Frontend.URL
+	switch {
+	case url == "":
+		return base + "/"
+	case strings.Contains(url, "://"):
+		return url
+	default:
+		return base + "/" + strings.TrimLeft(url, "/")
+	}
+}
+
+// launchBrowser opens the app in the browser once the backend answers.
+// There is no process to watch: the session lasts until it is stopped.
+func launchBrowser(config *AppConfig, session *Session) *frontendLaunch {
+	go func() {
+		select {
+		case <-session.Healthy():
+		case <-session.Stopping():
+			return
+		}
+		url := browserURL(config)
+		if config.OpenURL != "" {
+			url = linkURL(config, config.OpenURL)
+			config.OpenURL = ""
+		}
+		if err := openURL(url); err != nil {
+			logError("frontend", "failed to open the browser", "url", url, "error", err)
+			consoleErrorf("Failed to open %s in the browser: %v", url, err)
+		} else {
+			consoleSuccessf("Opened %s in the browser", url)
+			logInfo("frontend", "opened the application in the browser", "url", url)
+		}
+		session.splash.Close()
+		emitPhase(phaseFrontendStart, phaseDone, 100, "")
+		emitPhase(phaseRunning, phaseStarted, 100, url)
+		confirmUpdateStarted(config)
+	}()
+	return &frontendLaunch{exited: make(chan error)}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// GCSettings controls what "launcher gc" and the automatic daily run
+// remove. Retention values are in days.
+type GCSettings struct {
+	Auto            bool `json:"auto"`
+	LogRetention    int  `json:"log_retention_days"`    // default 14
+	BackupRetention int  `json:"backup_retention_days"` // default 30
+	RemovePrevious  bool `json:"remove_previous"`       // also delete bin.previous once confirmed
+}
+
+const (
+	backupsDirName         = "backups"
+	gcStampName            = ".last-gc"
+	defaultLogRetention    = 14
+	defaultBackupRetention = 30
+
+	// Staging leftovers younger than this may belong to a running update
+	gcGracePeriod = 24 * time.Hour
+)
+
+type gcItem struct {
+	path   string
+	reason string
+	size   int64
+}
+
+// collectGarbage lists what can be deleted without touching the running
+// version or an update that is pending or on trial.
+func collectGarbage(config *AppConfig, includePrevious bool) []gcItem {
+	settings := config.Settings.GC
+	logRetention := settings.LogRetention
+	if logRetention <= 0 {
+		logRetention = defaultLogRetention
+	}
+	backupRetention := settings.BackupRetention
+	if backupRetention <= 0 {
+		backupRetention = defaultBackupRetention
+	}
+
+	var items []gcItem
+	add := func(path, reason string) {
+		items = append(items, gcItem{path: path, reason: reason, size: diskUsage(path)})
+	}
+	now := time.Now()
+	olderThan := func(info fs.FileInfo, age time.Duration) bool {
+		return now.Sub(info.ModTime()) > age
+	}
+
+	// Content store blobs nothing links to any more
+	if store := openContentStore(config); store != nil {
+		filepath.WalkDir(filepath.Join(store.dir, "sha256"), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if strings.HasSuffix(path, ".partial") {
+				if olderThan(info, gcGracePeriod) {
+					add(path, "interrupted store write")
+				}
+				return nil
+			}
+			// A fresh blob may be an update's that is not linked in yet
+			if links, err := fileLinkCount(path); err == nil && links <= 1 && olderThan(info, gcGracePeriod) {
+				add(path, "unreferenced store blob")
+			}
+			return nil
+		})
+	}
+
+	// Staged updates nobody is going to install
+	updates := updatesDir(config)
+	staged := filepath.Join(config.RootDir, stagedBinName)
+	if info, err := os.Stat(staged); err == nil {
+		if _, err := os.Stat(filepath.Join(updates, pendingFileName)); err != nil && olderThan(info, gcGracePeriod) {
+			add(staged, "abandoned staged update")
+		}
+	}
+	if _, err := os.Stat(filepath.Join(config.RootDir, "bin.failed")); err == nil {
+		add(filepath.Join(config.RootDir, "bin.failed"), "failed update")
+	}
+	if entries, err := os.ReadDir(updates); err == nil {
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasSuffix(name, ".zip") && !strings.HasSuffix(name, ".partial") && !strings.HasPrefix(name, "manifest-") {
+				continue
+			}
+			if info, err := e.Info(); err == nil && olderThan(info, gcGracePeriod) {
+				add(filepath.Join(updates, name), "leftover download")
+			}
+		}
+	}
+
+	// The previous version is the rollback target while an update is on trial
+	if includePrevious || settings.RemovePrevious {
+		previous := filepath.Join(config.RootDir, previousBinName)
+		_, trialErr := os.Stat(filepath.Join(updates, trialFileName))
+		if _, err := os.Stat(previous); err == nil && trialErr != nil {
+			add(previous, "previous version")
+		}
+	}
+
+	if entries, err := os.ReadDir(backupsDir(config)); err == nil {
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && olderThan(info, time.Duration(backupRetention)*24*time.Hour) {
+				add(filepath.Join(backupsDir(config), e.Name()), "expired backup")
+			}
+		}
+	}
+
+	// Archived logs are named like launcher.log.1 or python_server.log.2026-01-31
+	if entries, err := os.ReadDir(config.LogDir); err == nil {
+		for _, e := range entries {
+			if e.IsDir() || !strings.Contains(e.Name(), ".log.") {
+				continue
+			}
+			if info, err := e.Info(); err == nil && olderThan(info, time.Duration(logRetention)*24*time.Hour) {
+				add(filepath.Join(config.LogDir, e.Name()), "archived log")
+			}
+		}
+	}
+
+	if entries, err := os.ReadDir(filepath.Join(config.LogDir, tracesDirName)); err == nil {
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil && olderThan(info, time.Duration(logRetention)*24*time.Hour) {
+				add(filepath.Join(config.LogDir, tracesDirName, e.Name()), "old trace")
+			}
+		}
+	}
+
+	return items
+}
+
+// fileLinkCount returns the number of hardlinks to path
+func fileLinkCount(path string) (uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &info); err != nil {
+		return 0, err
+	}
+	return info.NumberOfLinks, nil
+}
+
+// diskUsage returns the size of a file or everything below a directory
+func diskUsage(path string) int64 {
+	var total int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+func removeGarbage(items []gcItem) (int64, []error) {
+	var reclaimed int64
+	var errs []error
+	for _, item := range items {
+		if err := os.RemoveAll(item.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", item.path, err))
+			continue
+		}
+		reclaimed += item.size
+	}
+	return reclaimed, errs
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+}
+
+// startBackgroundGC runs the automatic policy at most once a day. The
+// stamp lives with the user's state, as the install folder may be
+// read-only.
+func startBackgroundGC(config *AppConfig) {
+	if !config.Settings.GC.Auto {
+		return
+	}
+	stamp := filepath.Join(config.StateDir, gcStampName)
+	if info, err := os.Stat(stamp); err == nil && time.Since(info.ModTime()) < 24*time.Hour {
+		return
+	}
+	go func() {
+		if err := os.WriteFile(stamp, []byte(time.Now().Format(time.RFC3339)), 0644); err != nil {
+			logWarn("gc", "failed to record the collection time", "error", err)
+		}
+		reclaimed, errs := removeGarbage(collectGarbage(config, false))
+		for _, err := range errs {
+			logWarn("gc", "failed to remove", "error", err)
+		}
+		if reclaimed > 0 {
+			logInfo("gc", "reclaimed disk space", "bytes", reclaimed)
+		}
+	}()
+}
+
+type gcOptions struct {
+	dryRun   bool
+	previous bool
+}
+
+func (o *gcOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.dryRun, "dry-run", false, "only list what would be removed")
+	fs.BoolVar(&o.previous, "previous", false, "also remove the previous version kept for rollback")
+}
+
+// runGCCommand implements "launcher gc [--dry-run] [--previous]"
+func runGCCommand(config *AppConfig, args []string) int {
+	var opts gcOptions
+	fs := newCommandFlagSet("gc")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	items := collectGarbage(config, opts.previous)
+	if len(items) == 0 {
+		fmt.Println("✓ Nothing to clean up")
+		return exitOK
+	}
+
+	var total int64
+	for _, item := range items {
+		fmt.Printf("%-26s %10s  %s\n", item.reason, formatBytes(item.size), item.path)
+		total += item.size
+	}
+	if opts.dryRun {
+		fmt.Printf("%d item(s), %s would be reclaimed\n", len(items), formatBytes(total))
+		return exitOK
+	}
+
+	reclaimed, errs := removeGarbage(items)
+	for _, err := range errs {
+		fmt.Printf("❌ %v\n", err)
+	}
+	fmt.Printf("✓ Reclaimed %s\n", formatBytes(reclaimed))
+	if len(errs) > 0 {
+		return exitLauncherError
+	}
+	return exitOK
+}
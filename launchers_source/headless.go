@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// A backend that crashes this often within headlessCrashWindow is not
+	// restarted again
+	headlessMaxCrashes  = 5
+	headlessCrashWindow = 5 * time.Minute
+)
+
+// backendPort returns the port of config.BackendURL
+func backendPort(config *AppConfig) int {
+	return urlPort(config.BackendURL)
+}
+
+// urlPort returns the port of an http(s)://host:port URL, 0 if it has none
+func urlPort(raw string) int {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+// setBackendPort points config.BackendURL at port; 0 picks a free one
+func setBackendPort(config *AppConfig, port int) error {
+	if port == 0 {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return fmt.Errorf("failed to find a free port: %w", err)
+		}
+		port = listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+	}
+	config.BackendURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+	return nil
+}
+
+// runHeadless starts only the Python backend and keeps it running,
+// restarting it after crashes, until Ctrl+C or a stop command.
+func runHeadless(config *AppConfig, control *controlServer, demoEnd time.Time) int {
+	// The headless backend is the service itself, never left behind warm
+	config.Settings.WarmBackend.Enabled = false
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			consolePrintln("Stopping...")
+			requestLauncherExit()
+		}
+	}()
+
+	var crashes []time.Time
+	for {
+		session := newSession(config)
+		session.control = control
+
+		// Restarting is stopping the backend and letting the loop below
+		// start it again
+		var restarting atomic.Bool
+		control.SetServiceHandler(func(command serviceCommand) error {
+			if command.Action == "open" || command.Action == "show" {
+				return fmt.Errorf("there is no app to open links or files in headless mode")
+			}
+			if command.Action == "restart" && session.workers.find(command.Service) != nil {
+				return session.workers.restart(command.Service)
+			}
+			if command.Action != "restart" || command.Service != serviceBackend {
+				return fmt.Errorf("only the backend can be restarted in headless mode")
+			}
+			restarting.Store(true)
+			go session.stopBackend()
+			return nil
+		})
+
+		control.resetProgress()
+		control.SetStage(stageStartingServer, "")
+		emitPhase(phaseBackendStart, phaseStarted, 0, "")
+		if err := runHooks(config, hookPreBackendStart); err != nil {
+			showError("A startup hook failed", err)
+			return exitHookFailed
+		}
+		pythonProcess, err := startBackend(config, control)
+		if err != nil {
+			showError("Failed to start Python backend", err)
+			return exitBackendStart
+		}
+		session.watchBackend(pythonProcess)
+		emitPhase(phaseBackendStart, phaseDone, 100, "")
+
+		emitPhase(phaseBackendHealth, phaseStarted, 0, "")
+		if err := waitForBackendHealthy(session, backendStartTimeout); err != nil {
+			session.stopBackend()
+			if session.StopReason() != "" {
+				return exitOK
+			}
+			if rollBackFailedUpdate(config, false) {
+				return exitUpdateFailed
+			}
+			showBackendError(config, "Python backend did not start", err)
+			return exitBackendUnhealthy
+		}
+		// A healthy backend is all a headless start has to show
+		confirmUpdateStarted(config)
+		if err := checkBackendSession(session); err != nil {
+			session.stopBackend()
+			showError("The backend's port belongs to another user", err)
+			return exitBackendUnhealthy
+		}
+		checkBackendBinding(session, false)
+		emitPhase(phaseBackendHealth, phaseDone, 100, "")
+		if err := runHooks(config, hookPostHealthy); err != nil {
+			session.stopBackend()
+			showError("A startup hook failed", err)
+			return exitHookFailed
+		}
+		session.workers = startWorkers(session)
+		control.SetStage(stageReady, "")
+
+		publicURL := config.BackendURL
+		if config.Proxy != nil {
+			publicURL = config.Proxy.url
+		}
+		consoleSuccessf("Backend listening on %s", publicURL)
+		consolePrintln("Press Ctrl+C to stop")
+		logInfo("headless", "backend ready", "url", publicURL)
+		emitPhase(phaseRunning, phaseStarted, 100, publicURL)
+		if !demoEnd.IsZero() {
+			startDemoTimer(session, demoEnd)
+		}
+		stopResumeCheck := onResume(func() {
+			session.checkBackendAfterResume(func() {
+				restarting.Store(true)
+				session.stopBackend()
+			})
+		})
+
+		select {
+		case <-session.Stopping():
+			stopResumeCheck()
+			runHooks(config, hookPreShutdown)
+			session.workers.Close()
+			session.stopBackend()
+			backupOnExit(config)
+			emitPhase(phaseRunning, phaseDone, 100, "")
+			if session.StopReason() == stopReasonDemoExpired {
+				return exitDemoExpired
+			}
+			return exitOK
+		case <-session.backendExited():
+		}
+		stopResumeCheck()
+		control.SetServiceHandler(nil)
+		if restarting.Load() {
+			consolePrintln("Restarting Python backend...")
+			logInfo("headless", "restarting backend on request")
+			session.RequestStop(stopReasonRestart)
+			continue
+		}
+
+		// The backend crashed; watchBackend has already reported it
+		session.RequestStop(stopReasonCrashed)
+		now := time.Now()
+		crashes = append(crashes, now)
+		for len(crashes) > 0 && now.Sub(crashes[0]) > headlessCrashWindow {
+			crashes = crashes[1:]
+		}
+		if len(crashes) >= headlessMaxCrashes {
+			notifyWebhook(webhookCrashLoop, fmt.Sprintf("The backend crashed %d times within %s and was not restarted again", len(crashes), headlessCrashWindow), backendLogName)
+			showBackendError(config, "Python backend keeps crashing", fmt.Errorf("%d crashes within %s, see %s", len(crashes), headlessCrashWindow, backendLogName))
+			return exitBackendUnhealthy
+		}
+		consolePrintln("Python backend exited unexpectedly, restarting...")
+		logWarn("headless", "restarting crashed backend", "recent_crashes", len(crashes))
+		notifyToast(config, toastBackendRestarted, tr("%s restarted its backend", config.AppName),
+			tr("The backend stopped unexpectedly and is being started again."))
+		select {
+		case <-time.After(time.Duration(len(crashes)) * 2 * time.Second):
+		case <-launcherExiting():
+			return exitOK
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// backendStartTimeout bounds how long the launcher waits for /health
+const backendStartTimeout = 90 * time.Second
+
+// waitForBackendHealthy polls the backend's /health endpoint until it
+// answers, the backend exits, or the session is stopped.
+func waitForBackendHealthy(session *Session, timeout time.Duration) error {
+	client := &http.Client{Timeout: 2 * time.Second}
+	healthURL := healthURL(session.config, session.config.BackendURL)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		resp, err := client.Get(healthURL)
+		if err == nil && chaosDropHealthCheck() {
+			resp.Body.Close()
+			err = errors.New("dropped by chaos mode")
+		}
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("backend did not become healthy within %s", timeout)
+		}
+		select {
+		case <-session.backendExited():
+			return errors.New("backend exited during startup, see " + backendLogName)
+		case <-session.Stopping():
+			return errors.New("startup cancelled")
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
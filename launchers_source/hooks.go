@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// HookSettings run site-specific commands at fixed points of a launch,
+// e.g. mounting a share before the backend starts or telling a system the
+// application is up:
+//
+//	"hooks": {
+//	  "pre_backend_start": [{"command": "net use S: \\\\files\\wap", "timeout_seconds": 30, "on_failure": "abort"}],
+//	  "post_healthy": [{"command": "scripts\\notify.cmd up"}]
+//	}
+//
+// Commands run through cmd.exe in the install folder, one after the other,
+// with WAP_HOOK, WAP_DATA_DIR, WAP_LOG_DIR, WAP_BACKEND_URL and WAP_PROFILE
+// set; post_exit hooks also get WAP_EXIT_CODE. Their output goes to
+// hooks.log.
+type HookSettings struct {
+	PreBackendStart []Hook `json:"pre_backend_start"` // before every backend start
+	PostHealthy     []Hook `json:"post_healthy"`      // once the backend first answers
+	PreShutdown     []Hook `json:"pre_shutdown"`      // before the backend is stopped
+	PostExit        []Hook `json:"post_exit"`         // as the launcher exits
+}
+
+// Hook is one command. A failure (an exit code other than 0 or the
+// timeout) is logged by on_failure "warn", the default, and skipped
+// silently by "ignore". "abort" stops a launch from a pre_backend_start or
+// post_healthy hook; the launch is over already for the others, so they
+// only warn.
+type Hook struct {
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeout_seconds"` // default 60
+	OnFailure      string `json:"on_failure"`      // warn, ignore or abort
+}
+
+// Hook points
+const (
+	hookPreBackendStart = "pre_backend_start"
+	hookPostHealthy     = "post_healthy"
+	hookPreShutdown     = "pre_shutdown"
+	hookPostExit        = "post_exit"
+)
+
+const (
+	hookFailIgnore = "ignore"
+	hookFailAbort  = "abort"
+
+	defaultHookTimeout = 60 * time.Second
+	hooksLogName       = "hooks.log"
+)
+
+func (s HookSettings) at(point string) []Hook {
+	switch point {
+	case hookPreBackendStart:
+		return s.PreBackendStart
+	case hookPostHealthy:
+		return s.PostHealthy
+	case hookPreShutdown:
+		return s.PreShutdown
+	case hookPostExit:
+		return s.PostExit
+	}
+	return nil
+}
+
+// runHooks runs the hooks of point in order. It returns an error only for
+// a failed "abort" hook, after which the remaining hooks do not run.
+func runHooks(config *AppConfig, point string, extraEnv ...string) error {
+	hooks := config.Settings.Hooks.at(point)
+	if len(hooks) == 0 {
+		return nil
+	}
+	logFile, err := os.OpenFile(filepath.Join(config.LogDir, hooksLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logWarn("hooks", "failed to open the hook log", "error", err)
+	} else {
+		defer logFile.Close()
+	}
+
+	for i, hook := range hooks {
+		if hook.Command == "" {
+			continue
+		}
+		consoleDetailf("Running %s hook: %s", point, hook.Command)
+		started := time.Now()
+		err := runHook(config, point, hook, logFile, extraEnv)
+		if err == nil {
+			logInfo("hooks", "hook done", "hook", point, "index", i, "duration_ms", time.Since(started).Milliseconds())
+			continue
+		}
+
+		switch hook.OnFailure {
+		case hookFailIgnore:
+			logDebug("hooks", "hook failed, ignored", "hook", point, "index", i, "error", err)
+		case hookFailAbort:
+			if point == hookPreBackendStart || point == hookPostHealthy {
+				logError("hooks", "hook failed, stopping the launch", "hook", point, "index", i, "error", err)
+				return fmt.Errorf("the %s hook %q failed: %w", point, hook.Command, err)
+			}
+			fallthrough
+		default:
+			logWarn("hooks", "hook failed", "hook", point, "index", i, "command", hook.Command, "error", err)
+			consoleWarnf("The %s hook %q failed: %v", point, hook.Command, err)
+		}
+	}
+	return nil
+}
+
+func runHook(config *AppConfig, point string, hook Hook, logFile *os.File, extraEnv []string) error {
+	timeout := defaultHookTimeout
+	if hook.TimeoutSeconds > 0 {
+		timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	// cmd.exe parses its own command line; quoting it like an argument
+	// would break commands with quotes of their own
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CmdLine: `cmd.exe /D /S /C "` + hook.Command + `"`}
+	cmd.Dir = config.RootDir
+	cmd.Env = append(os.Environ(),
+		"WAP_HOOK="+point,
+		"WAP_DATA_DIR="+config.DataDir,
+		"WAP_LOG_DIR="+config.LogDir,
+		"WAP_BACKEND_URL="+config.BackendURL,
+	)
+	cmd.Env = append(cmd.Env, profileEnv(config)...)
+	cmd.Env = append(cmd.Env, extraEnv...)
+	if logFile != nil {
+		fmt.Fprintf(logFile, "=== %s %s: %s\n", time.Now().Format(time.RFC3339), point, hook.Command)
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+	}
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// runPostExitHooks runs the post_exit hooks with the launcher's exit code
+func runPostExitHooks(config *AppConfig, code int) {
+	runHooks(config, hookPostExit, "WAP_EXIT_CODE="+strconv.Itoa(code))
+}
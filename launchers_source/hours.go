@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OperatingHours limits when a kiosk may run the application. Outside the
+// window the backend stays stopped and an "out of service" screen is shown.
+type OperatingHours struct {
+	Days    []string `json:"days"`  // "mon".."sun", empty means every day
+	Open    string   `json:"open"`  // "07:30"
+	Close   string   `json:"close"` // "19:00", earlier than open for overnight
+	Message string   `json:"message"`
+
+	open, close time.Duration
+	days        map[time.Weekday]bool
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (h *OperatingHours) parse() error {
+	var err error
+	if h.open, err = parseClock(h.Open); err != nil {
+		return fmt.Errorf("operating_hours.open: %w", err)
+	}
+	if h.close, err = parseClock(h.Close); err != nil {
+		return fmt.Errorf("operating_hours.close: %w", err)
+	}
+	if h.open == h.close {
+		return fmt.Errorf("operating_hours: open and close are both %s", h.Open)
+	}
+
+	h.days = map[time.Weekday]bool{}
+	for _, name := range h.Days {
+		day, ok := weekdayNames[strings.ToLower(name)[:min(3, len(name))]]
+		if !ok {
+			return fmt.Errorf("operating_hours.days: unknown day %q", name)
+		}
+		h.days[day] = true
+	}
+	return nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (h *OperatingHours) dayAllowed(day time.Weekday) bool {
+	return len(h.days) == 0 || h.days[day]
+}
+
+// currentWindow returns the opening window containing t, if any. Windows
+// belong to the day they open on, so overnight shifts follow that day's rule.
+func (h *OperatingHours) currentWindow(t time.Time) (start, end time.Time, ok bool) {
+	length := h.close - h.open
+	if length < 0 {
+		length += 24 * time.Hour
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for _, offset := range []int{0, -1} {
+		day := midnight.AddDate(0, 0, offset)
+		if !h.dayAllowed(day.Weekday()) {
+			continue
+		}
+		start = day.Add(h.open)
+		end = start.Add(length)
+		if !t.Before(start) && t.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func (h *OperatingHours) isOpen(t time.Time) bool {
+	_, _, open := h.currentWindow(t)
+	return open
+}
+
+// nextOpening returns the next time the application may start after t
+func (h *OperatingHours) nextOpening(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for offset := 0; offset <= 7; offset++ {
+		day := midnight.AddDate(0, 0, offset)
+		start := day.Add(h.open)
+		if start.After(t) && h.dayAllowed(day.Weekday()) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+func (h *OperatingHours) outOfServiceText(now time.Time) string {
+	text := h.Message
+	if text == "" {
+		text = tr("This terminal is currently out of service.")
+	}
+	if next := h.nextOpening(now); !next.IsZero() {
+		text += "\n\n" + tr("Available again %s", next.Format("Monday 15:04"))
+	}
+	return text
+}
+
+// waitForOperatingHours blocks while the terminal is outside its operating
+// hours. It returns false if the launcher was asked to exit while waiting.
+func waitForOperatingHours(config *AppConfig, hours *OperatingHours) bool {
+	if _, _, open := hours.currentWindow(time.Now()); open {
+		return true
+	}
+
+	consolePrintln("Outside operating hours, waiting...")
+	logInfo("hours", "outside operating hours, backend kept stopped", "next_opening", hours.nextOpening(time.Now()))
+
+	window := showStatusWindow(config.AppName, hours.outOfServiceText(time.Now()), true, false)
+	defer window.Close()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-launcherExiting():
+			return false
+		}
+
+		now := time.Now()
+		if _, _, open := hours.currentWindow(now); open {
+			logInfo("hours", "operating hours started")
+			return true
+		}
+		window.SetText(hours.outOfServiceText(now))
+	}
+}
+
+// startClosingTimer stops the session when the current opening window ends
+func startClosingTimer(session *Session, hours *OperatingHours) {
+	_, end, open := hours.currentWindow(time.Now())
+	if !open {
+		return
+	}
+
+	go func() {
+		select {
+		case <-time.After(time.Until(end)):
+			logInfo("hours", "closing time reached")
+			session.RequestStop(stopReasonClosingTime)
+		case <-session.Stopping():
+		}
+	}()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Text the user sees (dialogs, the tray, the splash, validation) goes
+// through tr, which looks the English text up in the catalog of the
+// current language; a missing entry stays English. Catalogs live in
+// messages_<language>.go and must keep the %-verbs of the English text in
+// order, or number them (%[2]s). Logs, the event log and error details
+// from Windows stay English for support.
+//
+// The language is Windows' display language unless launcher.json sets
+// "language". Both children get it as WAP_LOCALE (e.g. "id-ID") so the
+// whole application matches.
+var procGetUserPreferredUILanguages = kernel32.NewProc("GetUserPreferredUILanguages")
+
+const (
+	muiLanguageName = 0x8 // MUI_LANGUAGE_NAME
+	defaultLocale   = "en-US"
+)
+
+// catalogs maps a language to its translations, keyed by the English text
+var catalogs = map[string]map[string]string{
+	"id": messagesID,
+}
+
+var currentLocale struct {
+	sync.Once
+	tag      string
+	messages map[string]string
+}
+
+// windowsUILanguage returns the user's preferred display language
+func windowsUILanguage() string {
+	var count, size uint32
+	if r, _, _ := procGetUserPreferredUILanguages.Call(muiLanguageName, uintptr(unsafe.Pointer(&count)), 0, uintptr(unsafe.Pointer(&size))); r == 0 || size == 0 {
+		return ""
+	}
+	buf := make([]uint16, size)
+	if r, _, _ := procGetUserPreferredUILanguages.Call(muiLanguageName, uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); r == 0 {
+		return ""
+	}
+	// A double-null-terminated list, most preferred first
+	return syscall.UTF16ToString(buf)
+}
+
+// languageOf returns the language part of a tag: "id" for "id-ID"
+func languageOf(tag string) string {
+	language, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	return strings.ToLower(language)
+}
+
+// setLocale switches the launcher's text to tag, or to Windows' display
+// language when tag is empty
+func setLocale(tag string) {
+	currentLocale.Do(func() {})
+	applyLocale(tag)
+}
+
+func applyLocale(tag string) {
+	if tag == "" {
+		tag = windowsUILanguage()
+	}
+	if tag == "" {
+		tag = defaultLocale
+	}
+	currentLocale.tag = tag
+	currentLocale.messages = catalogs[languageOf(tag)]
+}
+
+// locale returns the current tag; before the settings are loaded it is
+// Windows' display language
+func locale() string {
+	currentLocale.Do(func() { applyLocale("") })
+	return currentLocale.tag
+}
+
+// tr translates format and, given args, formats it like fmt.Sprintf
+func tr(format string, args ...interface{}) string {
+	locale()
+	if translated, ok := currentLocale.messages[format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
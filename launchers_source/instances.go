@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Every running launcher registers itself in %ProgramData%\WAP\instances
+// so "launcher list" can show all instances on the machine, whichever user
+// or profile started them. Entries hold nothing secret: the control token
+// stays in the user's own .control.json. An entry whose process is gone is
+// stale and removed by the next list.
+const (
+	instancesDirName = "instances"
+
+	instanceStarting = "starting"
+	instanceRunning  = "running"
+)
+
+// instanceEntry is one registered launcher
+type instanceEntry struct {
+	PID      int       `json:"pid"`
+	Created  uint64    `json:"created"` // process creation time, against PID reuse
+	Profile  string    `json:"profile,omitempty"`
+	User     string    `json:"user"`
+	Session  uint32    `json:"session"`
+	RootDir  string    `json:"root_dir"`
+	Backend  string    `json:"backend_url,omitempty"`
+	Health   string    `json:"health_url,omitempty"`
+	LANPort  int       `json:"lan_port,omitempty"`
+	State    string    `json:"state"`
+	Started  time.Time `json:"started"`
+	Headless bool      `json:"headless,omitempty"`
+
+	path   string
+	config *AppConfig
+}
+
+func instancesDir() string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		return ""
+	}
+	return filepath.Join(programData, userDirName, instancesDirName)
+}
+
+// registerInstance adds this launcher to the registry. A registry that
+// cannot be written only hides the instance from "launcher list".
+func (c *controlServer) registerInstance(config *AppConfig, headless bool) {
+	c.instance = newInstanceEntry(config, headless)
+}
+
+func newInstanceEntry(config *AppConfig, headless bool) *instanceEntry {
+	dir := instancesDir()
+	if dir == "" {
+		return nil
+	}
+	entry := &instanceEntry{
+		PID:      os.Getpid(),
+		Profile:  config.Profile,
+		User:     currentUserName(),
+		RootDir:  config.RootDir,
+		State:    instanceStarting,
+		Started:  time.Now(),
+		Headless: headless,
+		path:     filepath.Join(dir, fmt.Sprintf("%d.json", os.Getpid())),
+		config:   config,
+	}
+	entry.Created, _ = processCreated(entry.PID)
+	entry.Session, _ = processSession(entry.PID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logDebug("launcher", "instance registry unavailable", "error", err)
+		return nil
+	}
+	entry.update(instanceStarting)
+	return entry
+}
+
+// update records the backend's current address and the state
+func (e *instanceEntry) update(state string) {
+	if e == nil {
+		return
+	}
+	config := e.config
+	e.State = state
+	e.Backend, e.Health = "", ""
+	if !config.RemoteBackend {
+		e.Backend = config.BackendURL
+		e.Health = healthURL(config, config.BackendURL)
+	}
+	e.LANPort = 0
+	if config.Settings.LAN.Enabled {
+		e.LANPort = lanPort(config)
+	}
+	if err := writeJSONFile(e.path, e); err != nil {
+		logDebug("launcher", "failed to update the instance registry", "error", err)
+	}
+}
+
+func (e *instanceEntry) remove() {
+	if e != nil {
+		os.Remove(e.path)
+	}
+}
+
+func currentUserName() string {
+	if domain := os.Getenv("USERDOMAIN"); domain != "" {
+		return domain + `\` + os.Getenv("USERNAME")
+	}
+	return os.Getenv("USERNAME")
+}
+
+// alive reports whether the entry's launcher still runs. Processes of
+// other users may not be opened; they count as running.
+func (e *instanceEntry) alive() bool {
+	created, err := processCreated(e.PID)
+	if errors.Is(err, syscall.ERROR_ACCESS_DENIED) {
+		return true
+	}
+	return err == nil && created == e.Created
+}
+
+// health asks the entry's backend's health endpoint
+func (e *instanceEntry) health() string {
+	if e.Health == "" {
+		return "remote"
+	}
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(e.Health)
+	if err != nil {
+		return "not answering"
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "unhealthy (" + resp.Status + ")"
+	}
+	return "healthy"
+}
+
+// listInstances returns the registered launchers that still run, removing
+// stale entries it is allowed to remove
+func listInstances() ([]*instanceEntry, error) {
+	dir := instancesDir()
+	if dir == "" {
+		return nil, errors.New("ProgramData is not set")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []*instanceEntry
+	for _, path := range files {
+		entry := &instanceEntry{path: path}
+		if readJSONFile(path, entry) != nil {
+			continue
+		}
+		if !entry.alive() {
+			os.Remove(path)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Started.Before(entries[j].Started) })
+	return entries, nil
+}
+
+type listOptions struct {
+	json bool
+}
+
+func (o *listOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.json, "json", false, "print the instances as JSON")
+}
+
+// runListCommand implements "launcher list"
+func runListCommand(config *AppConfig, args []string) int {
+	var opts listOptions
+	fs := newCommandFlagSet("list")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	entries, err := listInstances()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitLauncherError
+	}
+	if opts.json {
+		type listed struct {
+			*instanceEntry
+			Health string `json:"health"`
+		}
+		out := make([]listed, 0, len(entries))
+		for _, entry := range entries {
+			out = append(out, listed{entry, entry.health()})
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitLauncherError
+		}
+		fmt.Println(string(data))
+		return exitOK
+	}
+	if len(entries) == 0 {
+		fmt.Println("No running instances")
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tPROFILE\tUSER\tSESSION\tBACKEND\tSTATE\tHEALTH\tSTARTED\tINSTALL")
+	for _, entry := range entries {
+		profile := entry.Profile
+		if profile == "" {
+			profile = "-"
+		}
+		backend := entry.Backend
+		if backend == "" {
+			backend = "remote"
+		} else {
+			backend = strings.TrimPrefix(backend, "http://")
+		}
+		if entry.LANPort > 0 {
+			backend += fmt.Sprintf(" (LAN %d)", entry.LANPort)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", entry.PID, profile, entry.User, entry.Session, backend,
+			entry.State, entry.health(), entry.Started.Format("2006-01-02 15:04"), entry.RootDir)
+	}
+	w.Flush()
+	return exitOK
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// JobLimitSettings put the backend and every process it starts into a
+// Windows Job Object, so a runaway Python subprocess tree cannot take the
+// machine down. Allocations past max_memory_mb fail inside the backend;
+// max_processes caps how many processes the tree may have at once. Hitting
+// a limit is logged and shown as a tray notification.
+//
+// Processes the backend starts in the instant before it is assigned to the
+// job are not covered; the backend does not start any that early.
+type JobLimitSettings struct {
+	MaxMemoryMB  int `json:"max_memory_mb"` // whole tree, 0 for no limit
+	MaxProcesses int `json:"max_processes"` // 0 for no limit
+}
+
+var (
+	procCreateJobObjectW          = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject   = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject  = kernel32.NewProc("AssignProcessToJobObject")
+	procGetQueuedCompletionStatus = kernel32.NewProc("GetQueuedCompletionStatus")
+)
+
+const (
+	jobObjectAssociateCompletionPortInformation = 7
+	jobObjectExtendedLimitInformation           = 9
+
+	jobObjectLimitActiveProcess = 0x00000008
+	jobObjectLimitJobMemory     = 0x00000200
+
+	jobObjectMsgActiveProcessLimit = 3
+	jobObjectMsgJobMemoryLimit     = 10
+
+	processSetQuota  = 0x0100
+	processTerminate = 0x0001
+
+	// A tree stuck at its limit hits it over and over
+	jobLimitNotifyInterval = 10 * time.Minute
+)
+
+// jobExtendedLimitInformation is JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+type jobExtendedLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+	IoInfo                  [6]uint64 // IO_COUNTERS
+	ProcessMemoryLimit      uintptr
+	JobMemoryLimit          uintptr
+	PeakProcessMemoryUsed   uintptr
+	PeakJobMemoryUsed       uintptr
+}
+
+// jobAssociateCompletionPort is JOBOBJECT_ASSOCIATE_COMPLETION_PORT
+type jobAssociateCompletionPort struct {
+	CompletionKey  uintptr
+	CompletionPort syscall.Handle
+}
+
+// The job is created with the first backend and shared by its restarts.
+// It is never set to kill on close: a warm backend outlives the launcher.
+var backendJob struct {
+	once   sync.Once
+	handle syscall.Handle
+	err    error
+}
+
+func (s JobLimitSettings) enabled() bool {
+	return s.MaxMemoryMB > 0 || s.MaxProcesses > 0
+}
+
+// assignBackendJob puts the started backend into the limited job
+func assignBackendJob(config *AppConfig, pid int) {
+	settings := config.Settings.BackendJob
+	if !settings.enabled() {
+		return
+	}
+	backendJob.once.Do(func() {
+		backendJob.handle, backendJob.err = createLimitedJob(config, settings)
+	})
+	if backendJob.err != nil {
+		logWarn("backend", "failed to create job object, backend runs without limits", "error", backendJob.err)
+		return
+	}
+
+	process, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(pid))
+	if err != nil {
+		logWarn("backend", "failed to open backend for its job object", "child_pid", pid, "error", err)
+		return
+	}
+	defer syscall.CloseHandle(process)
+	if ret, _, err := procAssignProcessToJobObject.Call(uintptr(backendJob.handle), uintptr(process)); ret == 0 {
+		logWarn("backend", "failed to assign backend to its job object", "child_pid", pid, "error", err)
+		return
+	}
+	logInfo("backend", "backend limited by job object", "child_pid", pid,
+		"max_memory_mb", settings.MaxMemoryMB, "max_processes", settings.MaxProcesses)
+}
+
+func createLimitedJob(config *AppConfig, settings JobLimitSettings) (syscall.Handle, error) {
+	ret, _, err := procCreateJobObjectW.Call(0, 0)
+	if ret == 0 {
+		return 0, fmt.Errorf("CreateJobObject: %w", err)
+	}
+	job := syscall.Handle(ret)
+
+	var limits jobExtendedLimitInformation
+	if settings.MaxMemoryMB > 0 {
+		limits.LimitFlags |= jobObjectLimitJobMemory
+		limits.JobMemoryLimit = uintptr(settings.MaxMemoryMB) << 20
+	}
+	if settings.MaxProcesses > 0 {
+		limits.LimitFlags |= jobObjectLimitActiveProcess
+		limits.ActiveProcessLimit = uint32(settings.MaxProcesses)
+	}
+	if ret, _, err := procSetInformationJobObject.Call(uintptr(job), jobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&limits)), unsafe.Sizeof(limits)); ret == 0 {
+		syscall.CloseHandle(job)
+		return 0, fmt.Errorf("SetInformationJobObject: %w", err)
+	}
+
+	// Limit hits arrive as completion packets; without them the limits
+	// still apply, only unreported
+	port, err := syscall.CreateIoCompletionPort(syscall.InvalidHandle, 0, 0, 1)
+	if err != nil {
+		logWarn("backend", "job object limit hits will not be reported", "error", err)
+		return job, nil
+	}
+	associate := jobAssociateCompletionPort{CompletionPort: port}
+	if ret, _, err := procSetInformationJobObject.Call(uintptr(job), jobObjectAssociateCompletionPortInformation,
+		uintptr(unsafe.Pointer(&associate)), unsafe.Sizeof(associate)); ret == 0 {
+		syscall.CloseHandle(port)
+		logWarn("backend", "job object limit hits will not be reported", "error", err)
+		return job, nil
+	}
+	go watchJobLimits(config, port, settings)
+	return job, nil
+}
+
+// watchJobLimits reports limit hits for as long as the launcher runs
+func watchJobLimits(config *AppConfig, port syscall.Handle, settings JobLimitSettings) {
+	notified := map[uint32]time.Time{}
+	for {
+		var message uint32
+		var key, pid uintptr
+		ret, _, _ := procGetQueuedCompletionStatus.Call(uintptr(port), uintptr(unsafe.Pointer(&message)),
+			uintptr(unsafe.Pointer(&key)), uintptr(unsafe.Pointer(&pid)), syscall.INFINITE)
+		if ret == 0 {
+			return
+		}
+		var text string
+		switch message {
+		case jobObjectMsgJobMemoryLimit:
+			text = fmt.Sprintf("The background service reached its memory limit of %d MB; some operations may fail.", settings.MaxMemoryMB)
+		case jobObjectMsgActiveProcessLimit:
+			text = fmt.Sprintf("The background service reached its limit of %d processes; it could not start another one.", settings.MaxProcesses)
+		default:
+			continue
+		}
+		if last, ok := notified[message]; ok && time.Since(last) < jobLimitNotifyInterval {
+			continue
+		}
+		notified[message] = time.Now()
+		logWarn("backend", "job object limit reached", "message", message, "pid", pid)
+		reportEvent(eventTypeWarning, eventIDResourceLimit, text)
+		emitDegraded(serviceBackend, "job_limit", text)
+		if notify := alertNotifier(); notify != nil {
+			notify(config.AppName, text)
+		}
+	}
+}
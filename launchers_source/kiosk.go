@@ -0,0 +1,17 @@
+package main
+
+import "time"
+
+// KioskSettings tunes --kiosk, used by digital signage and counter
+// terminals where the app must always be on screen.
+type KioskSettings struct {
+	FrontendArgs []string `json:"frontend_args"` // passed to wap.exe, e.g. ["--fullscreen"]
+}
+
+const (
+	kioskMinRelaunchDelay = 2 * time.Second
+	kioskMaxRelaunchDelay = time.Minute
+
+	// A frontend that ran this long resets the relaunch backoff
+	kioskStableRuntime = 5 * time.Minute
+)
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// When the backend does not come up, python_server.log usually says why in
+// terms users cannot act on. Known signatures are turned into a dialog that
+// says what to do instead.
+type knownError struct {
+	name    string // for launcher.log and the event log
+	pattern *regexp.Regexp
+	title   string
+	advice  string // $1... expand from the pattern, {port} is the backend port
+}
+
+var knownBackendErrors = []knownError{
+	{
+		name:    "module_not_found",
+		pattern: regexp.MustCompile(`ModuleNotFoundError: No module named '([^']+)'`),
+		title:   "A backend component is missing",
+		advice:  "The Python module \"$1\" could not be found. It may have been removed by antivirus software or an incomplete update.\n\nRun \"launcher repair-python\" or reinstall the application.",
+	},
+	{
+		name:    "port_in_use",
+		pattern: regexp.MustCompile(`(?i)address already in use|WinError 10048|only one usage of each socket address`),
+		title:   "The backend's port is already in use",
+		advice:  "Another program, or another copy of the application, is using port {port}.\n\nClose it and try again, or set a different port in launcher.json.",
+	},
+	{
+		name:    "port_forbidden",
+		pattern: regexp.MustCompile(`(?i)WinError 10013|forbidden by its access permissions`),
+		title:   "Windows blocked the backend's port",
+		advice:  "Access to port {port} was denied. Security software may be blocking it, or Windows reserved it (check with \"netsh int ipv4 show excludedportrange protocol=tcp\").\n\nAllow the application in your security software or set a different port in launcher.json.",
+	},
+	{
+		name:    "dll_missing",
+		pattern: regexp.MustCompile(`(?i)DLL load failed(?: while importing (\w+))?`),
+		title:   "A system library is missing",
+		advice:  "A Windows library needed by the backend could not be loaded ($1).\n\nInstall the latest Microsoft Visual C++ Redistributable (x64) and start the application again.",
+	},
+	{
+		name:    "access_denied",
+		pattern: regexp.MustCompile(`PermissionError: \[(?:Errno 13|WinError 5)\][^'"]*['"]?([^'"]*)`),
+		title:   "The backend cannot access its files",
+		advice:  "Access to \"$1\" was denied. The file may be open in another program or blocked by security software.\n\nClose other programs using it and try again.",
+	},
+}
+
+// Only the end of the log is scanned; the failure is near the end
+const knownErrorScanBytes = 256 * 1024
+
+// backendDiagnosis is a known failure found in the backend log
+type backendDiagnosis struct {
+	name   string
+	title  string
+	advice string
+	line   string
+}
+
+// diagnoseBackendLog returns the last known failure in python_server.log
+func diagnoseBackendLog(config *AppConfig) (backendDiagnosis, bool) {
+	file, err := os.Open(filepath.Join(config.LogDir, backendLogName))
+	if err != nil {
+		return backendDiagnosis{}, false
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() > knownErrorScanBytes {
+		file.Seek(-knownErrorScanBytes, io.SeekEnd)
+	}
+
+	var found backendDiagnosis
+	ok := false
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, known := range knownBackendErrors {
+			match := known.pattern.FindStringSubmatchIndex(line)
+			if match == nil {
+				continue
+			}
+			advice := string(known.pattern.ExpandString(nil, tr(known.advice), line, match))
+			advice = strings.ReplaceAll(advice, "{port}", strconv.Itoa(backendPort(config)))
+			advice = strings.ReplaceAll(advice, " ()", "")
+			found = backendDiagnosis{name: known.name, title: tr(known.title), advice: advice, line: shortLine(strings.TrimSpace(line))}
+			ok = true
+			break
+		}
+	}
+	return found, ok
+}
+
+// showBackendError reports a backend start failure, replacing title and
+// err with friendly advice when the log shows a known cause
+func showBackendError(config *AppConfig, title string, err error) {
+	diagnosis, ok := diagnoseBackendLog(config)
+	if !ok {
+		showError(title, err)
+		return
+	}
+	logError("backend", "known backend failure", "signature", diagnosis.name, "line", diagnosis.line, "error", err)
+	showError(diagnosis.title, fmt.Errorf("%s\n\nFrom %s: %s", diagnosis.advice, backendLogName, diagnosis.line))
+}
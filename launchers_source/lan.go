@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LANSettings lets tablets or a second PC use this machine's backend. The
+// proxy then also listens on the network, and LAN clients authenticate with
+// a password instead of the local session token, which never leaves this
+// machine.
+type LANSettings struct {
+	Enabled  bool   `json:"enabled"`
+	Port     int    `json:"port"`     // default 5443
+	Password string `json:"password"` // default: generated once per install; may be secret://name
+	Firewall string `json:"firewall"` // ask (default), allow or off (firewall.go)
+}
+
+const (
+	defaultLANPort  = 5443
+	lanPasswordName = ".lan-password"
+
+	// No 0/O or 1/l/I, so the password can be read off a screen
+	lanPasswordAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// lanListener is the proxy's network-facing side
+type lanListener struct {
+	server      *http.Server
+	urls        []string
+	password    string
+	fingerprint string // SHA-256 of the certificate, for pinning
+}
+
+func lanPort(config *AppConfig) int {
+	if port := config.Settings.LAN.Port; port > 0 {
+		return port
+	}
+	return defaultLANPort
+}
+
+// lanAddresses lists this machine's IPv4 addresses on connected networks
+func lanAddresses() []net.IP {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var addresses []net.IP
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ip := ipnet.IP.To4(); ip != nil && !ip.IsLinkLocalUnicast() {
+				addresses = append(addresses, ip)
+			}
+		}
+	}
+	return addresses
+}
+
+func (p *backendProxy) startLAN(config *AppConfig, forward http.Handler, cert tls.Certificate) (*lanListener, error) {
+	password, err := lanPassword(config)
+	if err != nil {
+		return nil, err
+	}
+	port := lanPort(config)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on LAN port %d: %w", port, err)
+	}
+	ensureFirewallRule(config, port)
+
+	sum := sha256.Sum256(cert.Certificate[0])
+	lan := &lanListener{password: password, fingerprint: formatFingerprint(sum[:])}
+	for _, ip := range lanAddresses() {
+		lan.urls = append(lan.urls, fmt.Sprintf("https://%s:%d", ip, port))
+	}
+
+	authorized := func(r *http.Request) bool {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, given, _ = r.BasicAuth()
+		}
+		return subtle.ConstantTimeCompare([]byte(given), []byte(password)) == 1
+	}
+	lan.server = &http.Server{
+		Handler:           p.handler(forward, authorized),
+		TLSConfig:         &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go lan.server.Serve(tls.NewListener(listener, lan.server.TLSConfig))
+
+	logInfo("lan", "backend exposed on the network", "urls", strings.Join(lan.urls, " "), "fingerprint", lan.fingerprint)
+	consoleSuccessf("Other devices can connect to this backend:")
+	consolePrintln(lan.details())
+	return lan, nil
+}
+
+// details is the connection information shown to the user
+func (l *lanListener) details() string {
+	var b strings.Builder
+	if len(l.urls) == 0 {
+		b.WriteString("No network connection found.\n")
+	}
+	for _, url := range l.urls {
+		fmt.Fprintf(&b, "Address:     %s\n", url)
+	}
+	fmt.Fprintf(&b, "Password:    %s\n", l.password)
+	fmt.Fprintf(&b, "Certificate: %s", l.fingerprint)
+	return b.String()
+}
+
+// lanPassword returns the configured password, or the one generated on
+// first use so paired devices keep working across restarts
+func lanPassword(config *AppConfig) (string, error) {
+	if config.Settings.LAN.Password != "" {
+		return resolveSecretValue(config, "lan.password", config.Settings.LAN.Password)
+	}
+	path := filepath.Join(config.StateDir, lanPasswordName)
+	if data, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < 12; i++ {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(lanPasswordAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate LAN password: %w", err)
+		}
+		b.WriteByte(lanPasswordAlphabet[n.Int64()])
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return "", fmt.Errorf("failed to save LAN password: %w", err)
+	}
+	return b.String(), nil
+}
+
+func formatFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
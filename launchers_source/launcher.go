@@ -2,16 +2,20 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
 type AppConfig struct {
 	AppName       string
+	RootDir       string
 	BinDir        string
 	AppExe        string
 	PythonDir     string
@@ -19,190 +23,1080 @@ type AppConfig struct {
 	BackendDir    string
 	BackendScript string
 	DataDir       string
+	LogDir        string
+	StateDir      string // control info, LAN password, proxy certificate
+	Portable      bool   // logs, data and state stay inside bin/
+	BackendURL    string
+	RemoteBackend bool           // BackendURL is a central server, no local Python
+	Backend       BackendRunner  // what starts the backend (backendrunner.go)
+	Frontend      FrontendRunner // what shows the app (frontendrunner.go)
+	Proxy         *backendProxy  // fronts the local backend when proxy.enabled is set
 	FlutterDLL    string
+	Settings      Settings
+
+	BackendArgs     []string // --backend-args
+	AppArgs         []string // --app-args and arguments after --
+	OpenURL         string   // --open-url, until the app has been given it
+	OpenFile        string   // --open-file, likewise
+	Dev             bool     // --dev: hot reload, no integrity checks or updates
+	ExternalPython  bool     // PythonExe is a system or virtualenv Python, run as is
+	SafeMode        bool     // --safe-mode or accepted after repeated failures
+	MirrorOutput    bool     // --console or --dev: child output on the console too
+	Profile         string   // --profile, "" for the default instance
+	SessionIsolated bool     // other users' sessions may run the application too
 }
 
 func main() {
-	config := &AppConfig{
-		AppName: "WAP Application",
+	code := run()
+	emitExit(code)
+	os.Exit(code)
+}
+
+// run starts the application and returns the launcher exit code
+func run() (code int) {
+	setupConsole(wantsConsoleFlag(os.Args[1:]))
+
+	// Help works even when the configuration is broken
+	if len(os.Args) > 1 {
+		switch {
+		case isHelpFlag(os.Args[1]):
+			printHelp(os.Stdout)
+			return exitOK
+		case os.Args[1] == "--help-all":
+			printHelpAll(os.Stdout)
+			return exitOK
+		}
 	}
 
 	// Setup paths
 	exePath, err := os.Executable()
 	if err != nil {
 		showError("Cannot get executable path", err)
-		return
+		return exitLauncherError
 	}
+	config := newAppConfig(exePath)
 
-	exeDir := filepath.Dir(exePath)
-	config.BinDir = filepath.Join(exeDir, "bin")
-	config.AppExe = filepath.Join(config.BinDir, "wap.exe")
-	config.PythonDir = filepath.Join(config.BinDir, "embedded_python")
-	config.PythonExe = filepath.Join(config.PythonDir, "python.exe")
-	config.BackendDir = filepath.Join(config.BinDir, "python_backend")
-	config.BackendScript = filepath.Join(config.BackendDir, "start_server.py")
-	config.DataDir = filepath.Join(config.BinDir, "data")
-	config.FlutterDLL = filepath.Join(config.BinDir, "flutter_windows.dll")
+	config.Settings, err = loadSettings(config.RootDir)
+	if err != nil {
+		showError("Invalid launcher configuration", err)
+		return exitConfigInvalid
+	}
+	setLocale(config.Settings.Language)
+	setUserDirs(config)
+
+	// --profile applies to the subcommands too, so it is taken out first
+	profile, args, err := profileFlag(os.Args[1:])
+	if err != nil {
+		showError("Invalid command line", err)
+		return exitUsage
+	}
+	applyProfile(config, profile)
+	if err := applySessionIsolation(config); err != nil {
+		showError("Failed to start launcher", err)
+		return exitLauncherError
+	}
+	// The runners come last: a remote backend's URL must not be replaced
+	// by the profile's or the session's port
+	if err := selectBackendRunner(config); err != nil {
+		showError("Invalid launcher configuration", err)
+		return exitConfigInvalid
+	}
+	if err := selectFrontendRunner(config); err != nil {
+		showError("Invalid launcher configuration", err)
+		return exitConfigInvalid
+	}
+
+	// Subcommands (launcher logs, ...) run instead of starting the app
+	if len(args) > 0 {
+		if cmd := findCommand(args[0]); cmd != nil {
+			return cmd.run(config, args[1:])
+		}
+	}
+
+	opts, err := parseOptions(args)
+	if err != nil {
+		showError("Invalid command line", err)
+		return exitUsage
+	}
+	setJSONProgress(opts.JSONProgress)
+	setEventsJSON(opts.EventsJSON)
+	setConsoleStyle(opts.verbosity(), opts.NoColor)
+	if err := applyOptions(config, opts); err != nil {
+		showError("Invalid command line", err)
+		return exitUsage
+	}
+	if opts.Version {
+		printVersion(config)
+		return exitOK
+	}
+	if opts.DryRun {
+		plan, err := buildLaunchPlan(config, opts)
+		if err != nil {
+			showError("Invalid launcher configuration", err)
+			return exitConfigInvalid
+		}
+		printLaunchPlan(plan)
+		return exitOK
+	}
+
+	// An elevated start would put the data in the administrator's profile
+	if !checkElevation(config, exePath, !opts.Headless) {
+		return exitOK
+	}
+
+	// Databases on network and synced folders get corrupted
+	if !checkDataLocation(config, !opts.Headless) {
+		return exitOK
+	}
+
+	// One launcher per profile and Windows session
+	if !acquireInstance(config) {
+		// A link or file goes to the app already running (protocol.go)
+		if (config.OpenURL != "" || config.OpenFile != "") && forwardOpen(config) {
+			return exitOK
+		}
+		// Starting at login while already running changes nothing;
+		// starting again otherwise brings the app up (autostart.go)
+		if opts.Minimized {
+			logInfo("launcher", "already running, minimized start skipped")
+			return exitAlreadyRunning
+		}
+		if config.OpenURL == "" && config.OpenFile == "" && forwardShow(config) {
+			return exitOK
+		}
+		reportAlreadyRunning(config)
+		return exitAlreadyRunning
+	}
+
+	// Swap in a staged update (or roll back a failed one) before anything
+	// holds files in bin/ open
+	updateMessage, updateErr := applyPendingUpdate(config)
+
+	if err := prepareUserDirs(config); err != nil {
+		showError("Failed to prepare the data directory", err)
+		return exitLauncherError
+	}
+	if err := openLauncherLog(filepath.Join(config.LogDir, launcherLogName), opts.LogFormat); err != nil {
+		consoleWarnf("%v", err)
+	}
+	defer closeLauncherLog()
+	configureWebhooks(config)
+	defer waitForWebhooks()
+	enableStartupTiming(filepath.Join(config.LogDir, startupTimingName), opts.Profile)
+	defer func() { sendStartupTelemetry(config, code) }()
+	defer func() { notifyStartupFailure(code) }()
+	defer finishStartupTiming(false)
+	defer pendingCrashReports.Wait()
+	logInfo("launcher", "launcher starting", "exe", exePath, "version", launcherVersion, "commit", gitCommit, "log_format", opts.LogFormat)
+	registerEventSource()
+	setAppUserModelID(config)
+	emitEvent(lifecycleEvent{Event: eventStarted, Service: serviceLauncher, PID: os.Getpid(), Message: launcherVersion})
+
+	// Offer Safe Mode after repeated failed sessions
+	failures := beginSession(config)
+	defer func() { endSession(config, code) }()
+	defer func() { runPostExitHooks(config, code) }()
+	if opts.SafeMode || offerSafeMode(config, failures) {
+		enterSafeMode(config)
+	}
+	askTelemetryConsent(config)
+
+	if updateErr != nil {
+		logError("update", "failed to apply pending update", "error", updateErr)
+		notifyWebhook(webhookUpdateFailed, "Failed to apply the pending update: "+updateErr.Error(), launcherLogName)
+	} else if updateMessage != "" {
+		consoleSuccessf("%s", updateMessage)
+		logInfo("update", updateMessage)
+	}
+
+	// The control channel carries boot stages from the backend to the splash
+	control, err := startControlServer(filepath.Join(config.LogDir, statusFileName), config.Settings.Metrics.Port)
+	if err != nil {
+		showError("Failed to start launcher", err)
+		return exitLauncherError
+	}
+	if token := config.Settings.Metrics.Token; token != "" {
+		if token, err := resolveSecretValue(config, "metrics.token", token); err == nil {
+			control.EnableMetrics(token, healthURL(config, ""))
+		} else {
+			logWarn("metrics", "metrics scraping disabled", "error", err)
+		}
+	}
+	defer control.Close()
+	control.writeControlInfo(controlInfoPath(config))
+	control.registerInstance(config, opts.Headless)
+	control.publishSession(config)
+
+	// Logs sleep and resume and lets sessions recover from them
+	power := startPowerMonitor(config)
+	defer power.Close()
+	control.SetTraceRoot(filepath.Join(config.LogDir, tracesDirName))
+	var splash *splashScreen
+	if !opts.Headless && !opts.Minimized {
+		splash = showSplash(config, control)
+	}
+
+	// Single-file builds unpack themselves on first run
+	emitPhase(phasePayload, phaseStarted, 0, "")
+	if err := retryTransient(config, "Extracting application files", func() error { return ensurePayload(config, control) }); err != nil {
+		splash.Close()
+		showError("Failed to extract application files", err)
+		return exitPayloadFailed
+	}
+	emitPhase(phasePayload, phaseDone, 100, "")
+
+	// Check bin/ against manifest.json and offer to repair damaged files
+	emitPhase(phaseVerify, phaseStarted, 0, "")
+	if !config.Dev && !verifyBeforeStart(config, opts.Verify, !opts.Headless) {
+		splash.Close()
+		return exitIntegrityFailed
+	}
+	emitPhase(phaseVerify, phaseDone, 100, "")
 
 	// Validate all required files
-	if !validateEnvironment(config) {
-		return
+	emitPhase(phaseValidate, phaseStarted, 0, "")
+	if !ensurePrerequisites(config, bundledPython(config)) {
+		emitPhaseFailed("a prerequisite is missing")
+		splash.Close()
+		return exitPrerequisites
+	}
+	if !validateEnvironment(config, !config.RemoteBackend, !opts.Headless) {
+		emitPhaseFailed("required application files are missing")
+		splash.Close()
+		return exitMissingFiles
+	}
+	if bundledPython(config) && !config.Settings.Python.SkipCheck {
+		python, err := checkPython(config)
+		if err != nil {
+			splash.Close()
+			showError("The bundled Python interpreter cannot run the backend", err)
+			return exitBackendStart
+		}
+		logInfo("backend", "python interpreter", "python", python.String())
+		if err := verifyPythonDeps(config); err != nil {
+			splash.Close()
+			showError("The backend's Python packages are damaged", fmt.Errorf("%w\n\nRun \"launcher repair-python\" or reinstall the application.", err))
+			return exitBackendStart
+		}
+	}
+	emitPhase(phaseValidate, phaseDone, 100, "")
+
+	// Check the databases, then bring the data up to the version this
+	// build expects
+	if launcherPython(config) {
+		if !verifyDataBeforeStart(config) {
+			splash.Close()
+			return exitIntegrityFailed
+		}
+		emitPhase(phaseMigrate, phaseStarted, 0, "")
+		if err := migrateData(config, control); err != nil {
+			emitPhaseFailed(err.Error())
+			splash.Close()
+			if errors.Is(err, errDataTooNew) {
+				showError("This data belongs to a newer version", err)
+				return exitDataTooNew
+			}
+			showError("Failed to update the application data", err)
+			return exitDataMigration
+		}
+		emitPhase(phaseMigrate, phaseDone, 100, "")
+	}
+
+	// Demo builds refuse to start once the trial is over
+	demo := resolveDemoSettings(config)
+	var demoEnd time.Time
+	if demo.Enabled {
+		demoEnd, err = demoDeadline(config, demo, time.Now())
+		if err != nil {
+			splash.Close()
+			showError("Invalid demo configuration", err)
+			return exitConfigInvalid
+		}
+		if !demoEnd.IsZero() && !time.Now().Before(demoEnd) {
+			logInfo("demo", "demo period has ended")
+			splash.Close()
+			showUpgradePrompt(config, demo)
+			return exitDemoExpired
+		}
+	}
+
+	// Steps compiled in by downstream builds (steps.go)
+	if err := runSteps(config, control); err != nil {
+		splash.Close()
+		showError("A startup step failed", err)
+		return exitStepFailed
+	}
+
+	// Sidecars (sidecars.go) start before the backend and, deferred here,
+	// stop after it
+	sidecars, err := startSidecars(config, control)
+	if err != nil {
+		splash.Close()
+		showError("Failed to start a service the backend needs", err)
+		return exitSidecarFailed
+	}
+	defer sidecars.Close()
+
+	// The proxy takes over the backend's port before the backend starts.
+	// LAN clients always go through it.
+	if config.RemoteBackend && config.Settings.LAN.Enabled {
+		logWarn("lan", "lan.enabled is ignored with a remote backend")
+	}
+	if proxyEnabled(config) {
+		config.Proxy, err = startBackendProxy(config)
+		if err != nil {
+			splash.Close()
+			showError("Failed to start backend proxy", err)
+			return exitLauncherError
+		}
+		defer config.Proxy.Close()
+	}
+	if !config.Settings.LAN.Enabled {
+		removeUnusedFirewallRule(config)
+	}
+
+	control.SetBackendURL(config.BackendURL)
+
+	if config.Dev {
+		consolePrintf("Development mode: watching %s (Python: %s)\n", config.BackendDir, config.PythonExe)
+		watchBackendSources(config, control)
+	} else if !config.SafeMode {
+		startBackgroundUpdateCheck(config)
+	}
+	startBackgroundGC(config)
+	startScheduledBackups(config)
+	startResourceMonitor(config, control)
+	loadAlerts(config)
+	chaosBegin(config)
+
+	// Headless runs have no UI; an agent token enables the stop command
+	if opts.Headless {
+		if config.Settings.Agent.Token != "" {
+			if agent, err := startAgent(config); err == nil {
+				defer agent.Close()
+			} else {
+				logWarn("agent", "stop command unavailable", "error", err)
+			}
+		}
+		code := runHeadless(config, control, demoEnd)
+		logInfo("launcher", "launcher exiting", "exit_code", code)
+		return code
+	}
+
+	tray := startTray(config, config.AppName)
+	control.OnStatus(func(summary string) {
+		tray.SetTooltip(config.AppName + " - " + summary)
+	})
+	// Kiosks only exit on an authenticated stop command
+	if !opts.Kiosk {
+		tray.AddMenuItem(tr("Exit %s", config.AppName), requestLauncherExit)
+	}
+	defer tray.Close()
+	// A browser tab closed by mistake is opened again from the tray, as is
+	// the app of a minimized start
+	if _, ok := config.Frontend.(browserRunner); ok || opts.Minimized {
+		tray.AddMenuItem(tr("Open %s", config.AppName), func() {
+			if err := control.runServiceCommand(serviceCommand{Action: "show"}); err != nil {
+				logWarn("tray", "failed to open the application", "error", err)
+			}
+		})
+	}
+	if !opts.Kiosk && !opts.Agent {
+		tray.AddCheckMenuItem(tr("Start at login"), func() bool { return autostartEnabled(config) }, func() { toggleAutostart(config, tray) })
+	}
+	setAlertNotifier(tray.Notify)
+	defer setAlertNotifier(nil)
+	tray.AddMenuItem(tr("Start/stop diagnostic trace"), func() { toggleTrace(config, control, tray) })
+	if !config.Dev {
+		startScrubber(config, tray)
+	}
+	if config.Proxy != nil && config.Proxy.lan != nil {
+		lan := config.Proxy.lan
+		tray.AddMenuItem(tr("Connect another device..."), func() {
+			messageBox(config.AppName, tr("Other devices on this network can use this computer's backend:")+"\n\n"+lan.details(), mbOK|mbIconInformation)
+		})
+		tray.Notify(config.AppName, tr("Other devices can connect. Open the tray menu for the address and password."))
+	}
+
+	// In agent mode the fleet server decides when the app starts; kiosks
+	// take their stop command from the same listener
+	var agent *agentServer
+	if opts.Agent || opts.Kiosk {
+		agent, err = startAgent(config)
+		if err != nil {
+			splash.Close()
+			showError("Failed to start agent", err)
+			return exitAgentFailed
+		}
+		defer agent.Close()
 	}
 
+	exitCode := exitOK
+	hours := config.Settings.OperatingHours
+	for {
+		// No splash while idle between sessions
+		if opts.Agent || (hours != nil && !hours.isOpen(time.Now())) {
+			splash.Close()
+			splash = nil
+		}
+		if opts.Agent && !agent.waitForStart() {
+			break
+		}
+
+		// Kiosks outside their operating hours keep the backend stopped
+		if hours != nil && !waitForOperatingHours(config, hours) {
+			break
+		}
+
+		session := newSession(config)
+		session.tray = tray
+		session.control = control
+		session.splash = splash
+		session.kiosk = opts.Kiosk
+		session.background = opts.Minimized
+		if session.splash == nil && !session.background {
+			session.splash = showSplash(config, control)
+		}
+		splash = nil
+		if agent != nil {
+			agent.setRunning(true)
+		}
+		code := runSession(session, demoEnd)
+		if agent != nil {
+			agent.setRunning(false)
+		}
+		if code != exitOK {
+			return code
+		}
+		if session.deadline.Expired() {
+			session.splash.Close()
+			switch askAfterStartupTimeout(config, session.deadline) {
+			case startupRetry:
+				continue
+			case startupRetrySafeMode:
+				if !config.SafeMode {
+					enterSafeMode(config)
+				}
+				continue
+			}
+			return exitStartupTimeout
+		}
+
+		if session.StopReason() == stopReasonDemoExpired {
+			showUpgradePrompt(config, demo)
+			exitCode = exitDemoExpired
+		}
+		// Agents go back to waiting for the next start command
+		if !opts.Agent && session.StopReason() != stopReasonClosingTime {
+			break
+		}
+		if session.StopReason() == stopReasonUser {
+			break
+		}
+	}
+
+	logInfo("launcher", "launcher exiting", "exit_code", exitCode)
+	return exitCode
+}
+
+// StartupSettings controls the order children start in. With concurrent
+// the Flutter app starts right after the backend is spawned instead of
+// once it is healthy, and waits for WAP_BACKEND_HEALTH_URL itself.
+// TimeoutSeconds bounds the whole startup (startuptimeout.go); phases
+// failing for a transient reason are run again (retry.go).
+type StartupSettings struct {
+	Concurrent        bool `json:"concurrent"`
+	TimeoutSeconds    int  `json:"timeout_seconds"`     // default 120
+	Retries           int  `json:"retries"`             // default 2, -1 never retries
+	RetryDelaySeconds int  `json:"retry_delay_seconds"` // default 2, growing with each retry
+}
+
+// runSession starts the backend and the Flutter app and waits until the
+// app exits or the session is stopped. It returns exitOK unless startup
+// failed.
+func runSession(session *Session, demoEnd time.Time) int {
+	config := session.config
+
+	session.control.SetServiceHandler(session.handleServiceCommand)
+	defer session.control.SetServiceHandler(nil)
+	session.deadline = watchStartupDeadline(session)
+
 	// Start Python backend server
-	pythonProcess, err := startPythonBackend(config)
+	session.control.resetProgress()
+	session.control.SetStage(stageStartingServer, "")
+	if !config.RemoteBackend {
+		emitPhase(phaseBackendStart, phaseStarted, 0, "")
+		if !attachRunningBackend(config, session) {
+			if err := runHooks(config, hookPreBackendStart); err != nil {
+				session.splash.Close()
+				showError("A startup hook failed", err)
+				return exitHookFailed
+			}
+			var pythonProcess *exec.Cmd
+			err := retryTransient(config, "Starting the Python backend", func() (err error) {
+				pythonProcess, err = startBackend(config, session.control)
+				return err
+			})
+			if err != nil {
+				session.splash.Close()
+				showError("Failed to start Python backend", err)
+				return exitBackendStart
+			}
+			session.watchBackend(pythonProcess)
+		}
+		emitPhase(phaseBackendStart, phaseDone, 100, "")
+	} else {
+		consolePrintf("Using remote backend %s\n", config.BackendURL)
+		logInfo("backend", "using remote backend", "url", config.BackendURL)
+	}
+
+	// With startup.concurrent the app starts now and shows its own loading
+	// state until WAP_BACKEND_HEALTH_URL answers
+	var early *frontendLaunch
+	if config.Settings.Startup.Concurrent && !session.background {
+		consolePrintln("Starting Flutter application alongside the backend...")
+		emitPhase(phaseFrontendStart, phaseStarted, 0, "")
+		var err error
+		if err = retryTransient(config, "Starting the Flutter application", func() (err error) {
+			early, err = launchFrontend(config, session)
+			return err
+		}); err != nil {
+			session.stopBackend()
+			session.splash.Close()
+			showError("Failed to start Flutter application", err)
+			return exitFrontendStart
+		}
+	}
+
+	// Wait for the Python server to answer; the backend reports its own
+	// stages (loading models, ...) to the splash meanwhile
+	consolePrintln("Waiting for Python server to start...")
+	emitPhase(phaseBackendHealth, phaseStarted, 0, "")
+	unsubscribe := session.control.OnStatus(func(summary string) {
+		emitPhase(phaseBackendHealth, phaseProgress, session.control.status().Percent, summary)
+	})
+	// The startup deadline stops a backend that takes longer than both
+	err := waitForBackendHealthy(session, max(backendStartTimeout, startupTimeout(config)))
+	for attempt := 1; err != nil && retryBackendStart(session, attempt); attempt++ {
+		err = waitForBackendHealthy(session, max(backendStartTimeout, startupTimeout(config)))
+	}
+	unsubscribe()
 	if err != nil {
-		showError("Failed to start Python backend", err)
-		return
+		if early != nil {
+			stopFrontend(early.cmd, early.exited)
+		}
+		session.stopBackend()
+		session.splash.Close()
+		if session.StopReason() != "" {
+			return exitOK
+		}
+		if rollBackFailedUpdate(config, true) {
+			return exitUpdateFailed
+		}
+		showBackendError(config, "Python backend did not start", err)
+		return exitBackendUnhealthy
+	}
+	consoleSuccessf("Python server is ready")
+	if err := checkBackendSession(session); err != nil {
+		session.stopBackend()
+		session.splash.Close()
+		showError("The backend's port belongs to another user", err)
+		return exitBackendUnhealthy
+	}
+	checkBackendBinding(session, true)
+	emitPhase(phaseBackendHealth, phaseDone, 100, "")
+	session.markHealthy()
+	if err := runHooks(config, hookPostHealthy); err != nil {
+		if early != nil {
+			stopFrontend(early.cmd, early.exited)
+		}
+		session.stopBackend()
+		session.splash.Close()
+		showError("A startup hook failed", err)
+		return exitHookFailed
+	}
+	session.workers = startWorkers(session)
+	if early == nil {
+		session.control.SetStage(stageAlmostReady, "")
 	}
 
-	// Wait a moment for the Python server to start
-	fmt.Println("Waiting for Python server to start...")
-	time.Sleep(3 * time.Second)
+	// A backend that did not survive sleep is restarted after resume
+	if !config.RemoteBackend {
+		defer onResume(func() {
+			session.checkBackendAfterResume(func() {
+				if err := session.restartBackend(); err != nil {
+					logError("power", "failed to restart backend after resume", "error", err)
+				}
+			})
+		})()
+	}
 
-	// Start the Flutter application
-	if err := startFlutterApplication(config, pythonProcess); err != nil {
+	if !demoEnd.IsZero() {
+		startDemoTimer(session, demoEnd)
+	}
+	if hours := config.Settings.OperatingHours; hours != nil {
+		startClosingTimer(session, hours)
+	}
+
+	// Start the Flutter application, or keep watching the one started early
+	if early == nil {
+		emitPhase(phaseFrontendStart, phaseStarted, 0, "")
+	}
+	if err := startFlutterApplication(config, session, early); err != nil {
+		session.splash.Close()
+		if rollBackFailedUpdate(config, true) {
+			session.stopBackend()
+			return exitUpdateFailed
+		}
+		if errors.Is(err, errFrontendNoWindow) {
+			showFrontendHang(config)
+			session.stopBackend()
+			return exitFrontendHung
+		}
 		showError("Failed to start Flutter application", err)
 		// Try to kill Python process if Flutter fails
-		if pythonProcess != nil {
-			pythonProcess.Process.Kill()
-		}
-		return
+		session.stopBackend()
+		return exitFrontendStart
 	}
+
+	return exitOK
+}
+
+func newAppConfig(exePath string) *AppConfig {
+	config := &AppConfig{
+		AppName: "WAP Application",
+	}
+
+	exeDir := filepath.Dir(exePath)
+	config.RootDir = exeDir
+	config.BinDir = filepath.Join(exeDir, "bin")
+	config.AppExe = filepath.Join(config.BinDir, "wap.exe")
+	config.PythonDir = filepath.Join(config.BinDir, "embedded_python")
+	config.PythonExe = filepath.Join(config.PythonDir, "python.exe")
+	config.BackendDir = filepath.Join(config.BinDir, "python_backend")
+	config.BackendScript = filepath.Join(config.BackendDir, "start_server.py")
+	setPortableDirs(config) // until the settings are loaded
+	config.BackendURL = "http://127.0.0.1:5000"
+	config.FlutterDLL = filepath.Join(config.BinDir, "flutter_windows.dll")
+
+	return config
 }
 
-func validateEnvironment(config *AppConfig) bool {
-	requiredFiles := []struct {
-		path string
-		name string
-	}{
-		{config.AppExe, "Main application (wap.exe)"},
-		{config.FlutterDLL, "Flutter DLL (flutter_windows.dll)"},
-		{config.PythonExe, "Python executable"},
-		{config.BackendScript, "Python backend script (start_server.py)"},
-		{config.PythonDir, "Python backend"},
-		{config.DataDir, "Data directory"},
+func validateEnvironment(config *AppConfig, backend, frontend bool) bool {
+	var requiredFiles []requiredFile
+	// Thin clients use a remote backend, headless runs have no Flutter app
+	if backend {
+		requiredFiles = append(requiredFiles, config.Backend.Files(config)...)
+		requiredFiles = append(requiredFiles, requiredFile{config.DataDir, "Data directory"})
+	}
+	if frontend {
+		requiredFiles = append(config.Frontend.Files(config), requiredFiles...)
 	}
 
-	fmt.Println("Checking required files...")
+	consolePrintln(tr("Checking required files..."))
 	allValid := true
 
-	for _, file := range requiredFiles {
-		if _, err := os.Stat(file.path); os.IsNotExist(err) {
-			fmt.Printf("❌ %s not found: %s\n", file.name, file.path)
+	// Stat in parallel, report in order; network and AV-scanned installs
+	// make each stat slow
+	missing := make([]bool, len(requiredFiles))
+	parallelEach(len(requiredFiles), func(i int) {
+		_, err := os.Stat(requiredFiles[i].path)
+		missing[i] = os.IsNotExist(err)
+	})
+	for i, file := range requiredFiles {
+		if missing[i] {
+			consoleErrorf(tr("%s not found: %s"), tr(file.name), file.path)
+			logError("validate", "required file missing", "name", file.name, "path", file.path)
 			allValid = false
 		} else {
-			fmt.Printf("✓ %s found\n", file.name)
+			consoleSuccessf(tr("%s found"), tr(file.name))
 		}
 	}
 
 	return allValid
 }
 
-func startPythonBackend(config *AppConfig) (*exec.Cmd, error) {
-	fmt.Printf("\nStarting Python backend server...\n")
-	fmt.Printf("Python executable: %s\n", config.PythonExe)
-	
-	// Use start_server.py instead of api_server.py
-	startScript := filepath.Join(config.BackendDir, "start_server.py")
-	fmt.Printf("Start script: %s\n", startScript)
-
-	// Check if start_server.py exists
-	if _, err := os.Stat(startScript); os.IsNotExist(err) {
-		return nil, fmt.Errorf("start_server.py not found at: %s", startScript)
-	}
+// startBackend starts the backend with the configured runner
+// (backendrunner.go)
+func startBackend(config *AppConfig, control *controlServer) (*exec.Cmd, error) {
+	consolePrintf("\nStarting Python backend server...\n")
+	return startBackendProcess(config, control, serviceBackend, backendLogName)
+}
 
-	cmd := exec.Command(config.PythonExe, "start_server.py")
-	cmd.Dir = config.BackendDir
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+// startBackendProcess starts one backend process named service in the
+// status, logging to logName; workers (workers.go) are started this way too
+func startBackendProcess(config *AppConfig, control *controlServer, service, logName string) (*exec.Cmd, error) {
+	cmd, err := config.Backend.Command(config)
+	if err != nil {
+		return nil, err
 	}
+	program := cmd.Path
 
 	// Hide the console window
 	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow: true,  // This hides the console window
+		HideWindow: true, // This hides the console window
 	}
 
-
-	// Create log file for Python backend
-	pythonLogFile, err := os.Create(filepath.Join(config.BinDir, "python_server.log"))
+	env, err := backendEnv(config, strconv.Itoa(backendPort(config)), control.token)
+	if err != nil {
+		return nil, err
+	}
+	dotEnv, err := loadDotEnv(config)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Env = append(cmd.Env, dotEnv...)
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Env = append(cmd.Env, control.env()...)
+	if cmd.Env, err = resolveSecretEnv(config, cmd.Env); err != nil {
+		return nil, err
+	}
+	if err := config.Settings.Processes.Backend.prepare(cmd); err != nil {
+		return nil, fmt.Errorf("processes.backend: %w", err)
+	}
+	if err := verifySignature(config, program); err != nil {
+		return nil, err
+	}
+	releaseSandbox, err := applyBackendSandbox(config, cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create log file: %w", err)
+		return nil, err
 	}
+	defer releaseSandbox()
 
-	cmd.Stdout = pythonLogFile
-	cmd.Stderr = pythonLogFile
+	if warmBackendEnabled(config) {
+		// A backend that may outlive the launcher writes straight to its
+		// log file, as a pipe would break when the launcher exits
+		logFile, err := os.Create(filepath.Join(config.LogDir, logName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create log file: %w", err)
+		}
+		defer logFile.Close() // the backend inherits its own handle
+		cmd.Stdout, cmd.Stderr = logFile, logFile
+		cmd.SysProcAttr.CreationFlags |= detachedProcessFlag | syscall.CREATE_NEW_PROCESS_GROUP
+	} else {
+		// Log files for Python backend, closed by watchBackend
+		output, err := openChildOutput(config.LogDir, logName, watchChildLines(config, control, service))
+		if err != nil {
+			return nil, err
+		}
+		output.mirrorToConsole(config, mirrorPython)
+		cmd.Stdout = output.stdout
+		cmd.Stderr = output.stderr
+	}
 
-	fmt.Printf("Executing: %s start_server.py\n", config.PythonExe)
-	fmt.Printf("Working directory: %s\n", cmd.Dir)
+	consoleDetailf("Executing: %s %s", program, strings.Join(cmd.Args[1:], " "))
+	consoleDetailf("Working directory: %s", cmd.Dir)
 
 	err = cmd.Start()
 	if err != nil {
-		pythonLogFile.Close()
+		closeChildOutput(cmd.Stdout)
 		return nil, fmt.Errorf("failed to start Python backend: %w", err)
 	}
 
-	fmt.Printf("✓ Python backend started (PID: %d)\n", cmd.Process.Pid)
-	fmt.Printf("✓ Python server log: %s\\python_server.log\n", config.BinDir)
+	config.Settings.Processes.Backend.apply("backend", cmd.Process.Pid)
+	assignBackendJob(config, cmd.Process.Pid)
+	if service == serviceBackend {
+		markStartup("backend_spawned")
+	}
+	consoleSuccessf("Python backend started (PID: %d)", cmd.Process.Pid)
+	logInfo("backend", "python backend started", "service", service, "child_pid", cmd.Process.Pid, "runner", config.Backend.Name(), "program", program)
+	consoleDetailf("Python server log: %s", filepath.Join(config.LogDir, logName))
 
 	return cmd, nil
 }
 
-func startFlutterApplication(config *AppConfig, pythonProcess *exec.Cmd) error {
-	fmt.Printf("\nStarting Flutter application...\n")
-	fmt.Printf("Application: %s\n", config.AppExe)
-	fmt.Printf("Working directory: %s\n", config.BinDir)
+// frontendLaunch is a running wap.exe, the channel receiving its exit and
+// the one closed if it shows no window in time
+type frontendLaunch struct {
+	cmd      *exec.Cmd
+	exited   <-chan error
+	noWindow <-chan struct{}
+}
+
+// startFlutterApplication runs the Flutter app until it exits for good.
+// first is an app already started alongside the backend, or nil.
+func startFlutterApplication(config *AppConfig, session *Session, first *frontendLaunch) error {
+	if first == nil {
+		consolePrintf("\nStarting Flutter application...\n")
+		consoleDetailf("Application: %s", frontendProgram(config))
+		consoleDetailf("Working directory: %s", config.BinDir)
+	}
+
+	relaunchDelay := kioskMinRelaunchDelay
+	hangRetried := false
+	crashRestarts := 0
+	// A minimized start opens the app only when it is asked for, and waits
+	// again once it is closed
+	waitForShow := session.background
+	for {
+		launch := first
+		if launch != nil {
+			first = nil
+		} else {
+			if waitForShow {
+				if !session.waitForShow() {
+					break
+				}
+				waitForShow = false
+				session.splash = showSplash(config, session.control)
+			}
+			var err error
+			if err = retryTransient(config, "Starting the Flutter application", func() (err error) {
+				launch, err = launchFrontend(config, session)
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		cmd, exited := launch.cmd, launch.exited
+		started := time.Now()
+		var err error
+
+		// Wait for the Flutter app to exit, or close it when the session is stopped
+		stopping := false
+		select {
+		case err = <-exited:
+		case <-session.Stopping():
+			consolePrintln("Closing Flutter application...")
+			err = stopFrontend(cmd, exited)
+			stopping = true
+		case <-launch.noWindow:
+			killHungFrontend(config, launch)
+			if hangRetried {
+				return errFrontendNoWindow
+			}
+			hangRetried = true
+			consoleErrorf("The application window did not appear, starting it again...")
+			continue
+		}
+		if !stopping && session.takeFrontendRestart() {
+			consolePrintln("Restarting Flutter application...")
+			continue
+		}
+		if err != nil {
+			consoleErrorf("Flutter application exited with error: %v", err)
+			logWarn("frontend", "flutter application exited with error", "error", err)
+			reportEvent(eventTypeWarning, eventIDShutdownAnomaly, fmt.Sprintf("The WAP application exited with an error: %v", err))
+			if !stopping {
+				reportCrash(config, serviceFrontend, err)
+			}
+		} else {
+			consolePrintln("Flutter application exited successfully")
+			logInfo("frontend", "flutter application exited")
+		}
+		// Outside kiosk mode a crash may restart the app alone, keeping the
+		// backend (frontend_restart)
+		if err != nil && !stopping && !session.kiosk && restartCrashedFrontend(config, err, crashRestarts) {
+			crashRestarts++
+			consolePrintln("Restarting Flutter application...")
+			continue
+		}
+		if !stopping && session.background {
+			waitForShow, hangRetried, crashRestarts = true, false, 0
+			continue
+		}
+		if stopping || !session.kiosk {
+			break
+		}
+
+		// Kiosks relaunch the app with the backend still running, backing
+		// off while it keeps exiting right after starting
+		if time.Since(started) > kioskStableRuntime {
+			relaunchDelay = kioskMinRelaunchDelay
+		} else {
+			relaunchDelay = min(relaunchDelay*2, kioskMaxRelaunchDelay)
+		}
+		logWarn("frontend", "kiosk mode, relaunching flutter application", "delay", relaunchDelay.String())
+		select {
+		case <-time.After(relaunchDelay):
+		case <-session.Stopping():
+		}
+		if session.StopReason() != "" {
+			break
+		}
+	}
+
+	emitPhase(phaseRunning, phaseDone, 100, "")
+
+	// Cleanup: Kill Python process when Flutter app closes, unless it is
+	// kept warm for the next launch
+	if session.backend != nil && warmBackendEnabled(config) && session.StopReason() == "" {
+		keepBackendWarm(config, session)
+	} else if session.backend != nil {
+		runHooks(config, hookPreShutdown)
+		session.workers.Close()
+		consolePrintln("Shutting down Python backend...")
+		session.stopBackend()
+		consolePrintln("Python backend stopped")
+		logInfo("backend", "python backend stopped")
+		backupOnExit(config)
+	}
+
+	return nil
+}
+
+func frontendArgs(config *AppConfig, kiosk bool) []string {
+	var args []string
+	if kiosk {
+		args = append(args, config.Settings.Kiosk.FrontendArgs...)
+	}
+	args = append(args, config.AppArgs...)
+	for _, open := range []string{config.OpenURL, config.OpenFile} {
+		if open != "" {
+			args = append(args, open)
+		}
+	}
+	return args
+}
+
+// backendArgs is the backend's command line after the interpreter
+func backendArgs(config *AppConfig) []string {
+	return append([]string{"start_server.py"}, config.BackendArgs...)
+}
 
-	cmd := exec.Command(config.AppExe)
-	cmd.Dir = config.BinDir
+// launchFrontend starts the app with the configured runner
+// (frontendrunner.go) and returns a channel receiving its exit
+func launchFrontend(config *AppConfig, session *Session) (*frontendLaunch, error) {
+	cmd, err := config.Frontend.Command(config, session.kiosk)
+	if err != nil {
+		return nil, err
+	}
+	if cmd == nil {
+		return launchBrowser(config, session), nil
+	}
+	program := cmd.Path
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
 	}
 
-	// Create log file for Flutter app
-	flutterLogFile, err := os.Create(filepath.Join(config.BinDir, "flutter_app.log"))
+	env, err := frontendEnv(config)
 	if err != nil {
-		return fmt.Errorf("failed to create log file: %w", err)
+		return nil, err
+	}
+	cmd.Env, err = resolveSecretEnv(config, append(os.Environ(), env...))
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Settings.Processes.Frontend.prepare(cmd); err != nil {
+		return nil, fmt.Errorf("processes.frontend: %w", err)
+	}
+	if err := verifySignature(config, program); err != nil {
+		return nil, err
 	}
-	defer flutterLogFile.Close()
-
-	cmd.Stdout = flutterLogFile
-	cmd.Stderr = flutterLogFile
 
-	err = cmd.Start()
+	// Log files for Flutter app, closed once it exits
+	output, err := openChildOutput(config.LogDir, frontendLogName, watchChildLines(config, session.control, serviceFrontend))
 	if err != nil {
-		return fmt.Errorf("failed to start Flutter application: %w", err)
+		return nil, err
 	}
+	output.mirrorToConsole(config, mirrorFlutter)
 
-	fmt.Printf("✓ Flutter application started (PID: %d)\n", cmd.Process.Pid)
-	fmt.Printf("✓ Flutter app log: %s\\flutter_app.log\n", config.BinDir)
-	fmt.Println("✓ Both Python server and Flutter app are running...")
-	fmt.Println("✓ Application should be available shortly...")
+	cmd.Stdout = output.stdout
+	cmd.Stderr = output.stderr
 
-	// Wait for the Flutter app to exit
-	err = cmd.Wait()
+	err = cmd.Start()
 	if err != nil {
-		fmt.Printf("Flutter application exited with error: %v\n", err)
-	} else {
-		fmt.Println("Flutter application exited successfully")
+		output.Close()
+		return nil, fmt.Errorf("failed to start Flutter application: %w", err)
 	}
 
-	// Cleanup: Kill Python process when Flutter app closes
-	if pythonProcess != nil {
-		fmt.Println("Shutting down Python backend...")
-		pythonProcess.Process.Kill()
-		pythonProcess.Wait()
-		fmt.Println("Python backend stopped")
+	config.Settings.Processes.Frontend.apply("frontend", cmd.Process.Pid)
+	// A restarted app does not open the link or file again
+	config.OpenURL, config.OpenFile = "", ""
+	markStartup("frontend_spawned")
+	consoleSuccessf("Flutter application started (PID: %d)", cmd.Process.Pid)
+	logInfo("frontend", "flutter application started", "child_pid", cmd.Process.Pid)
+	consoleDetailf("Flutter app log: %s", filepath.Join(config.LogDir, frontendLogName))
+	consoleSuccessf("Both Python server and Flutter app are running...")
+	consoleSuccessf("Application should be available shortly...")
+
+	session.mu.Lock()
+	session.frontend = cmd
+	session.mu.Unlock()
+	removeTaskbarWindow := addTaskbarWindow(func() uintptr {
+		if windows := processWindows(cmd.Process.Pid); len(windows) > 0 {
+			return windows[0]
+		}
+		return 0
+	})
+	session.control.serviceStarted(serviceFrontend, cmd.Process.Pid)
+	noWindow := session.splash.closeWhenWindowShown(cmd.Process.Pid, windowTimeout(config), session.Healthy(), func() {
+		emitPhase(phaseFrontendStart, phaseDone, 100, "")
+		emitPhase(phaseRunning, phaseStarted, 100, "")
+		// The app is up, so a freshly installed update is good
+		confirmUpdateStarted(config)
+	})
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		output.Close()
+		removeTaskbarWindow()
+		reason := "exited"
+		if err != nil {
+			reason = "exited_with_error"
+		}
+		session.control.serviceStopped(serviceFrontend, reason)
+		exited <- err
+	}()
+	launch := &frontendLaunch{cmd: cmd, exited: exited}
+	if !config.Settings.WindowWatchdog.Disabled {
+		launch.noWindow = noWindow
 	}
+	return launch, nil
+}
 
-	return nil
+// stopFrontend closes the Flutter windows and gives the app a few seconds
+// to exit on its own before killing it.
+func stopFrontend(cmd *exec.Cmd, exited <-chan error) error {
+	if cmd == nil {
+		return nil // a browser tab is the user's to close
+	}
+	if closeProcessWindows(cmd.Process.Pid) {
+		select {
+		case err := <-exited:
+			return err
+		case <-time.After(5 * time.Second):
+			logWarn("frontend", "flutter application did not close in time, killing it")
+			reportEvent(eventTypeWarning, eventIDShutdownAnomaly, "The WAP application did not close within 5 seconds and was terminated.")
+		}
+	}
+	cmd.Process.Kill()
+	return <-exited
 }
 
 func showError(title string, err error) {
-	fmt.Printf("\nERROR: %s\n", title)
+	logError("launcher", title, "error", err)
+	message := title
 	if err != nil {
-		fmt.Printf("Details: %v\n", err)
+		message += ": " + err.Error()
+	}
+	reportEvent(eventTypeError, eventIDStartupFailure, message)
+	noteError(message)
+
+	// Wrappers reading JSON output are not interactive
+	if machineOutput() {
+		emitPhaseFailed(message)
+		return
 	}
-	fmt.Println("\nPress Enter to exit...")
+	title = tr(title)
+	// Without a console the error would go unseen
+	if !hasConsole() {
+		text := title
+		if err != nil {
+			text += ": " + err.Error()
+		}
+		messageBox(title, text, mbOK|mbIconError|mbTopmost)
+		return
+	}
+
+	consoleLine(verbosityQuiet, ansiRed, "\n"+tr("ERROR")+": "+title)
+	if err != nil {
+		consoleLine(verbosityQuiet, "", tr("Details")+": "+err.Error())
+	}
+	// Scripts asking for --quiet do not press Enter
+	if consoleVerbosity() == verbosityQuiet {
+		return
+	}
+	consolePrintln("\n" + tr("Press Enter to exit..."))
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
-}
\ No newline at end of file
+}
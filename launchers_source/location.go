@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// SQLite relies on file locking that network shares and sync clients
+// (OneDrive, Dropbox, Google Drive) do not honour, and a database synced
+// mid-write comes back corrupt. A portable install copied to such a folder
+// would keep its data there, so the launcher offers to move the data to
+// %LOCALAPPDATA% instead; allow_remote_data in launcher.json accepts the
+// risk. A per-user data folder that is itself redirected to the network
+// can only be warned about.
+var procGetDriveTypeW = kernel32.NewProc("GetDriveTypeW")
+
+const (
+	driveRemote = 4 // DRIVE_REMOTE
+
+	// In %LOCALAPPDATA%\WAP: the portable installs whose data was moved
+	localDataFileName = "local_data.json"
+
+	// Files and folders managed by a cloud files provider
+	fileAttributeRecallOnDataAccess = 0x00400000
+	fileAttributePinned             = 0x00080000
+	fileAttributeUnpinned           = 0x00100000
+)
+
+// dropboxInfo is the part of Dropbox's info.json naming its folders
+type dropboxInfo map[string]struct {
+	Path string `json:"path"`
+}
+
+// underDir reports whether path is dir or inside it
+func underDir(path, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	path, dir = strings.ToLower(filepath.Clean(path)), strings.ToLower(filepath.Clean(dir))
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, `\`)+`\`)
+}
+
+// syncedFolders returns the OneDrive and Dropbox folders of this user
+func syncedFolders() map[string]string {
+	folders := map[string]string{}
+	for _, name := range []string{"OneDrive", "OneDriveConsumer", "OneDriveCommercial"} {
+		if dir := os.Getenv(name); dir != "" {
+			folders[dir] = "OneDrive"
+		}
+	}
+	for _, base := range []string{os.Getenv("APPDATA"), os.Getenv("LOCALAPPDATA")} {
+		var info dropboxInfo
+		if base == "" || readJSONFile(filepath.Join(base, "Dropbox", "info.json"), &info) != nil {
+			continue
+		}
+		for _, account := range info {
+			if account.Path != "" {
+				folders[account.Path] = "Dropbox"
+			}
+		}
+	}
+	return folders
+}
+
+// existingAncestor returns path or the nearest parent that exists
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// remoteLocation describes why path is unsafe for databases, "" if it is
+// on a local disk
+func remoteLocation(path string) string {
+	path = filepath.Clean(strings.TrimPrefix(path, `\\?\`))
+	if strings.HasPrefix(path, `\\`) || strings.HasPrefix(strings.ToUpper(path), `UNC\`) {
+		return tr("a network share")
+	}
+	if volume := filepath.VolumeName(path); len(volume) == 2 {
+		root := syscall.StringToUTF16Ptr(volume + `\`)
+		if kind, _, _ := procGetDriveTypeW.Call(uintptr(unsafe.Pointer(root))); kind == driveRemote {
+			return tr("a mapped network drive (%s)", volume)
+		}
+	}
+	for dir, provider := range syncedFolders() {
+		if underDir(path, dir) {
+			return tr("a %s folder", provider)
+		}
+	}
+	if attrs, err := syscall.GetFileAttributes(syscall.StringToUTF16Ptr(existingAncestor(path))); err == nil &&
+		attrs&(fileAttributeRecallOnDataAccess|fileAttributePinned|fileAttributeUnpinned) != 0 {
+		return tr("a cloud-synced folder")
+	}
+	return ""
+}
+
+func localDataPath() string {
+	return filepath.Join(os.Getenv("LOCALAPPDATA"), userDirName, localDataFileName)
+}
+
+// useLocalData switches a portable install to the per-user directories
+func useLocalData(config *AppConfig) {
+	config.Settings.Portable = false
+	setUserDirs(config)
+}
+
+// dataMovedBefore reports whether the user already moved this portable
+// install's data to this PC
+func dataMovedBefore(config *AppConfig) bool {
+	var moved []string
+	if os.Getenv("LOCALAPPDATA") == "" || readJSONFile(localDataPath(), &moved) != nil {
+		return false
+	}
+	for _, dir := range moved {
+		if strings.EqualFold(filepath.Clean(dir), filepath.Clean(config.BinDir)) {
+			return true
+		}
+	}
+	return false
+}
+
+func rememberDataMoved(config *AppConfig) error {
+	var moved []string
+	readJSONFile(localDataPath(), &moved)
+	if err := os.MkdirAll(filepath.Dir(localDataPath()), 0755); err != nil {
+		return err
+	}
+	return writeJSONFile(localDataPath(), append(moved, config.BinDir))
+}
+
+// checkDataLocation warns about data on a network or synced folder and,
+// for a portable install, offers to move it to this PC. It returns false
+// if the launcher should exit.
+func checkDataLocation(config *AppConfig, interactive bool) bool {
+	if config.Settings.AllowRemoteData || config.RemoteBackend {
+		return true
+	}
+	if config.Portable && dataMovedBefore(config) {
+		useLocalData(config)
+		return true
+	}
+	where := remoteLocation(config.DataDir)
+	if where == "" {
+		if where := remoteLocation(config.BinDir); where != "" {
+			logInfo("launcher", "application runs from "+where, "bin_dir", config.BinDir)
+		}
+		return true
+	}
+	logWarn("launcher", "data directory is on "+where, "data_dir", config.DataDir, "portable", config.Portable)
+	warning := tr("The application data in %s is on %s. Databases there can be damaged when the file is synced or the network drops.", config.DataDir, where)
+	if !interactive || machineOutput() {
+		consoleWarnf("%s", warning)
+		return true
+	}
+
+	if !config.Portable || os.Getenv("LOCALAPPDATA") == "" {
+		messageBox(config.AppName, warning+"\n\n"+tr("Ask your administrator to keep %LOCALAPPDATA% on this PC."), mbOK|mbIconWarning|mbTopmost)
+		return true
+	}
+	answer := messageBox(config.AppName,
+		warning+"\n\n"+tr("Move the data to this PC (%%LOCALAPPDATA%%\\%s)? The original stays where it is.\n\nYes moves it, No continues, Cancel quits.", userDirName),
+		mbYesNoCancel|mbIconWarning|mbTopmost)
+	switch answer {
+	case idYes:
+		// prepareUserDirs copies bin\data over on first use
+		if err := rememberDataMoved(config); err != nil {
+			logWarn("launcher", "failed to remember the moved data", "error", err)
+		}
+		useLocalData(config)
+		logInfo("launcher", "moved data off "+where, "data_dir", config.DataDir)
+		return true
+	case idNo:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log formats accepted by --log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case levelDebug:
+		return "debug"
+	case levelInfo:
+		return "info"
+	case levelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// Logger writes launcher.log, either as plain text lines or as one JSON
+// object per line for log collectors (Splunk, ELK, ...).
+type Logger struct {
+	mu       sync.Mutex
+	out      io.Writer
+	file     *os.File
+	format   string
+	pid      int
+	minLevel logLevel
+	trace    io.Writer // copy of every record while a trace runs
+	verbose  bool      // debug records even without a trace (Safe Mode)
+}
+
+type logRecord struct {
+	Timestamp string                 `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Component string                 `json:"component"`
+	PID       int                    `json:"pid"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// launcherLog is discarded until openLauncherLog is called
+var launcherLog = &Logger{out: io.Discard, format: logFormatText, pid: os.Getpid(), minLevel: levelInfo}
+
+func validLogFormat(format string) bool {
+	return format == logFormatText || format == logFormatJSON
+}
+
+func openLauncherLog(path, format string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open launcher log: %w", err)
+	}
+
+	launcherLog.mu.Lock()
+	defer launcherLog.mu.Unlock()
+	if launcherLog.file != nil {
+		launcherLog.file.Close()
+	}
+	launcherLog.file = file
+	launcherLog.out = file
+	launcherLog.format = format
+	return nil
+}
+
+func closeLauncherLog() {
+	launcherLog.mu.Lock()
+	defer launcherLog.mu.Unlock()
+	if launcherLog.file != nil {
+		launcherLog.file.Close()
+		launcherLog.file = nil
+	}
+	launcherLog.out = io.Discard
+}
+
+// log writes a single record. fields are key/value pairs:
+// log(levelInfo, "backend", "started", "pid", 1234)
+func (l *Logger) log(level logLevel, component, msg string, fields ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.minLevel {
+		return
+	}
+
+	record := logRecord{
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Level:     level.String(),
+		Component: component,
+		PID:       l.pid,
+		Message:   msg,
+		Fields:    fieldMap(fields),
+	}
+
+	var line string
+	if l.format == logFormatJSON {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return
+		}
+		line = string(data) + "\n"
+	} else {
+		line = formatTextRecord(record)
+	}
+	io.WriteString(l.out, line)
+	if l.trace != nil {
+		io.WriteString(l.trace, line)
+	}
+}
+
+// setLogTrace copies all records, debug included, to w until it is called
+// again with nil
+func setLogTrace(w io.Writer) {
+	launcherLog.mu.Lock()
+	defer launcherLog.mu.Unlock()
+	launcherLog.trace = w
+	launcherLog.updateLevel()
+}
+
+// setLogVerbose writes debug records to launcher.log
+func setLogVerbose(verbose bool) {
+	launcherLog.mu.Lock()
+	defer launcherLog.mu.Unlock()
+	launcherLog.verbose = verbose
+	launcherLog.updateLevel()
+}
+
+// updateLevel is called with mu held
+func (l *Logger) updateLevel() {
+	l.minLevel = levelInfo
+	if l.trace != nil || l.verbose {
+		l.minLevel = levelDebug
+	}
+}
+
+func fieldMap(fields []interface{}) map[string]interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		if i+1 >= len(fields) {
+			m[key] = nil
+			break
+		}
+		value := fields[i+1]
+		// errors marshal to {} in JSON, keep their text instead
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		m[key] = value
+	}
+	return m
+}
+
+func formatTextRecord(record logRecord) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s [%s] %s", record.Timestamp, strings.ToUpper(record.Level), record.Component, record.Message)
+
+	keys := make([]string, 0, len(record.Fields))
+	for key := range record.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, record.Fields[key])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func logDebug(component, msg string, fields ...interface{}) {
+	launcherLog.log(levelDebug, component, msg, fields...)
+}
+
+func logInfo(component, msg string, fields ...interface{}) {
+	launcherLog.log(levelInfo, component, msg, fields...)
+}
+
+func logWarn(component, msg string, fields ...interface{}) {
+	launcherLog.log(levelWarn, component, msg, fields...)
+}
+
+func logError(component, msg string, fields ...interface{}) {
+	launcherLog.log(levelError, component, msg, fields...)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Log file names inside config.LogDir
+const (
+	launcherLogName = "launcher.log"
+	backendLogName  = "python_server.log"
+	frontendLogName = "flutter_app.log"
+)
+
+var logTargets = map[string]string{
+	"backend":        backendLogName,
+	"backend-stdout": streamLogName(backendLogName, streamStdout),
+	"backend-stderr": streamLogName(backendLogName, streamStderr),
+	"flutter":        frontendLogName,
+	"flutter-stdout": streamLogName(frontendLogName, streamStdout),
+	"flutter-stderr": streamLogName(frontendLogName, streamStderr),
+	"launcher":       launcherLogName,
+	"proxy":          proxyAccessLogName,
+}
+
+type logsOptions struct {
+	tail   int
+	follow bool
+}
+
+func (o *logsOptions) register(fs *flag.FlagSet) {
+	fs.IntVar(&o.tail, "tail", 50, "number of lines to show from the end of the log (0 for all)")
+	fs.BoolVar(&o.follow, "follow", false, "keep printing new lines as they are written")
+}
+
+// runLogsCommand implements: launcher logs [backend|flutter|launcher] --tail N --follow
+func runLogsCommand(config *AppConfig, args []string) int {
+	target := "backend"
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		target, args = args[0], args[1:]
+	}
+
+	var opts logsOptions
+	fs := newCommandFlagSet("logs")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+
+	name, ok := logTargets[target]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown log %q (expected %s)\n", target, strings.Join(logTargetNames(), ", "))
+		return exitUsage
+	}
+	path := filepath.Join(config.LogDir, name)
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot open %s: %v\n", path, err)
+		return exitLauncherError
+	}
+	defer file.Close()
+
+	offset, err := printLastLines(file, opts.tail, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read %s: %v\n", path, err)
+		return exitLauncherError
+	}
+	if opts.follow {
+		file.Close()
+		followFile(path, offset, os.Stdout)
+	}
+	return exitOK
+}
+
+// printLastLines writes the last n lines of file (all of it for n <= 0)
+// and returns the offset reading stopped at.
+func printLastLines(file *os.File, n int, out io.Writer) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	start := int64(0)
+	if n > 0 {
+		// Read backwards in chunks until enough newlines are found
+		const chunkSize = 64 * 1024
+		found := 0
+		pos := size
+		buf := make([]byte, chunkSize)
+	search:
+		for pos > 0 {
+			readSize := int64(chunkSize)
+			if pos < readSize {
+				readSize = pos
+			}
+			pos -= readSize
+			if _, err := file.ReadAt(buf[:readSize], pos); err != nil && err != io.EOF {
+				return 0, err
+			}
+			chunk := buf[:readSize]
+			for i := len(chunk) - 1; i >= 0; i-- {
+				// Ignore the newline that ends the last line
+				if chunk[i] == '\n' && pos+int64(i) != size-1 {
+					found++
+					if found == n {
+						start = pos + int64(i) + 1
+						break search
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	copied, err := io.Copy(out, file)
+	return start + copied, err
+}
+
+// followFile prints data appended to path until interrupted. The launcher
+// recreates child logs on every start, so a shrinking file is reopened.
+func followFile(path string, offset int64, out io.Writer) {
+	buf := make([]byte, 32*1024)
+	for {
+		file, err := os.Open(path)
+		if err != nil {
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+
+		for {
+			n, err := file.ReadAt(buf, offset)
+			if n > 0 {
+				out.Write(buf[:n])
+				offset += int64(n)
+			}
+			if err == nil {
+				continue
+			}
+			if err != io.EOF {
+				break
+			}
+
+			time.Sleep(500 * time.Millisecond)
+			if info, err := os.Stat(path); err == nil && info.Size() < offset {
+				fmt.Fprintln(out, "-------- log restarted --------")
+				offset = 0
+				break
+			}
+		}
+		file.Close()
+	}
+}
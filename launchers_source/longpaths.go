@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Deep OneDrive or corporate folders push paths past MAX_PATH. Go's own
+// file calls cope, but CreateProcess, a child's working directory and
+// Python's imports do not unless Windows has long paths enabled. The
+// launcher therefore hands children short (8.3) names for long working
+// directories and executables and extended-length (\\?\) forms of long
+// data paths, and refuses to start with a clear message when the install
+// needs long paths and the system policy blocks them.
+var procGetShortPathNameW = kernel32.NewProc("GetShortPathNameW")
+
+const (
+	maxPath = 260
+	// SetCurrentDirectory leaves room for a file name of 8.3 size
+	maxDirPath = maxPath - 12
+
+	longPathsDocURL = "https://learn.microsoft.com/windows/win32/fileio/maximum-file-path-limitation"
+)
+
+// longPathsEnabled reads the LongPathsEnabled policy
+func longPathsEnabled() bool {
+	var key syscall.Handle
+	path := syscall.StringToUTF16Ptr(`SYSTEM\CurrentControlSet\Control\FileSystem`)
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, path, 0, syscall.KEY_READ|0x0100, &key); err != nil {
+		return false
+	}
+	defer syscall.RegCloseKey(key)
+	var value, valueType uint32
+	size := uint32(unsafe.Sizeof(value))
+	if err := syscall.RegQueryValueEx(key, syscall.StringToUTF16Ptr("LongPathsEnabled"), nil, &valueType, (*byte)(unsafe.Pointer(&value)), &size); err != nil {
+		return false
+	}
+	return valueType == syscall.REG_DWORD && value == 1
+}
+
+// extendedPath returns the \\?\ form of a path too long for the classic
+// Win32 limit, and other paths unchanged
+func extendedPath(path string) string {
+	if len(path) < maxDirPath || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	path = filepath.Clean(path)
+	if unc, ok := strings.CutPrefix(path, `\\`); ok {
+		return `\\?\UNC\` + unc
+	}
+	return `\\?\` + path
+}
+
+// shortPath returns the 8.3 form of a long path where the volume keeps
+// short names, and the path unchanged otherwise
+func shortPath(path string) string {
+	if len(path) < maxDirPath {
+		return path
+	}
+	long := syscall.StringToUTF16Ptr(extendedPath(path))
+	buf := make([]uint16, 1024)
+	n, _, _ := procGetShortPathNameW.Call(uintptr(unsafe.Pointer(long)), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if n == 0 || int(n) >= len(buf) {
+		return path
+	}
+	short := strings.TrimPrefix(syscall.UTF16ToString(buf[:n]), `\\?\`)
+	if strings.HasPrefix(short, `UNC\`) {
+		short = `\` + short[3:]
+	}
+	if len(short) >= len(path) {
+		return path
+	}
+	logDebug("launcher", "using short path for a child", "path", path, "short", short)
+	return short
+}
+
+// longestInstallPath returns the longest path the application uses: the
+// files of bin/ from the manifest and the data and log directories
+func longestInstallPath(config *AppConfig) string {
+	longest := ""
+	consider := func(path string) {
+		if len(path) > len(longest) {
+			longest = path
+		}
+	}
+	consider(config.DataDir)
+	consider(config.LogDir)
+	if manifest, err := loadManifest(config.BinDir); err == nil && manifest != nil {
+		for rel := range manifest.Files {
+			consider(filepath.Join(config.BinDir, filepath.FromSlash(rel)))
+		}
+	}
+	return longest
+}
+
+// checkLongPaths reports an install the long path policy blocks
+func checkLongPaths(config *AppConfig) *missingPrerequisite {
+	longest := longestInstallPath(config)
+	if len(longest) < maxPath || longPathsEnabled() {
+		return nil
+	}
+	return &missingPrerequisite{
+		name: "Windows long path support",
+		problem: tr("its files are in a folder too deep for Windows (%d characters, the limit is %d). Install it in a shorter folder such as C:\\%s, or ask your administrator to enable \"Enable Win32 long paths\" in Group Policy",
+			len(longest), maxPath-1, userDirName),
+		url: longPathsDocURL,
+	}
+}
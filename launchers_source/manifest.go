@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifest.json lists every shipped file under bin/ with its SHA-256, so
+// the launcher can detect files deleted or damaged after installation
+// (antivirus quarantines being the usual culprit).
+const (
+	manifestName      = "manifest.json"
+	manifestCacheName = ".manifest-cache.json"
+
+	// Normal startups re-hash this many unchanged files at random
+	manifestSampleSize = 16
+
+	// Hashing is disk bound past a few workers
+	maxHashWorkers = 8
+)
+
+type fileManifest struct {
+	Version string                   `json:"version"`
+	Files   map[string]manifestEntry `json:"files"`
+}
+
+type manifestEntry struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// hashCacheEntry remembers the hash of a file for a given size and mtime
+type hashCacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+type verifyProblem struct {
+	Path    string
+	Problem string
+}
+
+// Files under bin/ that change at runtime and are never in the manifest
+func excludedFromManifest(rel string) bool {
+	base := filepath.Base(rel)
+	return rel == manifestName ||
+		strings.HasPrefix(base, ".") ||
+		strings.HasSuffix(base, ".log") ||
+		base == "demo_state.json" ||
+		strings.HasPrefix(rel, "data/")
+}
+
+func loadManifest(binDir string) (*fileManifest, error) {
+	data, err := os.ReadFile(filepath.Join(binDir, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest fileManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", manifestName, err)
+	}
+	return &manifest, nil
+}
+
+// parallelEach calls fn for 0..n-1 on a pool of workers and waits
+func parallelEach(n int, fn func(i int)) {
+	workers := min(runtime.NumCPU(), maxHashWorkers, n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// generateManifest hashes every file under binDir
+func generateManifest(binDir, version string) (*fileManifest, error) {
+	manifest := &fileManifest{Version: version, Files: map[string]manifestEntry{}}
+	var rels []string
+	var sizes []int64
+	err := filepath.WalkDir(binDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(binDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if excludedFromManifest(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rels = append(rels, rel)
+		sizes = append(sizes, info.Size())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(rels))
+	errs := make([]error, len(rels))
+	parallelEach(len(rels), func(i int) {
+		hashes[i], errs[i] = fileSHA256(filepath.Join(binDir, filepath.FromSlash(rels[i])))
+	})
+	for i, rel := range rels {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		manifest.Files[rel] = manifestEntry{SHA256: hashes[i], Size: sizes[i]}
+	}
+	return manifest, nil
+}
+
+func writeManifest(binDir string, manifest *fileManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(binDir, manifestName), data, 0644)
+}
+
+// verifyInstall checks bin/ against manifest.json. A full check hashes
+// everything; otherwise only files whose size or mtime changed since the
+// last check are hashed, plus a small random sample of the rest. Files
+// are checked in parallel; the hash cache is per user since bin/ may be
+// read-only.
+func verifyInstall(config *AppConfig, full bool) ([]verifyProblem, error) {
+	manifest, err := loadManifest(config.BinDir)
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+
+	cachePath := filepath.Join(config.StateDir, manifestCacheName)
+	cache := map[string]hashCacheEntry{}
+	if data, err := os.ReadFile(cachePath); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+
+	paths := make([]string, 0, len(manifest.Files))
+	for rel := range manifest.Files {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	sampled := map[string]bool{}
+	if !full {
+		for _, i := range rand.Perm(len(paths))[:min(manifestSampleSize, len(paths))] {
+			sampled[paths[i]] = true
+		}
+	}
+
+	// One slot per file keeps the problems in path order
+	results := make([]string, len(paths))
+	var cacheMu sync.Mutex
+	parallelEach(len(paths), func(i int) {
+		rel := paths[i]
+		expected := manifest.Files[rel]
+		path := filepath.Join(config.BinDir, filepath.FromSlash(rel))
+
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			results[i] = "missing"
+			return
+		}
+		if err != nil {
+			results[i] = err.Error()
+			return
+		}
+		if info.Size() != expected.Size {
+			results[i] = "size changed"
+			return
+		}
+
+		cacheMu.Lock()
+		cached, ok := cache[rel]
+		cacheMu.Unlock()
+		unchanged := ok && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime())
+		if unchanged && !full && !sampled[rel] {
+			if !strings.EqualFold(cached.SHA256, expected.SHA256) {
+				results[i] = "checksum mismatch"
+			}
+			return
+		}
+
+		hash, err := fileSHA256(path)
+		if err != nil {
+			results[i] = err.Error()
+			return
+		}
+		cacheMu.Lock()
+		cache[rel] = hashCacheEntry{Size: info.Size(), ModTime: info.ModTime(), SHA256: hash}
+		cacheMu.Unlock()
+		if !strings.EqualFold(hash, expected.SHA256) {
+			results[i] = "checksum mismatch"
+		}
+	})
+
+	var problems []verifyProblem
+	for i, problem := range results {
+		if problem != "" {
+			problems = append(problems, verifyProblem{paths[i], problem})
+		}
+	}
+
+	if data, err := json.Marshal(cache); err == nil {
+		os.WriteFile(cachePath, data, 0644)
+	}
+	return problems, nil
+}
+
+// runManifestCommand implements "launcher manifest [version]", used by the
+// release build to produce bin/manifest.json.
+func runManifestCommand(config *AppConfig, args []string) int {
+	version := ""
+	if len(args) > 0 {
+		version = args[0]
+	}
+	manifest, err := generateManifest(config.BinDir, version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to hash %s: %v\n", config.BinDir, err)
+		return exitLauncherError
+	}
+	if err := writeManifest(config.BinDir, manifest); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write manifest: %v\n", err)
+		return exitLauncherError
+	}
+	fmt.Printf("✓ Wrote %s with %d files\n", filepath.Join(config.BinDir, manifestName), len(manifest.Files))
+	return exitOK
+}
@@ -0,0 +1,159 @@
+package main
+
+// Indonesian (Bahasa Indonesia)
+var messagesID = map[string]string{
+	// Startup and validation
+	"Checking required files...":              "Memeriksa file yang diperlukan...",
+	"%s not found: %s":                        "%s tidak ditemukan: %s",
+	"%s found":                                "%s ditemukan",
+	"Python executable":                       "Program Python",
+	"Backend executable":                      "Program backend",
+	"Backend interpreter":                     "Interpreter backend",
+	"Backend script":                          "Skrip backend",
+	"Python backend script (start_server.py)": "Skrip backend Python (start_server.py)",
+	"Python backend":                          "Backend Python",
+	"Data directory":                          "Folder data",
+	"Main application (wap.exe)":              "Aplikasi utama (wap.exe)",
+	"Main application":                        "Aplikasi utama",
+	"Flutter DLL (flutter_windows.dll)":       "DLL Flutter (flutter_windows.dll)",
+	"Extracting application files...":         "Mengekstrak file aplikasi...",
+	"Starting the backend...":                 "Memulai backend...",
+	"Migrating data...":                       "Memigrasikan data...",
+	"Loading models...":                       "Memuat model...",
+	"Preparing...":                            "Menyiapkan...",
+	"Almost ready...":                         "Hampir siap...",
+	"Ready":                                   "Siap",
+	"Warning":                                 "Peringatan",
+	"ERROR":                                   "KESALAHAN",
+	"Details":                                 "Detail",
+	"Press Enter to exit...":                  "Tekan Enter untuk keluar...",
+
+	// Error titles
+	"Cannot get executable path":                            "Tidak dapat menentukan lokasi program",
+	"Invalid launcher configuration":                        "Konfigurasi launcher tidak valid",
+	"Invalid command line":                                  "Baris perintah tidak valid",
+	"Invalid demo configuration":                            "Konfigurasi demo tidak valid",
+	"Failed to prepare the data directory":                  "Gagal menyiapkan folder data",
+	"Failed to start launcher":                              "Gagal memulai launcher",
+	"Failed to extract application files":                   "Gagal mengekstrak file aplikasi",
+	"Failed to start Python backend":                        "Gagal memulai backend Python",
+	"Python backend did not start":                          "Backend Python tidak berjalan",
+	"Python backend keeps crashing":                         "Backend Python terus berhenti mendadak",
+	"The bundled Python interpreter cannot run the backend": "Interpreter Python bawaan tidak dapat menjalankan backend",
+	"The backend's Python packages are damaged":             "Paket Python backend rusak",
+	"This data belongs to a newer version":                  "Data ini milik versi yang lebih baru",
+	"Failed to update the application data":                 "Gagal memperbarui data aplikasi",
+	"Failed to start backend proxy":                         "Gagal memulai proxy backend",
+	"Failed to start agent":                                 "Gagal memulai agen",
+	"Failed to start Flutter application":                   "Gagal memulai aplikasi Flutter",
+	"The application window did not appear":                 "Jendela aplikasi tidak muncul",
+	"Failed to restart normally":                            "Gagal memulai ulang secara normal",
+	"Failed to add the Defender exclusion":                  "Gagal menambahkan pengecualian Defender",
+	"Failed to install the %s":                              "Gagal memasang %s",
+	"Restore failed":                                        "Pemulihan gagal",
+	"The restored backup is damaged too":                    "Cadangan yang dipulihkan juga rusak",
+	"The backend's port belongs to another user":            "Port backend milik pengguna lain",
+	"A startup step failed":                                 "Langkah awal gagal",
+	"Failed to start a service the backend needs":           "Gagal memulai layanan yang dibutuhkan backend",
+	"A startup hook failed":                                 "Skrip awal (hook) gagal",
+	"Repair failed":                                         "Perbaikan gagal",
+
+	// Known backend failures
+	"A backend component is missing": "Komponen backend tidak ada",
+	"The Python module \"$1\" could not be found. It may have been removed by antivirus software or an incomplete update.\n\nRun \"launcher repair-python\" or reinstall the application.": "Modul Python \"$1\" tidak ditemukan. Mungkin dihapus oleh antivirus atau pembaruan yang tidak selesai.\n\nJalankan \"launcher repair-python\" atau pasang ulang aplikasi.",
+	"The backend's port is already in use": "Port backend sudah digunakan",
+	"Another program, or another copy of the application, is using port {port}.\n\nClose it and try again, or set a different port in launcher.json.": "Program lain, atau salinan lain aplikasi ini, menggunakan port {port}.\n\nTutup program tersebut dan coba lagi, atau atur port lain di launcher.json.",
+	"Windows blocked the backend's port": "Windows memblokir port backend",
+	"Access to port {port} was denied. Security software may be blocking it, or Windows reserved it (check with \"netsh int ipv4 show excludedportrange protocol=tcp\").\n\nAllow the application in your security software or set a different port in launcher.json.": "Akses ke port {port} ditolak. Perangkat lunak keamanan mungkin memblokirnya, atau Windows mencadangkannya (periksa dengan \"netsh int ipv4 show excludedportrange protocol=tcp\").\n\nIzinkan aplikasi di perangkat lunak keamanan Anda atau atur port lain di launcher.json.",
+	"A system library is missing": "Pustaka sistem tidak ada",
+	"A Windows library needed by the backend could not be loaded ($1).\n\nInstall the latest Microsoft Visual C++ Redistributable (x64) and start the application again.": "Pustaka Windows yang dibutuhkan backend tidak dapat dimuat ($1).\n\nPasang Microsoft Visual C++ Redistributable (x64) terbaru dan jalankan aplikasi lagi.",
+	"The backend cannot access its files": "Backend tidak dapat mengakses filenya",
+	"Access to \"$1\" was denied. The file may be open in another program or blocked by security software.\n\nClose other programs using it and try again.": "Akses ke \"$1\" ditolak. File mungkin sedang dibuka di program lain atau diblokir perangkat lunak keamanan.\n\nTutup program lain yang menggunakannya dan coba lagi.",
+
+	// Tray
+	"Exit %s":        "Keluar dari %s",
+	"Open %s":        "Buka %s",
+	"Start at login": "Mulai saat masuk",
+	"Could not change whether %s starts at login: %v":              "Tidak dapat mengubah apakah %s dimulai saat masuk: %v",
+	"%s will start in the background when you sign in.":            "%s akan dimulai di latar belakang saat Anda masuk.",
+	"%s will no longer start when you sign in.":                    "%s tidak akan lagi dimulai saat Anda masuk.",
+	"%s restarted its backend":                                     "%s memulai ulang backend-nya",
+	"The backend stopped unexpectedly and is being started again.": "Backend berhenti secara tak terduga dan sedang dimulai lagi.",
+	"%s restarted a backend process":                               "%s memulai ulang proses backend",
+	"%s stopped unexpectedly and is being started again.":          "%s berhenti secara tak terduga dan sedang dimulai lagi.",
+	"%s %s is ready": "%s %s sudah siap",
+	"The update was downloaded and will be installed the next time %s starts.": "Pembaruan sudah diunduh dan akan dipasang saat %s dimulai berikutnya.",
+	"Backup failed":                          "Pencadangan gagal",
+	"%s could not back up its data: %v":      "%s tidak dapat mencadangkan datanya: %v",
+	"Backup completed":                       "Pencadangan selesai",
+	"%s backed up its data to %s":            "%s telah mencadangkan datanya ke %s",
+	"the application window to appear":       "jendela aplikasi muncul",
+	"the backend to answer its health check": "backend menjawab pemeriksaan kesehatannya",
+	"%s did not finish starting within %s.\n\nStill waiting for %s (%s).": "%s tidak selesai dimulai dalam %s.\n\nMasih menunggu %s (%s).",
+	"The end of %s:": "Bagian akhir %s:",
+	"A support bundle for the support team was saved to %s":                       "Paket dukungan untuk tim dukungan telah disimpan ke %s",
+	"Yes tries again, No tries again in Safe Mode, Cancel quits.":                 "Ya mencoba lagi, Tidak mencoba lagi dalam Mode Aman, Batal keluar.",
+	"Start/stop diagnostic trace":                                                 "Mulai/hentikan jejak diagnostik",
+	"Connect another device...":                                                   "Hubungkan perangkat lain...",
+	"%s (demo) - %s remaining":                                                    "%s (demo) - sisa %s",
+	"%s - disk problem":                                                           "%s - masalah disk",
+	"Diagnostic trace stopped.":                                                   "Jejak diagnostik dihentikan.",
+	"Could not start a diagnostic trace: %v":                                      "Tidak dapat memulai jejak diagnostik: %v",
+	"Diagnostic trace running until %s. Files: %s":                                "Jejak diagnostik berjalan sampai %s. File: %s",
+	"Other devices on this network can use this computer's backend:":              "Perangkat lain di jaringan ini dapat menggunakan backend komputer ini:",
+	"Other devices can connect. Open the tray menu for the address and password.": "Perangkat lain dapat terhubung. Buka menu baki untuk alamat dan kata sandinya.",
+	"A crash report was saved to %s":                                              "Laporan crash disimpan di %s",
+	"The backend can be reached from the network":                                 "Backend dapat dijangkau dari jaringan",
+
+	// Dialogs
+	"%s was started as administrator. Your data and settings would be saved in the administrator's profile instead of yours.\n\nStart it normally instead?\n\nYes restarts it normally, No continues as administrator, Cancel quits.": "%s dijalankan sebagai administrator. Data dan pengaturan Anda akan disimpan di profil administrator, bukan di profil Anda.\n\nJalankan secara normal saja?\n\nYa menjalankan ulang secara normal, Tidak melanjutkan sebagai administrator, Batal keluar.",
+	"The application data in %s is on %s. Databases there can be damaged when the file is synced or the network drops.":                                                                                                               "Data aplikasi di %s berada di %s. Basis data di sana dapat rusak saat file disinkronkan atau jaringan terputus.",
+	"a network share":             "folder bersama jaringan",
+	"a mapped network drive (%s)": "drive jaringan yang dipetakan (%s)",
+	"a %s folder":                 "folder %s",
+	"a cloud-synced folder":       "folder yang disinkronkan ke cloud",
+	"Ask your administrator to keep %LOCALAPPDATA% on this PC.":                                                                     "Minta administrator Anda agar %LOCALAPPDATA% tetap berada di PC ini.",
+	"Move the data to this PC (%%LOCALAPPDATA%%\\%s)? The original stays where it is.\n\nYes moves it, No continues, Cancel quits.": "Pindahkan data ke PC ini (%%LOCALAPPDATA%%\\%s)? Data asli tetap di tempatnya.\n\nYa memindahkannya, Tidak melanjutkan, Batal keluar.",
+	"Windows build %d or later is required, this PC runs build %d":                                                                  "Diperlukan Windows build %d atau yang lebih baru, PC ini menjalankan build %d",
+	"its files are in a folder too deep for Windows (%d characters, the limit is %d). Install it in a shorter folder such as C:\\%s, or ask your administrator to enable \"Enable Win32 long paths\" in Group Policy": "filenya berada di folder yang terlalu dalam untuk Windows (%d karakter, batasnya %d). Pasang di folder yang lebih pendek seperti C:\\%s, atau minta administrator Anda mengaktifkan \"Enable Win32 long paths\" di Group Policy",
+	"missing %s": "tidak ada %s",
+	"%s needs the %s, which is not installed on this PC (%s).\n\nInstall it now? Windows will ask for administrator permission.": "%s memerlukan %s, yang belum terpasang di PC ini (%s).\n\nPasang sekarang? Windows akan meminta izin administrator.",
+	"%s installed": "%s terpasang",
+	"%s cannot run on this PC: %s.\n\nOpen the download page for the %s?":                                            "%s tidak dapat berjalan di PC ini: %s.\n\nBuka halaman unduhan untuk %s?",
+	"%d application file(s) are missing or damaged. This is often caused by antivirus software.\n\nRepair them now?": "%d file aplikasi hilang atau rusak. Hal ini sering disebabkan oleh antivirus.\n\nPerbaiki sekarang?",
+	"Repairing application files...":                "Memperbaiki file aplikasi...",
+	"Repair complete":                               "Perbaikan selesai",
+	"Repair complete; the modified files are in %s": "Perbaikan selesai; file yang diubah ada di %s",
+	"%d program file(s) were modified since installation:\n\n%s\nThis can be caused by malware or an interrupted update. Move them to quarantine and restore the original files?\n\nYes repairs, No starts anyway, Cancel quits.": "%d file program telah diubah sejak pemasangan:\n\n%s\nHal ini dapat disebabkan oleh malware atau pembaruan yang terputus. Pindahkan ke karantina dan pulihkan file aslinya?\n\nYa memperbaiki, Tidak tetap menjalankan, Batal keluar.",
+	"Moving %d modified file(s) to quarantine...":                     "Memindahkan %d file yang diubah ke karantina...",
+	"The modified files were moved to %s. Reinstall the application.": "File yang diubah dipindahkan ke %s. Pasang ulang aplikasi.",
+	"... and %d more": "... dan %d lainnya",
+	"Microsoft Defender quarantined %d file(s) of %s:":                            "Microsoft Defender mengarantina %d file milik %s:",
+	"This is a false positive: the files are part of the bundled Python runtime.": "Ini adalah deteksi keliru: file tersebut bagian dari runtime Python bawaan.",
+	"%d program file(s) of %s are missing or cannot be opened, for example %s. Antivirus software that quarantined or is scanning them is the usual cause.":                                            "%d file program milik %s hilang atau tidak dapat dibuka, misalnya %s. Penyebab umumnya adalah antivirus yang mengarantina atau sedang memindai file tersebut.",
+	"Ask your administrator to exclude %s from antivirus scanning.":                                                                                                                                    "Minta administrator Anda mengecualikan %s dari pemindaian antivirus.",
+	"Add %s to the Microsoft Defender exclusions so this does not happen again? Windows will ask for administrator permission. If you use another antivirus product, add the exclusion there instead.": "Tambahkan %s ke pengecualian Microsoft Defender agar hal ini tidak terulang? Windows akan meminta izin administrator. Jika Anda memakai antivirus lain, tambahkan pengecualian di sana.",
+	"%s is excluded from Microsoft Defender scans": "%s dikecualikan dari pemindaian Microsoft Defender",
+	"The %s backend listens on %s and can be reached by other computers on the network. It has no password of its own.\n\nUpdate the application, or enable LAN mode if network access is intended.": "Backend %s mendengarkan di %s dan dapat dijangkau komputer lain di jaringan. Backend ini tidak memiliki kata sandi sendiri.\n\nPerbarui aplikasi, atau aktifkan mode LAN jika akses jaringan memang diinginkan.",
+	"Other devices connect to %s on port %d. Windows Firewall may block them.\n\nAdd a firewall rule that allows this port on private networks? Windows will ask for administrator permission.":      "Perangkat lain terhubung ke %s melalui port %d. Windows Firewall mungkin memblokirnya.\n\nTambahkan aturan firewall yang mengizinkan port ini di jaringan pribadi? Windows akan meminta izin administrator.",
+	"%s ran into a problem and saved a crash report.\n\nSend it to the developers? It contains recent log lines and the configuration without passwords or tokens.":                                  "%s mengalami masalah dan menyimpan laporan crash.\n\nKirim ke pengembang? Laporan berisi baris log terbaru dan konfigurasi tanpa kata sandi atau token.",
+	"The application data is damaged and there is no backup to restore:\n\n%s\nStart anyway?":                                                                                                        "Data aplikasi rusak dan tidak ada cadangan untuk dipulihkan:\n\n%s\nTetap jalankan?",
+	"The application data is damaged:\n\n%s\nRestore the most recent backup (%s)?\n\nYes restores the backup, No starts anyway, Cancel quits.":                                                       "Data aplikasi rusak:\n\n%s\nPulihkan cadangan terbaru (%s)?\n\nYa memulihkan cadangan, Tidak tetap menjalankan, Batal keluar.",
+	"Your WAP demo has ended.": "Masa demo WAP Anda telah berakhir.",
+	"Please contact your administrator to upgrade to the full version.": "Silakan hubungi administrator Anda untuk meningkatkan ke versi lengkap.",
+	"Would you like to open the upgrade page now?":                      "Buka halaman peningkatan sekarang?",
+	"Disk %q reports %s. Back up data and replace the disk soon.":       "Disk %q melaporkan %s. Cadangkan data dan segera ganti disk tersebut.",
+	"This terminal is currently out of service.":                        "Terminal ini sedang tidak dapat digunakan.",
+	"Available again %s": "Tersedia kembali %s",
+	"The background service used %s of memory, more than the %d MB allowed, and is being restarted.": "Layanan latar belakang memakai memori %s, melebihi batas %d MB, dan sedang dijalankan ulang.",
+	"Only %s is free on the drive holding %s. The application may fail to save data.":                "Hanya tersisa %s di drive yang menyimpan %s. Aplikasi mungkin gagal menyimpan data.",
+	"%s - disk full": "%s - disk penuh",
+	"The system disk (%s) reports %s. Back up data and replace the disk soon.":                                                                                                                                                                                                             "Disk sistem (%s) melaporkan %s. Cadangkan data dan segera ganti disk tersebut.",
+	"%s closed unexpectedly (%v).\n\nRestart it? The background service keeps running, so your work in progress is kept.":                                                                                                                                                                  "%s tertutup secara tak terduga (%v).\n\nJalankan ulang? Layanan latar belakang tetap berjalan, sehingga pekerjaan Anda tetap tersimpan.",
+	"%s failed to start or closed unexpectedly %d times in a row.\n\nStart in Safe Mode? Plugins are disabled, updates are not checked and detailed logs are written to %s.":                                                                                                               "%s gagal berjalan atau tertutup secara tak terduga %d kali berturut-turut.\n\nJalankan dalam Mode Aman? Plugin dinonaktifkan, pembaruan tidak diperiksa dan log terperinci ditulis ke %s.",
+	"Help improve %s by sending anonymous startup reports?\n\nThey say whether the application started, how long each step took and which versions of %s and Windows are used. They contain no names, files or data. You can change this later with \"launcher telemetry on\" or \"off\".": "Bantu tingkatkan %s dengan mengirim laporan startup anonim?\n\nLaporan ini berisi apakah aplikasi berhasil berjalan, lama setiap langkah, serta versi %s dan Windows yang digunakan. Tidak ada nama, file, atau data. Anda dapat mengubahnya nanti dengan \"launcher telemetry on\" atau \"off\".",
+	"%s is already running.":                                                  "%s sudah berjalan.",
+	"%s is already running with the profile \"%s\".":                          "%s sudah berjalan dengan profil \"%s\".",
+	"Version %s failed to start. The previous version (%s) will be restored.": "Versi %s gagal berjalan. Versi sebelumnya (%s) akan dipulihkan.",
+	"%s was started twice but did not open its window within %s.\n\nThis is usually a plugin or graphics driver hanging at startup. Update the graphics driver, or try Safe Mode (launcher --safe-mode).\n\nDetails are in %s": "%s sudah dijalankan dua kali tetapi tidak membuka jendelanya dalam %s.\n\nBiasanya ini disebabkan plugin atau driver grafis yang macet saat startup. Perbarui driver grafis, atau coba Mode Aman (launcher --safe-mode).\n\nDetail ada di %s",
+}
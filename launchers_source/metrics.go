@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MetricsSettings expose the launcher's metrics in the Prometheus text
+// format at /metrics on the control channel, for a local agent scraping a
+// fleet of kiosks. The control port is random unless port is set, and
+// scrapers authenticate with "Authorization: Bearer <token>":
+//
+//	"metrics": {"port": 9464, "token": "secret://metrics-token"}
+type MetricsSettings struct {
+	Port  int    `json:"port"`
+	Token string `json:"token"` // may be secret://name; empty disables /metrics for scrapers
+}
+
+// EnableMetrics lets scrapers read /metrics with token; healthPath is the
+// backend's health endpoint
+func (c *controlServer) EnableMetrics(token, healthPath string) {
+	c.mu.Lock()
+	c.metricsToken = token
+	c.healthPath = healthPath
+	c.mu.Unlock()
+}
+
+// metricsAuthorized accepts the control token or the scrape token
+func (c *controlServer) metricsAuthorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		scrapeToken := c.metricsToken
+		c.mu.Unlock()
+		bearer, isBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if scrapeToken != "" && isBearer && subtle.ConstantTimeCompare([]byte(bearer), []byte(scrapeToken)) == 1 {
+			handler(w, r)
+			return
+		}
+		c.authorized(handler)(w, r)
+	}
+}
+
+// metricsWriter writes one family at a time in the text exposition format
+type metricsWriter struct {
+	b strings.Builder
+}
+
+func (m *metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(&m.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.b.WriteString(name)
+	if len(labels) > 0 {
+		m.b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.b.WriteByte(',')
+			}
+			fmt.Fprintf(&m.b, "%s=%q", labels[i], labels[i+1])
+		}
+		m.b.WriteByte('}')
+	}
+	fmt.Fprintf(&m.b, " %g\n", value)
+}
+
+func (c *controlServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.mu.Lock()
+	services := c.servicesSnapshot()
+	backendURL, healthPath := c.backendURL, c.healthPath
+	stage := c.stage.Name
+	c.mu.Unlock()
+
+	var m metricsWriter
+	m.family("wap_launcher_info", "gauge", "Launcher version, always 1.")
+	m.sample("wap_launcher_info", 1, "version", launcherVersion, "commit", gitCommit)
+	m.family("wap_launcher_uptime_seconds", "gauge", "Seconds since the launcher started.")
+	m.sample("wap_launcher_uptime_seconds", time.Since(launcherStarted).Seconds())
+	m.family("wap_launcher_ready", "gauge", "1 once the application is ready.")
+	m.sample("wap_launcher_ready", boolMetric(stage == stageReady))
+
+	m.family("wap_service_up", "gauge", "1 while the child process runs.")
+	for _, s := range services {
+		m.sample("wap_service_up", boolMetric(s.State == "running"), "service", s.Name)
+	}
+	m.family("wap_service_restarts_total", "counter", "Times the child was started again.")
+	for _, s := range services {
+		m.sample("wap_service_restarts_total", float64(s.Restarts), "service", s.Name)
+	}
+	m.family("wap_service_errors_total", "counter", "Error lines the child wrote to stderr.")
+	for _, s := range services {
+		m.sample("wap_service_errors_total", float64(s.Errors), "service", s.Name)
+	}
+	m.family("wap_service_uptime_seconds", "gauge", "Seconds since the child started.")
+	for _, s := range services {
+		if s.State == "running" {
+			m.sample("wap_service_uptime_seconds", time.Since(s.Started).Seconds(), "service", s.Name)
+		}
+	}
+	m.family("wap_service_cpu_percent", "gauge", "CPU share of the whole machine at the last resource sample.")
+	for _, s := range services {
+		m.sample("wap_service_cpu_percent", s.CPUPercent, "service", s.Name)
+	}
+	m.family("wap_service_working_set_bytes", "gauge", "Working set at the last resource sample.")
+	for _, s := range services {
+		m.sample("wap_service_working_set_bytes", float64(s.WorkingSet), "service", s.Name)
+	}
+
+	// The health check is made for the scrape so its latency is current
+	if backendURL != "" {
+		started := time.Now()
+		healthy := backendHealthy(backendURL + healthPath)
+		m.family("wap_backend_healthy", "gauge", "1 if the backend answered /health.")
+		m.sample("wap_backend_healthy", boolMetric(healthy))
+		m.family("wap_backend_health_check_seconds", "gauge", "Time the /health request took.")
+		m.sample("wap_backend_health_check_seconds", time.Since(started).Seconds())
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(m.b.String()))
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options holds the launcher command-line flags
+type Options struct {
+	LogFormat    string
+	Agent        bool
+	Verify       bool
+	Version      bool
+	JSONProgress bool
+	Console      bool
+	Kiosk        bool
+	Headless     bool
+	Port         int
+	DryRun       bool
+	BackendURL   string
+	LAN          bool
+	EventsJSON   bool
+	Dev          bool
+	Python       string
+	BackendArgs  string
+	AppArgs      string
+	ExtraAppArgs []string // after --
+	Portable     bool
+	SafeMode     bool
+	Profile      bool // --profile-startup
+	Quiet        bool
+	Verbose      bool
+	NoColor      bool
+	ProfileName  string // --profile, taken out of the arguments by profileFlag
+	OpenURL      string
+	OpenFile     string
+	Minimized    bool
+}
+
+// verbosity is the console verbosity the flags ask for
+func (opts *Options) verbosity() int {
+	switch {
+	case opts.Quiet:
+		return verbosityQuiet
+	case opts.Verbose:
+		return verbosityVerbose
+	default:
+		return verbosityNormal
+	}
+}
+
+func parseOptions(args []string) (*Options, error) {
+	opts := &Options{}
+	fs := flag.NewFlagSet("launcher", flag.ContinueOnError)
+	fs.Usage = func() { printHelp(os.Stderr) }
+	defineLaunchFlags(fs, opts)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	// Anything after a literal -- goes to the Flutter app unchanged
+	for i, arg := range args {
+		if arg == "--" {
+			opts.ExtraAppArgs = args[i+1:]
+			break
+		}
+	}
+	if err := validateOptions(opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// defineLaunchFlags registers the flags for starting the application. They
+// are shared with "launcher plan" and listed by help and completion.
+func defineLaunchFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.LogFormat, "log-format", logFormatText, "launcher.log format: text or json")
+	fs.BoolVar(&opts.Verify, "verify", false, "hash every file against manifest.json before starting")
+	fs.BoolVar(&opts.Version, "version", false, "print launcher, application and backend versions and exit")
+	fs.BoolVar(&opts.JSONProgress, "json-progress", false, "write startup progress as JSON lines on stdout instead of text")
+	fs.BoolVar(&opts.EventsJSON, "events-json", false, "write lifecycle events (started, ready, degraded, stopped) as JSON lines on stdout")
+	fs.BoolVar(&opts.Console, "console", false, "show launcher output in a console window")
+	fs.BoolVar(&opts.Quiet, "quiet", false, "print only errors to the console")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "also print details such as child command lines and log paths")
+	fs.BoolVar(&opts.NoColor, "no-color", false, "do not color console output (NO_COLOR does the same)")
+	fs.BoolVar(&opts.Kiosk, "kiosk", false, "relaunch the app whenever it exits; only an authenticated stop command ends the launcher")
+	fs.BoolVar(&opts.Headless, "headless", false, "run only the Python backend until Ctrl+C or a stop command")
+	fs.IntVar(&opts.Port, "port", -1, "backend port, passed to the app in WAP_BACKEND_URL; 0 picks a free port")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "print what would be started and exit")
+	fs.StringVar(&opts.BackendURL, "backend-url", "", "use a remote backend instead of starting Python, e.g. https://server:5000")
+	fs.BoolVar(&opts.Minimized, "minimized", false, "start the backend without a splash or window; the app opens from the tray or when started again (autostart)")
+	fs.BoolVar(&opts.Agent, "agent", false, "stay resident and start the app on an authenticated LAN command")
+	fs.BoolVar(&opts.LAN, "lan", false, "let other devices on the network use this backend, with a password")
+	fs.BoolVar(&opts.Dev, "dev", false, "backend development: restart the backend when a .py file changes")
+	fs.StringVar(&opts.Python, "python", "", "with --dev, run the backend with this Python (default: the active virtualenv)")
+	fs.BoolVar(&opts.Profile, "profile-startup", false, "print how long each startup phase took (always written to startup_timing.json)")
+	fs.BoolVar(&opts.SafeMode, "safe-mode", false, "start the backend without plugins, skip the update check and log verbosely")
+	fs.StringVar(&opts.ProfileName, profileFlagName, "", "run a separate instance with its own data, logs and ports, e.g. \"staging\"")
+	fs.BoolVar(&opts.Portable, "portable", false, "keep logs and data next to the launcher instead of %LOCALAPPDATA%\\WAP")
+	fs.StringVar(&opts.BackendArgs, "backend-args", "", "extra arguments for start_server.py, e.g. \"--log-level=debug\"")
+	fs.StringVar(&opts.OpenURL, "open-url", "", "open a link such as wap://... in the app, the running one if any (see register-protocol)")
+	fs.StringVar(&opts.OpenFile, "open-file", "", "open a file in the app, the running one if any (see register-file-type)")
+	fs.StringVar(&opts.AppArgs, "app-args", "", "extra arguments for the Flutter app, e.g. \"--mock-data\"; arguments after -- are added too")
+}
+
+func validateOptions(opts *Options) error {
+	if opts.Quiet && opts.Verbose {
+		return fmt.Errorf("--quiet cannot be combined with --verbose")
+	}
+	if opts.Headless && (opts.Kiosk || opts.Agent) {
+		return fmt.Errorf("--headless cannot be combined with --kiosk or --agent")
+	}
+	if opts.Minimized && (opts.Headless || opts.Kiosk || opts.Agent) {
+		return fmt.Errorf("--minimized cannot be combined with --headless, --kiosk or --agent")
+	}
+	if opts.Port > 65535 {
+		return fmt.Errorf("invalid --port %d", opts.Port)
+	}
+	if opts.BackendURL != "" {
+		if opts.Headless || opts.Port >= 0 || opts.LAN {
+			return fmt.Errorf("--backend-url cannot be combined with --headless, --port or --lan")
+		}
+		if u, err := url.Parse(opts.BackendURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid --backend-url %q (expected http(s)://host:port)", opts.BackendURL)
+		}
+	}
+	if opts.Python != "" && !opts.Dev {
+		return fmt.Errorf("--python is only supported with --dev")
+	}
+	if opts.Dev && (opts.BackendURL != "" || opts.Kiosk || opts.Agent) {
+		return fmt.Errorf("--dev cannot be combined with --backend-url, --kiosk or --agent")
+	}
+	if opts.OpenURL != "" && opts.OpenFile != "" {
+		return fmt.Errorf("--open-url cannot be combined with --open-file")
+	}
+	if opts.BackendArgs != "" && opts.BackendURL != "" {
+		return fmt.Errorf("--backend-args cannot be combined with --backend-url")
+	}
+	for name, value := range map[string]string{"backend-args": opts.BackendArgs, "app-args": opts.AppArgs} {
+		if _, err := splitArgs(value); err != nil {
+			return fmt.Errorf("invalid --%s: %w", name, err)
+		}
+	}
+	if !validLogFormat(opts.LogFormat) {
+		return fmt.Errorf("invalid --log-format %q (expected text or json)", opts.LogFormat)
+	}
+	return nil
+}
+
+// applyOptions adjusts the configuration for flags that change where the
+// backend is
+func applyOptions(config *AppConfig, opts *Options) error {
+	if opts.Port >= 0 {
+		if err := setBackendPort(config, opts.Port); err != nil {
+			return err
+		}
+	}
+	if opts.BackendURL != "" {
+		useRemoteBackend(config, opts.BackendURL)
+	}
+	if opts.LAN {
+		config.Settings.LAN.Enabled = true
+	}
+	if opts.Portable {
+		setPortableDirs(config)
+	}
+	config.BackendArgs, _ = splitArgs(opts.BackendArgs)
+	config.AppArgs, _ = splitArgs(opts.AppArgs)
+	config.AppArgs = append(config.AppArgs, opts.ExtraAppArgs...)
+	config.MirrorOutput = opts.Console || opts.Dev
+	if opts.OpenURL != "" {
+		if !isAppLink(config, opts.OpenURL) {
+			return fmt.Errorf("invalid --open-url %q (expected %s://...)", opts.OpenURL, protocolScheme(config))
+		}
+		config.OpenURL = opts.OpenURL
+	}
+	if opts.OpenFile != "" {
+		path, err := filepath.Abs(opts.OpenFile)
+		if err != nil {
+			return fmt.Errorf("invalid --open-file %q: %w", opts.OpenFile, err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("invalid --open-file: %w", err)
+		}
+		config.OpenFile = path
+	}
+	if opts.Dev {
+		config.Dev = true
+		python, err := resolveDevPython(opts.Python)
+		if err != nil {
+			return err
+		}
+		if python != "" {
+			usePython(config, python)
+		}
+	}
+	return nil
+}
+
+// splitArgs splits a pass-through argument string the way a command line
+// is split: on spaces, except inside double quotes; \" is a literal quote.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inQuotes, hasArg := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '"':
+			current.WriteByte('"')
+			hasArg = true
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteByte(c)
+			hasArg = true
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
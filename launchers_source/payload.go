@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// payloadFS is set by payload_embed.go in single-file builds
+// (go build -tags embedpayload). It contains payload.json and the archive
+// it names, laid out like bin/.
+var payloadFS fs.FS
+
+// PayloadSettings lets a small launcher download the payload on first run
+// instead of embedding it.
+type PayloadSettings struct {
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+	Version string `json:"version"`
+}
+
+type payloadManifest struct {
+	Version string `json:"version"`
+	Archive string `json:"archive"`
+	SHA256  string `json:"sha256"`
+}
+
+const (
+	payloadMarkerName   = ".payload-version"
+	payloadDownloadName = "payload-download.zip"
+)
+
+// ensurePayload extracts the embedded (or downloaded) payload into bin/
+// when it has not been extracted yet or a different version is installed.
+func ensurePayload(config *AppConfig, control *controlServer) (err error) {
+	manifest, open, err := resolvePayload(config)
+	if err != nil || open == nil {
+		return err
+	}
+
+	// Updates record their version in the marker too, so an older embedded
+	// payload never overwrites an updated installation
+	marker := filepath.Join(config.BinDir, payloadMarkerName)
+	if installed, err := os.ReadFile(marker); err == nil && compareVersions(strings.TrimSpace(string(installed)), manifest.Version) >= 0 {
+		return nil
+	}
+
+	consolePrintf("Preparing %s %s for first use...\n", config.AppName, manifest.Version)
+	control.SetStage(stageExtracting, "")
+	logInfo("payload", "extracting payload", "version", manifest.Version)
+	onTaskbar := startTaskbarProgress("payload")
+	defer func() { onTaskbar.End(err) }()
+
+	archivePath, cleanup, err := open(onTaskbar.Set)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := verifyFileSHA256(archivePath, manifest.SHA256); err != nil {
+		return err
+	}
+	lastPercent := int64(-1)
+	progress := func(done, total int64) {
+		if total <= 0 || done*100/total == lastPercent {
+			return
+		}
+		percent := done * 100 / total
+		lastPercent = percent
+		onTaskbar.Set(done, total)
+		consolePrintf("\rExtracting... %3d%%", percent)
+		emitPhase(phasePayload, phaseProgress, float64(percent), "")
+		control.SetStage(stageExtracting, fmt.Sprintf("Extracting application files... %d%%", percent))
+	}
+	extract := extractOptions{
+		Progress: progress,
+		Journal:  filepath.Join(config.BinDir, extractJournalName),
+		Version:  manifest.Version,
+		Store:    openContentStore(config),
+	}
+	if err := extractZip(archivePath, config.BinDir, extract); err != nil {
+		return err
+	}
+	consolePrintln()
+
+	if err := os.WriteFile(marker, []byte(manifest.Version), 0644); err != nil {
+		return fmt.Errorf("failed to record payload version: %w", err)
+	}
+	os.Remove(extract.Journal)
+	consoleSuccessf("Application files extracted")
+	logInfo("payload", "payload extracted", "version", manifest.Version)
+	return nil
+}
+
+// payloadOpener produces a local copy of the payload archive and a function
+// removing it. progress is called while a download is under way.
+type payloadOpener func(progress func(done, total int64)) (string, func(), error)
+
+// resolvePayload returns the payload manifest and its opener, or a nil
+// opener when there is no payload.
+func resolvePayload(config *AppConfig) (payloadManifest, payloadOpener, error) {
+	if payloadFS != nil {
+		var manifest payloadManifest
+		data, err := fs.ReadFile(payloadFS, "payload.json")
+		if err != nil {
+			return manifest, nil, fmt.Errorf("embedded payload has no payload.json: %w", err)
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return manifest, nil, fmt.Errorf("invalid embedded payload.json: %w", err)
+		}
+		return manifest, func(func(done, total int64)) (string, func(), error) {
+			return copyToTemp(config, func(w io.Writer) error {
+				src, err := payloadFS.Open(manifest.Archive)
+				if err != nil {
+					return err
+				}
+				defer src.Close()
+				_, err = io.Copy(w, src)
+				return err
+			})
+		}, nil
+	}
+
+	settings := config.Settings.Payload
+	if settings.URL == "" {
+		return payloadManifest{}, nil, nil
+	}
+	manifest := payloadManifest{Version: settings.Version, SHA256: settings.SHA256}
+	return manifest, func(progress func(done, total int64)) (string, func(), error) {
+		consolePrintf("Downloading %s\n", settings.URL)
+		archive := filepath.Join(config.BinDir, payloadDownloadName)
+		cleanup := func() { os.Remove(archive) }
+		if err := downloadFileProgress(settings.URL, archive, progress); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("failed to obtain payload: %w", err)
+		}
+		return archive, cleanup, nil
+	}, nil
+}
+
+func copyToTemp(config *AppConfig, write func(io.Writer) error) (string, func(), error) {
+	if err := os.MkdirAll(config.BinDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create %s: %w", config.BinDir, err)
+	}
+	tmp, err := os.CreateTemp(config.BinDir, "payload-*.zip")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	err = write(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to obtain payload: %w", err)
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+func verifyFileSHA256(path, expected string) error {
+	if expected == "" {
+		return fmt.Errorf("payload has no checksum, refusing to extract")
+	}
+	actual, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("payload checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// safeJoin rejects archive entries such as "../../evil.dll"
+func safeJoin(dest, name string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q escapes the target directory", name)
+	}
+	return target, nil
+}
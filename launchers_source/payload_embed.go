@@ -0,0 +1,23 @@
+//go:build embedpayload
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Single-file builds embed launchers_source/payload/ (payload.json plus the
+// archive it names):
+//
+//	go build -tags embedpayload -o wap_launcher.exe
+//
+//go:embed payload
+var embeddedPayload embed.FS
+
+func init() {
+	sub, err := fs.Sub(embeddedPayload, "payload")
+	if err == nil {
+		payloadFS = sub
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// launchPlan is the fully resolved description of what a launch would do,
+// for review tools and support tickets. Secrets in the environment are
+// redacted.
+type launchPlan struct {
+	Mode     string        `json:"mode"` // normal, kiosk, agent or headless
+	Version  string        `json:"launcher_version"`
+	RootDir  string        `json:"root_dir"`
+	LogDir   string        `json:"log_dir"`
+	DataDir  string        `json:"data_dir"`
+	Services []planService `json:"services"`
+	Ports    []planPort    `json:"ports"`
+	Hooks    []string      `json:"hooks"`
+}
+
+type planService struct {
+	Name      string        `json:"name"`
+	Command   string        `json:"command"`
+	Args      []string      `json:"args"`
+	Dir       string        `json:"dir"`
+	Env       []string      `json:"env"`
+	Log       string        `json:"log"`
+	Readiness planReadiness `json:"readiness"`
+	Restart   string        `json:"restart"` // never, on-exit or on-crash
+}
+
+type planReadiness struct {
+	Kind    string `json:"kind"`          // http or window
+	URL     string `json:"url,omitempty"` // for http
+	Timeout string `json:"timeout"`
+}
+
+type planPort struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+const redacted = "<redacted>"
+
+func buildLaunchPlan(config *AppConfig, opts *Options) (*launchPlan, error) {
+	plan := &launchPlan{
+		Mode:    "normal",
+		Version: launcherVersion,
+		RootDir: config.RootDir,
+		LogDir:  config.LogDir,
+		DataDir: config.DataDir,
+		Hooks:   []string{},
+	}
+	switch {
+	case opts.Headless:
+		plan.Mode = "headless"
+	case opts.Kiosk:
+		plan.Mode = "kiosk"
+	case opts.Agent:
+		plan.Mode = "agent"
+	}
+
+	// The control channel address is only known once the launcher runs,
+	// and so is the backend's port behind the proxy
+	proxied := proxyEnabled(config)
+	readinessURL := healthURL(config, config.BackendURL)
+	port := strconv.Itoa(backendPort(config))
+	if proxied {
+		readinessURL = healthURL(config, "http://127.0.0.1:<dynamic>")
+		port = "<dynamic>"
+	}
+	backendEnvVars, err := backendEnv(config, port, "<generated>")
+	if err != nil {
+		return nil, err
+	}
+	backendEnvVars = append(backendEnvVars, "WAP_CONTROL_URL=http://127.0.0.1:<dynamic>", "WAP_CONTROL_TOKEN=<generated>")
+	dotEnv, err := loadDotEnv(config)
+	if err != nil {
+		return nil, err
+	}
+	// PYTHON* variables from the user's environment are removed
+	var pythonVars []string
+	if bundledPython(config) {
+		pythonVars = pythonEnv(config)
+	}
+	backendEnvVars = append(append(pythonVars, dotEnv...), backendEnvVars...)
+	backend := planService{
+		Name:    "backend",
+		Command: config.PythonExe,
+		Args:    backendArgs(config),
+		Dir:     config.BackendDir,
+		Env:     redactEnv(backendEnvVars),
+		Log:     filepath.Join(config.LogDir, backendLogName),
+		Readiness: planReadiness{
+			Kind:    "http",
+			URL:     readinessURL,
+			Timeout: backendStartTimeout.String(),
+		},
+		Restart: "never",
+	}
+	if cmd, err := config.Backend.Command(config); err == nil {
+		backend.Command, backend.Args, backend.Dir = cmd.Path, cmd.Args[1:], cmd.Dir
+	}
+	if opts.Headless {
+		backend.Restart = "on-crash"
+	}
+	if !config.RemoteBackend {
+		plan.Services = append(plan.Services, backend)
+	}
+
+	if !opts.Headless {
+		frontendEnvVars, err := frontendEnv(config)
+		if err != nil {
+			return nil, err
+		}
+		frontend := planService{
+			Name:      "frontend",
+			Command:   config.AppExe,
+			Args:      append([]string{}, frontendArgs(config, opts.Kiosk)...),
+			Dir:       config.BinDir,
+			Env:       redactEnv(frontendEnvVars),
+			Log:       filepath.Join(config.LogDir, frontendLogName),
+			Readiness: planReadiness{Kind: "window", Timeout: (30 * time.Second).String()},
+			Restart:   "never",
+		}
+		if opts.Kiosk {
+			frontend.Restart = "on-exit"
+		}
+		cmd, err := config.Frontend.Command(config, opts.Kiosk)
+		if err != nil {
+			return nil, err
+		}
+		if cmd != nil {
+			frontend.Command, frontend.Args, frontend.Dir = cmd.Path, cmd.Args[1:], cmd.Dir
+		} else {
+			frontend.Command, frontend.Args = "", nil
+			frontend.Readiness = planReadiness{Kind: "browser", URL: browserURL(config)}
+			frontend.Restart = "never"
+		}
+		if proxied {
+			extra, err := serviceEnv("frontend_env", config.Settings.FrontendEnv, childEnvValues{
+				Port:       strconv.Itoa(proxyPort(config)),
+				Token:      "<generated>",
+				BackendURL: fmt.Sprintf("https://127.0.0.1:%d", proxyPort(config)),
+				DataDir:    config.DataDir,
+				LogDir:     config.LogDir,
+			})
+			if err != nil {
+				return nil, err
+			}
+			frontend.Env = redactEnv(append(append(envList(config.Settings.Env), extra...),
+				fmt.Sprintf("WAP_BACKEND_URL=https://127.0.0.1:%d", proxyPort(config)),
+				"WAP_BACKEND_TOKEN=<generated>",
+				"WAP_BACKEND_CERT="+filepath.Join(config.StateDir, proxyCertName),
+				"WAP_BACKEND_HEALTH_URL="+healthURL(config, fmt.Sprintf("https://127.0.0.1:%d", proxyPort(config))),
+				"WAP_SESSION_FILE="+sessionFilePath(config), "WAP_APP_USER_MODEL_ID="+appUserModelID(config)))
+		}
+		plan.Services = append(plan.Services, frontend)
+	}
+
+	if config.RemoteBackend {
+		plan.Ports = append(plan.Ports, planPort{Name: "remote backend", Address: config.BackendURL})
+	} else if proxied {
+		plan.Ports = append(plan.Ports, planPort{Name: "proxy", Address: fmt.Sprintf("https://127.0.0.1:%d", proxyPort(config))})
+		plan.Ports = append(plan.Ports, planPort{Name: "backend", Address: "127.0.0.1:<dynamic>"})
+		if config.Settings.LAN.Enabled {
+			plan.Ports = append(plan.Ports, planPort{Name: "lan", Address: fmt.Sprintf("https://0.0.0.0:%d", lanPort(config))})
+		}
+	} else {
+		plan.Ports = append(plan.Ports, planPort{Name: "backend", Address: strings.TrimPrefix(config.BackendURL, "http://")})
+	}
+	plan.Ports = append(plan.Ports, planPort{Name: "control", Address: "127.0.0.1:<dynamic>"})
+	if opts.Agent || opts.Kiosk || (opts.Headless && config.Settings.Agent.Token != "") {
+		listen := config.Settings.Agent.Listen
+		if listen == "" {
+			listen = defaultAgentListen
+		}
+		plan.Ports = append(plan.Ports, planPort{Name: "agent", Address: listen})
+	}
+	return plan, nil
+}
+
+func redactEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if isSecretName(name) {
+			entry = name + "=" + redacted
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+func printLaunchPlan(plan *launchPlan) {
+	fmt.Printf("Launch plan (%s mode, launcher %s)\n", plan.Mode, plan.Version)
+	fmt.Printf("Logs in %s, data in %s\n", plan.LogDir, plan.DataDir)
+	for _, service := range plan.Services {
+		fmt.Printf("\n%s\n", service.Name)
+		fmt.Printf("  command:   %s %s\n", service.Command, strings.Join(service.Args, " "))
+		fmt.Printf("  directory: %s\n", service.Dir)
+		fmt.Printf("  log:       %s\n", service.Log)
+		if service.Readiness.URL != "" {
+			fmt.Printf("  ready:     %s answers within %s\n", service.Readiness.URL, service.Readiness.Timeout)
+		} else {
+			fmt.Printf("  ready:     a %s appears within %s\n", service.Readiness.Kind, service.Readiness.Timeout)
+		}
+		fmt.Printf("  restart:   %s\n", service.Restart)
+		for _, entry := range service.Env {
+			fmt.Printf("  env:       %s\n", entry)
+		}
+	}
+	fmt.Println()
+	for _, port := range plan.Ports {
+		fmt.Printf("port %-8s %s\n", port.Name, port.Address)
+	}
+}
+
+type planOptions struct {
+	Options
+	json bool
+}
+
+func (o *planOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.json, "json", false, "print the plan as JSON")
+	defineLaunchFlags(fs, &o.Options)
+}
+
+// runPlanCommand implements "launcher plan [--json] [launch flags]"
+func runPlanCommand(config *AppConfig, args []string) int {
+	var planOpts planOptions
+	fs := newCommandFlagSet("plan")
+	planOpts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	opts := &planOpts.Options
+	if err := validateOptions(opts); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitUsage
+	}
+	if err := applyOptions(config, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitUsage
+	}
+
+	plan, err := buildLaunchPlan(config, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfigInvalid
+	}
+	if !planOpts.json {
+		printLaunchPlan(plan)
+		return exitOK
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+	fmt.Println(string(data))
+	return exitOK
+}
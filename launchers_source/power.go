@@ -0,0 +1,142 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Windows broadcasts WM_POWERBROADCAST to top-level windows. Suspend and
+// resume are logged so "broken after sleep" reports line up with
+// launcher.log, and after a resume the backend is checked and restarted if
+// it died or stopped answering while the machine slept.
+const (
+	wmPowerBroadcast      = 0x0218
+	pbtAPMSuspend         = 0x0004
+	pbtAPMResumeAutomatic = 0x0012 // sent on every resume, with or without a user
+
+	// The network stack needs a moment after resume before a failed
+	// health check means anything
+	resumeSettleDelay  = 5 * time.Second
+	resumeHealthWindow = 30 * time.Second
+)
+
+type powerMonitor struct {
+	hwnd      uintptr
+	done      chan struct{}
+	suspended time.Time // only used on the window's thread
+}
+
+var resumeHandlers struct {
+	mu     sync.Mutex
+	nextID int
+	fns    map[int]func()
+}
+
+// startPowerMonitor creates the hidden window that receives power events
+func startPowerMonitor(config *AppConfig) *powerMonitor {
+	p := &powerMonitor{done: make(chan struct{})}
+	ready := make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(p.done)
+
+		hwnd, err := createWindow("WAPLauncherPower", config.AppName, 0, 0, 0, 0, 0, 0, p.handleMessage)
+		if err != nil {
+			logWarn("power", "failed to create power notification window", "error", err)
+			close(ready)
+			return
+		}
+		p.hwnd = hwnd
+		close(ready)
+		runMessageLoop()
+	}()
+	<-ready
+	return p
+}
+
+func (p *powerMonitor) Close() {
+	if p.hwnd == 0 {
+		return
+	}
+	postMessage(p.hwnd, wmClose, 0, 0)
+	<-p.done
+}
+
+func (p *powerMonitor) handleMessage(hwnd uintptr, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	switch msg {
+	case wmPowerBroadcast:
+		switch wparam {
+		case pbtAPMSuspend:
+			p.suspended = time.Now()
+			logInfo("power", "system suspending")
+		case pbtAPMResumeAutomatic:
+			fields := []interface{}{}
+			if !p.suspended.IsZero() {
+				fields = append(fields, "slept", time.Since(p.suspended).Round(time.Second).String())
+			}
+			logInfo("power", "system resumed", fields...)
+			notifyResumed()
+		}
+		return 1, true
+	case wmClose:
+		procDestroyWindow.Call(hwnd)
+		return 0, true
+	case wmDestroy:
+		procPostQuitMessage.Call(0)
+		return 0, true
+	}
+	return 0, false
+}
+
+// onResume runs fn in its own goroutine after every resume from sleep until
+// the returned function is called
+func onResume(fn func()) func() {
+	resumeHandlers.mu.Lock()
+	defer resumeHandlers.mu.Unlock()
+	if resumeHandlers.fns == nil {
+		resumeHandlers.fns = map[int]func(){}
+	}
+	resumeHandlers.nextID++
+	id := resumeHandlers.nextID
+	resumeHandlers.fns[id] = fn
+	return func() {
+		resumeHandlers.mu.Lock()
+		delete(resumeHandlers.fns, id)
+		resumeHandlers.mu.Unlock()
+	}
+}
+
+func notifyResumed() {
+	resumeHandlers.mu.Lock()
+	defer resumeHandlers.mu.Unlock()
+	for _, fn := range resumeHandlers.fns {
+		go fn()
+	}
+}
+
+// checkBackendAfterResume gives the backend a short while to answer after
+// a resume and calls restart if it does not
+func (s *Session) checkBackendAfterResume(restart func()) {
+	select {
+	case <-time.After(resumeSettleDelay):
+	case <-s.Stopping():
+		return
+	}
+	deadline := time.Now().Add(resumeHealthWindow)
+	for !checkBackendHealth(s.config) {
+		if time.Now().After(deadline) {
+			logWarn("power", "backend not answering after resume, restarting it", "url", s.config.BackendURL)
+			emitDegraded(serviceBackend, "unhealthy_after_resume", "")
+			restart()
+			return
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-s.Stopping():
+			return
+		}
+	}
+	logInfo("power", "backend healthy after resume")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+)
+
+// PrerequisiteSettings describe what the machine must provide before
+// anything starts. CPython needs the Visual C++ runtime; builds that ship
+// vc_redist.x64.exe name it here so it can be installed on the spot:
+//
+//	"prerequisites": {"min_windows_build": 19041, "vc_redist": "redist\\vc_redist.x64.exe"}
+type PrerequisiteSettings struct {
+	MinWindowsBuild int    `json:"min_windows_build"` // default 17763, Windows 10 1809
+	VCRedist        string `json:"vc_redist"`         // bundled installer, relative to bin/
+	Skip            bool   `json:"skip"`
+}
+
+const (
+	defaultMinWindowsBuild = 17763
+	vcRedistURL            = "https://aka.ms/vs/17/release/vc_redist.x64.exe"
+	windowsUpdateURL       = "ms-settings:windowsupdate"
+
+	// vc_redist exit codes that mean the runtime is there
+	vcRedistRestartNeeded = 3010
+	vcRedistNewerPresent  = 1638
+)
+
+// The DLLs CPython's python3X.dll imports from the Visual C++ runtime
+var vcRuntimeDLLs = []string{"vcruntime140.dll", "vcruntime140_1.dll"}
+
+// missingPrerequisite is one requirement the machine does not meet
+type missingPrerequisite struct {
+	name      string
+	problem   string
+	url       string // where to get it
+	installer string // bundled installer, if any
+}
+
+// windowsBuild returns the build number from RtlGetVersion, which unlike
+// GetVersionEx is not lied to by compatibility shims
+func windowsBuild() int {
+	version := osVersionInfo{}
+	version.Size = uint32(unsafe.Sizeof(version))
+	if ret, _, _ := procRtlGetVersion.Call(uintptr(unsafe.Pointer(&version))); ret != 0 {
+		return 0
+	}
+	return int(version.BuildNumber)
+}
+
+// missingVCRuntime returns the runtime DLLs found neither next to
+// python.exe nor in System32
+func missingVCRuntime(config *AppConfig) []string {
+	system32 := filepath.Join(os.Getenv("SystemRoot"), "System32")
+	var missing []string
+	for _, dll := range vcRuntimeDLLs {
+		found := false
+		for _, dir := range []string{filepath.Dir(config.PythonExe), system32} {
+			if _, err := os.Stat(filepath.Join(dir, dll)); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, dll)
+		}
+	}
+	return missing
+}
+
+// checkPrerequisites lists what the machine lacks. backend is false when
+// no bundled Python runs here.
+func checkPrerequisites(config *AppConfig, backend bool) []missingPrerequisite {
+	settings := config.Settings.Prerequisites
+	var missing []missingPrerequisite
+
+	minBuild := settings.MinWindowsBuild
+	if minBuild <= 0 {
+		minBuild = defaultMinWindowsBuild
+	}
+	if build := windowsBuild(); build > 0 && build < minBuild {
+		missing = append(missing, missingPrerequisite{
+			name:    "Windows",
+			problem: tr("Windows build %d or later is required, this PC runs build %d", minBuild, build),
+			url:     windowsUpdateURL,
+		})
+	}
+
+	if prereq := checkLongPaths(config); prereq != nil {
+		missing = append(missing, *prereq)
+	}
+
+	if backend {
+		if dlls := missingVCRuntime(config); len(dlls) > 0 {
+			prereq := missingPrerequisite{
+				name:    "Microsoft Visual C++ Redistributable (x64)",
+				problem: tr("missing %s", strings.Join(dlls, ", ")),
+				url:     vcRedistURL,
+			}
+			if settings.VCRedist != "" {
+				installer := filepath.Join(config.BinDir, settings.VCRedist)
+				if _, err := os.Stat(installer); err == nil {
+					prereq.installer = installer
+				}
+			}
+			missing = append(missing, prereq)
+		}
+	}
+	return missing
+}
+
+// ensurePrerequisites reports missing prerequisites and offers to install
+// or download them. It returns false if the launcher should not continue.
+func ensurePrerequisites(config *AppConfig, backend bool) bool {
+	if config.Settings.Prerequisites.Skip {
+		return true
+	}
+	for _, prereq := range checkPrerequisites(config, backend) {
+		consoleErrorf("%s: %s", prereq.name, prereq.problem)
+		logError("validate", "prerequisite missing", "name", prereq.name, "problem", prereq.problem)
+		if machineOutput() {
+			return false
+		}
+
+		if prereq.installer != "" {
+			answer := messageBox(config.AppName,
+				tr("%s needs the %s, which is not installed on this PC (%s).\n\nInstall it now? Windows will ask for administrator permission.", config.AppName, prereq.name, prereq.problem),
+				mbYesNo|mbIconWarning|mbTopmost)
+			if answer != idYes {
+				return false
+			}
+			if err := installPrerequisite(prereq); err != nil {
+				showError(tr("Failed to install the %s", prereq.name), err)
+				return false
+			}
+			consoleSuccessf(tr("%s installed"), prereq.name)
+			continue
+		}
+
+		answer := messageBox(config.AppName,
+			tr("%s cannot run on this PC: %s.\n\nOpen the download page for the %s?", config.AppName, prereq.problem, prereq.name),
+			mbYesNo|mbIconWarning|mbTopmost)
+		if answer == idYes {
+			if err := openURL(prereq.url); err != nil {
+				logWarn("validate", "failed to open download page", "url", prereq.url, "error", err)
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// installPrerequisite runs a bundled installer elevated. "Already
+// installed" and "restart needed" count as success.
+func installPrerequisite(prereq missingPrerequisite) error {
+	logInfo("validate", "running bundled installer", "installer", prereq.installer)
+	code, err := runElevated(prereq.installer, []string{"/install", "/passive", "/norestart"})
+	if err != nil {
+		return err
+	}
+	switch code {
+	case 0, vcRedistRestartNeeded, vcRedistNewerPresent:
+		return nil
+	default:
+		return fmt.Errorf("%s exited with code %d", filepath.Base(prereq.installer), code)
+	}
+}
+
+func doctorPrerequisites(config *AppConfig) doctorResult {
+	missing := checkPrerequisites(config, bundledPython(config))
+	if len(missing) > 0 {
+		return doctorResult{detail: fmt.Sprintf("%s: %s", missing[0].name, missing[0].problem)}
+	}
+	return doctorResult{ok: true, detail: fmt.Sprintf("Windows build %d", windowsBuild())}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ProcessSettings set the priority class and CPU affinity of one child, for
+// low-end point-of-sale machines where the backend must not starve the UI:
+//
+//	"processes": {"backend": {"priority": "below_normal", "affinity": "1-3"}}
+type ProcessSettings struct {
+	Priority string `json:"priority"` // idle, below_normal, normal, above_normal or high
+	Affinity string `json:"affinity"` // CPUs such as "0,2-3", or a mask such as "0xC"
+}
+
+type ProcessesSettings struct {
+	Backend  ProcessSettings `json:"backend"`
+	Frontend ProcessSettings `json:"frontend"`
+}
+
+var procSetProcessAffinityMask = kernel32.NewProc("SetProcessAffinityMask")
+
+const processSetInformation = 0x0200
+
+// Priority class creation flags; realtime is deliberately not offered
+var priorityClasses = map[string]uint32{
+	"idle":         0x00000040,
+	"below_normal": 0x00004000,
+	"normal":       0x00000020,
+	"above_normal": 0x00008000,
+	"high":         0x00000080,
+}
+
+// affinityMask parses Affinity, 0 meaning all CPUs
+func (p ProcessSettings) affinityMask() (uintptr, error) {
+	value := strings.TrimSpace(p.Affinity)
+	if value == "" {
+		return 0, nil
+	}
+	if hex, ok := strings.CutPrefix(strings.ToLower(value), "0x"); ok {
+		mask, err := strconv.ParseUint(hex, 16, bits.UintSize)
+		if err != nil || mask == 0 {
+			return 0, fmt.Errorf("invalid affinity mask %q", value)
+		}
+		return uintptr(mask), nil
+	}
+	var mask uintptr
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(first)
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(last)
+		}
+		if err != nil || from < 0 || to < from || to >= bits.UintSize {
+			return 0, fmt.Errorf("invalid CPU list %q", value)
+		}
+		for cpu := from; cpu <= to; cpu++ {
+			mask |= 1 << cpu
+		}
+	}
+	return mask, nil
+}
+
+// prepare checks the settings and adds the priority class to cmd
+func (p ProcessSettings) prepare(cmd *exec.Cmd) error {
+	if p.Priority != "" {
+		class, ok := priorityClasses[p.Priority]
+		if !ok {
+			return fmt.Errorf("unknown priority %q (expected idle, below_normal, normal, above_normal or high)", p.Priority)
+		}
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.CreationFlags |= class
+	}
+	_, err := p.affinityMask()
+	return err
+}
+
+// apply sets the affinity of the started child. A mask naming CPUs the
+// machine does not have only costs a warning.
+func (p ProcessSettings) apply(service string, pid int) {
+	mask, _ := p.affinityMask()
+	if mask == 0 {
+		return
+	}
+	handle, err := syscall.OpenProcess(processSetInformation, false, uint32(pid))
+	if err == nil {
+		if ret, _, callErr := procSetProcessAffinityMask.Call(uintptr(handle), mask); ret == 0 {
+			err = callErr
+		}
+		syscall.CloseHandle(handle)
+	}
+	if err != nil {
+		logWarn(service, "failed to set CPU affinity", "child_pid", pid, "affinity", p.Affinity, "error", err)
+		return
+	}
+	logInfo(service, "CPU affinity set", "child_pid", pid, "affinity", p.Affinity)
+}
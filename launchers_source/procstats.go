@@ -0,0 +1,67 @@
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var procGetProcessMemoryInfo = kernel32.NewProc("K32GetProcessMemoryInfo")
+
+const processQueryLimitedInformation = 0x1000
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS
+type processMemoryCounters struct {
+	Cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// processSample is the CPU time and memory of a process at one moment
+type processSample struct {
+	At         time.Time
+	CPUTime    time.Duration // kernel + user
+	WorkingSet uint64
+}
+
+func sampleProcess(pid int) (processSample, error) {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return processSample{}, err
+	}
+	defer syscall.CloseHandle(handle)
+
+	var created, exited, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &created, &exited, &kernel, &user); err != nil {
+		return processSample{}, err
+	}
+	sample := processSample{At: time.Now(), CPUTime: filetimeDuration(kernel) + filetimeDuration(user)}
+
+	counters := processMemoryCounters{Cb: uint32(unsafe.Sizeof(processMemoryCounters{}))}
+	if ret, _, _ := procGetProcessMemoryInfo.Call(uintptr(handle), uintptr(unsafe.Pointer(&counters)), uintptr(counters.Cb)); ret != 0 {
+		sample.WorkingSet = uint64(counters.WorkingSetSize)
+	}
+	return sample, nil
+}
+
+// FILETIME durations are in 100 ns units
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
+
+// cpuPercent is the share of the whole machine used between two samples
+func cpuPercent(prev, cur processSample) float64 {
+	wall := cur.At.Sub(prev.At)
+	if wall <= 0 || cur.CPUTime < prev.CPUTime {
+		return 0
+	}
+	return float64(cur.CPUTime-prev.CPUTime) / float64(wall) / float64(runtime.NumCPU()) * 100
+}
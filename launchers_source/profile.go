@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// A named profile (--profile staging) is a separate instance of the
+// application: its own data, logs and state below profiles\<name>, its own
+// block of ports and its own single-instance lock, so a "staging" and a
+// "production" copy run side by side. launcher.json may fix the ports:
+//
+//	"profiles": {"staging": {"port_base": 5200}}
+//
+// The backend gets port_base, LAN mode port_base+1 and a fixed metrics
+// port port_base+2. Without port_base the block is derived from the name.
+type ProfileSettings struct {
+	PortBase int `json:"port_base"`
+}
+
+const (
+	profileFlagName = "profile"
+	profilesDirName = "profiles"
+
+	// Derived port blocks lie in [profilePortFirst, profilePortFirst+profilePortBlocks*profilePortBlock)
+	profilePortFirst  = 5100
+	profilePortBlock  = 10
+	profilePortBlocks = 400
+
+	// How long a launcher started by an update rollback waits for the
+	// exiting one before deciding another instance is running
+	instanceWait     = 5 * time.Second
+	instanceWaitPoll = 100 * time.Millisecond
+
+	// Set for the launcher started by rollBackFailedUpdate
+	relaunchEnv = "WAP_UPDATE_RELAUNCH"
+)
+
+var (
+	procCreateMutexW = kernel32.NewProc("CreateMutexW")
+
+	profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+)
+
+// profileFlag takes --profile NAME (or --profile=NAME) out of the
+// arguments before "--", so it applies to subcommands too
+func profileFlag(args []string) (string, []string, error) {
+	name := ""
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		flagName, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || flagName != profileFlagName {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("--profile needs a name")
+			}
+			i++
+			value = args[i]
+		}
+		name = value
+	}
+	if name != "" && !profileNamePattern.MatchString(name) {
+		return "", nil, fmt.Errorf("invalid profile name %q (letters, digits, - and _ only)", name)
+	}
+	return name, rest, nil
+}
+
+// profileDir is where a named profile keeps its files below base
+func profileDir(config *AppConfig, base string) string {
+	if config.Profile == "" {
+		return base
+	}
+	return filepath.Join(base, profilesDirName, config.Profile)
+}
+
+// profilePortBase returns the first port of the profile's block
+func profilePortBase(config *AppConfig) int {
+	if base := config.Settings.Profiles[config.Profile].PortBase; base > 0 {
+		return base
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(strings.ToLower(config.Profile)))
+	return profilePortFirst + int(hash.Sum32()%profilePortBlocks)*profilePortBlock
+}
+
+// applyProfile moves the directories and ports to the named profile. It
+// runs right after the settings are loaded.
+func applyProfile(config *AppConfig, name string) {
+	if name == "" {
+		return
+	}
+	config.Profile = name
+	setUserDirs(config)
+
+	base := profilePortBase(config)
+	config.BackendURL = fmt.Sprintf("http://127.0.0.1:%d", base)
+	if config.Settings.LAN.Port == 0 {
+		config.Settings.LAN.Port = base + 1
+	}
+	if config.Settings.Metrics.Port != 0 {
+		config.Settings.Metrics.Port = base + 2
+	}
+}
+
+// profileEnv tells a child which profile it belongs to
+func profileEnv(config *AppConfig) []string {
+	if config.Profile == "" {
+		return nil
+	}
+	return []string{"WAP_PROFILE=" + config.Profile}
+}
+
+// instanceMutexName scopes the single-instance lock to the profile and the
+// Windows session, or on a shared machine to the user across sessions
+func instanceMutexName(config *AppConfig) string {
+	name := `Local\WAP-launcher`
+	if config.SessionIsolated {
+		name = `Global\WAP-launcher-` + currentUserSID()
+	}
+	if config.Profile != "" {
+		name += "-" + strings.ToLower(config.Profile)
+	}
+	return name
+}
+
+// acquireInstance takes the profile's single-instance lock and returns
+// false if another launcher of the same profile runs in this session. The
+// lock is a named mutex that exists while a launcher holds a handle to
+// it, so it goes away when that process exits, even after a crash. Only
+// a launcher relaunched by an update rollback waits for the old one.
+func acquireInstance(config *AppConfig) bool {
+	name, err := syscall.UTF16PtrFromString(instanceMutexName(config))
+	if err != nil {
+		return true
+	}
+	relaunched := os.Getenv(relaunchEnv) != ""
+	os.Unsetenv(relaunchEnv)
+	deadline := time.Now().Add(instanceWait)
+	for {
+		handle, _, err := procCreateMutexW.Call(0, 0, uintptr(unsafe.Pointer(name)))
+		if handle == 0 {
+			logWarn("launcher", "failed to create the instance lock", "error", err)
+			return true
+		}
+		if err != syscall.ERROR_ALREADY_EXISTS {
+			return true
+		}
+		syscall.CloseHandle(syscall.Handle(handle))
+		if !relaunched || time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(instanceWaitPoll)
+	}
+}
+
+// reportAlreadyRunning tells the user why nothing starts
+func reportAlreadyRunning(config *AppConfig) {
+	message := tr("%s is already running.", config.AppName)
+	if config.Profile != "" {
+		message = tr("%s is already running with the profile \"%s\".", config.AppName, config.Profile)
+	}
+	logWarn("launcher", "another instance is running", "profile", config.Profile)
+	consoleErrorf("%s", message)
+	if !hasConsole() && !machineOutput() {
+		messageBox(config.AppName, message, mbOK|mbIconInformation|mbTopmost)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// statusFileName is written next to the logs with the current boot status
+const statusFileName = "status.json"
+
+// progressUpdate is what the backend POSTs to /progress while it works
+// through long startup tasks such as loading ML models:
+//
+//	{"task": "models", "message": "Loading classifier", "percent": 42}
+//	{"task": "models", "done": true}
+type progressUpdate struct {
+	Task    string  `json:"task"`
+	Message string  `json:"message,omitempty"`
+	Percent float64 `json:"percent"`
+	Done    bool    `json:"done,omitempty"`
+}
+
+// launcherStatus is republished to status.json for tools and support
+type launcherStatus struct {
+	PID      int              `json:"launcher_pid"`
+	Backend  string           `json:"backend_url,omitempty"`
+	Stage    bootStage        `json:"stage"`
+	Percent  float64          `json:"percent"`
+	Tasks    []progressUpdate `json:"tasks"`
+	Services []serviceStatus  `json:"services"`
+	Trace    *traceInfo       `json:"trace,omitempty"`
+	Updated  time.Time        `json:"updated"`
+}
+
+// overallPercent averages all known tasks, finished ones count as 100%
+func overallPercent(tasks map[string]progressUpdate) float64 {
+	if len(tasks) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, task := range tasks {
+		if task.Done {
+			sum += 100
+		} else {
+			sum += task.Percent
+		}
+	}
+	return sum / float64(len(tasks))
+}
+
+// progressSummary is the one-line text shown on the splash and in the tray
+func progressSummary(stage bootStage, tasks map[string]progressUpdate) string {
+	var active []string
+	for _, task := range tasks {
+		if !task.Done {
+			label := task.Message
+			if label == "" {
+				label = task.Task
+			}
+			active = append(active, fmt.Sprintf("%s %d%%", label, int(task.Percent)))
+		}
+	}
+	sort.Strings(active)
+	if len(active) == 0 {
+		return stage.label()
+	}
+	return strings.Join(active, "\n")
+}
+
+func (c *controlServer) handleProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var update progressUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil || update.Task == "" {
+		http.Error(w, "expected {\"task\": ..., \"percent\": ...}", http.StatusBadRequest)
+		return
+	}
+	update.Percent = min(max(update.Percent, 0), 100)
+	c.SetProgress(update)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetProgress records a task update and notifies listeners
+func (c *controlServer) SetProgress(update progressUpdate) {
+	c.mu.Lock()
+	previous, known := c.tasks[update.Task]
+	c.tasks[update.Task] = update
+	c.mu.Unlock()
+
+	// Log task boundaries, not every percent
+	if !known || previous.Done != update.Done {
+		logInfo("control", "startup task progress", "task", update.Task, "percent", update.Percent, "done", update.Done)
+	}
+	c.notify()
+}
+
+// resetProgress forgets the tasks of a previous session
+func (c *controlServer) resetProgress() {
+	c.mu.Lock()
+	c.tasks = map[string]progressUpdate{}
+	c.mu.Unlock()
+}
+
+func (c *controlServer) status() launcherStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := launcherStatus{
+		PID:      os.Getpid(),
+		Backend:  c.backendURL,
+		Stage:    c.stage,
+		Percent:  overallPercent(c.tasks),
+		Services: c.servicesSnapshot(),
+		Updated:  time.Now(),
+	}
+	if c.trace != nil {
+		trace := *c.trace
+		status.Trace = &trace
+	}
+	for _, task := range c.tasks {
+		status.Tasks = append(status.Tasks, task)
+	}
+	sort.Slice(status.Tasks, func(i, j int) bool { return status.Tasks[i].Task < status.Tasks[j].Task })
+	return status
+}
+
+func (c *controlServer) writeStatusFile() {
+	if c.statusPath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(c.status(), "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := c.statusPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err == nil {
+		os.Rename(tmp, c.statusPath)
+	}
+}
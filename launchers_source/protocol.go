@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ProtocolSettings name the URL scheme "launcher register-protocol"
+// registers, so links in browsers and e-mails open the application:
+//
+//	"protocol": {"scheme": "wap"}
+//
+// Windows starts the launcher with --open-url and the link. A launcher
+// already running takes the link over: the app's window receives it as
+// WM_COPYDATA (dwData copyDataOpenURL, the link as NUL-terminated UTF-16)
+// and comes to the front. Otherwise the app starts with the link as its
+// last argument. With the browser runner the part after scheme:// is a
+// path below the application's address. Files work the same way, see
+// fileassoc.go.
+type ProtocolSettings struct {
+	Scheme string `json:"scheme"` // default "wap"
+}
+
+const (
+	defaultProtocolScheme = "wap"
+
+	// copyDataOpenURL marks a link in WM_COPYDATA ("WAPL")
+	copyDataOpenURL = 0x5741504c
+
+	wmCopyData         = 0x004a
+	smtoAbortIfHung    = 0x0002
+	copyDataTimeout    = 5 * time.Second
+	registryClassesKey = `Software\Classes\`
+)
+
+var (
+	procRegDeleteTreeW      = advapi32.NewProc("RegDeleteTreeW")
+	procSendMessageTimeoutW = user32.NewProc("SendMessageTimeoutW")
+)
+
+// copyDataStruct is COPYDATASTRUCT
+type copyDataStruct struct {
+	data   uintptr
+	size   uint32
+	buffer uintptr
+}
+
+func protocolScheme(config *AppConfig) string {
+	if scheme := config.Settings.Protocol.Scheme; scheme != "" {
+		return strings.ToLower(scheme)
+	}
+	return defaultProtocolScheme
+}
+
+// isAppLink reports whether link uses the application's scheme
+func isAppLink(config *AppConfig, link string) bool {
+	return strings.HasPrefix(strings.ToLower(link), protocolScheme(config)+"://")
+}
+
+// linkURL is where the browser runner opens a link
+func linkURL(config *AppConfig, link string) string {
+	path := link[len(protocolScheme(config)+"://"):]
+	return strings.TrimRight(browserURL(config), "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// forwardOpen hands --open-url or --open-file to the launcher already
+// running. It returns false when that launcher cannot take it.
+func forwardOpen(config *AppConfig) bool {
+	command := serviceCommand{Action: "open", URL: config.OpenURL, Path: config.OpenFile}
+	if err := sendServiceCommand(config, command); err != nil {
+		logWarn("protocol", "failed to forward to the running launcher", "url", command.URL, "path", command.Path, "error", err)
+		return false
+	}
+	logInfo("protocol", "forwarded to the running launcher", "url", command.URL, "path", command.Path)
+	return true
+}
+
+// open shows a link or a file in the running app
+func (s *Session) open(command serviceCommand) error {
+	// A minimized start opens the app with it
+	s.mu.Lock()
+	waiting := s.awaitingShow
+	s.mu.Unlock()
+	if waiting && (command.URL == "" || isAppLink(s.config, command.URL)) {
+		s.config.OpenURL, s.config.OpenFile = command.URL, command.Path
+		s.requestShow()
+		return nil
+	}
+	switch {
+	case command.URL != "":
+		return s.openLink(command.URL)
+	case command.Path != "":
+		return s.openFile(command.Path)
+	}
+	return errors.New("open needs a url or a path")
+}
+
+// openLink shows a link in the running app
+func (s *Session) openLink(link string) error {
+	if !isAppLink(s.config, link) {
+		return fmt.Errorf("not a %s:// link", protocolScheme(s.config))
+	}
+	if _, ok := s.config.Frontend.(browserRunner); ok {
+		return openURL(linkURL(s.config, link))
+	}
+	if err := s.sendToApp(copyDataOpenURL, link); err != nil {
+		return err
+	}
+	logInfo("protocol", "opened a link in the application", "url", link)
+	return nil
+}
+
+// sendToApp passes text to the app's window with WM_COPYDATA and brings
+// the window to the front
+func (s *Session) sendToApp(kind uintptr, text string) error {
+	s.mu.Lock()
+	frontend := s.frontend
+	s.mu.Unlock()
+	if frontend == nil {
+		return errors.New("the application is not running")
+	}
+	windows := processWindows(frontend.Process.Pid)
+	if len(windows) == 0 {
+		return errors.New("the application has no window yet")
+	}
+	if err := sendCopyData(windows[0], kind, text); err != nil {
+		return err
+	}
+	procSetForegroundWindow.Call(windows[0])
+	return nil
+}
+
+func sendCopyData(hwnd, kind uintptr, text string) error {
+	buffer, err := syscall.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+	data := copyDataStruct{data: kind, size: uint32(len(buffer) * 2), buffer: uintptr(unsafe.Pointer(&buffer[0]))}
+	var result uintptr
+	ret, _, err := procSendMessageTimeoutW.Call(hwnd, wmCopyData, 0, uintptr(unsafe.Pointer(&data)),
+		smtoAbortIfHung, uintptr(copyDataTimeout.Milliseconds()), uintptr(unsafe.Pointer(&result)))
+	if ret == 0 {
+		return fmt.Errorf("the application did not respond: %w", err)
+	}
+	return nil
+}
+
+type registerProtocolOptions struct {
+	remove bool
+}
+
+func (o *registerProtocolOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.remove, "remove", false, "remove the registration instead")
+}
+
+// runRegisterProtocolCommand registers the scheme for the current user,
+// which needs no administrator rights
+func runRegisterProtocolCommand(config *AppConfig, args []string) int {
+	var opts registerProtocolOptions
+	fs := newCommandFlagSet("register-protocol")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	scheme := protocolScheme(config)
+	key := registryClassesKey + scheme
+	if opts.remove {
+		if err := deleteRegistryTree(syscall.HKEY_CURRENT_USER, key); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to remove the %s:// handler: %v\n", scheme, err)
+			return exitLauncherError
+		}
+		fmt.Printf("✓ %s:// links no longer open %s\n", scheme, config.AppName)
+		return exitOK
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+	command := fmt.Sprintf(`"%s"`, exe)
+	if config.Profile != "" {
+		command += fmt.Sprintf(` --%s "%s"`, profileFlagName, config.Profile)
+	}
+	command += ` --open-url "%1"`
+	values := []struct{ key, name, value string }{
+		{key, "", "URL:" + config.AppName},
+		{key, "URL Protocol", ""},
+		{key + `\DefaultIcon`, "", fmt.Sprintf(`"%s",0`, exe)},
+		{key + `\shell\open\command`, "", command},
+	}
+	for _, v := range values {
+		if err := setRegistryString(syscall.HKEY_CURRENT_USER, v.key, v.name, v.value); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to register the %s:// handler: %v\n", scheme, err)
+			return exitLauncherError
+		}
+	}
+	logInfo("protocol", "registered the URL protocol", "scheme", scheme, "command", command)
+	fmt.Printf("✓ %s:// links now open %s\n", scheme, config.AppName)
+	return exitOK
+}
+
+func setRegistryString(root syscall.Handle, path, name, value string) error {
+	var key syscall.Handle
+	ret, _, _ := procRegCreateKeyExW.Call(
+		uintptr(root),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(path))),
+		0, 0, 0,
+		uintptr(syscall.KEY_WRITE),
+		0,
+		uintptr(unsafe.Pointer(&key)),
+		0,
+	)
+	if ret != 0 {
+		return syscall.Errno(ret)
+	}
+	defer syscall.RegCloseKey(key)
+
+	data, _ := syscall.UTF16FromString(value)
+	ret, _, _ = procRegSetValueExW.Call(uintptr(key),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(name))), 0,
+		syscall.REG_SZ,
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)*2))
+	if ret != 0 {
+		return syscall.Errno(ret)
+	}
+	return nil
+}
+
+// deleteRegistryTree removes a key and everything below it; a missing key
+// is not an error
+func deleteRegistryTree(root syscall.Handle, path string) error {
+	ret, _, _ := procRegDeleteTreeW.Call(uintptr(root), uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(path))))
+	if ret != 0 && syscall.Errno(ret) != syscall.ERROR_FILE_NOT_FOUND {
+		return syscall.Errno(ret)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxySettings puts a launcher-run HTTPS proxy in front of the Python
+// backend. The backend moves to a private port and only requests carrying
+// the session token reach it.
+type ProxySettings struct {
+	Enabled bool `json:"enabled"`
+	Port    int  `json:"port"` // default: the backend's usual port
+}
+
+const (
+	proxyCertName      = ".proxy-cert.pem"
+	proxyKeyName       = ".proxy-key.pem"
+	proxyAccessLogName = "proxy_access.log"
+
+	proxyCertValidity = 10 * 365 * 24 * time.Hour
+	// Certificates this close to expiring are replaced at startup
+	proxyCertRenewal = 30 * 24 * time.Hour
+)
+
+// backendProxy forwards authenticated requests to the backend
+type backendProxy struct {
+	url      string
+	token    string
+	certPath string
+	server   *http.Server
+	lan      *lanListener
+	workers  atomic.Pointer[workerPool] // set while extra backend workers run
+
+	logMu     sync.Mutex
+	accessLog *os.File
+}
+
+// proxyPort is the port clients use when the proxy is enabled
+func proxyPort(config *AppConfig) int {
+	if port := config.Settings.Proxy.Port; port > 0 {
+		return port
+	}
+	return backendPort(config)
+}
+
+// startBackendProxy listens on the backend's public port and moves the
+// backend itself to a free port. It must run before the backend starts.
+func startBackendProxy(config *AppConfig) (*backendProxy, error) {
+	// The certificate has to name every address clients connect to
+	hosts := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	if config.Settings.LAN.Enabled {
+		hosts = append(hosts, lanAddresses()...)
+	}
+	certPath := filepath.Join(config.StateDir, proxyCertName)
+	cert, err := ensureProxyCertificate(certPath, filepath.Join(config.StateDir, proxyKeyName), hosts)
+	if err != nil {
+		return nil, err
+	}
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	port := proxyPort(config)
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("proxy cannot listen on port %d: %w", port, err)
+	}
+	if err := setBackendPort(config, 0); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	target, err := url.Parse(config.BackendURL)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	p := &backendProxy{
+		url:      fmt.Sprintf("https://127.0.0.1:%d", port),
+		token:    token,
+		certPath: certPath,
+	}
+	if p.accessLog, err = os.OpenFile(filepath.Join(config.LogDir, proxyAccessLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		logWarn("proxy", "failed to open access log", "error", err)
+	}
+
+	forward := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(p.target(target))
+			r.SetXForwarded()
+			r.Out.Header.Del("Authorization")
+			r.Out.Header.Del("X-WAP-Token")
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logWarn("proxy", "backend request failed", "path", r.URL.Path, "error", err)
+			http.Error(w, "backend unavailable", http.StatusBadGateway)
+		},
+	}
+	p.server = &http.Server{
+		Handler:           p.handler(forward, p.authorized),
+		TLSConfig:         &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go p.server.Serve(tls.NewListener(listener, p.server.TLSConfig))
+
+	logInfo("proxy", "backend proxy listening", "url", p.url, "backend", config.BackendURL)
+
+	if config.Settings.LAN.Enabled {
+		if p.lan, err = p.startLAN(config, forward, cert); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// target returns where a request goes: the backend, or with workers the
+// next one in turn
+func (p *backendProxy) target(backend *url.URL) *url.URL {
+	if pool := p.workers.Load(); pool != nil {
+		return pool.pick(backend)
+	}
+	return backend
+}
+
+func (p *backendProxy) setWorkers(pool *workerPool) {
+	p.workers.Store(pool)
+}
+
+func (p *backendProxy) Close() {
+	p.server.Close()
+	if p.lan != nil {
+		p.lan.server.Close()
+	}
+	if p.accessLog != nil {
+		p.accessLog.Close()
+	}
+}
+
+// env tells the Flutter app where the proxy is and how to talk to it
+func (p *backendProxy) env() []string {
+	return []string{
+		"WAP_BACKEND_URL=" + p.url,
+		"WAP_BACKEND_TOKEN=" + p.token,
+		"WAP_BACKEND_CERT=" + p.certPath,
+	}
+}
+
+func (p *backendProxy) handler(next http.Handler, authorized func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		switch {
+		case !authorized(r):
+			http.Error(recorder, "unauthorized", http.StatusUnauthorized)
+		case r.URL.Path == "/health" && chaosDropHealthCheck():
+			http.Error(recorder, "dropped by chaos mode", http.StatusServiceUnavailable)
+		default:
+			next.ServeHTTP(recorder, r)
+		}
+		p.logRequest(r, recorder, time.Since(start))
+	})
+}
+
+// authorized accepts the token as a bearer token or in X-WAP-Token, the
+// header the control channel uses
+func (p *backendProxy) authorized(r *http.Request) bool {
+	token := r.Header.Get("X-WAP-Token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(p.token)) == 1
+}
+
+// logRequest writes one line per request to proxy_access.log
+func (p *backendProxy) logRequest(r *http.Request, recorder *statusRecorder, elapsed time.Duration) {
+	if p.accessLog == nil {
+		return
+	}
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
+	fmt.Fprintf(p.accessLog, "%s %s %s %s %d %d %s\n",
+		time.Now().Format(time.RFC3339), r.RemoteAddr, r.Method, r.URL.RequestURI(), recorder.status, recorder.bytes, elapsed.Round(time.Millisecond))
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(data)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps streamed responses such as /progress flowing
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ensureProxyCertificate loads the install's self-signed certificate,
+// generating a new one if it is missing, about to expire or does not cover
+// all of hosts
+func ensureProxyCertificate(certPath, keyPath string, hosts []net.IP) (tls.Certificate, error) {
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil && time.Until(leaf.NotAfter) > proxyCertRenewal && certCovers(leaf, hosts) {
+			return cert, nil
+		}
+	}
+
+	logInfo("proxy", "generating self-signed certificate", "path", certPath)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "WAP local backend"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(proxyCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true, // clients trust it directly as a root
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           hosts,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to write %s: %w", keyPath, err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to write %s: %w", certPath, err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generated certificate is unusable: %w", err)
+	}
+	return cert, nil
+}
+
+func certCovers(leaf *x509.Certificate, hosts []net.IP) bool {
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host.String()) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// PythonSettings pins the interpreter the backend needs. By default the
+// version and bit-ness are read from the native extensions (.pyd files)
+// bundled with the embedded interpreter.
+type PythonSettings struct {
+	ExpectedVersion string `json:"expected_version"` // e.g. "3.11"
+	ExpectedBits    int    `json:"expected_bits"`    // 32 or 64
+	SkipCheck       bool   `json:"skip_check"`       // also skips the requirements.lock check
+}
+
+// pythonInfo describes an interpreter
+type pythonInfo struct {
+	Implementation string // CPython
+	Version        string // 3.11.5
+	Bits           int
+}
+
+func (p pythonInfo) String() string {
+	return fmt.Sprintf("%d-bit %s %s", p.Bits, p.Implementation, p.Version)
+}
+
+// Extension module names carry the ABI they were built for, e.g.
+// _imaging.cp311-win_amd64.pyd
+var pydTag = regexp.MustCompile(`\.cp(\d)(\d+)-(win_amd64|win32|win_arm64)\.pyd$`)
+
+// pythonEnviron is the environment the embedded interpreter runs in. A
+// system Python's PYTHONPATH, PYTHONHOME or user site-packages would
+// otherwise be picked up and break imports, so every PYTHON* variable is
+// dropped and the embedded directory goes first on PATH. An external
+// Python (--dev --python) gets the environment unchanged.
+func pythonEnviron(config *AppConfig) []string {
+	if config.ExternalPython {
+		return os.Environ()
+	}
+	path := ""
+	var env, dropped []string
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		switch {
+		case strings.HasPrefix(strings.ToUpper(name), "PYTHON"):
+			dropped = append(dropped, name)
+			continue
+		case strings.EqualFold(name, "PATH"):
+			path = value
+			continue
+		}
+		env = append(env, entry)
+	}
+	if len(dropped) > 0 {
+		logInfo("backend", "ignoring Python variables from the environment", "names", strings.Join(dropped, ","))
+	}
+
+	pythonDir := shortPath(config.PythonDir)
+	path = strings.Join([]string{pythonDir, filepath.Join(pythonDir, "Scripts"), path}, string(os.PathListSeparator))
+	env = append(env, "PATH="+path)
+	return append(env, pythonEnv(config)...)
+}
+
+// pythonEnv is what pythonEnviron sets besides PATH
+func pythonEnv(config *AppConfig) []string {
+	if config.ExternalPython {
+		return nil
+	}
+	// UTF-8 mode, so non-ASCII user names and paths survive printing
+	// to the launcher's pipes
+	return []string{
+		"PYTHONHOME=" + shortPath(config.PythonDir),
+		"PYTHONNOUSERSITE=1",
+		"PYTHONUTF8=1",
+		"PYTHONIOENCODING=utf-8",
+	}
+}
+
+// probePython asks the interpreter what it is
+func probePython(config *AppConfig) (*pythonInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, config.PythonExe, "-c",
+		"import platform, struct; print(platform.python_implementation(), platform.python_version(), struct.calcsize('P') * 8)")
+	cmd.Env = pythonEnviron(config)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s does not run: %w", config.PythonExe, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected output from %s: %q", config.PythonExe, strings.TrimSpace(string(out)))
+	}
+	bits, _ := strconv.Atoi(fields[2])
+	return &pythonInfo{Implementation: fields[0], Version: fields[1], Bits: bits}, nil
+}
+
+// expectedPython returns the interpreter the bundled extensions were built
+// for, or nil if there is nothing to go by
+func expectedPython(config *AppConfig) *pythonInfo {
+	settings := config.Settings.Python
+	expected := &pythonInfo{Implementation: "CPython", Version: settings.ExpectedVersion, Bits: settings.ExpectedBits}
+	if expected.Version != "" && expected.Bits != 0 {
+		return expected
+	}
+
+	filepath.WalkDir(config.PythonDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		m := pydTag.FindStringSubmatch(d.Name())
+		if m == nil {
+			return nil
+		}
+		if expected.Version == "" {
+			expected.Version = m[1] + "." + m[2]
+		}
+		if expected.Bits == 0 {
+			expected.Bits = 64
+			if m[3] == "win32" {
+				expected.Bits = 32
+			}
+		}
+		return fs.SkipAll
+	})
+	if expected.Version == "" && expected.Bits == 0 {
+		return nil
+	}
+	return expected
+}
+
+// checkPython fails with a precise message when the interpreter cannot
+// load the bundled extensions, instead of the backend dying later with an
+// ImportError
+func checkPython(config *AppConfig) (*pythonInfo, error) {
+	found, err := probePython(config)
+	if err != nil {
+		return nil, err
+	}
+	expected := expectedPython(config)
+	if expected == nil {
+		return found, nil
+	}
+
+	versionOK := expected.Version == "" || found.Version == expected.Version || strings.HasPrefix(found.Version, expected.Version+".")
+	bitsOK := expected.Bits == 0 || found.Bits == expected.Bits
+	if found.Implementation == expected.Implementation && versionOK && bitsOK {
+		return found, nil
+	}
+
+	want := expected.Implementation
+	if expected.Bits != 0 {
+		want = fmt.Sprintf("%d-bit %s", expected.Bits, want)
+	}
+	if expected.Version != "" {
+		want += " " + expected.Version + ".x"
+	}
+	return found, errors.New("Expected " + want + ", found " + found.String())
+}
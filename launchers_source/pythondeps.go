@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The backend ships a pinned list of its packages and the wheels they were
+// installed from, so an interrupted copy can be repaired without network:
+//
+//	bin/python_backend/requirements.lock   flask==2.3.3, one per line
+//	bin/python_backend/wheels/             *.whl
+const (
+	requirementsLockName = "requirements.lock"
+	wheelsDirName        = "wheels"
+	pipRepairLogName     = "pip_repair.log"
+)
+
+// depProblem is a locked package that is not installed as expected
+type depProblem struct {
+	Requirement string // flask==2.3.3
+	Problem     string
+}
+
+// Checks each requirement against the installed distribution metadata and
+// makes sure every file in its RECORD is still there
+const checkDepsScript = `import sys, importlib.metadata as md
+for req in sys.argv[1:]:
+    name, _, want = req.partition('==')
+    try:
+        d = md.distribution(name)
+    except md.PackageNotFoundError:
+        print(req + '\tnot installed'); continue
+    if want and d.version != want:
+        print(req + '\tversion ' + d.version + ' installed'); continue
+    missing = [str(f) for f in (d.files or []) if not f.locate().exists()]
+    if missing:
+        print(req + '\t%d file(s) missing, e.g. %s' % (len(missing), missing[0]))
+`
+
+// readRequirementsLock returns the pinned requirements, or nil if the
+// backend has no lock file
+func readRequirementsLock(config *AppConfig) ([]string, error) {
+	file, err := os.Open(filepath.Join(config.BackendDir, requirementsLockName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reqs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line, _, _ = strings.Cut(line, ";") // environment markers
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), "\\"))
+		// Skip options such as --hash or -i
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			reqs = append(reqs, fields[0])
+		}
+	}
+	return reqs, scanner.Err()
+}
+
+// checkPythonDeps reports the locked packages that are missing, at the
+// wrong version or damaged in the embedded Python
+func checkPythonDeps(config *AppConfig) ([]depProblem, error) {
+	reqs, err := readRequirementsLock(config)
+	if err != nil || len(reqs) == 0 {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, config.PythonExe, append([]string{"-c", checkDepsScript}, reqs...)...)
+	cmd.Env = pythonEnviron(config)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check Python packages: %w", err)
+	}
+
+	var problems []depProblem
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if req, problem, ok := strings.Cut(strings.TrimSpace(line), "\t"); ok {
+			problems = append(problems, depProblem{Requirement: req, Problem: problem})
+		}
+	}
+	return problems, nil
+}
+
+// canRepairPythonDeps reports whether bundled wheels are available
+func canRepairPythonDeps(config *AppConfig) bool {
+	info, err := os.Stat(filepath.Join(config.BackendDir, wheelsDirName))
+	return err == nil && info.IsDir()
+}
+
+// repairPythonDeps reinstalls the broken packages from the bundled wheels
+// without touching the network
+func repairPythonDeps(config *AppConfig, problems []depProblem) error {
+	if !canRepairPythonDeps(config) {
+		return fmt.Errorf("no %s directory in %s to repair from", wheelsDirName, config.BackendDir)
+	}
+	args := []string{"-m", "pip", "install", "--no-index", "--find-links", filepath.Join(config.BackendDir, wheelsDirName),
+		"--force-reinstall", "--no-deps", "--disable-pip-version-check", "--no-warn-script-location"}
+	for _, p := range problems {
+		args = append(args, p.Requirement)
+	}
+
+	logFile, err := os.Create(filepath.Join(config.LogDir, pipRepairLogName))
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	defer logFile.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, config.PythonExe, args...)
+	cmd.Env = pythonEnviron(config)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	logInfo("backend", "reinstalling python packages", "count", len(problems))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pip failed, see %s: %w", logFile.Name(), err)
+	}
+	return nil
+}
+
+func printDepProblems(problems []depProblem) {
+	for _, p := range problems {
+		consoleErrorf("%s: %s", p.Requirement, p.Problem)
+		logError("backend", "python package failed verification", "requirement", p.Requirement, "problem", p.Problem)
+	}
+}
+
+// verifyPythonDeps runs the startup package check and repairs what it can.
+// It returns an error if the backend would not be able to import its
+// packages.
+func verifyPythonDeps(config *AppConfig) error {
+	problems, err := checkPythonDeps(config)
+	if err != nil {
+		// A broken check is not a broken install
+		logWarn("backend", "python package check failed", "error", err)
+		return nil
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	printDepProblems(problems)
+	if !canRepairPythonDeps(config) {
+		return fmt.Errorf("%d Python package(s) are missing or damaged, for example %s (%s)",
+			len(problems), problems[0].Requirement, problems[0].Problem)
+	}
+	consolePrintf("Reinstalling %d Python package(s) from the bundled wheels...\n", len(problems))
+	if err := repairPythonDeps(config, problems); err != nil {
+		return err
+	}
+	if problems, err = checkPythonDeps(config); err == nil && len(problems) > 0 {
+		return fmt.Errorf("%s is still broken after repair: %s", problems[0].Requirement, problems[0].Problem)
+	}
+	consoleSuccessf("Python packages repaired")
+	return nil
+}
+
+type repairPythonOptions struct {
+	check bool
+}
+
+func (o *repairPythonOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.check, "check", false, "only verify the packages, do not reinstall")
+}
+
+// runRepairPythonCommand implements "launcher repair-python": verify the
+// locked packages and reinstall the broken ones from the bundled wheels.
+func runRepairPythonCommand(config *AppConfig, args []string) int {
+	var opts repairPythonOptions
+	fs := newCommandFlagSet("repair-python")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	reqs, err := readRequirementsLock(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read %s: %v\n", requirementsLockName, err)
+		return exitLauncherError
+	}
+	if len(reqs) == 0 {
+		fmt.Printf("No %s in %s, nothing to verify\n", requirementsLockName, config.BackendDir)
+		return exitOK
+	}
+
+	fmt.Printf("Verifying %d Python package(s)...\n", len(reqs))
+	problems, err := checkPythonDeps(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+		return exitLauncherError
+	}
+	if len(problems) == 0 {
+		fmt.Println("✓ All Python packages are intact")
+		return exitOK
+	}
+	printDepProblems(problems)
+	if opts.check {
+		return exitLauncherError
+	}
+
+	fmt.Printf("Reinstalling %d package(s) from %s...\n", len(problems), wheelsDirName)
+	if err := repairPythonDeps(config, problems); err != nil {
+		fmt.Fprintf(os.Stderr, "Repair failed: %v\n", err)
+		return exitLauncherError
+	}
+	if problems, err := checkPythonDeps(config); err != nil || len(problems) > 0 {
+		fmt.Fprintln(os.Stderr, "Repair failed: packages are still broken, see", pipRepairLogName)
+		return exitLauncherError
+	}
+	fmt.Println("✓ Repair complete")
+	return exitOK
+}
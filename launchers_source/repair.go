@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RepairSettings names where damaged files can be fetched from when the
+// launcher has no payload archive to re-extract them from.
+type RepairSettings struct {
+	BaseURL string `json:"base_url"` // files are fetched from BaseURL + "/" + path
+}
+
+// repairInstall restores the files listed in problems, preferring the
+// payload archive and falling back to downloading them one by one.
+func repairInstall(config *AppConfig, problems []verifyProblem) (err error) {
+	manifest, err := loadManifest(config.BinDir)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return errors.New("no manifest.json to repair against")
+	}
+
+	broken := map[string]bool{}
+	for _, p := range problems {
+		broken[p.Path] = true
+	}
+
+	onTaskbar := startTaskbarProgress("repair")
+	defer func() { onTaskbar.End(err) }()
+	_, openPayload, err := resolvePayload(config)
+	switch {
+	case err != nil:
+		return err
+	case openPayload != nil:
+		err = repairFromPayload(config, openPayload, broken, onTaskbar)
+	case config.Settings.Repair.BaseURL != "":
+		err = repairFromURL(config, manifest, broken, onTaskbar)
+	default:
+		return errors.New("no repair source available (no embedded payload, payload.url or repair.base_url)")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Make sure what we restored actually matches the manifest
+	for rel := range broken {
+		path := filepath.Join(config.BinDir, filepath.FromSlash(rel))
+		hash, err := fileSHA256(path)
+		if err != nil {
+			return fmt.Errorf("%s is still missing after repair: %w", rel, err)
+		}
+		if !strings.EqualFold(hash, manifest.Files[rel].SHA256) {
+			return fmt.Errorf("%s does not match the manifest after repair", rel)
+		}
+	}
+	logInfo("repair", "repaired files", "count", len(broken))
+	return nil
+}
+
+func repairFromPayload(config *AppConfig, openPayload payloadOpener, broken map[string]bool, onTaskbar *taskbarProgress) error {
+	archive, cleanup, err := openPayload(onTaskbar.Set)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	include := func(name string) bool { return broken[strings.TrimPrefix(name, "./")] }
+	return extractZip(archive, config.BinDir, extractOptions{Include: include, Progress: onTaskbar.Set})
+}
+
+func repairFromURL(config *AppConfig, manifest *fileManifest, broken map[string]bool, onTaskbar *taskbarProgress) error {
+	base := strings.TrimRight(config.Settings.Repair.BaseURL, "/")
+	done := 0
+	for rel := range broken {
+		consolePrintf("Downloading %s\n", rel)
+		target := filepath.Join(config.BinDir, filepath.FromSlash(rel))
+		if err := downloadFile(fileURL(base, rel), target); err != nil {
+			return fmt.Errorf("failed to download %s: %w", rel, err)
+		}
+		done++
+		onTaskbar.Set(int64(done), int64(len(broken)))
+	}
+	return nil
+}
+
+// downloadTimeout bounds a whole download, full update archives included,
+// so a stalled server does not hang a repair or an update forever
+const downloadTimeout = 15 * time.Minute
+
+func downloadFile(url, target string) error {
+	return downloadFileProgress(url, target, nil)
+}
+
+// fileURL is where a file of the install is found below base, with each
+// segment of its slash-separated path escaped
+func fileURL(base, rel string) string {
+	segments := strings.Split(rel, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.Join(segments, "/")
+}
+
+// downloadFileProgress is downloadFile calling progress as data arrives,
+// when the server sent the size
+func downloadFileProgress(url, target string, progress func(done, total int64)) error {
+	client := &http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := target + ".partial"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = out
+	if progress != nil && resp.ContentLength > 0 {
+		w = &progressWriter{w: out, total: resp.ContentLength, report: progress}
+	}
+	_, err = io.Copy(w, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// progressWriter reports how much was written through it
+type progressWriter struct {
+	w      io.Writer
+	done   int64
+	total  int64
+	report func(done, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	p.report(p.done, p.total)
+	return n, err
+}
+
+func printVerifyProblems(problems []verifyProblem) {
+	for _, p := range problems {
+		consoleErrorf("%s: %s", p.Path, p.Problem)
+		logError("verify", "file failed verification", "path", p.Path, "problem", p.Problem)
+	}
+}
+
+// repairOrElevate repairs in this process when bin/ is writable and
+// otherwise runs "launcher repair" as administrator
+func repairOrElevate(config *AppConfig, problems []verifyProblem) error {
+	if canWrite(config.BinDir) || isElevated() {
+		return repairInstall(config, problems)
+	}
+	consolePrintln("Windows will ask for permission to repair the installation...")
+	return runElevatedStep("repair")
+}
+
+// runRepairCommand implements "launcher repair": verify every file and
+// restore the ones that fail.
+func runRepairCommand(config *AppConfig, args []string) int {
+	fs := newCommandFlagSet("repair")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	fmt.Println("Verifying application files...")
+	problems, err := verifyInstall(config, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+		return exitLauncherError
+	}
+	if len(problems) == 0 {
+		fmt.Println("✓ All files are intact")
+		return exitOK
+	}
+
+	printVerifyProblems(problems)
+	if tampered := tamperedFiles(problems); len(tampered) > 0 && tamperPolicy(config) != tamperOff {
+		if quarantine, err := quarantineFiles(config, tampered); err == nil {
+			fmt.Printf("Moved %d modified file(s) to %s\n", len(tampered), quarantine)
+		}
+	}
+	fmt.Printf("Repairing %d file(s)...\n", len(problems))
+	if err := repairOrElevate(config, problems); err != nil {
+		fmt.Fprintf(os.Stderr, "Repair failed: %v\n", err)
+		return exitLauncherError
+	}
+	fmt.Println("✓ Repair complete")
+	return exitOK
+}
+
+// verifyBeforeStart runs the startup integrity check. It returns false if
+// the launcher should not continue. Only an interactive launch asks before
+// repairing; others log the damage and start anyway.
+func verifyBeforeStart(config *AppConfig, full, interactive bool) bool {
+	if full {
+		consolePrintln("Verifying all application files...")
+	}
+	problems, err := verifyInstall(config, full)
+	if err != nil {
+		logWarn("verify", "integrity check failed", "error", err)
+		return true
+	}
+	if len(problems) == 0 {
+		return true
+	}
+
+	printVerifyProblems(problems)
+	if suspects := antivirusSuspects(problems); len(suspects) > 0 {
+		adviseAntivirus(config, suspects)
+	}
+	if tampered := tamperedFiles(problems); len(tampered) > 0 && tamperPolicy(config) != tamperOff {
+		return handleTampering(config, problems, tampered, interactive)
+	}
+	// Nobody could answer the question: the damage is in the log
+	if !interactive || machineOutput() {
+		logWarn("verify", "starting with missing or damaged files, run \"launcher repair\" to fix them", "count", len(problems))
+		return true
+	}
+	answer := messageBox(config.AppName,
+		tr("%d application file(s) are missing or damaged. This is often caused by antivirus software.\n\nRepair them now?", len(problems)),
+		mbYesNo|mbIconWarning|mbTopmost)
+	if answer != idYes {
+		return true
+	}
+
+	consolePrintln("Repairing application files...")
+	if err := repairOrElevate(config, problems); err != nil {
+		showError("Repair failed", err)
+		return false
+	}
+	consoleSuccessf("Repair complete")
+	return true
+}
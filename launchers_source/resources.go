@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ResourceSettings control how often the children's CPU and memory are
+// sampled for launcher.log and /status, and an optional memory ceiling
+// for the backend: a backend whose working set grows past backend_max_mb
+// is restarted, with a notification, to contain leaks. The free space of
+// the data drive is checked on the same schedule.
+type ResourceSettings struct {
+	IntervalSeconds int `json:"interval_seconds"` // default 60
+	BackendMaxMB    int `json:"backend_max_mb"`   // 0 for no ceiling
+	MinFreeMB       int `json:"min_free_mb"`      // default 1024
+}
+
+var procGetDiskFreeSpaceExW = kernel32.NewProc("GetDiskFreeSpaceExW")
+
+const (
+	defaultResourceInterval = time.Minute
+	defaultMinFreeMB        = 1024
+
+	// A full disk stays full; it is reported again at most this often
+	diskFullReportInterval = time.Hour
+)
+
+// startResourceMonitor samples the running services until the launcher exits
+func startResourceMonitor(config *AppConfig, control *controlServer) {
+	interval := defaultResourceInterval
+	if seconds := config.Settings.Resources.IntervalSeconds; seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+	go func() {
+		previous := map[int]processSample{}
+		var diskFullReported time.Time
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-launcherExiting():
+				return
+			}
+			previous = sampleServices(config, control, previous)
+			if time.Since(diskFullReported) > diskFullReportInterval && checkFreeSpace(config) {
+				diskFullReported = time.Now()
+			}
+		}
+	}()
+}
+
+// sampleServices records one sample per running service and returns the
+// samples to compare the next round against
+func sampleServices(config *AppConfig, control *controlServer, previous map[int]processSample) map[int]processSample {
+	control.mu.Lock()
+	running := map[string]int{}
+	for name, service := range control.services {
+		if service.State == "running" && service.PID != 0 {
+			running[name] = service.PID
+		}
+	}
+	control.mu.Unlock()
+
+	samples := map[int]processSample{}
+	for name, pid := range running {
+		sample, err := sampleProcess(pid)
+		if err != nil {
+			continue
+		}
+		samples[pid] = sample
+		cpu := 0.0
+		if prev, ok := previous[pid]; ok {
+			cpu = cpuPercent(prev, sample)
+		}
+		control.serviceResources(name, pid, cpu, sample.WorkingSet)
+		logInfo("resources", "resource usage", "service", name, "child_pid", pid,
+			"cpu_percent", fmt.Sprintf("%.1f", cpu), "working_set_mb", sample.WorkingSet>>20)
+
+		if name == serviceBackend {
+			checkBackendMemory(config, control, pid, sample.WorkingSet)
+		}
+	}
+	return samples
+}
+
+// checkBackendMemory restarts a backend past its memory ceiling
+func checkBackendMemory(config *AppConfig, control *controlServer, pid int, workingSet uint64) {
+	limit := uint64(config.Settings.Resources.BackendMaxMB) << 20
+	if limit == 0 || workingSet <= limit {
+		return
+	}
+	message := tr("The background service used %s of memory, more than the %d MB allowed, and is being restarted.",
+		formatBytes(int64(workingSet)), config.Settings.Resources.BackendMaxMB)
+	logWarn("resources", "backend exceeded its memory ceiling, restarting it", "child_pid", pid,
+		"working_set_mb", workingSet>>20, "max_mb", config.Settings.Resources.BackendMaxMB)
+	reportEvent(eventTypeWarning, eventIDResourceLimit, message)
+	emitDegraded(serviceBackend, "memory_ceiling", message)
+	if notify := alertNotifier(); notify != nil {
+		notify(config.AppName, message)
+	}
+	if err := control.runServiceCommand(serviceCommand{Service: serviceBackend, Action: "restart"}); err != nil {
+		logWarn("resources", "failed to restart backend", "error", err)
+	}
+}
+
+// freeSpace returns the bytes available to the user on dir's drive
+func freeSpace(dir string) (uint64, error) {
+	path, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if ret, _, err := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&available)), 0, 0); ret == 0 {
+		return 0, err
+	}
+	return available, nil
+}
+
+// checkFreeSpace reports a nearly full data drive and returns true if it did
+func checkFreeSpace(config *AppConfig) bool {
+	minFree := config.Settings.Resources.MinFreeMB
+	if minFree <= 0 {
+		minFree = defaultMinFreeMB
+	}
+	available, err := freeSpace(config.DataDir)
+	if err != nil || available >= uint64(minFree)<<20 {
+		return false
+	}
+	message := tr("Only %s is free on the drive holding %s. The application may fail to save data.",
+		formatBytes(int64(available)), config.DataDir)
+	logWarn("resources", "disk almost full", "dir", config.DataDir, "free_mb", available>>20, "min_free_mb", minFree)
+	reportEvent(eventTypeWarning, eventIDResourceLimit, message)
+	emitDegraded(serviceLauncher, "disk_full", message)
+	if notify := alertNotifier(); notify != nil {
+		notify(tr("%s - disk full", config.AppName), message)
+	}
+	notifyWebhook(webhookDiskFull, message, "")
+	return true
+}
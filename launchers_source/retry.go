@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// On slow or busy machines some startup failures go away by themselves: an
+// antivirus scan holds a file open for a moment, the backend's port is
+// still held by a process that is exiting, a network drive answers late.
+// Phases that fail this way are run again, startup.retries times with
+// startup.retry_delay_seconds growing after each attempt, before an error
+// is shown.
+
+const (
+	defaultStartupRetries    = 2
+	defaultStartupRetryDelay = 2 * time.Second
+)
+
+// Windows errors that are usually gone a moment later
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+	errorSemTimeout       syscall.Errno = 121
+	errorUserMappedFile   syscall.Errno = 1224
+	wsaeAddrInUse         syscall.Errno = 10048
+)
+
+// Backend log signatures (knownerrors.go) of transient failures
+var transientBackendErrors = map[string]bool{
+	"port_in_use":   true,
+	"access_denied": true,
+}
+
+// startupRetries is how often a failed phase is run again, 0 when
+// startup.retries is -1
+func startupRetries(config *AppConfig) int {
+	switch retries := config.Settings.Startup.Retries; {
+	case retries < 0:
+		return 0
+	case retries == 0:
+		return defaultStartupRetries
+	default:
+		return retries
+	}
+}
+
+// startupRetryDelay is the wait before the attempt-th retry
+func startupRetryDelay(config *AppConfig, attempt int) time.Duration {
+	delay := defaultStartupRetryDelay
+	if seconds := config.Settings.Startup.RetryDelaySeconds; seconds > 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	return delay * time.Duration(attempt)
+}
+
+// isTransientError reports whether err is likely to go away on its own
+func isTransientError(err error) bool {
+	for _, errno := range []syscall.Errno{errorAccessDenied, errorSharingViolation, errorLockViolation, errorSemTimeout, errorUserMappedFile, wsaeAddrInUse} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// retryTransient runs phase, running it again after a delay while it
+// fails with a transient error. It returns the last error.
+func retryTransient(config *AppConfig, phase string, run func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || attempt > startupRetries(config) || !isTransientError(err) {
+			return err
+		}
+		if !waitBeforeRetry(config, phase, attempt, err) {
+			return err
+		}
+	}
+}
+
+// waitBeforeRetry logs a retry and waits for its delay; false if the
+// launcher is exiting meanwhile
+func waitBeforeRetry(config *AppConfig, phase string, attempt int, err error) bool {
+	delay := startupRetryDelay(config, attempt)
+	logWarn("launcher", "transient startup failure, retrying", "phase", phase, "attempt", attempt, "delay", delay.String(), "error", err)
+	consoleWarnf("%s failed (%v), trying again in %s", phase, err, delay)
+	select {
+	case <-time.After(delay):
+		return true
+	case <-launcherExiting():
+		return false
+	}
+}
+
+// retryBackendStart starts the backend again when it exited during
+// startup for a transient reason. It returns false when the failure
+// should be reported instead.
+func retryBackendStart(session *Session, attempt int) bool {
+	config := session.config
+	if config.RemoteBackend || attempt > startupRetries(config) || session.StopReason() != "" {
+		return false
+	}
+	select {
+	case <-session.backendExited():
+	default:
+		return false // still running but not healthy: not transient
+	}
+	diagnosis, ok := diagnoseBackendLog(config)
+	if !ok || !transientBackendErrors[diagnosis.name] {
+		return false
+	}
+	if !waitBeforeRetry(config, "Starting the Python backend", attempt, errors.New(diagnosis.line)) {
+		return false
+	}
+	var cmd *exec.Cmd
+	err := retryTransient(config, "Starting the Python backend", func() (err error) {
+		cmd, err = startBackend(config, session.control)
+		return err
+	})
+	if err != nil {
+		logError("backend", "failed to start python backend again", "error", err)
+		return false
+	}
+	session.watchBackend(cmd)
+	logInfo("backend", "python backend started again", "attempt", attempt, "child_pid", cmd.Process.Pid)
+	return true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// Every start is recorded in session_state.json. A session that ends with
+// a failure exit code, or never ends because the launcher was killed or
+// crashed, counts as failed; a clean exit resets the count. After
+// safe_mode.failures failed sessions in a row the user is offered Safe
+// Mode, which is also available as --safe-mode:
+//
+//   - the backend starts with --safe and WAP_SAFE_MODE=1 (minimal plugins)
+//   - no update check
+//   - debug logging in launcher.log and the backend
+type SafeModeSettings struct {
+	Failures int  `json:"failures"` // default 3
+	Disabled bool `json:"disabled"` // never offer Safe Mode
+}
+
+const (
+	sessionStateName       = "session_state.json"
+	defaultSafeModeFailure = 3
+)
+
+type sessionState struct {
+	Running             bool      `json:"running"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastExitCode        int       `json:"last_exit_code"`
+	LastStart           time.Time `json:"last_start"`
+	LastEnd             time.Time `json:"last_end,omitempty"`
+}
+
+func sessionStatePath(config *AppConfig) string {
+	return filepath.Join(config.StateDir, sessionStateName)
+}
+
+// sessionFailed reports whether an exit code counts toward Safe Mode.
+// The demo ending is not the application's fault.
+func sessionFailed(code int) bool {
+	return code != exitOK && code != exitDemoExpired
+}
+
+// beginSession records the start and returns the number of failed
+// sessions before it, counting one that never recorded its end
+func beginSession(config *AppConfig) int {
+	var state sessionState
+	readJSONFile(sessionStatePath(config), &state)
+	if state.Running {
+		state.ConsecutiveFailures++
+		logWarn("launcher", "previous session did not end cleanly", "started", state.LastStart.Format(time.RFC3339))
+	}
+	failures := state.ConsecutiveFailures
+	state.Running = true
+	state.LastStart = time.Now()
+	if err := writeJSONFile(sessionStatePath(config), state); err != nil {
+		logWarn("launcher", "failed to record session start", "error", err)
+	}
+	return failures
+}
+
+// endSession records how the session ended
+func endSession(config *AppConfig, code int) {
+	var state sessionState
+	readJSONFile(sessionStatePath(config), &state)
+	state.Running = false
+	state.LastExitCode = code
+	state.LastEnd = time.Now()
+	if sessionFailed(code) {
+		state.ConsecutiveFailures++
+	} else {
+		state.ConsecutiveFailures = 0
+	}
+	writeJSONFile(sessionStatePath(config), state)
+}
+
+// offerSafeMode asks whether to start in Safe Mode after repeated failures
+func offerSafeMode(config *AppConfig, failures int) bool {
+	threshold := config.Settings.SafeMode.Failures
+	if threshold <= 0 {
+		threshold = defaultSafeModeFailure
+	}
+	if config.Settings.SafeMode.Disabled || failures < threshold {
+		return false
+	}
+	logWarn("launcher", "repeated failed sessions, offering safe mode", "failures", failures)
+	answer := messageBox(config.AppName,
+		tr("%s failed to start or closed unexpectedly %d times in a row.\n\nStart in Safe Mode? Plugins are disabled, updates are not checked and detailed logs are written to %s.",
+			config.AppName, failures, config.LogDir),
+		mbYesNo|mbIconWarning|mbTopmost)
+	return answer == idYes
+}
+
+// enterSafeMode applies Safe Mode to the configuration
+func enterSafeMode(config *AppConfig) {
+	config.SafeMode = true
+	config.BackendArgs = append(config.BackendArgs, "--safe", "--log-level=debug")
+	setLogVerbose(true)
+	consolePrintln("Starting in Safe Mode")
+	logInfo("launcher", "starting in safe mode", "log_dir", config.LogDir)
+}
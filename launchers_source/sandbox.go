@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// SandboxSettings harden the Python backend so a vulnerable dependency
+// cannot take over the machine:
+//
+//	"backend_sandbox": {"mode": "restricted"}
+//
+// "restricted" starts python.exe with a restricted copy of the user's
+// token: the Administrators group is deny-only, all privileges except
+// bypass traverse checking are removed, and the integrity level is Low,
+// so it can write nothing but DataDir, LogDir and its own temp folder
+// (DataDir\tmp), which are labelled Low for it. Packages that write to
+// the user profile or next to their own code fail under it, which is why
+// it is opt-in. An AppContainer would isolate the network too, but
+// starting one needs process attributes os/exec does not offer.
+type SandboxSettings struct {
+	Mode string `json:"mode"` // "" (off) or restricted
+}
+
+const sandboxRestricted = "restricted"
+
+var (
+	procCreateRestrictedToken                                = advapi32.NewProc("CreateRestrictedToken")
+	procSetTokenInformation                                  = advapi32.NewProc("SetTokenInformation")
+	procConvertStringSecurityDescriptorToSecurityDescriptorW = advapi32.NewProc("ConvertStringSecurityDescriptorToSecurityDescriptorW")
+	procGetSecurityDescriptorSacl                            = advapi32.NewProc("GetSecurityDescriptorSacl")
+	procSetNamedSecurityInfoW                                = advapi32.NewProc("SetNamedSecurityInfoW")
+)
+
+const (
+	disableMaxPrivilege = 0x1
+	luaToken            = 0x4
+
+	tokenIntegrityLevel = 25
+	seGroupIntegrity    = 0x20
+
+	tokenAdjustDefault = 0x0080
+
+	seFileObject             = 1
+	labelSecurityInformation = 0x10
+
+	administratorsSID = "S-1-5-32-544"
+	lowIntegritySID   = "S-1-16-4096"
+	// Low mandatory label, inherited by files and folders, no write up
+	lowLabelSDDL = "S:(ML;OICI;NW;;;LW)"
+)
+
+// tokenMandatoryLabel is TOKEN_MANDATORY_LABEL
+type tokenMandatoryLabel struct {
+	Label syscall.SIDAndAttributes
+}
+
+// restrictedToken returns a Low integrity, non-admin copy of the
+// launcher's token
+func restrictedToken() (syscall.Token, error) {
+	var token syscall.Token
+	process, _ := syscall.GetCurrentProcess()
+	access := uint32(syscall.TOKEN_DUPLICATE | syscall.TOKEN_QUERY | syscall.TOKEN_ASSIGN_PRIMARY | tokenAdjustDefault)
+	if err := syscall.OpenProcessToken(process, access, &token); err != nil {
+		return 0, fmt.Errorf("OpenProcessToken: %w", err)
+	}
+	defer token.Close()
+
+	admins, err := syscall.StringToSid(administratorsSID)
+	if err != nil {
+		return 0, err
+	}
+	disable := syscall.SIDAndAttributes{Sid: admins}
+	var restricted syscall.Token
+	r, _, err := procCreateRestrictedToken.Call(uintptr(token), disableMaxPrivilege|luaToken,
+		1, uintptr(unsafe.Pointer(&disable)), 0, 0, 0, 0, uintptr(unsafe.Pointer(&restricted)))
+	if r == 0 {
+		return 0, fmt.Errorf("CreateRestrictedToken: %w", err)
+	}
+
+	low, err := syscall.StringToSid(lowIntegritySID)
+	if err != nil {
+		restricted.Close()
+		return 0, err
+	}
+	label := tokenMandatoryLabel{Label: syscall.SIDAndAttributes{Sid: low, Attributes: seGroupIntegrity}}
+	size := uint32(unsafe.Sizeof(label)) + uint32(low.Len())
+	if r, _, err := procSetTokenInformation.Call(uintptr(restricted), tokenIntegrityLevel, uintptr(unsafe.Pointer(&label)), uintptr(size)); r == 0 {
+		restricted.Close()
+		return 0, fmt.Errorf("SetTokenInformation: %w", err)
+	}
+	return restricted, nil
+}
+
+// labelLowIntegrity lets Low integrity processes write below dir. The
+// label is inherited by everything already in it.
+func labelLowIntegrity(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var sd uintptr
+	if r, _, err := procConvertStringSecurityDescriptorToSecurityDescriptorW.Call(
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(lowLabelSDDL))), 1, uintptr(unsafe.Pointer(&sd)), 0); r == 0 {
+		return fmt.Errorf("ConvertStringSecurityDescriptorToSecurityDescriptor: %w", err)
+	}
+	defer syscall.LocalFree(syscall.Handle(sd))
+
+	var present, defaulted int32
+	var sacl uintptr
+	if r, _, err := procGetSecurityDescriptorSacl.Call(sd, uintptr(unsafe.Pointer(&present)), uintptr(unsafe.Pointer(&sacl)), uintptr(unsafe.Pointer(&defaulted))); r == 0 {
+		return fmt.Errorf("GetSecurityDescriptorSacl: %w", err)
+	}
+	if r, _, _ := procSetNamedSecurityInfoW.Call(uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(dir))),
+		seFileObject, labelSecurityInformation, 0, 0, 0, sacl); r != 0 {
+		return fmt.Errorf("SetNamedSecurityInfo %s: %w", dir, syscall.Errno(r))
+	}
+	return nil
+}
+
+// applyBackendSandbox prepares cmd to run in the configured sandbox. The
+// returned function releases the token once the process has started.
+func applyBackendSandbox(config *AppConfig, cmd *exec.Cmd) (func(), error) {
+	switch config.Settings.BackendSandbox.Mode {
+	case "":
+		return func() {}, nil
+	case sandboxRestricted:
+	case "appcontainer":
+		return nil, errors.New("backend_sandbox.mode appcontainer is not supported, use restricted")
+	default:
+		return nil, fmt.Errorf("unknown backend_sandbox.mode %q", config.Settings.BackendSandbox.Mode)
+	}
+
+	tempDir := filepath.Join(config.DataDir, "tmp")
+	for _, dir := range []string{config.DataDir, config.LogDir, tempDir} {
+		if err := labelLowIntegrity(dir); err != nil {
+			return nil, fmt.Errorf("backend_sandbox: %w", err)
+		}
+	}
+	token, err := restrictedToken()
+	if err != nil {
+		return nil, fmt.Errorf("backend_sandbox: %w", err)
+	}
+	cmd.SysProcAttr.Token = token
+	// Bytecode caches next to the code could not be written anyway
+	cmd.Env = append(cmd.Env, "TEMP="+tempDir, "TMP="+tempDir, "PYTHONDONTWRITEBYTECODE=1")
+	logInfo("backend", "starting backend with a restricted token", "writable", config.DataDir+";"+config.LogDir)
+	return func() { token.Close() }, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+var (
+	procGetCurrentThread  = kernel32.NewProc("GetCurrentThread")
+	procSetThreadPriority = kernel32.NewProc("SetThreadPriority")
+)
+
+// Background mode lowers both CPU and I/O priority of the calling thread
+const (
+	threadModeBackgroundBegin = 0x00010000
+	threadModeBackgroundEnd   = 0x00020000
+)
+
+// ScrubSettings schedules the background re-verification of bin/
+type ScrubSettings struct {
+	Disabled     bool `json:"disabled"`
+	IntervalDays int  `json:"interval_days"` // default 7
+}
+
+const (
+	scrubStampName       = ".last-scrub"
+	defaultScrubInterval = 7
+)
+
+// startScrubber re-hashes every installed file and checks disk health
+// once per interval while the launcher runs. Corrupt files are reported
+// right away, and because the hash cache now records the bad hash, the next
+// launch offers a repair.
+func startScrubber(config *AppConfig, tray *Tray) {
+	settings := config.Settings.Scrub
+	if settings.Disabled {
+		return
+	}
+	days := settings.IntervalDays
+	if days <= 0 {
+		days = defaultScrubInterval
+	}
+	interval := time.Duration(days) * 24 * time.Hour
+	stamp := filepath.Join(config.RootDir, scrubStampName)
+
+	go func() {
+		// Let startup finish before competing for the disk
+		time.Sleep(10 * time.Minute)
+		for {
+			if info, err := os.Stat(stamp); err != nil || time.Since(info.ModTime()) >= interval {
+				scrubInstall(config)
+				if disks, err := checkDiskHealth(); err != nil {
+					logWarn("disk", "disk health check failed", "error", err)
+				} else {
+					reportFailingDisks(config, tray, disks)
+				}
+				os.WriteFile(stamp, []byte(time.Now().Format(time.RFC3339)), 0644)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+func scrubInstall(config *AppConfig) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	thread, _, _ := procGetCurrentThread.Call()
+	procSetThreadPriority.Call(thread, threadModeBackgroundBegin)
+	defer procSetThreadPriority.Call(thread, threadModeBackgroundEnd)
+
+	started := time.Now()
+	logInfo("scrub", "verifying installed files")
+	problems, err := verifyInstall(config, true)
+	if err != nil {
+		logWarn("scrub", "integrity scrub failed", "error", err)
+		return
+	}
+	if len(problems) == 0 {
+		logInfo("scrub", "all files intact", "duration", time.Since(started).Round(time.Second).String())
+		return
+	}
+
+	for _, p := range problems {
+		logError("scrub", "file failed verification", "path", p.Path, "problem", p.Problem)
+	}
+	emitDegraded(serviceLauncher, "files_damaged", fmt.Sprintf("%d file(s) failed verification", len(problems)))
+	reportEvent(eventTypeWarning, eventIDIntegrity, fmt.Sprintf(
+		"%d installed file(s) of %s are missing or damaged, for example %s (%s). This can indicate a failing disk. Run \"launcher repair\" to restore them.",
+		len(problems), config.AppName, problems[0].Path, problems[0].Problem))
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Environment values of the form secret://name are replaced with the named
+// secret when a child starts, so API keys never sit in plain text next to
+// the launcher:
+//
+//	"backend_env": {"MAPS_API_KEY": "secret://maps-api-key"}
+//
+// The token and password settings (agent.token, lan.password,
+// metrics.token, crash_reports.token, webhook.url) take secret://name too.
+//
+// Secrets are set with "launcher secret set maps-api-key" and kept in
+// Windows Credential Manager, or with secrets.store "dpapi" in files under
+// %APPDATA%\WAP\secrets encrypted for the current user.
+type SecretsSettings struct {
+	Store string `json:"store"` // credman (default) or dpapi
+}
+
+const (
+	secretPrefix      = "secret://"
+	secretStoreCred   = "credman"
+	secretStoreDPAPI  = "dpapi"
+	secretTargetName  = "WAP/"
+	secretFileSuffix  = ".bin"
+	credTypeGeneric   = 1
+	credPersistLocal  = 2
+	cryptProtectNoUI  = 0x1
+	errorNotFound     = syscall.Errno(1168)
+	maxSecretBlobSize = 5 * 512 // CRED_MAX_CREDENTIAL_BLOB_SIZE
+)
+
+var (
+	crypt32 = syscall.NewLazyDLL("crypt32.dll")
+
+	procCredWriteW         = advapi32.NewProc("CredWriteW")
+	procCredReadW          = advapi32.NewProc("CredReadW")
+	procCredDeleteW        = advapi32.NewProc("CredDeleteW")
+	procCredFree           = advapi32.NewProc("CredFree")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+)
+
+var errSecretNotFound = errors.New("secret not found")
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+type dataBlob struct {
+	Size uint32
+	Data *byte
+}
+
+// secretStore keeps named secrets for the current user
+type secretStore interface {
+	get(name string) (string, error)
+	set(name, value string) error
+	delete(name string) error
+}
+
+func openSecretStore(config *AppConfig) (secretStore, error) {
+	switch config.Settings.Secrets.Store {
+	case "", secretStoreCred:
+		return credentialStore{}, nil
+	case secretStoreDPAPI:
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return nil, errors.New("APPDATA is not set")
+		}
+		return dpapiStore{dir: filepath.Join(appData, "WAP", "secrets")}, nil
+	}
+	return nil, fmt.Errorf("unknown secrets.store %q (expected credman or dpapi)", config.Settings.Secrets.Store)
+}
+
+// credentialStore uses generic credentials named WAP/<name>
+type credentialStore struct{}
+
+func (credentialStore) get(name string) (string, error) {
+	var cred *credential
+	target, _ := syscall.UTF16PtrFromString(secretTargetName + name)
+	ret, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		if err == errorNotFound {
+			return "", errSecretNotFound
+		}
+		return "", fmt.Errorf("failed to read credential: %w", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (credentialStore) set(name, value string) error {
+	if len(value) > maxSecretBlobSize {
+		return fmt.Errorf("secret is longer than %d bytes", maxSecretBlobSize)
+	}
+	target, _ := syscall.UTF16PtrFromString(secretTargetName + name)
+	blob := []byte(value)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocal,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if ret, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); ret == 0 {
+		return fmt.Errorf("failed to write credential: %w", err)
+	}
+	return nil
+}
+
+func (credentialStore) delete(name string) error {
+	target, _ := syscall.UTF16PtrFromString(secretTargetName + name)
+	if ret, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); ret == 0 {
+		if err == errorNotFound {
+			return errSecretNotFound
+		}
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return nil
+}
+
+// dpapiStore keeps one file per secret, encrypted with the user's key
+type dpapiStore struct {
+	dir string
+}
+
+func (s dpapiStore) path(name string) string {
+	return filepath.Join(s.dir, name+secretFileSuffix)
+}
+
+func (s dpapiStore) get(name string) (string, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	plain, err := dpapiCall(procCryptUnprotectData, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", s.path(name), err)
+	}
+	return string(plain), nil
+}
+
+func (s dpapiStore) set(name, value string) error {
+	data, err := dpapiCall(procCryptProtectData, []byte(value))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(name), data, 0600)
+}
+
+func (s dpapiStore) delete(name string) error {
+	err := os.Remove(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return errSecretNotFound
+	}
+	return err
+}
+
+// dpapiCall runs CryptProtectData or CryptUnprotectData on data
+func dpapiCall(proc *syscall.LazyProc, data []byte) ([]byte, error) {
+	in := dataBlob{Size: uint32(len(data))}
+	if len(data) > 0 {
+		in.Data = &data[0]
+	}
+	var out dataBlob
+	ret, _, err := proc.Call(uintptr(unsafe.Pointer(&in)), 0, 0, 0, 0, cryptProtectNoUI, uintptr(unsafe.Pointer(&out)))
+	if ret == 0 {
+		return nil, err
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(out.Data)))
+	return append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), nil
+}
+
+// resolveSecretEnv replaces secret://name values in KEY=value entries
+func resolveSecretEnv(config *AppConfig, env []string) ([]string, error) {
+	var store secretStore
+	for i, entry := range env {
+		key, value, _ := strings.Cut(entry, "=")
+		name, ok := strings.CutPrefix(value, secretPrefix)
+		if !ok {
+			continue
+		}
+		// The name becomes a file name with the dpapi store
+		if !secretNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s refers to an invalid secret name %q (letters, digits, '.', '_' and '-')", key, name)
+		}
+		if store == nil {
+			var err error
+			if store, err = openSecretStore(config); err != nil {
+				return nil, err
+			}
+		}
+		secret, err := store.get(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s refers to secret %q: %w (set it with \"launcher secret set %s\")", key, name, err, name)
+		}
+		env[i] = key + "=" + secret
+	}
+	return env, nil
+}
+
+// resolveSecretValue resolves one setting that may be secret://name
+func resolveSecretValue(config *AppConfig, key, value string) (string, error) {
+	env, err := resolveSecretEnv(config, []string{key + "=" + value})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(env[0], key+"="), nil
+}
+
+type secretOptions struct {
+	value string
+}
+
+func (o *secretOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.value, "value", "", "secret value for set (default: read a line from stdin)")
+}
+
+// runSecretCommand implements "launcher secret set|get|delete NAME"
+func runSecretCommand(config *AppConfig, args []string) int {
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") {
+		printCommandHelp(os.Stderr, findCommand("secret"))
+		return exitUsage
+	}
+	action, name := args[0], args[1]
+	var opts secretOptions
+	fs := newCommandFlagSet("secret")
+	opts.register(fs)
+	if err := fs.Parse(args[2:]); err != nil {
+		return exitUsage
+	}
+	if !secretNamePattern.MatchString(name) {
+		fmt.Fprintf(os.Stderr, "Invalid secret name %q (letters, digits, '.', '_' and '-')\n", name)
+		return exitUsage
+	}
+	store, err := openSecretStore(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfigInvalid
+	}
+
+	switch action {
+	case "set":
+		value := opts.value
+		if value == "" {
+			fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				fmt.Fprintln(os.Stderr, "No value given")
+				return exitUsage
+			}
+			value = strings.TrimRight(line, "\r\n")
+		}
+		if err := store.set(name, value); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitLauncherError
+		}
+		fmt.Printf("✓ Secret %s saved; use \"%s%s\" in the configuration\n", name, secretPrefix, name)
+	case "get":
+		value, err := store.get(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return exitLauncherError
+		}
+		fmt.Println(value)
+	case "delete":
+		if err := store.delete(name); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return exitLauncherError
+		}
+		fmt.Printf("✓ Secret %s deleted\n", name)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown action %q (expected set, get or delete)\n", action)
+		return exitUsage
+	}
+	return exitOK
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Service names used in status.json and service commands
+const (
+	serviceBackend  = "backend"
+	serviceFrontend = "frontend"
+)
+
+// serviceStatus describes one child process in status.json
+type serviceStatus struct {
+	Name     string    `json:"name"`
+	PID      int       `json:"pid"`
+	State    string    `json:"state"` // running or stopped
+	Started  time.Time `json:"started"`
+	Restarts int       `json:"restarts"`
+	// Errors counts stderr lines that look like errors since the launcher
+	// started; LastError is the most recent one
+	Errors    int    `json:"errors"`
+	LastError string `json:"last_error,omitempty"`
+	// Alerts counts matches per alert rule
+	Alerts map[string]int `json:"alerts,omitempty"`
+	// Last resource sample, see resources.go
+	CPUPercent float64 `json:"cpu_percent"`
+	WorkingSet uint64  `json:"working_set"`
+	PeakMemory uint64  `json:"peak_working_set"`
+}
+
+// serviceCommand is POSTed to /service by tools such as "launcher top":
+//
+//	{"service": "backend", "action": "restart"}
+//	{"action": "stop"}
+//	{"action": "open", "url": "wap://orders/42"}
+//	{"action": "open", "path": "C:\\Users\\ana\\plan.wapx"}
+type serviceCommand struct {
+	Service string `json:"service"`
+	Action  string `json:"action"` // restart, stop or open
+	URL     string `json:"url,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// controlInfo is written to .control.json so local tools can reach the
+// running launcher's control channel
+type controlInfo struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+	PID   int    `json:"pid"`
+}
+
+const controlInfoName = ".control.json"
+
+func (c *controlServer) serviceStarted(name string, pid int) {
+	c.mu.Lock()
+	service, known := c.services[name]
+	if !known {
+		service = &serviceStatus{Name: name}
+		c.services[name] = service
+	} else {
+		service.Restarts++
+	}
+	service.PID = pid
+	service.State = "running"
+	service.Started = time.Now()
+	service.CPUPercent, service.WorkingSet, service.PeakMemory = 0, 0, 0
+	c.mu.Unlock()
+	emitEvent(lifecycleEvent{Event: eventStarted, Service: name, PID: pid})
+	c.notify()
+}
+
+// serviceStopped records that a child exited; reason says whether that was
+// expected, e.g. "stopped" or "crashed"
+func (c *controlServer) serviceStopped(name, reason string) {
+	c.mu.Lock()
+	pid := 0
+	if service, ok := c.services[name]; ok {
+		service.State = "stopped"
+		pid = service.PID
+	}
+	c.mu.Unlock()
+	emitEvent(lifecycleEvent{Event: eventStopped, Service: name, PID: pid, Reason: reason})
+	c.notify()
+}
+
+// serviceError counts an error line a child wrote to stderr
+func (c *controlServer) serviceError(name, line string) {
+	c.mu.Lock()
+	service, known := c.services[name]
+	if !known {
+		service = &serviceStatus{Name: name}
+		c.services[name] = service
+	}
+	service.Errors++
+	service.LastError = shortLine(line)
+	c.mu.Unlock()
+	c.notify()
+}
+
+// serviceAlert counts a match of an alert rule in a child's output
+func (c *controlServer) serviceAlert(name, rule string) {
+	c.mu.Lock()
+	service, known := c.services[name]
+	if !known {
+		service = &serviceStatus{Name: name}
+		c.services[name] = service
+	}
+	if service.Alerts == nil {
+		service.Alerts = map[string]int{}
+	}
+	service.Alerts[rule]++
+	c.mu.Unlock()
+	c.notify()
+}
+
+// serviceResources records a CPU and memory sample of a running child
+func (c *controlServer) serviceResources(name string, pid int, cpu float64, workingSet uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	service, ok := c.services[name]
+	if !ok || service.PID != pid {
+		return
+	}
+	service.CPUPercent = cpu
+	service.WorkingSet = workingSet
+	service.PeakMemory = max(service.PeakMemory, workingSet)
+}
+
+// servicesSnapshot must be called with c.mu held
+func (c *controlServer) servicesSnapshot() []serviceStatus {
+	services := make([]serviceStatus, 0, len(c.services))
+	for _, service := range c.services {
+		services = append(services, *service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services
+}
+
+// SetServiceHandler routes service commands to the running session; nil
+// means no session is running
+func (c *controlServer) SetServiceHandler(handler func(serviceCommand) error) {
+	c.mu.Lock()
+	c.serviceHandler = handler
+	c.mu.Unlock()
+}
+
+func (c *controlServer) handleService(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var command serviceCommand
+	if err := json.NewDecoder(r.Body).Decode(&command); err != nil {
+		http.Error(w, "expected {\"service\": ..., \"action\": ...}", http.StatusBadRequest)
+		return
+	}
+	logInfo("control", "service command", "service", command.Service, "action", command.Action)
+
+	if command.Action == "stop" {
+		requestLauncherExit()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err := c.runServiceCommand(command); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// runServiceCommand passes a restart command to the running session
+func (c *controlServer) runServiceCommand(command serviceCommand) error {
+	c.mu.Lock()
+	handler := c.serviceHandler
+	c.mu.Unlock()
+	if handler == nil {
+		return errors.New("no session is running")
+	}
+	return handler(command)
+}
+
+// writeControlInfo publishes the channel address and token next to
+// status.json. Anyone able to read the install directory can control the
+// launcher, which matches who can already replace its files.
+func (c *controlServer) writeControlInfo(path string) {
+	c.infoPath = path
+	writeJSONFile(path, controlInfo{URL: c.url, Token: c.token, PID: os.Getpid()})
+}
+
+// controlRequest calls the running launcher's control channel from
+// another process, such as "launcher top"
+func controlRequest(config *AppConfig, method, path string, body []byte) (*http.Response, error) {
+	var info controlInfo
+	if err := readJSONFile(controlInfoPath(config), &info); err != nil {
+		return nil, errors.New("the launcher does not seem to be running")
+	}
+	req, err := http.NewRequest(method, info.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-WAP-Token", info.Token)
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.New("the launcher does not seem to be running")
+	}
+	return resp, nil
+}
+
+func sendServiceCommand(config *AppConfig, command serviceCommand) error {
+	body, _ := json.Marshal(command)
+	resp, err := controlRequest(config, http.MethodPost, "/service", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New(strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func fetchLauncherStatus(config *AppConfig) (*launcherStatus, error) {
+	resp, err := controlRequest(config, http.MethodGet, "/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("launcher returned %s", resp.Status)
+	}
+	var status launcherStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func controlInfoPath(config *AppConfig) string {
+	return filepath.Join(config.StateDir, controlInfoName)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Reasons passed to Session.RequestStop
+const (
+	stopReasonUser        = "user"
+	stopReasonDemoExpired = "demo-expired"
+	stopReasonClosingTime = "closing-time"
+	stopReasonCrashed     = "crashed"
+	stopReasonRestart     = "restart"
+	// stopReasonStartupTimeout: startup.timeout_seconds passed first
+	stopReasonStartupTimeout = "startup-timeout"
+)
+
+var (
+	exitOnce sync.Once
+	exitCh   = make(chan struct{})
+)
+
+// requestLauncherExit stops the running session, if any, and makes the
+// launcher exit instead of waiting for the next one.
+func requestLauncherExit() {
+	exitOnce.Do(func() { close(exitCh) })
+}
+
+func launcherExiting() <-chan struct{} {
+	return exitCh
+}
+
+// Session tracks the running child processes so a shutdown can be
+// requested from outside the main flow (tray menu, timers).
+type Session struct {
+	config   *AppConfig
+	backend  *exec.Cmd
+	frontend *exec.Cmd
+	tray     *Tray
+	control  *controlServer
+	splash   *splashScreen
+	kiosk    bool // relaunch the frontend whenever it exits
+	// background (--minimized) opens the frontend only on request
+	background   bool
+	showCh       chan struct{}
+	awaitingShow bool
+	deadline     *startupDeadline
+
+	backendMu       sync.Mutex // serialises stopping and restarting the backend
+	backendDone     chan struct{}
+	backendStopping bool
+	frontendRestart bool
+	healthy         chan struct{} // closed once the backend first answered
+	workers         *workerPool   // the backend processes besides the first
+	healthyOnce     sync.Once
+
+	stopOnce   sync.Once
+	stopCh     chan struct{}
+	mu         sync.Mutex
+	stopReason string
+}
+
+func newSession(config *AppConfig) *Session {
+	s := &Session{config: config, stopCh: make(chan struct{}), healthy: make(chan struct{}), showCh: make(chan struct{}, 1)}
+	go func() {
+		select {
+		case <-launcherExiting():
+			s.RequestStop(stopReasonUser)
+		case <-s.stopCh:
+		}
+	}()
+	return s
+}
+
+// markHealthy records that the backend answered for the first time
+func (s *Session) markHealthy() {
+	s.healthyOnce.Do(func() { close(s.healthy) })
+}
+
+// Healthy is closed once the backend answered for the first time
+func (s *Session) Healthy() <-chan struct{} {
+	return s.healthy
+}
+
+// RequestStop asks the session to shut the frontend and backend down.
+// Only the first reason is kept.
+func (s *Session) RequestStop(reason string) {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.stopReason = reason
+		s.mu.Unlock()
+		if reason != stopReasonRestart && reason != stopReasonCrashed {
+			noteStopReason(reason)
+		}
+		logInfo("session", "stop requested", "reason", reason)
+		close(s.stopCh)
+	})
+}
+
+func (s *Session) Stopping() <-chan struct{} {
+	return s.stopCh
+}
+
+func (s *Session) StopReason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopReason
+}
+
+// watchBackend waits for the backend in the background so an unexpected
+// exit is noticed while the frontend is still running.
+func (s *Session) watchBackend(cmd *exec.Cmd) {
+	if warmBackendEnabled(s.config) {
+		recordWarmBackend(s.config, cmd.Process.Pid)
+	}
+	s.watchBackendProcess(cmd, cmd.Wait)
+}
+
+// adoptBackend watches a warm backend started by an earlier launch
+func (s *Session) adoptBackend(process *os.Process) {
+	cmd := &exec.Cmd{Path: backendProgram(s.config), Process: process}
+	s.watchBackendProcess(cmd, func() error {
+		state, err := process.Wait()
+		if err == nil && !state.Success() {
+			err = fmt.Errorf("exit status %d", state.ExitCode())
+		}
+		return err
+	})
+}
+
+// detachBackend lets the backend outlive the session and returns its PID,
+// 0 if there is none
+func (s *Session) detachBackend() int {
+	s.backendMu.Lock()
+	defer s.backendMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backend == nil {
+		return 0
+	}
+	pid := s.backend.Process.Pid
+	s.backend = nil
+	s.backendStopping = true
+	return pid
+}
+
+func (s *Session) watchBackendProcess(cmd *exec.Cmd, wait func() error) {
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.backend = cmd
+	s.backendDone = done
+	s.backendStopping = false
+	s.mu.Unlock()
+	if s.control != nil {
+		s.control.serviceStarted(serviceBackend, cmd.Process.Pid)
+	}
+	chaosBackendStarted(cmd, done)
+
+	go func() {
+		err := wait()
+		closeChildOutput(cmd.Stdout)
+		s.mu.Lock()
+		expected := s.backendStopping
+		s.mu.Unlock()
+		if s.control != nil {
+			reason := "crashed"
+			if expected {
+				reason = "stopped"
+			}
+			s.control.serviceStopped(serviceBackend, reason)
+		}
+		close(done)
+		if expected {
+			return
+		}
+
+		logError("backend", "python backend exited unexpectedly", "child_pid", cmd.Process.Pid, "error", err)
+		reportCrash(s.config, serviceBackend, err)
+		emitDegraded(serviceBackend, "crashed", fmt.Sprint(err))
+		reportEvent(eventTypeError, eventIDBackendCrash,
+			fmt.Sprintf("The WAP Python backend (PID %d) exited unexpectedly: %v", cmd.Process.Pid, err))
+		notifyWebhook(webhookBackendCrash, fmt.Sprintf("The backend (PID %d) exited unexpectedly: %v", cmd.Process.Pid, err), backendLogName)
+	}()
+}
+
+// backendExited is closed when the current backend process exits
+func (s *Session) backendExited() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.backendDone
+}
+
+// stopBackend kills the backend and waits for it to exit
+func (s *Session) stopBackend() {
+	s.backendMu.Lock()
+	defer s.backendMu.Unlock()
+	s.stopBackendLocked()
+}
+
+func (s *Session) stopBackendLocked() {
+	s.mu.Lock()
+	backend, done := s.backend, s.backendDone
+	s.backendStopping = true
+	s.mu.Unlock()
+	if backend == nil {
+		return
+	}
+
+	select {
+	case <-done:
+		return
+	default:
+	}
+
+	if err := backend.Process.Kill(); err != nil {
+		logWarn("backend", "failed to kill python backend", "error", err)
+		reportEvent(eventTypeWarning, eventIDShutdownAnomaly,
+			fmt.Sprintf("The WAP launcher could not stop the Python backend (PID %d): %v", backend.Process.Pid, err))
+	}
+	<-done
+}
+
+// restartBackend replaces the backend with a fresh process and waits for
+// it to become healthy. The frontend keeps running meanwhile.
+func (s *Session) restartBackend() error {
+	if s.config.RemoteBackend {
+		return errors.New("the backend is remote and cannot be restarted from here")
+	}
+	s.backendMu.Lock()
+	defer s.backendMu.Unlock()
+	select {
+	case <-s.Stopping():
+		return errors.New("the session is stopping")
+	default:
+	}
+
+	logInfo("backend", "restarting python backend")
+	s.control.SetStage(stageStartingServer, "")
+	s.stopBackendLocked()
+	cmd, err := startBackend(s.config, s.control)
+	if err != nil {
+		return err
+	}
+	s.watchBackend(cmd)
+	if err := waitForBackendHealthy(s, backendStartTimeout); err != nil {
+		return err
+	}
+	s.control.SetStage(stageReady, "")
+	return nil
+}
+
+// restartFrontend closes the Flutter app; startFlutterApplication sees
+// the request and launches it again straight away.
+func (s *Session) restartFrontend() error {
+	s.mu.Lock()
+	frontend := s.frontend
+	if frontend != nil {
+		s.frontendRestart = true
+	}
+	s.mu.Unlock()
+	if frontend == nil {
+		return errors.New("the application is not running")
+	}
+
+	logInfo("frontend", "restarting flutter application")
+	if !closeProcessWindows(frontend.Process.Pid) {
+		frontend.Process.Kill()
+		return nil
+	}
+	time.AfterFunc(5*time.Second, func() { frontend.Process.Kill() })
+	return nil
+}
+
+// takeFrontendRestart reports whether the frontend exited because of
+// restartFrontend
+func (s *Session) takeFrontendRestart() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	restart := s.frontendRestart
+	s.frontendRestart = false
+	return restart
+}
+
+// handleServiceCommand runs restart commands from the control channel.
+// Restarts take a while, so they run in the background and report
+// failures to the log.
+func (s *Session) handleServiceCommand(command serviceCommand) error {
+	var restart func() error
+	switch {
+	case command.Action == "open":
+		return s.open(command)
+	case command.Action == "show":
+		return s.show()
+	case command.Action != "restart":
+		return fmt.Errorf("unknown action %q", command.Action)
+	case command.Service == serviceBackend:
+		if s.config.RemoteBackend {
+			return errors.New("the backend is remote and cannot be restarted from here")
+		}
+		restart = s.restartBackend
+	case command.Service == serviceFrontend:
+		restart = s.restartFrontend
+	case s.workers.find(command.Service) != nil:
+		restart = func() error { return s.workers.restart(command.Service) }
+	default:
+		return fmt.Errorf("unknown service %q", command.Service)
+	}
+	go func() {
+		if err := restart(); err != nil {
+			logError("session", "service restart failed", "service", command.Service, "error", err)
+		}
+	}()
+	return nil
+}
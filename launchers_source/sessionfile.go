@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The launcher keeps session.json in the per-user state folder
+// (%LOCALAPPDATA%\WAP, or the profile's folder) up to date while it runs,
+// so the app and other local tools find the backend without a hard-coded
+// port. The app also gets its path as WAP_SESSION_FILE. A client should
+// wait until ready is true; the file disappears when the launcher exits,
+// and launcher_pid tells a stale file left by a crash apart.
+//
+//	{"version": 1, "launcher_pid": 4120, "status": "ready", "ready": true,
+//	 "backend_url": "https://127.0.0.1:8443", "port": 8443, "token": "...",
+//	 "health_url": "https://127.0.0.1:8443/health", "pids": {"backend": 4388, "frontend": 5012}}
+//
+// token and cert are set when the proxy fronts the backend; requests then
+// carry the token as a bearer token.
+const (
+	sessionFileName    = "session.json"
+	sessionFileVersion = 1
+)
+
+// sessionFile is the content of session.json
+type sessionFile struct {
+	Version     int            `json:"version"`
+	LauncherPID int            `json:"launcher_pid"`
+	Profile     string         `json:"profile,omitempty"`
+	Status      string         `json:"status"` // the boot stage
+	Ready       bool           `json:"ready"`
+	BackendURL  string         `json:"backend_url,omitempty"`
+	Port        int            `json:"port,omitempty"`
+	Token       string         `json:"token,omitempty"`
+	Cert        string         `json:"cert,omitempty"`
+	HealthURL   string         `json:"health_url,omitempty"`
+	Remote      bool           `json:"remote,omitempty"`
+	PIDs        map[string]int `json:"pids"` // running services
+	Updated     time.Time      `json:"updated"`
+}
+
+// sessionPublisher rewrites session.json when what it says changes
+type sessionPublisher struct {
+	path   string
+	config *AppConfig
+
+	mu   sync.Mutex
+	last []byte
+}
+
+func sessionFilePath(config *AppConfig) string {
+	return filepath.Join(config.StateDir, sessionFileName)
+}
+
+// publishSession starts keeping session.json up to date
+func (c *controlServer) publishSession(config *AppConfig) {
+	c.session = &sessionPublisher{path: sessionFilePath(config), config: config}
+	c.writeSessionFile()
+}
+
+func (c *controlServer) writeSessionFile() {
+	p := c.session
+	if p == nil {
+		return
+	}
+	status := c.status()
+	config := p.config
+	file := sessionFile{
+		Version:     sessionFileVersion,
+		LauncherPID: status.PID,
+		Profile:     config.Profile,
+		Status:      status.Stage.Name,
+		Ready:       status.Stage.Name == stageReady,
+		BackendURL:  config.BackendURL,
+		Remote:      config.RemoteBackend,
+		PIDs:        map[string]int{},
+	}
+	if config.Proxy != nil {
+		file.BackendURL = config.Proxy.url
+		file.Token = config.Proxy.token
+		file.Cert = config.Proxy.certPath
+	}
+	if file.BackendURL != "" {
+		file.Port = urlPort(file.BackendURL)
+		file.HealthURL = healthURL(config, file.BackendURL)
+	}
+	for _, service := range status.Services {
+		if service.State == "running" {
+			file.PIDs[service.Name] = service.PID
+		}
+	}
+
+	// Progress updates arrive often; only a change is written
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data, err := json.Marshal(file)
+	if err != nil || bytes.Equal(data, p.last) {
+		return
+	}
+	p.last = data
+	file.Updated = time.Now()
+	if data, err = json.MarshalIndent(file, "", "  "); err != nil {
+		return
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err == nil {
+		os.Rename(tmp, p.path)
+	} else {
+		logDebug("control", "failed to write the session file", "error", err)
+	}
+}
+
+func (p *sessionPublisher) remove() {
+	if p != nil {
+		os.Remove(p.path)
+	}
+}
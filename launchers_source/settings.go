@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Settings is the optional launcher.json placed next to the launcher,
+// overlaid by site.yaml (see settingsLayers). Every section is optional; no
+// files at all means defaults everywhere.
+// String values may use machine facts as templates, see machineFacts.
+type Settings struct {
+	Demo            DemoSettings               `json:"demo"`
+	OperatingHours  *OperatingHours            `json:"operating_hours"`
+	Agent           AgentSettings              `json:"agent"`
+	Payload         PayloadSettings            `json:"payload"`
+	Repair          RepairSettings             `json:"repair"`
+	Update          UpdateSettings             `json:"update"`
+	Store           StoreSettings              `json:"store"`
+	GC              GCSettings                 `json:"gc"`
+	Scrub           ScrubSettings              `json:"scrub"`
+	Kiosk           KioskSettings              `json:"kiosk"`
+	Proxy           ProxySettings              `json:"proxy"`
+	LAN             LANSettings                `json:"lan"`
+	Python          PythonSettings             `json:"python"`
+	Alerts          AlertSettings              `json:"alerts"`
+	Secrets         SecretsSettings            `json:"secrets"`
+	Backup          BackupSettings             `json:"backup"`
+	SafeMode        SafeModeSettings           `json:"safe_mode"`
+	WarmBackend     WarmBackendSettings        `json:"warm_backend"`
+	Startup         StartupSettings            `json:"startup"`
+	WindowWatchdog  WindowWatchdogSettings     `json:"window_watchdog"`
+	FrontendRestart FrontendRestartSettings    `json:"frontend_restart"`
+	Resources       ResourceSettings           `json:"resources"`
+	Processes       ProcessesSettings          `json:"processes"`
+	BackendJob      JobLimitSettings           `json:"backend_job"`
+	CrashReports    CrashReportSettings        `json:"crash_reports"`
+	Telemetry       TelemetrySettings          `json:"telemetry"`
+	Metrics         MetricsSettings            `json:"metrics"`
+	Webhook         WebhookSettings            `json:"webhook"`
+	Signatures      SignatureSettings          `json:"signatures"`
+	Tamper          TamperSettings             `json:"tamper"`
+	BackendSandbox  SandboxSettings            `json:"backend_sandbox"`
+	Prerequisites   PrerequisiteSettings       `json:"prerequisites"`
+	Antivirus       AntivirusSettings          `json:"antivirus"`
+	Profiles        map[string]ProfileSettings `json:"profiles"`
+	Sessions        SessionSettings            `json:"sessions"`
+	Workers         WorkerSettings             `json:"workers"`
+	Sidecars        []SidecarSettings          `json:"sidecars"`
+	Protocol        ProtocolSettings           `json:"protocol"`
+	FileTypes       []FileTypeSettings         `json:"file_types"`
+	Shortcuts       ShortcutSettings           `json:"shortcuts"`
+	Notifications   NotificationSettings       `json:"notifications"`
+	Frontend        FrontendSettings           `json:"frontend"`
+	Backend         BackendSettings            `json:"backend"`
+	Hooks           HookSettings               `json:"hooks"`
+
+	// Keep logs and data in bin/ instead of %LOCALAPPDATA%\WAP (userdirs.go)
+	Portable bool `json:"portable"`
+
+	// Start as administrator without the warning (elevation.go)
+	AllowElevated bool `json:"allow_elevated"`
+
+	// Keep data on a network or synced folder without the warning (location.go)
+	AllowRemoteData bool `json:"allow_remote_data"`
+
+	// Language of dialogs and the tray, e.g. "id" or "en-US"; default:
+	// Windows' display language (i18n.go)
+	Language string `json:"language"`
+
+	// Extra environment variables for the backend and the Flutter app,
+	// then for one of them only (see childenv.go)
+	Env         map[string]string `json:"env"`
+	BackendEnv  map[string]string `json:"backend_env"`
+	FrontendEnv map[string]string `json:"frontend_env"`
+}
+
+// Configuration layers, lowest precedence first. Each layer overrides the
+// ones before it key by key; lists and plain values are replaced whole.
+//
+//  1. built-in defaults
+//  2. launcher.json next to the launcher, shipped with the application
+//  3. site.yaml next to the launcher, deployed by IT per location
+//  4. %ProgramData%\WAP\site.yaml, deployed by policy and kept across
+//     reinstalls
+type settingsLayer struct {
+	path string
+	yaml bool
+}
+
+const (
+	settingsFileName = "launcher.json"
+	siteFileName     = "site.yaml"
+	originDefault    = "default"
+)
+
+func settingsLayers(rootDir string) []settingsLayer {
+	layers := []settingsLayer{
+		{path: filepath.Join(rootDir, settingsFileName)},
+		{path: filepath.Join(rootDir, siteFileName), yaml: true},
+	}
+	if programData := os.Getenv("ProgramData"); programData != "" {
+		layers = append(layers, settingsLayer{path: filepath.Join(programData, "WAP", siteFileName), yaml: true})
+	}
+	return layers
+}
+
+// loadSettingsLayers merges all existing layers and records which file set
+// each value, keyed by dotted path ("update.channel").
+func loadSettingsLayers(rootDir string) (map[string]interface{}, map[string]string, error) {
+	merged := map[string]interface{}{}
+	origins := map[string]string{}
+
+	for _, layer := range settingsLayers(rootDir) {
+		data, err := os.ReadFile(layer.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", layer.path, err)
+		}
+
+		var raw interface{}
+		if layer.yaml {
+			raw, err = parseYAML(data)
+		} else {
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			err = decoder.Decode(&raw)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", layer.path, err)
+		}
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid %s: expected a mapping at the top level", layer.path)
+		}
+		mergeSettings(merged, values, "", layer.path, origins)
+	}
+	return merged, origins, nil
+}
+
+func mergeSettings(dst, src map[string]interface{}, prefix, origin string, origins map[string]string) {
+	for key, value := range src {
+		path := prefix + key
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeSettings(dstMap, srcMap, path+".", origin, origins)
+			continue
+		}
+
+		for existing := range origins {
+			if existing == path || strings.HasPrefix(existing, path+".") {
+				delete(origins, existing)
+			}
+		}
+		dst[key] = value
+		if srcIsMap {
+			mergeSettings(map[string]interface{}{}, srcMap, path+".", origin, origins)
+		} else {
+			origins[path] = origin
+		}
+	}
+}
+
+func loadSettings(rootDir string) (Settings, error) {
+	var settings Settings
+
+	merged, _, err := loadSettingsLayers(rootDir)
+	if err != nil {
+		return settings, err
+	}
+	// Templates are expanded after merging so site values can use them too
+	raw, err := expandTemplates(merged, "settings")
+	if err != nil {
+		return settings, err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("invalid settings: %w", err)
+	}
+	if err := validateChildEnv(settings); err != nil {
+		return settings, err
+	}
+	if settings.OperatingHours != nil {
+		if err := settings.OperatingHours.parse(); err != nil {
+			return settings, fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+	return settings, nil
+}
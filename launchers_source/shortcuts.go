@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// ShortcutSettings shape the shortcuts "launcher install-shortcuts"
+// creates, for zip distributions that have no installer:
+//
+//	"shortcuts": {"name": "WAP", "app_user_model_id": "Example.WAP", "icon": "bin\\data\\app.ico"}
+//
+// name defaults to the application's name and icon to the launcher's.
+// The shortcuts carry app_user_model_id (default WAP.Application, with
+// the profile appended), which the launcher also takes for itself and
+// passes to the app as WAP_APP_USER_MODEL_ID, so Windows groups their
+// taskbar buttons and shows toasts under the shortcut's name and icon.
+type ShortcutSettings struct {
+	Name           string `json:"name"`
+	AppUserModelID string `json:"app_user_model_id"`
+	Icon           string `json:"icon"` // relative to the install folder
+}
+
+const defaultAppUserModelID = "WAP.Application"
+
+var (
+	ole32 = syscall.NewLazyDLL("ole32.dll")
+
+	procCoInitializeEx   = ole32.NewProc("CoInitializeEx")
+	procCoUninitialize   = ole32.NewProc("CoUninitialize")
+	procCoCreateInstance = ole32.NewProc("CoCreateInstance")
+	procCoTaskMemFree    = ole32.NewProc("CoTaskMemFree")
+
+	procSHGetKnownFolderPath                    = shell32.NewProc("SHGetKnownFolderPath")
+	procSetCurrentProcessExplicitAppUserModelID = shell32.NewProc("SetCurrentProcessExplicitAppUserModelID")
+)
+
+var (
+	clsidShellLink     = guid{0x00021401, 0x0000, 0x0000, [8]byte{0xc0, 0, 0, 0, 0, 0, 0, 0x46}}
+	iidShellLinkW      = guid{0x000214f9, 0x0000, 0x0000, [8]byte{0xc0, 0, 0, 0, 0, 0, 0, 0x46}}
+	iidPersistFile     = guid{0x0000010b, 0x0000, 0x0000, [8]byte{0xc0, 0, 0, 0, 0, 0, 0, 0x46}}
+	iidPropertyStore   = guid{0x886d8eeb, 0x8cf2, 0x4446, [8]byte{0x8d, 0x02, 0xcd, 0xba, 0x1d, 0xbd, 0xcf, 0x99}}
+	folderIDDesktop    = guid{0xb4bfcc3a, 0xdb2c, 0x424c, [8]byte{0xb0, 0x29, 0x7f, 0xe9, 0x9a, 0x87, 0xc6, 0x41}}
+	folderIDPrograms   = guid{0xa77f5d77, 0x2e2b, 0x44c3, [8]byte{0xa6, 0xa2, 0xab, 0xa6, 0x01, 0x05, 0x4a, 0x51}}
+	pkeyAppUserModelID = propertyKey{guid{0x9f4c2855, 0x9f79, 0x4b39, [8]byte{0xa8, 0xd0, 0xe1, 0xd4, 0x2d, 0xe1, 0xd5, 0xf3}}, 5}
+)
+
+// propertyKey is PROPERTYKEY
+type propertyKey struct {
+	format guid
+	id     uint32
+}
+
+// propVariant is a PROPVARIANT holding a string (VT_LPWSTR)
+type propVariant struct {
+	vt       uint16
+	reserved [3]uint16
+	value    [2]uintptr
+}
+
+const (
+	coinitApartmentThreaded = 0x2
+	clsctxInprocServer      = 0x1
+	vtLPWStr                = 31
+	rpcEChangedMode         = 0x80010106
+)
+
+// Method indexes in the COM interfaces' vtables
+const (
+	methodRelease            = 2
+	methodQueryInterface     = 0
+	shellLinkSetDescription  = 7
+	shellLinkSetWorkingDir   = 9
+	shellLinkSetArguments    = 11
+	shellLinkSetIconLocation = 17
+	shellLinkSetPath         = 20
+	persistFileSave          = 6
+	propertyStoreSetValue    = 6
+	propertyStoreCommit      = 7
+)
+
+// comObject is an interface pointer; its first word is the vtable
+type comObject struct {
+	vtbl *[32]uintptr
+}
+
+func (o *comObject) call(method int, args ...uintptr) error {
+	ret, _, _ := syscall.SyscallN(o.vtbl[method], append([]uintptr{uintptr(unsafe.Pointer(o))}, args...)...)
+	if int32(ret) < 0 {
+		return fmt.Errorf("COM error 0x%08x", uint32(ret))
+	}
+	return nil
+}
+
+func (o *comObject) queryInterface(iid *guid) (*comObject, error) {
+	var out *comObject
+	if err := o.call(methodQueryInterface, uintptr(unsafe.Pointer(iid)), uintptr(unsafe.Pointer(&out))); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (o *comObject) release() {
+	o.call(methodRelease)
+}
+
+func utf16Arg(s string) uintptr {
+	return uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(s)))
+}
+
+// appUserModelID identifies the application to the taskbar and toasts
+func appUserModelID(config *AppConfig) string {
+	id := config.Settings.Shortcuts.AppUserModelID
+	if id == "" {
+		id = defaultAppUserModelID
+	}
+	if config.Profile != "" {
+		id += "." + config.Profile
+	}
+	return id
+}
+
+// setAppUserModelID makes the launcher's own windows, such as the splash
+// and the tray, belong with the shortcuts
+func setAppUserModelID(config *AppConfig) {
+	if ret, _, _ := procSetCurrentProcessExplicitAppUserModelID.Call(utf16Arg(appUserModelID(config))); int32(ret) < 0 {
+		logDebug("launcher", "failed to set the AppUserModelID", "error", fmt.Sprintf("0x%08x", uint32(ret)))
+	}
+}
+
+// knownFolder returns a shell folder, e.g. the desktop, wherever the user
+// or a policy moved it
+func knownFolder(id *guid) (string, error) {
+	var path *uint16
+	ret, _, _ := procSHGetKnownFolderPath.Call(uintptr(unsafe.Pointer(id)), 0, 0, uintptr(unsafe.Pointer(&path)))
+	if path != nil {
+		defer procCoTaskMemFree.Call(uintptr(unsafe.Pointer(path)))
+	}
+	if int32(ret) < 0 {
+		return "", fmt.Errorf("shell folder not found (0x%08x)", uint32(ret))
+	}
+	return syscall.UTF16ToString(unsafe.Slice(path, 32768)), nil
+}
+
+// shortcut is one .lnk file the launcher manages
+type shortcut struct {
+	path        string
+	target      string
+	args        string
+	dir         string
+	icon        string
+	description string
+	appID       string
+}
+
+// shortcutPaths are the desktop and Start Menu shortcuts' files
+func shortcutPaths(config *AppConfig, desktop, startMenu bool) ([]string, error) {
+	name := config.Settings.Shortcuts.Name
+	if name == "" {
+		name = config.AppName
+	}
+	if config.Profile != "" {
+		name += " (" + config.Profile + ")"
+	}
+	var paths []string
+	for _, folder := range []struct {
+		wanted bool
+		id     *guid
+	}{{desktop, &folderIDDesktop}, {startMenu, &folderIDPrograms}} {
+		if !folder.wanted {
+			continue
+		}
+		dir, err := knownFolder(folder.id)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, filepath.Join(dir, name+".lnk"))
+	}
+	return paths, nil
+}
+
+// save writes the shortcut with IShellLink, IPropertyStore and
+// IPersistFile
+func (s shortcut) save() error {
+	// COM objects belong to the thread that created them
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	ret, _, _ := procCoInitializeEx.Call(0, coinitApartmentThreaded)
+	if int32(ret) < 0 && uint32(ret) != rpcEChangedMode {
+		return fmt.Errorf("COM unavailable (0x%08x)", uint32(ret))
+	}
+	if int32(ret) >= 0 {
+		defer procCoUninitialize.Call()
+	}
+
+	var link *comObject
+	ret, _, _ = procCoCreateInstance.Call(uintptr(unsafe.Pointer(&clsidShellLink)), 0, clsctxInprocServer,
+		uintptr(unsafe.Pointer(&iidShellLinkW)), uintptr(unsafe.Pointer(&link)))
+	if int32(ret) < 0 {
+		return fmt.Errorf("failed to create a shell link (0x%08x)", uint32(ret))
+	}
+	defer link.release()
+
+	for _, step := range []struct {
+		method int
+		arg    uintptr
+	}{
+		{shellLinkSetPath, utf16Arg(s.target)},
+		{shellLinkSetArguments, utf16Arg(s.args)},
+		{shellLinkSetWorkingDir, utf16Arg(s.dir)},
+		{shellLinkSetDescription, utf16Arg(s.description)},
+	} {
+		if err := link.call(step.method, step.arg); err != nil {
+			return err
+		}
+	}
+	if err := link.call(shellLinkSetIconLocation, utf16Arg(s.icon), 0); err != nil {
+		return err
+	}
+
+	store, err := link.queryInterface(&iidPropertyStore)
+	if err != nil {
+		return err
+	}
+	defer store.release()
+	id, _ := syscall.UTF16PtrFromString(s.appID)
+	value := propVariant{vt: vtLPWStr}
+	value.value[0] = uintptr(unsafe.Pointer(id))
+	if err := store.call(propertyStoreSetValue, uintptr(unsafe.Pointer(&pkeyAppUserModelID)), uintptr(unsafe.Pointer(&value))); err != nil {
+		return fmt.Errorf("failed to set the AppUserModelID: %w", err)
+	}
+	if err := store.call(propertyStoreCommit); err != nil {
+		return err
+	}
+	runtime.KeepAlive(id)
+
+	file, err := link.queryInterface(&iidPersistFile)
+	if err != nil {
+		return err
+	}
+	defer file.release()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return file.call(persistFileSave, utf16Arg(s.path), 1)
+}
+
+type shortcutOptions struct {
+	desktop   bool
+	startMenu bool
+}
+
+func (o *shortcutOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.desktop, "desktop", true, "the desktop shortcut")
+	fs.BoolVar(&o.startMenu, "start-menu", true, "the Start Menu entry")
+}
+
+// runInstallShortcutsCommand implements "launcher install-shortcuts"
+func runInstallShortcutsCommand(config *AppConfig, args []string) int {
+	var opts shortcutOptions
+	fs := newCommandFlagSet("install-shortcuts")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	paths, err := shortcutPaths(config, opts.desktop, opts.startMenu)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+	var launchArgs []string
+	if config.Profile != "" {
+		launchArgs = append(launchArgs, fmt.Sprintf(`--%s "%s"`, profileFlagName, config.Profile))
+	}
+	icon := exe
+	if config.Settings.Shortcuts.Icon != "" {
+		icon = installPath(config, config.Settings.Shortcuts.Icon)
+	}
+	for _, path := range paths {
+		link := shortcut{
+			path:        path,
+			target:      exe,
+			args:        strings.Join(launchArgs, " "),
+			dir:         config.RootDir,
+			icon:        icon,
+			description: config.AppName,
+			appID:       appUserModelID(config),
+		}
+		if err := link.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", path, err)
+			return exitLauncherError
+		}
+		logInfo("launcher", "created a shortcut", "path", path, "app_user_model_id", link.appID)
+		fmt.Printf("✓ Created %s\n", path)
+	}
+	return exitOK
+}
+
+// runRemoveShortcutsCommand implements "launcher remove-shortcuts"
+func runRemoveShortcutsCommand(config *AppConfig, args []string) int {
+	var opts shortcutOptions
+	fs := newCommandFlagSet("remove-shortcuts")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	paths, err := shortcutPaths(config, opts.desktop, opts.startMenu)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+	for _, path := range paths {
+		err := os.Remove(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			fmt.Printf("%s does not exist\n", path)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Failed to remove %s: %v\n", path, err)
+			return exitLauncherError
+		default:
+			logInfo("launcher", "removed a shortcut", "path", path)
+			fmt.Printf("✓ Removed %s\n", path)
+		}
+	}
+	return exitOK
+}
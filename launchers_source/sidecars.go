@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
+)
+
+// SidecarSettings declare daemons the backend needs, such as a bundled
+// Postgres or Redis. They start in the order given, each once the one
+// before is healthy, before the backend; they stop in reverse order after
+// it. A sidecar that crashes is started again.
+//
+//	"sidecars": [
+//	  {"name": "postgres", "command": "bin\\pgsql\\bin\\postgres.exe", "args": ["-D", "{{data_dir}}\\pgdata", "-p", "{{port}}"],
+//	   "port": 5433, "stop_command": ["bin\\pgsql\\bin\\pg_ctl.exe", "stop", "-D", "{{data_dir}}\\pgdata", "-m", "fast"]},
+//	  {"name": "redis", "command": "bin\\redis\\redis-server.exe", "args": ["--port", "{{port}}"], "port": 6380}
+//	]
+//
+// args, env, health_url and stop_command may use {{port}}, {{data_dir}}
+// and {{log_dir}}. A sidecar is ready when health_url answers 200 or,
+// without one, when port accepts connections. Without stop_command it is
+// terminated on exit.
+type SidecarSettings struct {
+	Name                string            `json:"name"`
+	Command             string            `json:"command"` // relative to the install folder
+	Args                []string          `json:"args"`
+	Env                 map[string]string `json:"env"`
+	Port                int               `json:"port"`
+	HealthURL           string            `json:"health_url"`
+	StartTimeoutSeconds int               `json:"start_timeout_seconds"` // default 60
+	StopCommand         []string          `json:"stop_command"`
+	StopTimeoutSeconds  int               `json:"stop_timeout_seconds"` // default 10
+	Optional            bool              `json:"optional"`             // failing to start only warns
+}
+
+const (
+	defaultSidecarStartTimeout = time.Minute
+	defaultSidecarStopTimeout  = 10 * time.Second
+	sidecarLogPrefix           = "sidecar_"
+)
+
+// sidecars are the running sidecars of this launch
+type sidecars struct {
+	config  *AppConfig
+	control *controlServer
+	running []*sidecar
+}
+
+// sidecar is one supervised daemon
+type sidecar struct {
+	settings SidecarSettings
+	values   childEnvValues
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	exited   chan struct{}
+	stopping bool
+	done     chan struct{} // closed when supervise returns
+}
+
+// startSidecars starts the declared sidecars in order and returns them
+// to be closed on exit. A sidecar that is not optional and does not become
+// healthy fails the launch.
+func startSidecars(config *AppConfig, control *controlServer) (*sidecars, error) {
+	s := &sidecars{config: config, control: control}
+	if len(config.Settings.Sidecars) == 0 {
+		return s, nil
+	}
+	if config.RemoteBackend {
+		logInfo("sidecars", "remote backend, sidecars are not started")
+		return s, nil
+	}
+	seen := map[string]bool{serviceBackend: true, serviceFrontend: true}
+	for _, settings := range config.Settings.Sidecars {
+		if settings.Name == "" || settings.Command == "" || seen[settings.Name] {
+			s.Close()
+			return nil, fmt.Errorf("every sidecar needs a command and a unique name other than backend or frontend (%q)", settings.Name)
+		}
+		seen[settings.Name] = true
+
+		sc := &sidecar{
+			settings: settings,
+			values: childEnvValues{
+				Port:       strconv.Itoa(settings.Port),
+				BackendURL: config.BackendURL,
+				DataDir:    config.DataDir,
+				LogDir:     config.LogDir,
+			},
+			done: make(chan struct{}),
+		}
+		consolePrintf("Starting %s...\n", settings.Name)
+		if err := sc.start(config, control); err != nil {
+			if settings.Optional {
+				logWarn("sidecars", "optional sidecar failed to start", "sidecar", settings.Name, "error", err)
+				consoleWarnf("%s did not start: %v", settings.Name, err)
+				continue
+			}
+			s.Close()
+			return nil, fmt.Errorf("%s: %w", settings.Name, err)
+		}
+		if err := sc.waitHealthy(); err != nil {
+			sc.stop(config)
+			if settings.Optional {
+				logWarn("sidecars", "optional sidecar did not become ready", "sidecar", settings.Name, "error", err)
+				consoleWarnf("%s is not ready: %v", settings.Name, err)
+				continue
+			}
+			s.Close()
+			return nil, fmt.Errorf("%s: %w", settings.Name, err)
+		}
+		consoleSuccessf("%s is ready", settings.Name)
+		logInfo("sidecars", "sidecar ready", "sidecar", settings.Name, "child_pid", sc.pid())
+		s.running = append(s.running, sc)
+		go sc.supervise(config, control)
+	}
+	return s, nil
+}
+
+// Close stops the sidecars in reverse order
+func (s *sidecars) Close() {
+	for i := len(s.running) - 1; i >= 0; i-- {
+		sc := s.running[i]
+		consoleDetailf("Stopping %s", sc.settings.Name)
+		sc.stop(s.config)
+		<-sc.done
+	}
+	s.running = nil
+}
+
+// render fills in {{port}}, {{data_dir}} and {{log_dir}}
+func (sc *sidecar) render(field, value string) (string, error) {
+	tmpl, err := template.New(field).Funcs(childEnvFuncs(sc.values)).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("template in sidecars.%s.%s: %w", sc.settings.Name, field, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, currentMachineFacts()); err != nil {
+		return "", fmt.Errorf("template in sidecars.%s.%s: %w", sc.settings.Name, field, err)
+	}
+	return out.String(), nil
+}
+
+func (sc *sidecar) renderAll(field string, values []string) ([]string, error) {
+	out := make([]string, len(values))
+	for i, value := range values {
+		var err error
+		if out[i], err = sc.render(field, value); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (sc *sidecar) start(config *AppConfig, control *controlServer) error {
+	name := sc.settings.Name
+	program := installPath(config, sc.settings.Command)
+	args, err := sc.renderAll("args", sc.settings.Args)
+	if err != nil {
+		return err
+	}
+	env, err := serviceEnv("sidecars."+name+".env", sc.settings.Env, sc.values)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(config, program); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(shortPath(program), args...)
+	cmd.Dir = shortPath(filepath.Dir(program))
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env, "WAP_DATA_DIR="+config.DataDir, "WAP_LOG_DIR="+config.LogDir)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	output, err := openChildOutput(config.LogDir, sidecarLogPrefix+name+".log", watchChildLines(config, control, name))
+	if err != nil {
+		return err
+	}
+	output.mirrorToConsole(config, name)
+	cmd.Stdout = output.stdout
+	cmd.Stderr = output.stderr
+	if err := cmd.Start(); err != nil {
+		output.Close()
+		return fmt.Errorf("failed to start %s: %w", program, err)
+	}
+
+	exited := make(chan struct{})
+	sc.mu.Lock()
+	sc.cmd, sc.exited = cmd, exited
+	if sc.stopping {
+		cmd.Process.Kill()
+	}
+	sc.mu.Unlock()
+	go func() {
+		cmd.Wait()
+		output.Close()
+		close(exited)
+	}()
+	control.serviceStarted(name, cmd.Process.Pid)
+	logInfo("sidecars", "sidecar started", "sidecar", name, "child_pid", cmd.Process.Pid, "program", program)
+	return nil
+}
+
+func (sc *sidecar) pid() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.cmd == nil {
+		return 0
+	}
+	return sc.cmd.Process.Pid
+}
+
+// healthy asks health_url, or tries to connect to port
+func (sc *sidecar) healthy() bool {
+	if sc.settings.HealthURL != "" {
+		url, err := sc.render("health_url", sc.settings.HealthURL)
+		return err == nil && backendHealthy(url)
+	}
+	if sc.settings.Port == 0 {
+		return true
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(sc.settings.Port)), 2*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// waitHealthy waits until the sidecar is ready, exits or runs out of time
+func (sc *sidecar) waitHealthy() error {
+	timeout := defaultSidecarStartTimeout
+	if sc.settings.StartTimeoutSeconds > 0 {
+		timeout = time.Duration(sc.settings.StartTimeoutSeconds) * time.Second
+	}
+	sc.mu.Lock()
+	exited := sc.exited
+	sc.mu.Unlock()
+	deadline := time.Now().Add(timeout)
+	for {
+		if sc.healthy() {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not ready within %s", timeout)
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("exited during startup, see %s%s.log", sidecarLogPrefix, sc.settings.Name)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// supervise starts the sidecar again whenever it exits until it is
+// stopped, backing off while it keeps crashing
+func (sc *sidecar) supervise(config *AppConfig, control *controlServer) {
+	defer close(sc.done)
+	name := sc.settings.Name
+	delay := workerRestartMin
+	for {
+		sc.mu.Lock()
+		exited := sc.exited
+		sc.mu.Unlock()
+		started := time.Now()
+		<-exited
+
+		sc.mu.Lock()
+		stopping := sc.stopping
+		sc.mu.Unlock()
+		if stopping {
+			control.serviceStopped(name, "stopped")
+			return
+		}
+		control.serviceStopped(name, "crashed")
+		logError("sidecars", "sidecar exited unexpectedly", "sidecar", name)
+		emitDegraded(name, "crashed", "")
+
+		if time.Since(started) > workerStableRuntime {
+			delay = workerRestartMin
+		} else {
+			delay = min(delay*2, workerRestartMax)
+		}
+		for {
+			time.Sleep(delay)
+			sc.mu.Lock()
+			stopping := sc.stopping
+			sc.mu.Unlock()
+			if stopping {
+				return
+			}
+			err := sc.start(config, control)
+			if err == nil {
+				break
+			}
+			logError("sidecars", "failed to restart sidecar", "sidecar", name, "error", err)
+			delay = min(delay*2, workerRestartMax)
+		}
+	}
+}
+
+// stop runs stop_command, or terminates the sidecar, and waits for it
+func (sc *sidecar) stop(config *AppConfig) {
+	sc.mu.Lock()
+	sc.stopping = true
+	cmd, exited := sc.cmd, sc.exited
+	sc.mu.Unlock()
+	if cmd == nil {
+		return
+	}
+	select {
+	case <-exited:
+		return
+	default:
+	}
+
+	timeout := defaultSidecarStopTimeout
+	if sc.settings.StopTimeoutSeconds > 0 {
+		timeout = time.Duration(sc.settings.StopTimeoutSeconds) * time.Second
+	}
+	if len(sc.settings.StopCommand) > 0 {
+		if err := sc.runStopCommand(config, timeout); err != nil {
+			logWarn("sidecars", "stop command failed", "sidecar", sc.settings.Name, "error", err)
+		}
+		select {
+		case <-exited:
+			return
+		case <-time.After(timeout):
+			logWarn("sidecars", "sidecar did not stop in time, killing it", "sidecar", sc.settings.Name)
+		}
+	}
+	cmd.Process.Kill()
+	<-exited
+}
+
+func (sc *sidecar) runStopCommand(config *AppConfig, timeout time.Duration) error {
+	argv, err := sc.renderAll("stop_command", sc.settings.StopCommand)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	program := installPath(config, argv[0])
+	cmd := exec.CommandContext(ctx, shortPath(program), argv[1:]...)
+	cmd.Dir = shortPath(filepath.Dir(program))
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return errors.New("timed out")
+		}
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
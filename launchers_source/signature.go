@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// SignatureSettings make the launcher check the Authenticode signatures of
+// wap.exe and python.exe with WinVerifyTrust before starting them, and
+// refuse to start a binary that is unsigned, tampered with or signed by a
+// publisher not on the list:
+//
+//	"signatures": {"verify": true, "publishers": ["WAP Software Ltd", "Python Software Foundation"]}
+//
+// An empty list accepts any valid signature. Revocation is only checked
+// against cached data, so an offline machine still starts.
+type SignatureSettings struct {
+	Verify     bool     `json:"verify"`
+	Publishers []string `json:"publishers"`
+}
+
+var (
+	wintrust = syscall.NewLazyDLL("wintrust.dll")
+
+	procWinVerifyTrust                 = wintrust.NewProc("WinVerifyTrust")
+	procWTHelperProvDataFromStateData  = wintrust.NewProc("WTHelperProvDataFromStateData")
+	procWTHelperGetProvSignerFromChain = wintrust.NewProc("WTHelperGetProvSignerFromChain")
+	procCertGetNameStringW             = crypt32.NewProc("CertGetNameStringW")
+)
+
+const (
+	wtdUINone                 = 2
+	wtdRevokeWholeChain       = 1
+	wtdChoiceFile             = 1
+	wtdStateActionVerify      = 1
+	wtdStateActionClose       = 2
+	wtdCacheOnlyURLRetrieval  = 0x00001000
+	certNameSimpleDisplayType = 4
+
+	trustENoSignature = 0x800B0100
+	trustEBadDigest   = 0x80096010
+)
+
+// WINTRUST_ACTION_GENERIC_VERIFY_V2
+var actionGenericVerifyV2 = syscall.GUID{
+	Data1: 0x00aac56b, Data2: 0xcd44, Data3: 0x11d0,
+	Data4: [8]byte{0x8c, 0xc2, 0x00, 0xc0, 0x4f, 0xc2, 0x95, 0xee},
+}
+
+// wintrustFileInfo is WINTRUST_FILE_INFO
+type wintrustFileInfo struct {
+	Size         uint32
+	FilePath     *uint16
+	File         syscall.Handle
+	KnownSubject *syscall.GUID
+}
+
+// wintrustData is WINTRUST_DATA
+type wintrustData struct {
+	Size               uint32
+	PolicyCallbackData uintptr
+	SIPClientData      uintptr
+	UIChoice           uint32
+	RevocationChecks   uint32
+	UnionChoice        uint32
+	File               *wintrustFileInfo
+	StateAction        uint32
+	StateData          syscall.Handle
+	URLReference       *uint16
+	ProvFlags          uint32
+	UIContext          uint32
+	SignatureSettings  uintptr
+}
+
+// cryptProviderSigner is the start of CRYPT_PROVIDER_SGNR
+type cryptProviderSigner struct {
+	Size           uint32
+	VerifyAsOf     syscall.Filetime
+	CertChainCount uint32
+	CertChain      *cryptProviderCert
+}
+
+// cryptProviderCert is the start of CRYPT_PROVIDER_CERT
+type cryptProviderCert struct {
+	Size uint32
+	Cert uintptr // PCCERT_CONTEXT
+}
+
+// Verified binaries are not checked again until they change
+var verifiedSignatures struct {
+	mu    sync.Mutex
+	files map[string]time.Time // path -> modification time
+}
+
+// verifySignature returns an error if path may not be started
+func verifySignature(config *AppConfig, path string) error {
+	settings := config.Settings.Signatures
+	if !settings.Verify {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	verifiedSignatures.mu.Lock()
+	checked, ok := verifiedSignatures.files[path]
+	verifiedSignatures.mu.Unlock()
+	if ok && checked.Equal(info.ModTime()) {
+		return nil
+	}
+
+	publisher, err := authenticodePublisher(path)
+	if err == nil && len(settings.Publishers) > 0 && !slices.ContainsFunc(settings.Publishers, func(p string) bool {
+		return strings.EqualFold(p, publisher)
+	}) {
+		err = fmt.Errorf("signed by %q, which is not an allowed publisher", publisher)
+	}
+	if err != nil {
+		message := fmt.Sprintf("Refusing to start %s: %v", path, err)
+		logError("signature", "signature check failed", "path", path, "error", err)
+		reportEvent(eventTypeError, eventIDIntegrity, message)
+		return fmt.Errorf("refusing to start %s: %w", path, err)
+	}
+
+	logInfo("signature", "signature verified", "path", path, "publisher", publisher)
+	verifiedSignatures.mu.Lock()
+	if verifiedSignatures.files == nil {
+		verifiedSignatures.files = map[string]time.Time{}
+	}
+	verifiedSignatures.files[path] = info.ModTime()
+	verifiedSignatures.mu.Unlock()
+	return nil
+}
+
+// authenticodePublisher verifies path's signature and returns the signer's
+// display name
+func authenticodePublisher(path string) (string, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+	file := wintrustFileInfo{FilePath: pathPtr}
+	file.Size = uint32(unsafe.Sizeof(file))
+	data := wintrustData{
+		UIChoice:         wtdUINone,
+		RevocationChecks: wtdRevokeWholeChain,
+		UnionChoice:      wtdChoiceFile,
+		File:             &file,
+		StateAction:      wtdStateActionVerify,
+		ProvFlags:        wtdCacheOnlyURLRetrieval,
+	}
+	data.Size = uint32(unsafe.Sizeof(data))
+
+	ret, _, _ := procWinVerifyTrust.Call(^uintptr(0), uintptr(unsafe.Pointer(&actionGenericVerifyV2)), uintptr(unsafe.Pointer(&data)))
+	defer func() {
+		data.StateAction = wtdStateActionClose
+		procWinVerifyTrust.Call(^uintptr(0), uintptr(unsafe.Pointer(&actionGenericVerifyV2)), uintptr(unsafe.Pointer(&data)))
+	}()
+	switch uint32(ret) {
+	case 0:
+	case trustENoSignature:
+		return "", fmt.Errorf("the file is not signed")
+	case trustEBadDigest:
+		return "", fmt.Errorf("the file was modified after it was signed")
+	default:
+		return "", fmt.Errorf("the signature is not trusted (0x%08X)", uint32(ret))
+	}
+
+	provData, _, _ := procWTHelperProvDataFromStateData.Call(uintptr(data.StateData))
+	if provData == 0 {
+		return "", fmt.Errorf("no signer information")
+	}
+	signerPtr, _, _ := procWTHelperGetProvSignerFromChain.Call(provData, 0, 0, 0)
+	if signerPtr == 0 {
+		return "", fmt.Errorf("no signer information")
+	}
+	signer := *(**cryptProviderSigner)(unsafe.Pointer(&signerPtr))
+	if signer.CertChainCount == 0 || signer.CertChain == nil || signer.CertChain.Cert == 0 {
+		return "", fmt.Errorf("no signing certificate")
+	}
+	name := make([]uint16, 256)
+	n, _, _ := procCertGetNameStringW.Call(signer.CertChain.Cert, certNameSimpleDisplayType, 0, 0,
+		uintptr(unsafe.Pointer(&name[0])), uintptr(len(name)))
+	if n <= 1 {
+		return "", fmt.Errorf("no publisher name in the signing certificate")
+	}
+	return syscall.UTF16ToString(name[:n]), nil
+}
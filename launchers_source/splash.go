@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// splashScreen shows the current boot stage until the Flutter window is up
+type splashScreen struct {
+	window      *StatusWindow
+	control     *controlServer
+	unsubscribe func()
+	taskbar     func()
+	closeOnce   sync.Once
+}
+
+func showSplash(config *AppConfig, control *controlServer) *splashScreen {
+	window := showStatusWindow(config.AppName, config.AppName+"\n\nStarting...", false, false)
+	splash := &splashScreen{window: window, control: control, taskbar: addTaskbarWindow(window.Handle)}
+	splash.unsubscribe = control.OnStatus(func(summary string) {
+		window.SetText(config.AppName + "\n\n" + summary)
+	})
+	return splash
+}
+
+func (s *splashScreen) Close() {
+	if s == nil {
+		return
+	}
+	s.closeOnce.Do(func() {
+		s.unsubscribe()
+		s.taskbar()
+		s.window.Close()
+	})
+}
+
+// closeWhenWindowShown closes the splash once pid shows a window, or after
+// the timeout so a frontend that never draws one does not keep it open.
+// The application counts as ready, and onShown runs, once the window
+// appeared and ready is closed. The returned channel is closed if the
+// timeout passed without a window.
+func (s *splashScreen) closeWhenWindowShown(pid int, timeout time.Duration, ready <-chan struct{}, onShown func()) <-chan struct{} {
+	noWindow := make(chan struct{})
+	go func() {
+		deadline := time.Now().Add(timeout)
+		shown := false
+		for time.Now().Before(deadline) {
+			if len(processWindows(pid)) > 0 {
+				shown = true
+				break
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+		if s != nil {
+			s.Close()
+		}
+		if !shown {
+			close(noWindow)
+		}
+		<-ready
+		if s != nil {
+			s.control.SetStage(stageReady, "")
+		}
+		if shown && onShown != nil {
+			onShown()
+		}
+	}()
+	return noWindow
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// startup.timeout_seconds bounds a whole launch, from starting the backend
+// until the app's window is up (or, for --minimized, the backend is
+// healthy). When it passes the session is stopped, a support bundle is
+// saved (crashreport.go) and the user is shown the phase that hung with
+// the end of its log, and can try again, try again in Safe Mode or quit.
+
+const (
+	defaultStartupTimeout  = 2 * time.Minute
+	startupTimeoutLogLines = 8
+)
+
+// What the user chose after the deadline passed
+const (
+	startupQuit = iota
+	startupRetry
+	startupRetrySafeMode
+)
+
+func startupTimeout(config *AppConfig) time.Duration {
+	if seconds := config.Settings.Startup.TimeoutSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultStartupTimeout
+}
+
+// startupDeadline records what the session was waiting for when its
+// startup deadline passed
+type startupDeadline struct {
+	mu      sync.Mutex
+	expired bool
+	phase   string // phaseBackendHealth or phaseFrontendStart
+	stage   string // the boot stage shown on the splash
+	log     string
+}
+
+// watchStartupDeadline stops session if it is not ready in time
+func watchStartupDeadline(session *Session) *startupDeadline {
+	d := &startupDeadline{}
+	timeout := startupTimeout(session.config)
+	ready := make(chan struct{})
+	var readyOnce sync.Once
+	// The stage is shared with earlier sessions, so ready only counts once
+	// this session's backend answered
+	unsubscribe := session.control.OnStatus(func(string) {
+		select {
+		case <-session.Healthy():
+		default:
+			return
+		}
+		if session.control.status().Stage.Name == stageReady {
+			readyOnce.Do(func() { close(ready) })
+		}
+	})
+	// A minimized start shows no window until it is asked for
+	var healthy <-chan struct{}
+	if session.background {
+		healthy = session.Healthy()
+	}
+
+	go func() {
+		defer unsubscribe()
+		select {
+		case <-time.After(timeout):
+		case <-ready:
+			return
+		case <-healthy:
+			return
+		case <-session.Stopping():
+			return
+		}
+
+		phase, log := phaseFrontendStart, frontendLogName
+		select {
+		case <-session.Healthy():
+		default:
+			phase, log = phaseBackendHealth, backendLogName
+		}
+		stage := session.control.status().Stage.label()
+		d.mu.Lock()
+		d.expired, d.phase, d.stage, d.log = true, phase, stage, log
+		d.mu.Unlock()
+
+		logError("launcher", "startup did not finish in time", "timeout", timeout.String(), "phase", phase, "stage", stage)
+		reportEvent(eventTypeError, eventIDStartupFailure,
+			fmt.Sprintf("WAP did not finish starting within %s (phase %s, stage %q)", timeout, phase, stage))
+		emitPhaseFailed(fmt.Sprintf("startup did not finish within %s", timeout))
+		session.RequestStop(stopReasonStartupTimeout)
+	}()
+	return d
+}
+
+// Expired reports whether the deadline stopped the session
+func (d *startupDeadline) Expired() bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.expired
+}
+
+// askAfterStartupTimeout saves a support bundle and asks the user how to
+// go on. Unattended launches quit.
+func askAfterStartupTimeout(config *AppConfig, d *startupDeadline) int {
+	d.mu.Lock()
+	phase, stage, log := d.phase, d.stage, d.log
+	d.mu.Unlock()
+
+	waitingFor := tr("the application window to appear")
+	if phase == phaseBackendHealth {
+		waitingFor = tr("the backend to answer its health check")
+	}
+	err := fmt.Errorf("startup did not finish within %s, still waiting for %s (%s)", startupTimeout(config), waitingFor, stage)
+	noteError(err.Error())
+	bundle, bundleErr := writeCrashBundle(config, "startup", err)
+	if bundleErr != nil {
+		logWarn("crash", "failed to write support bundle", "error", bundleErr)
+	} else {
+		pruneCrashBundles(config)
+		logInfo("crash", "support bundle saved", "path", bundle)
+		consolePrintf("Support bundle saved to %s\n", bundle)
+	}
+	if machineOutput() {
+		return startupQuit
+	}
+
+	message := tr("%s did not finish starting within %s.\n\nStill waiting for %s (%s).", config.AppName, startupTimeout(config), waitingFor, stage)
+	logPath := filepath.Join(config.LogDir, log)
+	if file, err := os.Open(logPath); err == nil {
+		var tail strings.Builder
+		printLastLines(file, startupTimeoutLogLines, &tail)
+		file.Close()
+		if lines := strings.TrimSpace(tail.String()); lines != "" {
+			message += "\n\n" + tr("The end of %s:", logPath) + "\n\n" + lines
+		}
+	}
+	if bundleErr == nil {
+		message += "\n\n" + tr("A support bundle for the support team was saved to %s", bundle)
+	}
+	message += "\n\n" + tr("Yes tries again, No tries again in Safe Mode, Cancel quits.")
+
+	switch messageBox(config.AppName, message, mbYesNoCancel|mbIconError|mbTopmost) {
+	case idYes:
+		logInfo("launcher", "retrying startup after the deadline passed")
+		return startupRetry
+	case idNo:
+		logInfo("launcher", "retrying startup in safe mode after the deadline passed")
+		return startupRetrySafeMode
+	default:
+		return startupQuit
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	gdi32 = syscall.NewLazyDLL("gdi32.dll")
+
+	procCreateSolidBrush = gdi32.NewProc("CreateSolidBrush")
+	procCreateFontW      = gdi32.NewProc("CreateFontW")
+	procSelectObject     = gdi32.NewProc("SelectObject")
+	procDeleteObject     = gdi32.NewProc("DeleteObject")
+	procSetTextColor     = gdi32.NewProc("SetTextColor")
+	procSetBkMode        = gdi32.NewProc("SetBkMode")
+
+	procBeginPaint       = user32.NewProc("BeginPaint")
+	procEndPaint         = user32.NewProc("EndPaint")
+	procFillRect         = user32.NewProc("FillRect")
+	procDrawTextW        = user32.NewProc("DrawTextW")
+	procGetClientRect    = user32.NewProc("GetClientRect")
+	procInvalidateRect   = user32.NewProc("InvalidateRect")
+	procGetSystemMetrics = user32.NewProc("GetSystemMetrics")
+)
+
+const (
+	wmPaint = 0x000F
+
+	wsPopup   = 0x80000000
+	wsVisible = 0x10000000
+	wsBorder  = 0x00800000
+
+	wsExTopmost    = 0x00000008
+	wsExToolWindow = 0x00000080
+	wsExAppWindow  = 0x00040000
+
+	smCxScreen = 0
+	smCyScreen = 1
+
+	dtCenter    = 0x1
+	dtWordBreak = 0x10
+	dtCalcRect  = 0x400
+
+	transparent = 1
+)
+
+type rect struct {
+	Left, Top, Right, Bottom int32
+}
+
+type paintStruct struct {
+	Hdc         uintptr
+	Erase       int32
+	RcPaint     rect
+	Restore     int32
+	IncUpdate   int32
+	RgbReserved [32]byte
+}
+
+// StatusWindow is a plain native window showing a block of centered text.
+// It is used for screens shown while the Flutter app is not running.
+type StatusWindow struct {
+	mu         sync.Mutex
+	hwnd       uintptr
+	text       string
+	closable   bool
+	fullscreen bool
+	done       chan struct{}
+}
+
+// showStatusWindow opens the window on its own OS thread. Fullscreen windows
+// are topmost and ignore Alt+F4 unless closable is set.
+func showStatusWindow(title, text string, fullscreen, closable bool) *StatusWindow {
+	w := &StatusWindow{text: text, closable: closable, fullscreen: fullscreen, done: make(chan struct{})}
+	ready := make(chan struct{})
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(w.done)
+
+		screenW, _, _ := procGetSystemMetrics.Call(smCxScreen)
+		screenH, _, _ := procGetSystemMetrics.Call(smCyScreen)
+
+		var x, y, width, height int32
+		var exStyle uint32
+		style := uint32(wsPopup | wsVisible)
+		if fullscreen {
+			width, height = int32(screenW), int32(screenH)
+			exStyle = wsExToolWindow | wsExTopmost
+		} else {
+			// The splash gets a taskbar button, which shows the progress
+			// of long operations (taskbar.go)
+			width, height = 520, 180
+			exStyle = wsExAppWindow
+			x, y = (int32(screenW)-width)/2, (int32(screenH)-height)/2
+			style |= wsBorder
+		}
+
+		hwnd, err := createWindow("WAPLauncherStatus", title, exStyle, style, x, y, width, height, w.handleMessage)
+		if err != nil {
+			logWarn("ui", "failed to create status window", "error", err)
+			close(ready)
+			return
+		}
+		w.mu.Lock()
+		w.hwnd = hwnd
+		w.mu.Unlock()
+		close(ready)
+
+		runMessageLoop()
+	}()
+
+	<-ready
+	return w
+}
+
+func (w *StatusWindow) SetText(text string) {
+	w.mu.Lock()
+	w.text = text
+	hwnd := w.hwnd
+	w.mu.Unlock()
+	if hwnd != 0 {
+		procInvalidateRect.Call(hwnd, 0, 1)
+	}
+}
+
+// Handle is the window's HWND, 0 once it is closed
+func (w *StatusWindow) Handle() uintptr {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.hwnd
+}
+
+func (w *StatusWindow) Close() {
+	w.mu.Lock()
+	hwnd := w.hwnd
+	w.closable = true
+	w.mu.Unlock()
+	if hwnd == 0 {
+		return
+	}
+	postMessage(hwnd, wmClose, 0, 0)
+	<-w.done
+}
+
+func (w *StatusWindow) handleMessage(hwnd uintptr, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	switch msg {
+	case wmPaint:
+		w.paint(hwnd)
+		return 0, true
+	case wmClose:
+		w.mu.Lock()
+		closable := w.closable
+		if closable {
+			w.hwnd = 0
+		}
+		w.mu.Unlock()
+		if closable {
+			procDestroyWindow.Call(hwnd)
+		}
+		return 0, true
+	case wmDestroy:
+		procPostQuitMessage.Call(0)
+		return 0, true
+	}
+	return 0, false
+}
+
+func (w *StatusWindow) paint(hwnd uintptr) {
+	w.mu.Lock()
+	text := w.text
+	fullscreen := w.fullscreen
+	w.mu.Unlock()
+
+	var ps paintStruct
+	hdc, _, _ := procBeginPaint.Call(hwnd, uintptr(unsafe.Pointer(&ps)))
+	defer procEndPaint.Call(hwnd, uintptr(unsafe.Pointer(&ps)))
+
+	var client rect
+	procGetClientRect.Call(hwnd, uintptr(unsafe.Pointer(&client)))
+
+	// Dark background with light text for fullscreen screens, the reverse
+	// for the small window
+	background, foreground, fontHeight := uintptr(0x00FFFFFF), uintptr(0x00202020), 22
+	if fullscreen {
+		background, foreground, fontHeight = 0x00202020, 0x00F0F0F0, 40
+	}
+
+	brush, _, _ := procCreateSolidBrush.Call(background)
+	procFillRect.Call(hdc, uintptr(unsafe.Pointer(&client)), brush)
+	procDeleteObject.Call(brush)
+
+	font, _, _ := procCreateFontW.Call(uintptr(fontHeight), 0, 0, 0, 400, 0, 0, 0, 1, 0, 0, 5, 0,
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr("Segoe UI"))))
+	oldFont, _, _ := procSelectObject.Call(hdc, font)
+	defer func() {
+		procSelectObject.Call(hdc, oldFont)
+		procDeleteObject.Call(font)
+	}()
+
+	procSetTextColor.Call(hdc, foreground)
+	procSetBkMode.Call(hdc, transparent)
+
+	// Vertically center by measuring the wrapped text first
+	textPtr := syscall.StringToUTF16Ptr(text)
+	measure := rect{Left: client.Left + 24, Right: client.Right - 24}
+	procDrawTextW.Call(hdc, uintptr(unsafe.Pointer(textPtr)), ^uintptr(0), uintptr(unsafe.Pointer(&measure)), dtCenter|dtWordBreak|dtCalcRect)
+	textHeight := measure.Bottom - measure.Top
+	target := rect{
+		Left:   client.Left + 24,
+		Right:  client.Right - 24,
+		Top:    (client.Bottom - textHeight) / 2,
+		Bottom: (client.Bottom + textHeight) / 2,
+	}
+	procDrawTextW.Call(hdc, uintptr(unsafe.Pointer(textPtr)), ^uintptr(0), uintptr(unsafe.Pointer(&target)), dtCenter|dtWordBreak)
+}
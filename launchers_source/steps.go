@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Step is a startup step compiled into a downstream build, e.g. a license
+// check or an asset sync. A build adds its steps in a file of its own,
+// usually behind a build tag, and registers them from init:
+//
+//	//go:build acme
+//
+//	package main
+//
+//	func init() { registerStep(licenseStep{}) }
+//
+// Steps run in registration order once the data is migrated and before
+// the backend starts. A step reports progress with reportStepProgress and
+// fails the launch by returning an error, unless it is optionalStep.
+type Step interface {
+	Name() string
+	Run(ctx context.Context, config *AppConfig) error
+}
+
+// optionalStep is a step whose failure is only logged
+type optionalStep interface {
+	Optional() bool
+}
+
+// timedStep is a step that needs longer (or less) than defaultStepTimeout
+type timedStep interface {
+	Timeout() time.Duration
+}
+
+const (
+	defaultStepTimeout = 5 * time.Minute
+	stepTaskPrefix     = "step:"
+)
+
+var registeredSteps []Step
+
+// registerStep adds a step to every launch. It is meant to be called from
+// init, before the launcher starts.
+func registerStep(step Step) {
+	for _, s := range registeredSteps {
+		if s.Name() == step.Name() {
+			panic(fmt.Sprintf("startup step %q registered twice", step.Name()))
+		}
+	}
+	registeredSteps = append(registeredSteps, step)
+}
+
+type stepProgressKey struct{}
+
+// reportStepProgress shows a running step's progress on the splash and in
+// the status; ctx is the one the step's Run was given
+func reportStepProgress(ctx context.Context, percent float64, message string) {
+	if report, ok := ctx.Value(stepProgressKey{}).(func(float64, string)); ok {
+		report(min(max(percent, 0), 100), message)
+	}
+}
+
+// runSteps runs the registered steps in order and returns the first
+// failure of a step that is not optional
+func runSteps(config *AppConfig, control *controlServer) error {
+	if len(registeredSteps) == 0 {
+		return nil
+	}
+	control.SetStage(stagePreparing, "")
+	emitPhase(phaseSteps, phaseStarted, 0, "")
+	for i, step := range registeredSteps {
+		name := step.Name()
+		task := stepTaskPrefix + name
+		ctx := context.WithValue(context.Background(), stepProgressKey{}, func(percent float64, message string) {
+			control.SetProgress(progressUpdate{Task: task, Message: message, Percent: percent})
+			emitPhase(phaseSteps, phaseProgress, float64(i*100)/float64(len(registeredSteps))+percent/float64(len(registeredSteps)), message)
+		})
+		timeout := defaultStepTimeout
+		if timed, ok := step.(timedStep); ok && timed.Timeout() > 0 {
+			timeout = timed.Timeout()
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+
+		consoleDetailf("Running startup step %s", name)
+		control.SetProgress(progressUpdate{Task: task})
+		started := time.Now()
+		err := step.Run(ctx, config)
+		if err == nil && ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		cancel()
+		control.SetProgress(progressUpdate{Task: task, Percent: 100, Done: true})
+
+		if err != nil {
+			if optional, ok := step.(optionalStep); ok && optional.Optional() {
+				logWarn("steps", "optional startup step failed", "step", name, "error", err)
+				consoleWarnf("Startup step %s failed: %v", name, err)
+				continue
+			}
+			logError("steps", "startup step failed", "step", name, "error", err)
+			emitPhaseFailed(err.Error())
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		logInfo("steps", "startup step done", "step", name, "duration_ms", time.Since(started).Milliseconds())
+	}
+	emitPhase(phaseSteps, phaseDone, 100, "")
+	return nil
+}
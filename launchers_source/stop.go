@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// "launcher stop" and "launcher restart-backend" manage the running
+// instance over its control channel, for scripts and IT tooling. stop
+// closes the app first and the backend after it, as the tray's Exit does.
+
+type stopOptions struct {
+	timeout time.Duration
+}
+
+func (o *stopOptions) register(fs *flag.FlagSet) {
+	fs.DurationVar(&o.timeout, "timeout", 30*time.Second, "how long to wait for the launcher to exit")
+}
+
+type restartBackendOptions struct {
+	timeout time.Duration
+}
+
+func (o *restartBackendOptions) register(fs *flag.FlagSet) {
+	fs.DurationVar(&o.timeout, "timeout", 2*time.Minute, "how long to wait for the backend to be healthy again")
+}
+
+// runStopCommand implements "launcher stop". Nothing running counts as
+// stopped.
+func runStopCommand(config *AppConfig, args []string) int {
+	var opts stopOptions
+	fs := newCommandFlagSet("stop")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	status, err := fetchLauncherStatus(config)
+	if err != nil {
+		fmt.Println("The launcher is not running")
+		return exitOK
+	}
+	if err := sendServiceCommand(config, serviceCommand{Action: "stop"}); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Stop failed: %v\n", err)
+		return exitLauncherError
+	}
+
+	deadline := time.Now().Add(opts.timeout)
+	for {
+		if stopped, _ := checkWaitCondition(config, waitStopped); stopped {
+			fmt.Printf("✓ Stopped the launcher (PID %d)\n", status.PID)
+			return exitOK
+		}
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "❌ The launcher (PID %d) did not exit within %s\n", status.PID, opts.timeout)
+			return exitWaitTimeout
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// runRestartBackendCommand implements "launcher restart-backend" and waits
+// until the new backend answers /health
+func runRestartBackendCommand(config *AppConfig, args []string) int {
+	var opts restartBackendOptions
+	fs := newCommandFlagSet("restart-backend")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	status, err := fetchLauncherStatus(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitLauncherError
+	}
+	before, ok := findService(status, serviceBackend)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "❌ The running launcher has no local backend")
+		return exitLauncherError
+	}
+	if err := sendServiceCommand(config, serviceCommand{Service: serviceBackend, Action: "restart"}); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Restart failed: %v\n", err)
+		return exitLauncherError
+	}
+
+	start := time.Now()
+	deadline := start.Add(opts.timeout)
+	for {
+		if backendRestarted(config, before) {
+			fmt.Printf("✓ Backend restarted and healthy after %s\n", time.Since(start).Round(100*time.Millisecond))
+			return exitOK
+		}
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "❌ The backend was not healthy again within %s\n", opts.timeout)
+			return exitWaitTimeout
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// backendRestarted reports whether a new backend process replaced before
+// and answers /health
+func backendRestarted(config *AppConfig, before serviceStatus) bool {
+	status, err := fetchLauncherStatus(config)
+	if err != nil {
+		return false
+	}
+	backend, ok := findService(status, serviceBackend)
+	if !ok || backend.State != "running" || (backend.Restarts == before.Restarts && backend.PID == before.PID) {
+		return false
+	}
+	healthy, _ := checkWaitCondition(config, waitHealthy)
+	return healthy
+}
+
+func findService(status *launcherStatus, name string) (serviceStatus, bool) {
+	for _, service := range status.Services {
+		if service.Name == name {
+			return service, true
+		}
+	}
+	return serviceStatus{}, false
+}
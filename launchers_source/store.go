@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StoreSettings configures the content-addressed store that large files
+// (embedded Python, models) are hardlinked from, so two installed versions
+// share one copy on disk. The store must be on the same volume as bin/.
+type StoreSettings struct {
+	Disabled bool   `json:"disabled"`
+	Dir      string `json:"dir"`      // defaults to <install>/store
+	MinSize  int64  `json:"min_size"` // bytes, smaller files are copied
+}
+
+const defaultStoreMinSize = 1 << 20
+
+type contentStore struct {
+	dir     string
+	minSize int64
+}
+
+// openContentStore returns nil when the store is disabled
+func openContentStore(config *AppConfig) *contentStore {
+	settings := config.Settings.Store
+	if settings.Disabled {
+		return nil
+	}
+	store := &contentStore{dir: settings.Dir, minSize: settings.MinSize}
+	if store.dir == "" {
+		store.dir = filepath.Join(config.RootDir, "store")
+	}
+	if store.minSize <= 0 {
+		store.minSize = defaultStoreMinSize
+	}
+	return store
+}
+
+// wants reports whether a file of this size and hash belongs in the store
+func (s *contentStore) wants(size int64, hash string) bool {
+	return s != nil && hash != "" && size >= s.minSize
+}
+
+func (s *contentStore) blobPath(hash string) string {
+	hash = strings.ToLower(hash)
+	return filepath.Join(s.dir, "sha256", hash[:2], hash)
+}
+
+func (s *contentStore) has(hash string) bool {
+	_, err := os.Stat(s.blobPath(hash))
+	return err == nil
+}
+
+// put writes a blob through write and keeps it only if its content
+// matches hash.
+func (s *contentStore) put(hash string, write func(io.Writer) error) error {
+	blob := s.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(blob), "blob-*.partial")
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	err = write(io.MultiWriter(tmp, h))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), hash) {
+		err = fmt.Errorf("checksum mismatch")
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), blob); err != nil {
+		os.Remove(tmp.Name())
+		// Another worker may have stored the same blob meanwhile
+		if s.has(hash) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// adopt hardlinks an existing, already verified file into the store
+func (s *contentStore) adopt(path, hash string) error {
+	if s.has(hash) {
+		return nil
+	}
+	blob := s.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		return err
+	}
+	return os.Link(path, blob)
+}
+
+// link materializes a blob at target, hardlinking when possible and
+// copying when the store is on another volume.
+func (s *contentStore) link(hash, target string) error {
+	blob := s.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	os.Remove(target)
+	if err := os.Link(blob, target); err == nil {
+		return nil
+	}
+	return copyFile(blob, target)
+}
+
+func copyFile(source, target string) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// seedStoreFromInstall hardlinks large files of the current bin/ into the
+// store, so a staged update containing the same files shares them.
+func seedStoreFromInstall(config *AppConfig, store *contentStore) {
+	if store == nil {
+		return
+	}
+	manifest, err := loadManifest(config.BinDir)
+	if err != nil || manifest == nil {
+		return
+	}
+	for rel, entry := range manifest.Files {
+		if !store.wants(entry.Size, entry.SHA256) || store.has(entry.SHA256) {
+			continue
+		}
+		path := filepath.Join(config.BinDir, filepath.FromSlash(rel))
+		if hash, err := fileSHA256(path); err != nil || !strings.EqualFold(hash, entry.SHA256) {
+			continue
+		}
+		if err := store.adopt(path, entry.SHA256); err != nil {
+			logDebug("store", "could not add file to store", "path", rel, "error", err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Child output goes to three files: one per stream, written verbatim, and
+// the combined log (python_server.log, flutter_app.log) where every line is
+// tagged with the stream it came from. With --console or --dev the lines
+// are also mirrored live to the console, prefixed with the child's name.
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+
+	mirrorPython  = "python"
+	mirrorFlutter = "flutter"
+)
+
+// Lines on stderr that count as errors. Python's logging writes INFO to
+// stderr too, so stderr alone says little.
+var stderrErrorLine = regexp.MustCompile(`(?i)\b(error|critical|fatal|exception|traceback)\b`)
+
+// streamLogName is the per-stream file for a combined log name, e.g.
+// python_server.stderr.log
+func streamLogName(logName, stream string) string {
+	return strings.TrimSuffix(logName, ".log") + "." + stream + ".log"
+}
+
+// childOutput owns a child's log files
+type childOutput struct {
+	mu       sync.Mutex
+	combined *os.File
+	stdout   *streamWriter
+	stderr   *streamWriter
+	onLine   func(stream, line string)
+	mirror   string // console prefix, "" when not mirrored
+}
+
+// streamWriter splits what a child writes into lines
+type streamWriter struct {
+	output  *childOutput
+	stream  string
+	file    *os.File
+	pending []byte
+}
+
+// openChildOutput creates the log files for logName in dir. onLine, if
+// set, is called for every complete line.
+func openChildOutput(dir, logName string, onLine func(stream, line string)) (*childOutput, error) {
+	o := &childOutput{onLine: onLine}
+	var err error
+	if o.combined, err = os.Create(filepath.Join(dir, logName)); err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+	for _, stream := range []string{streamStdout, streamStderr} {
+		file, err := os.Create(filepath.Join(dir, streamLogName(logName, stream)))
+		if err != nil {
+			o.Close()
+			return nil, fmt.Errorf("failed to create log file: %w", err)
+		}
+		writer := &streamWriter{output: o, stream: stream, file: file}
+		if stream == streamStdout {
+			o.stdout = writer
+		} else {
+			o.stderr = writer
+		}
+	}
+	return o, nil
+}
+
+// mirrorToConsole shows the child's lines on the console as they arrive
+// when the launcher runs with --console or --dev. A warm backend writes
+// straight to its log file and cannot be mirrored.
+func (o *childOutput) mirrorToConsole(config *AppConfig, name string) {
+	if config.MirrorOutput {
+		o.mirror = name
+	}
+}
+
+// Close flushes unterminated lines and closes the files. It must only be
+// called after the child has exited and cmd.Wait returned.
+func (o *childOutput) Close() {
+	for _, writer := range []*streamWriter{o.stdout, o.stderr} {
+		if writer == nil {
+			continue
+		}
+		if len(writer.pending) > 0 {
+			writer.writeLine(writer.pending)
+		}
+		writer.file.Close()
+	}
+	o.combined.Close()
+}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	chaosSlowWrite()
+	w.file.Write(data)
+	w.pending = append(w.pending, data...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.pending[:i])
+		w.pending = w.pending[i+1:]
+	}
+	return len(data), nil
+}
+
+func (w *streamWriter) writeLine(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	o := w.output
+	now := time.Now()
+	o.mu.Lock()
+	fmt.Fprintf(o.combined, "%s [%s] %s\n", now.Format("15:04:05.000"), w.stream, line)
+	o.mu.Unlock()
+	if o.mirror != "" {
+		consoleChildLine(now, o.mirror, w.stream, string(line))
+	}
+	if o.onLine != nil {
+		o.onLine(w.stream, string(line))
+	}
+}
+
+// watchChildLines counts stderr errors and checks alert rules for the
+// output of service
+func watchChildLines(config *AppConfig, control *controlServer, service string) func(stream, line string) {
+	return func(stream, line string) {
+		if stream == streamStderr && stderrErrorLine.MatchString(line) {
+			control.serviceError(service, line)
+		}
+		checkAlerts(config, control, service, line)
+	}
+}
+
+// shortLine trims a log line for status.json and notifications
+func shortLine(line string) string {
+	return truncateLine(strings.TrimSpace(line), 200)
+}
+
+// closeChildOutput closes the logs of an exited child started with
+// childOutput writers
+func closeChildOutput(stdout interface{}) {
+	if writer, ok := stdout.(*streamWriter); ok {
+		writer.output.Close()
+	}
+}
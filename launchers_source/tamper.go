@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TamperSettings decide what happens to critical files (programs, libraries
+// and Python code) that are present but no longer match manifest.json.
+// Besides a broken update this is what malware leaves behind, so the
+// changed files are moved to StateDir\quarantine for inspection before the
+// originals are restored:
+//
+//   - "ask" (the default) warns the user and offers it
+//   - "auto" does it without asking, for kiosks and unattended machines
+//   - "off" treats them like any other damaged file
+type TamperSettings struct {
+	Policy string `json:"policy"`
+}
+
+const (
+	tamperAsk  = "ask"
+	tamperAuto = "auto"
+	tamperOff  = "off"
+
+	quarantineDirName = "quarantine"
+)
+
+// Changes to these can run code; anything else is just a damaged file
+var criticalExtensions = map[string]bool{
+	".exe": true,
+	".dll": true,
+	".pyd": true,
+	".py":  true,
+	".bat": true,
+	".cmd": true,
+	".ps1": true,
+}
+
+func tamperPolicy(config *AppConfig) string {
+	switch policy := strings.ToLower(config.Settings.Tamper.Policy); policy {
+	case tamperAuto, tamperOff:
+		return policy
+	default:
+		return tamperAsk
+	}
+}
+
+// tamperedFiles returns the critical files among the problems that exist
+// but were changed since installation
+func tamperedFiles(problems []verifyProblem) []verifyProblem {
+	var tampered []verifyProblem
+	for _, p := range problems {
+		if p.Problem == "missing" || !criticalExtensions[strings.ToLower(filepath.Ext(p.Path))] {
+			continue
+		}
+		tampered = append(tampered, p)
+	}
+	return tampered
+}
+
+// quarantineFiles moves the tampered files out of BinDir, keeping their
+// relative paths, and returns the quarantine folder. A file that cannot
+// be moved is left for the repair to overwrite.
+func quarantineFiles(config *AppConfig, tampered []verifyProblem) (string, error) {
+	dir := filepath.Join(config.StateDir, quarantineDirName, time.Now().Format("20060102-150405"))
+	moved := 0
+	for _, p := range tampered {
+		source := filepath.Join(config.BinDir, filepath.FromSlash(p.Path))
+		target := filepath.Join(dir, filepath.FromSlash(p.Path))
+		if err := moveToQuarantine(source, target); err != nil {
+			logWarn("verify", "failed to quarantine file", "path", p.Path, "error", err)
+			continue
+		}
+		moved++
+		logWarn("verify", "quarantined modified file", "path", p.Path, "problem", p.Problem, "quarantine", target)
+	}
+	if moved == 0 {
+		return "", fmt.Errorf("failed to move any of %d modified file(s) to %s", len(tampered), dir)
+	}
+	return dir, nil
+}
+
+// moveToQuarantine renames the file, copying it when the quarantine is on
+// another volume
+func moveToQuarantine(source, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+	if err := copyFile(source, target); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
+
+// handleTampering warns about modified critical files, quarantines them
+// and repairs the installation. It returns false if the launcher should
+// not continue.
+func handleTampering(config *AppConfig, problems, tampered []verifyProblem, interactive bool) bool {
+	var details strings.Builder
+	for i, p := range tampered {
+		if i == 5 {
+			details.WriteString(tr("... and %d more", len(tampered)-i) + "\n")
+			break
+		}
+		fmt.Fprintf(&details, "%s\n", p.Path)
+	}
+	logError("verify", "critical files were modified since installation", "count", len(tampered), "policy", tamperPolicy(config))
+	message := fmt.Sprintf("%d program file(s) of %s were modified since installation:\n%s", len(tampered), config.AppName, details.String())
+	reportEvent(eventTypeError, eventIDIntegrity, message)
+	notifyWebhook(webhookTampered, message, launcherLogName)
+
+	if tamperPolicy(config) == tamperAsk {
+		if !interactive || machineOutput() {
+			logWarn("verify", "starting with modified program files, nobody to ask")
+			return true
+		}
+		answer := messageBox(config.AppName,
+			tr("%d program file(s) were modified since installation:\n\n%s\nThis can be caused by malware or an interrupted update. Move them to quarantine and restore the original files?\n\nYes repairs, No starts anyway, Cancel quits.",
+				len(tampered), details.String()),
+			mbYesNoCancel|mbIconWarning|mbTopmost)
+		switch answer {
+		case idYes:
+		case idNo:
+			logWarn("verify", "starting with modified program files")
+			return true
+		default:
+			return false
+		}
+	}
+
+	consolePrintln(tr("Moving %d modified file(s) to quarantine...", len(tampered)))
+	quarantine, err := quarantineFiles(config, tampered)
+	if err != nil {
+		logWarn("verify", "quarantine failed, repairing in place", "error", err)
+	}
+	consolePrintln(tr("Repairing application files..."))
+	if err := repairOrElevate(config, problems); err != nil {
+		if quarantine != "" {
+			err = fmt.Errorf("%w\n\n%s", err, tr("The modified files were moved to %s. Reinstall the application.", quarantine))
+		}
+		showError("Repair failed", err)
+		return false
+	}
+	if quarantine != "" {
+		consoleSuccessf(tr("Repair complete; the modified files are in %s"), quarantine)
+		logInfo("verify", "repaired tampered installation", "quarantine", quarantine)
+	} else {
+		consoleSuccessf("%s", tr("Repair complete"))
+	}
+	return true
+}
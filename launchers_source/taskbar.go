@@ -0,0 +1,212 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Long operations (first-run extraction, staging an update, a backup or
+// a repair) show their progress on a taskbar button through
+// ITaskbarList3, so they are noticed while the splash is behind other
+// windows. The button is the newest window registered with
+// addTaskbarWindow (the splash, then the app's window), or the console's
+// for commands run from a terminal.
+
+var (
+	clsidTaskbarList = guid{0x56fdf344, 0xfd6d, 0x11d0, [8]byte{0x95, 0x8a, 0x00, 0x60, 0x97, 0xc9, 0xa0, 0x90}}
+	iidTaskbarList3  = guid{0xea1afb91, 0x9e28, 0x4b86, [8]byte{0x90, 0xe9, 0x9e, 0x9f, 0x8a, 0x5e, 0xef, 0xaf}}
+
+	procGetConsoleWindow = kernel32.NewProc("GetConsoleWindow")
+)
+
+// ITaskbarList3 methods and TBPFLAG progress states
+const (
+	taskbarHrInit           = 3
+	taskbarSetProgressValue = 9
+	taskbarSetProgressState = 10
+
+	tbpfNoProgress    = 0x0
+	tbpfIndeterminate = 0x1
+	tbpfNormal        = 0x2
+	tbpfError         = 0x4
+)
+
+// taskbarErrorShown is how long a failed operation leaves its button red
+const taskbarErrorShown = 5 * time.Second
+
+type taskbarState struct {
+	flags       uintptr
+	done, total uint64
+}
+
+// taskbarWindow is a window registered with addTaskbarWindow
+type taskbarWindow struct {
+	handle func() uintptr
+}
+
+var taskbar struct {
+	mu      sync.Mutex
+	windows []*taskbarWindow
+	current *taskbarProgress
+	state   taskbarState
+	once    sync.Once
+}
+
+var taskbarWake = make(chan struct{}, 1)
+
+// taskbarProgress is one long operation; a nil one does nothing
+type taskbarProgress struct {
+	name string
+}
+
+// addTaskbarWindow makes window's button show progress until the returned
+// function is called. window returns 0 while there is no window yet.
+func addTaskbarWindow(window func() uintptr) (remove func()) {
+	entry := &taskbarWindow{handle: window}
+	taskbar.mu.Lock()
+	taskbar.windows = append(taskbar.windows, entry)
+	taskbar.mu.Unlock()
+	wakeTaskbar()
+	return func() {
+		taskbar.mu.Lock()
+		for i, w := range taskbar.windows {
+			if w == entry {
+				taskbar.windows = append(taskbar.windows[:i], taskbar.windows[i+1:]...)
+				break
+			}
+		}
+		taskbar.mu.Unlock()
+		wakeTaskbar()
+	}
+}
+
+// startTaskbarProgress shows an operation of unknown length until Set
+// reports how far it got
+func startTaskbarProgress(name string) *taskbarProgress {
+	p := &taskbarProgress{name: name}
+	taskbar.once.Do(func() { go runTaskbar() })
+	logDebug("taskbar", "showing progress", "operation", name)
+	taskbar.mu.Lock()
+	taskbar.current = p
+	taskbar.state = taskbarState{flags: tbpfIndeterminate}
+	taskbar.mu.Unlock()
+	wakeTaskbar()
+	return p
+}
+
+// Set reports done out of total, in any unit
+func (p *taskbarProgress) Set(done, total int64) {
+	if p == nil || total <= 0 {
+		return
+	}
+	p.update(taskbarState{flags: tbpfNormal, done: uint64(min(max(done, 0), total)), total: uint64(total)})
+}
+
+// End removes the progress, or turns the button red for a moment when the
+// operation failed
+func (p *taskbarProgress) End(err error) {
+	if p == nil {
+		return
+	}
+	if err == nil {
+		p.update(taskbarState{flags: tbpfNoProgress})
+		return
+	}
+	p.update(taskbarState{flags: tbpfError, done: 1, total: 1})
+	time.AfterFunc(taskbarErrorShown, func() { p.update(taskbarState{flags: tbpfNoProgress}) })
+}
+
+// update applies state unless a later operation took the button over
+func (p *taskbarProgress) update(state taskbarState) {
+	taskbar.mu.Lock()
+	if taskbar.current != p {
+		taskbar.mu.Unlock()
+		return
+	}
+	taskbar.state = state
+	taskbar.mu.Unlock()
+	wakeTaskbar()
+}
+
+func wakeTaskbar() {
+	select {
+	case taskbarWake <- struct{}{}:
+	default:
+	}
+}
+
+// taskbarTarget is the window whose button shows progress
+func taskbarTarget() uintptr {
+	taskbar.mu.Lock()
+	windows := append([]*taskbarWindow(nil), taskbar.windows...)
+	taskbar.mu.Unlock()
+	for i := len(windows) - 1; i >= 0; i-- {
+		if hwnd := windows[i].handle(); hwnd != 0 {
+			return hwnd
+		}
+	}
+	hwnd, _, _ := procGetConsoleWindow.Call()
+	return hwnd
+}
+
+// runTaskbar owns the ITaskbarList3 object on a thread of its own. It
+// applies every change, and checks each second for a new window, since
+// buttons appear a moment after their windows and the splash hands over
+// to the app.
+func runTaskbar() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	ret, _, _ := procCoInitializeEx.Call(0, coinitApartmentThreaded)
+	if int32(ret) < 0 && uint32(ret) != rpcEChangedMode {
+		logDebug("taskbar", "COM unavailable, no taskbar progress", "hresult", uint32(ret))
+		return
+	}
+	if int32(ret) >= 0 {
+		defer procCoUninitialize.Call()
+	}
+	var list *comObject
+	ret, _, _ = procCoCreateInstance.Call(uintptr(unsafe.Pointer(&clsidTaskbarList)), 0, clsctxInprocServer,
+		uintptr(unsafe.Pointer(&iidTaskbarList3)), uintptr(unsafe.Pointer(&list)))
+	if int32(ret) < 0 {
+		logDebug("taskbar", "no taskbar list, no taskbar progress", "hresult", uint32(ret))
+		return
+	}
+	defer list.release()
+	if err := list.call(taskbarHrInit); err != nil {
+		logDebug("taskbar", "failed to initialise the taskbar list", "error", err)
+		return
+	}
+
+	var shownOn uintptr
+	var shown taskbarState
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-taskbarWake:
+		case <-ticker.C:
+		}
+		taskbar.mu.Lock()
+		state := taskbar.state
+		taskbar.mu.Unlock()
+		if state.flags == tbpfNoProgress && shown.flags == tbpfNoProgress {
+			continue
+		}
+		hwnd := taskbarTarget()
+		if hwnd == shownOn && state == shown {
+			continue
+		}
+		if shownOn != 0 && hwnd != shownOn {
+			list.call(taskbarSetProgressState, shownOn, tbpfNoProgress)
+		}
+		if hwnd != 0 {
+			list.call(taskbarSetProgressState, hwnd, state.flags)
+			if state.flags == tbpfNormal || state.flags == tbpfError {
+				list.call(taskbarSetProgressValue, hwnd, uintptr(state.done), uintptr(state.total))
+			}
+		}
+		shownOn, shown = hwnd, state
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TelemetrySettings name where startup reports go. Nothing is sent until
+// the user agrees, asked once on the first launch or given with
+// "launcher telemetry on". A report says whether startup succeeded, how
+// long each phase took and the launcher, app and Windows versions, under
+// a random ID that is not derived from the machine or the user.
+type TelemetrySettings struct {
+	Endpoint string `json:"endpoint"` // HTTPS; empty turns telemetry off
+}
+
+const (
+	telemetryStateName = "telemetry.json"
+	telemetryTimeout   = 5 * time.Second
+)
+
+// telemetryConsent is stored per user in StateDir
+type telemetryConsent struct {
+	Enabled   bool      `json:"enabled"`
+	InstallID string    `json:"install_id,omitempty"`
+	Decided   time.Time `json:"decided"`
+}
+
+// telemetryReport is what one launch sends
+type telemetryReport struct {
+	InstallID       string        `json:"install_id"`
+	LauncherVersion string        `json:"launcher_version"`
+	AppVersion      string        `json:"app_version"`
+	OSVersion       string        `json:"os_version"`
+	Ready           bool          `json:"ready"`
+	ExitCode        int           `json:"exit_code"`
+	ExitReason      string        `json:"exit_reason"`
+	SafeMode        bool          `json:"safe_mode"`
+	TotalMS         int64         `json:"total_ms"`
+	Phases          []phaseTiming `json:"phases"`
+}
+
+func telemetryStatePath(config *AppConfig) string {
+	return filepath.Join(config.StateDir, telemetryStateName)
+}
+
+// readTelemetryConsent returns the stored decision; false if none was made
+func readTelemetryConsent(config *AppConfig) (telemetryConsent, bool) {
+	var consent telemetryConsent
+	if err := readJSONFile(telemetryStatePath(config), &consent); err != nil {
+		return telemetryConsent{}, false
+	}
+	return consent, true
+}
+
+func setTelemetryConsent(config *AppConfig, enabled bool) error {
+	consent := telemetryConsent{Enabled: enabled, Decided: time.Now()}
+	if enabled {
+		id := make([]byte, 16)
+		rand.Read(id)
+		consent.InstallID = hex.EncodeToString(id)
+	}
+	return writeJSONFile(telemetryStatePath(config), consent)
+}
+
+// askTelemetryConsent asks once, when an endpoint is configured and the
+// user has not decided yet
+func askTelemetryConsent(config *AppConfig) {
+	if config.Settings.Telemetry.Endpoint == "" || machineOutput() {
+		return
+	}
+	if _, decided := readTelemetryConsent(config); decided {
+		return
+	}
+	answer := messageBox(config.AppName,
+		tr("Help improve %s by sending anonymous startup reports?\n\nThey say whether the application started, how long each step took and which versions of %s and Windows are used. They contain no names, files or data. You can change this later with \"launcher telemetry on\" or \"off\".",
+			config.AppName, config.AppName),
+		mbYesNo|mbIconInformation|mbTopmost)
+	enabled := answer == idYes
+	if err := setTelemetryConsent(config, enabled); err != nil {
+		logWarn("telemetry", "failed to store telemetry consent", "error", err)
+	}
+	logInfo("telemetry", "telemetry consent", "enabled", enabled)
+}
+
+// sendStartupTelemetry reports this launch if the user agreed
+func sendStartupTelemetry(config *AppConfig, code int) {
+	endpoint := config.Settings.Telemetry.Endpoint
+	consent, decided := readTelemetryConsent(config)
+	if endpoint == "" || !decided || !consent.Enabled {
+		return
+	}
+	timing, ok := startupTimingReport()
+	if !ok {
+		return
+	}
+	report := telemetryReport{
+		InstallID:       consent.InstallID,
+		LauncherVersion: launcherVersion,
+		AppVersion:      installedVersion(config),
+		OSVersion:       currentMachineFacts().OSVersion,
+		Ready:           timing.Ready,
+		ExitCode:        code,
+		ExitReason:      exitCodeNames[code],
+		SafeMode:        config.SafeMode,
+		TotalMS:         timing.TotalMS,
+		Phases:          timing.Phases,
+	}
+	if err := postTelemetry(endpoint, report); err != nil {
+		logDebug("telemetry", "failed to send telemetry", "error", err)
+	}
+}
+
+func postTelemetry(endpoint string, report telemetryReport) error {
+	target, err := url.Parse(endpoint)
+	if err != nil || target.Scheme != "https" {
+		return fmt.Errorf("telemetry.endpoint must be an https URL")
+	}
+	body, _ := json.Marshal(report)
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// runTelemetryCommand implements "launcher telemetry [on|off|status]"
+func runTelemetryCommand(config *AppConfig, args []string) int {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "on", "off":
+		if err := setTelemetryConsent(config, action == "on"); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitLauncherError
+		}
+		fmt.Printf("✓ Telemetry turned %s\n", action)
+		if action == "on" && config.Settings.Telemetry.Endpoint == "" {
+			fmt.Println("No telemetry.endpoint is configured, so nothing will be sent")
+		}
+	case "status":
+		consent, decided := readTelemetryConsent(config)
+		switch {
+		case config.Settings.Telemetry.Endpoint == "":
+			fmt.Println("Telemetry is off: no endpoint is configured")
+		case !decided:
+			fmt.Println("Telemetry is off: you will be asked on the next launch")
+		case consent.Enabled:
+			fmt.Printf("Telemetry is on, reports go to %s\n", config.Settings.Telemetry.Endpoint)
+		default:
+			fmt.Println("Telemetry is off")
+		}
+	default:
+		printCommandHelp(os.Stderr, findCommand("telemetry"))
+		return exitUsage
+	}
+	return exitOK
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// On a terminal server several users run the application at once. Data
+// and logs are per user already, but the backend's fixed port, a portable
+// install's state files and a per-session instance lock are not. With
+// isolation the launcher therefore
+//
+//   - gives each launch a free backend port instead of the fixed one
+//   - keeps a portable install's state, logs and data in users\<name>
+//   - allows one launcher per user and profile across all sessions, since
+//     two sessions of one user share the same data
+//   - checks that the port the backend answers on belongs to this session,
+//     so nobody talks to another user's backend by accident
+//
+// "auto" (the default) isolates remote sessions and every session other
+// than the console's:
+//
+//	"sessions": {"isolation": "on"}
+type SessionSettings struct {
+	Isolation string `json:"isolation"` // auto, on or off
+}
+
+var (
+	procProcessIdToSessionId         = kernel32.NewProc("ProcessIdToSessionId")
+	procWTSGetActiveConsoleSessionId = kernel32.NewProc("WTSGetActiveConsoleSessionId")
+)
+
+const (
+	isolationAuto = "auto"
+	isolationOn   = "on"
+	isolationOff  = "off"
+
+	smRemoteSession = 0x1000 // SM_REMOTESESSION
+	usersDirName    = "users"
+)
+
+// processSession returns the Windows session a process runs in
+func processSession(pid int) (uint32, bool) {
+	var session uint32
+	ret, _, _ := procProcessIdToSessionId.Call(uintptr(pid), uintptr(unsafe.Pointer(&session)))
+	return session, ret != 0
+}
+
+// sharedMachine reports whether this launcher runs in a session other
+// users' sessions may run next to
+func sharedMachine() bool {
+	if remote, _, _ := procGetSystemMetrics.Call(smRemoteSession); remote != 0 {
+		return true
+	}
+	session, ok := processSession(os.Getpid())
+	console, _, _ := procWTSGetActiveConsoleSessionId.Call()
+	return ok && uintptr(session) != console
+}
+
+func sessionIsolation(config *AppConfig) bool {
+	switch strings.ToLower(config.Settings.Sessions.Isolation) {
+	case isolationOn:
+		return true
+	case isolationOff:
+		return false
+	default:
+		return sharedMachine()
+	}
+}
+
+// currentUserSID names the user in the instance lock
+func currentUserSID() string {
+	token, err := syscall.OpenCurrentProcessToken()
+	if err != nil {
+		return ""
+	}
+	defer token.Close()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return ""
+	}
+	sid, err := user.User.Sid.String()
+	if err != nil {
+		return ""
+	}
+	return sid
+}
+
+// applySessionIsolation scopes ports and state to this user when other
+// sessions may run the application too. It runs after applyProfile.
+func applySessionIsolation(config *AppConfig) error {
+	if !sessionIsolation(config) {
+		return nil
+	}
+	config.SessionIsolated = true
+	if config.Portable {
+		setPortableDirs(config)
+	}
+	if config.Settings.Profiles[config.Profile].PortBase == 0 {
+		if err := setBackendPort(config, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userDir is where a portable install keeps one user's files on a shared
+// machine
+func userDir(config *AppConfig, base string) string {
+	if !config.SessionIsolated {
+		return base
+	}
+	name := os.Getenv("USERNAME")
+	if name == "" {
+		name = currentUserSID()
+	}
+	return filepath.Join(base, usersDirName, name)
+}
+
+// foreignListener returns a description of a socket on port owned by a
+// process of another session, "" if there is none
+func foreignListener(port int) (string, error) {
+	own, ok := processSession(os.Getpid())
+	if !ok {
+		return "", nil
+	}
+	sockets, err := listeningSockets(port)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range sockets {
+		if session, ok := processSession(s.pid); ok && session != own {
+			return fmt.Sprintf("PID %d in session %d", s.pid, session), nil
+		}
+	}
+	return "", nil
+}
+
+// checkBackendSession makes sure the backend port is this session's. A
+// port taken by another session's process is given up for a free one.
+func checkBackendSession(session *Session) error {
+	config := session.config
+	if !config.SessionIsolated || config.RemoteBackend {
+		return nil
+	}
+	port := backendPort(config)
+	foreign, err := foreignListener(port)
+	if err != nil {
+		logWarn("launcher", "failed to check the backend port's owner", "error", err)
+		return nil
+	}
+	if foreign == "" {
+		logDebug("launcher", "backend port belongs to this session", "port", port)
+		return nil
+	}
+	// Behind the proxy the backend's port was picked free already
+	if config.Proxy != nil {
+		return fmt.Errorf("port %d is used by %s", port, foreign)
+	}
+
+	logWarn("launcher", "backend port is used by another session, moving to a free port", "port", port, "owner", foreign)
+	if err := setBackendPort(config, 0); err != nil {
+		return err
+	}
+	if err := session.restartBackend(); err != nil {
+		return err
+	}
+	port = backendPort(config)
+	if foreign, err := foreignListener(port); err == nil && foreign != "" {
+		return fmt.Errorf("port %d is used by %s", port, foreign)
+	}
+	logInfo("launcher", "backend moved to a port of this session", "port", port)
+	return nil
+}
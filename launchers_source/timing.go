@@ -0,0 +1,163 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Startup is measured phase by phase from the emitPhase calls, from the
+// launcher starting until the app window appears (or the headless backend
+// is ready). Each run's report is written to startup_timing.json in the
+// log directory and summarized in launcher.log; --profile-startup also
+// prints it.
+const startupTimingName = "startup_timing.json"
+
+var launcherStarted = time.Now()
+
+type phaseTiming struct {
+	Phase      string `json:"phase"`
+	StartMS    int64  `json:"start_ms"` // since the launcher started
+	DurationMS int64  `json:"duration_ms"`
+	Failed     bool   `json:"failed,omitempty"`
+}
+
+type startupReport struct {
+	Started time.Time        `json:"started"`
+	TotalMS int64            `json:"total_ms"`
+	Ready   bool             `json:"ready"` // false if startup failed or was cancelled
+	Phases  []phaseTiming    `json:"phases"`
+	Marks   map[string]int64 `json:"marks"` // ms since the launcher started
+}
+
+var startupTiming struct {
+	mu      sync.Mutex
+	path    string // "" until enabled for a launch
+	print   bool
+	started map[string]time.Time
+	report  startupReport
+	done    bool
+}
+
+// enableStartupTiming starts collecting for this launch
+func enableStartupTiming(path string, print bool) {
+	startupTiming.mu.Lock()
+	defer startupTiming.mu.Unlock()
+	startupTiming.path = path
+	startupTiming.print = print
+	if startupTiming.started == nil {
+		startupTiming.started = map[string]time.Time{}
+	}
+	startupTiming.report = startupReport{Started: launcherStarted, Marks: map[string]int64{}}
+}
+
+func sinceLaunch(t time.Time) int64 {
+	return t.Sub(launcherStarted).Milliseconds()
+}
+
+// recordPhase is called for every emitPhase
+func recordPhase(phase, status string) {
+	startupTiming.mu.Lock()
+	if startupTiming.path == "" || startupTiming.done {
+		startupTiming.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	switch status {
+	case phaseStarted:
+		startupTiming.started[phase] = now
+	case phaseDone, phaseFailed:
+		if began, ok := startupTiming.started[phase]; ok {
+			delete(startupTiming.started, phase)
+			startupTiming.report.Phases = append(startupTiming.report.Phases, phaseTiming{
+				Phase:      phase,
+				StartMS:    sinceLaunch(began),
+				DurationMS: now.Sub(began).Milliseconds(),
+				Failed:     status == phaseFailed,
+			})
+		}
+	}
+	startupTiming.mu.Unlock()
+
+	if phase == phaseRunning && status == phaseStarted {
+		finishStartupTiming(true)
+	}
+}
+
+// markStartup records a point in time inside a phase, such as the moment a
+// child process was spawned
+func markStartup(name string) {
+	startupTiming.mu.Lock()
+	defer startupTiming.mu.Unlock()
+	if startupTiming.path != "" && !startupTiming.done {
+		startupTiming.report.Marks[name] = sinceLaunch(time.Now())
+	}
+}
+
+// finishStartupTiming writes the report once, when the app is up or the
+// launcher exits without getting there
+func finishStartupTiming(ready bool) {
+	startupTiming.mu.Lock()
+	if startupTiming.path == "" || startupTiming.done {
+		startupTiming.mu.Unlock()
+		return
+	}
+	startupTiming.done = true
+	startupTiming.report.Ready = ready
+	startupTiming.report.TotalMS = sinceLaunch(time.Now())
+	report := startupTiming.report
+	sort.SliceStable(report.Phases, func(i, j int) bool { return report.Phases[i].StartMS < report.Phases[j].StartMS })
+	path, print := startupTiming.path, startupTiming.print
+	startupTiming.mu.Unlock()
+
+	if err := writeJSONFile(path, report); err != nil {
+		logWarn("timing", "failed to write startup timing", "error", err)
+	}
+	fields := []interface{}{"total_ms", report.TotalMS, "ready", ready}
+	for _, p := range report.Phases {
+		fields = append(fields, p.Phase+"_ms", p.DurationMS)
+	}
+	for name, ms := range report.Marks {
+		fields = append(fields, name+"_at_ms", ms)
+	}
+	logInfo("timing", "startup timing", fields...)
+	if print {
+		printStartupReport(report)
+	}
+}
+
+// startupTimingReport returns the finished report of this launch
+func startupTimingReport() (startupReport, bool) {
+	startupTiming.mu.Lock()
+	defer startupTiming.mu.Unlock()
+	if !startupTiming.done {
+		return startupReport{}, false
+	}
+	report := startupTiming.report
+	report.Phases = append([]phaseTiming(nil), report.Phases...)
+	return report, true
+}
+
+func printStartupReport(report startupReport) {
+	consolePrintln("\nStartup timing")
+	for _, p := range report.Phases {
+		status := ""
+		if p.Failed {
+			status = "  failed"
+		}
+		consolePrintf("  %-16s %7d ms  (at %d ms)%s\n", p.Phase, p.DurationMS, p.StartMS, status)
+	}
+	names := make([]string, 0, len(report.Marks))
+	for name := range report.Marks {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return report.Marks[names[i]] < report.Marks[names[j]] })
+	for _, name := range names {
+		consolePrintf("  %-16s at %d ms\n", name, report.Marks[name])
+	}
+	outcome := "ready"
+	if !report.Ready {
+		outcome = "not ready"
+	}
+	consolePrintf("  %-16s %7d ms  (%s)\n\n", "total", report.TotalMS, outcome)
+}
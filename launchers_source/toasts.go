@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// NotificationSettings control the toast notifications the launcher shows
+// for things that happen in the background, such as a backend restarted
+// after a crash, a downloaded update or a scheduled backup:
+//
+//	"notifications": {"disabled": true}
+//
+// Toasts carry the application's AppUserModelID (shortcuts.go), so
+// Windows shows them under the application's name and icon. Where toasts
+// are unavailable the tray balloon is used instead.
+type NotificationSettings struct {
+	Disabled bool `json:"disabled"`
+}
+
+// Kinds of toast, each shown at most once per toastCooldown so a crash
+// loop does not flood the action center
+const (
+	toastBackendRestarted = "backend-restarted"
+	toastWorkerRestarted  = "worker-restarted"
+	toastUpdateStaged     = "update-staged"
+	toastBackupCompleted  = "backup-completed"
+	toastBackupFailed     = "backup-failed"
+)
+
+const (
+	toastCooldown = 10 * time.Minute
+	toastTimeout  = 30 * time.Second
+)
+
+var (
+	toastMu       sync.Mutex
+	toastLastShow = map[string]time.Time{}
+	toastAppOnce  sync.Once
+)
+
+// toastScript shows a toast through the WinRT notification API, which
+// PowerShell reaches without a packaged app
+const toastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml('%XML%')
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('%APPID%').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+
+// notifyToast tells the user about a background event without waiting
+// for the toast to appear
+func notifyToast(config *AppConfig, kind, title, text string) {
+	if config.Settings.Notifications.Disabled {
+		return
+	}
+	toastMu.Lock()
+	if last, ok := toastLastShow[kind]; ok && time.Since(last) < toastCooldown {
+		toastMu.Unlock()
+		logDebug("toasts", "toast skipped, shown recently", "kind", kind)
+		return
+	}
+	toastLastShow[kind] = time.Now()
+	toastMu.Unlock()
+
+	go func() {
+		toastAppOnce.Do(func() { registerToastAppID(config) })
+		if err := showToast(config, title, text); err != nil {
+			logWarn("toasts", "failed to show a toast, using the tray instead", "kind", kind, "error", err)
+			if notify := alertNotifier(); notify != nil {
+				notify(title, text)
+			}
+			return
+		}
+		logInfo("toasts", "toast shown", "kind", kind)
+	}()
+}
+
+func showToast(config *AppConfig, title, text string) error {
+	var body strings.Builder
+	body.WriteString(`<toast><visual><binding template="ToastGeneric"><text>`)
+	xml.EscapeText(&body, []byte(title))
+	body.WriteString(`</text><text>`)
+	xml.EscapeText(&body, []byte(text))
+	body.WriteString(`</text></binding></visual></toast>`)
+
+	// Both values end up in single-quoted PowerShell strings
+	quote := strings.NewReplacer("'", "''")
+	script := strings.NewReplacer(
+		"%XML%", quote.Replace(body.String()),
+		"%APPID%", quote.Replace(appUserModelID(config)),
+	).Replace(toastScript)
+
+	ctx, cancel := context.WithTimeout(context.Background(), toastTimeout)
+	defer cancel()
+	return powershell(ctx, script).Run()
+}
+
+// registerToastAppID gives the AppUserModelID a display name and icon, so
+// toasts are labelled properly even before "install-shortcuts" ran
+func registerToastAppID(config *AppConfig) {
+	key := registryClassesKey + `AppUserModelId\` + appUserModelID(config)
+	if err := setRegistryString(syscall.HKEY_CURRENT_USER, key, "DisplayName", config.AppName); err != nil {
+		logWarn("toasts", "failed to register the application for toasts", "error", err)
+		return
+	}
+	if icon := config.Settings.Shortcuts.Icon; icon != "" {
+		if err := setRegistryString(syscall.HKEY_CURRENT_USER, key, "IconUri", installPath(config, icon)); err != nil {
+			logWarn("toasts", "failed to register the toast icon", "error", err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	procGetConsoleMode = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+)
+
+const (
+	enableProcessedInput            = 0x1
+	enableLineInput                 = 0x2
+	enableEchoInput                 = 0x4
+	enableVirtualTerminalProcessing = 0x4
+
+	topLogLines = 6
+	topMaxWidth = 160
+)
+
+// topState is what the dashboard remembers between refreshes
+type topState struct {
+	samples map[int]processSample // by PID
+	message string
+	confirm bool // "s" was pressed, waiting for "y"
+}
+
+type topOptions struct {
+	interval time.Duration
+}
+
+func (o *topOptions) register(fs *flag.FlagSet) {
+	fs.DurationVar(&o.interval, "interval", time.Second, "refresh interval")
+}
+
+// runTopCommand implements "launcher top": a live view of the running
+// launcher's services, their CPU and memory use and the latest log lines,
+// with keys to restart or stop them.
+func runTopCommand(config *AppConfig, args []string) int {
+	var opts topOptions
+	fs := newCommandFlagSet("top")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	restoreOutput, err := setConsoleMode(syscall.STD_OUTPUT_HANDLE, enableVirtualTerminalProcessing, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "This console does not support the dashboard: %v\n", err)
+		return exitLauncherError
+	}
+	defer restoreOutput()
+	// Keys arrive one at a time, Ctrl+C included
+	restoreInput, err := setConsoleMode(syscall.STD_INPUT_HANDLE, 0, enableProcessedInput|enableLineInput|enableEchoInput)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read keys from this console: %v\n", err)
+		return exitLauncherError
+	}
+	defer restoreInput()
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			for _, key := range buf[:n] {
+				keys <- key
+			}
+		}
+	}()
+
+	// Alternate screen buffer, hidden cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	state := &topState{samples: map[int]processSample{}}
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		renderTop(config, state)
+		select {
+		case <-ticker.C:
+		case key, ok := <-keys:
+			if !ok || !handleTopKey(config, state, key) {
+				return exitOK
+			}
+		}
+	}
+}
+
+// handleTopKey runs the action bound to key. It returns false to quit.
+func handleTopKey(config *AppConfig, state *topState, key byte) bool {
+	confirm := state.confirm
+	state.confirm = false
+
+	var command serviceCommand
+	switch key {
+	case 'q', 'Q', 0x1b, 0x03: // Esc, Ctrl+C
+		return false
+	case 'b', 'B':
+		command = serviceCommand{Service: serviceBackend, Action: "restart"}
+	case 'f', 'F':
+		command = serviceCommand{Service: serviceFrontend, Action: "restart"}
+	case 's', 'S':
+		state.confirm = true
+		state.message = "Stop the application? Press y to confirm"
+		return true
+	case 'y', 'Y':
+		if !confirm {
+			return true
+		}
+		command = serviceCommand{Action: "stop"}
+	default:
+		state.message = ""
+		return true
+	}
+
+	if err := sendServiceCommand(config, command); err != nil {
+		state.message = "❌ " + err.Error()
+	} else if command.Action == "stop" {
+		state.message = "✓ Stopping the application"
+	} else {
+		state.message = fmt.Sprintf("✓ Restarting %s", command.Service)
+	}
+	return true
+}
+
+func renderTop(config *AppConfig, state *topState) {
+	var out bytes.Buffer
+	out.WriteString("\x1b[H\x1b[2J")
+
+	status, err := fetchLauncherStatus(config)
+	if err != nil {
+		fmt.Fprintf(&out, "%s  %s\n\n❌ %v\n", config.AppName, time.Now().Format("15:04:05"), err)
+	} else {
+		stage := status.Stage.Name
+		if status.Stage.Message != "" {
+			stage += " - " + status.Stage.Message
+		}
+		fmt.Fprintf(&out, "%s  launcher PID %d  %s\n", config.AppName, status.PID, time.Now().Format("15:04:05"))
+		fmt.Fprintf(&out, "Stage: %s (%.0f%%)\n\n", stage, status.Percent)
+		writeServiceTable(&out, state, status.Services)
+	}
+
+	for _, target := range []string{"backend", "flutter"} {
+		name := logTargets[target]
+		fmt.Fprintf(&out, "\n── %s (%s) ──\n", target, name)
+		var lines bytes.Buffer
+		if file, err := os.Open(filepath.Join(config.LogDir, name)); err == nil {
+			printLastLines(file, topLogLines, &lines)
+			file.Close()
+		}
+		for _, line := range strings.Split(strings.TrimRight(lines.String(), "\r\n"), "\n") {
+			out.WriteString(truncateLine(strings.TrimRight(line, "\r"), topMaxWidth) + "\n")
+		}
+	}
+
+	out.WriteString("\n[b] restart backend  [f] restart frontend  [s] stop  [q] quit\n")
+	if state.message != "" {
+		out.WriteString(state.message + "\n")
+	}
+	os.Stdout.Write(out.Bytes())
+}
+
+func writeServiceTable(out *bytes.Buffer, state *topState, services []serviceStatus) {
+	fmt.Fprintf(out, "%-10s %-8s %-9s %7s %10s %9s %7s %10s\n", "SERVICE", "PID", "STATE", "CPU", "MEMORY", "RESTARTS", "ERRORS", "UPTIME")
+	if len(services) == 0 {
+		out.WriteString("(no services started yet)\n")
+	}
+
+	samples := map[int]processSample{}
+	for _, service := range services {
+		cpu, memory, uptime := "-", "-", "-"
+		if service.State == "running" {
+			uptime = time.Since(service.Started).Round(time.Second).String()
+			if sample, err := sampleProcess(service.PID); err == nil {
+				samples[service.PID] = sample
+				memory = formatBytes(int64(sample.WorkingSet))
+				if prev, ok := state.samples[service.PID]; ok {
+					cpu = fmt.Sprintf("%.1f%%", cpuPercent(prev, sample))
+				}
+			}
+		}
+		fmt.Fprintf(out, "%-10s %-8d %-9s %7s %10s %9d %7d %10s\n",
+			service.Name, service.PID, service.State, cpu, memory, service.Restarts, service.Errors, uptime)
+	}
+	state.samples = samples
+}
+
+func truncateLine(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// setConsoleMode sets and clears bits on a standard handle's console mode
+// and returns a function restoring the previous mode
+func setConsoleMode(which int, set, clear uint32) (func(), error) {
+	handle, err := syscall.GetStdHandle(which)
+	if err != nil {
+		return nil, err
+	}
+	var mode uint32
+	if ret, _, err := procGetConsoleMode.Call(uintptr(handle), uintptr(unsafe.Pointer(&mode))); ret == 0 {
+		return nil, err
+	}
+	if ret, _, err := procSetConsoleMode.Call(uintptr(handle), uintptr((mode|set)&^clear)); ret == 0 {
+		return nil, err
+	}
+	return func() { procSetConsoleMode.Call(uintptr(handle), uintptr(mode)) }, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A trace captures an intermittent problem on a customer machine: for a
+// bounded time the launcher logs at debug level and the backend logs every
+// request, both into traces/<trace id>/ next to the other logs.
+const (
+	tracesDirName        = "traces"
+	traceInfoName        = "trace.json"
+	traceLauncherLogName = "launcher.log"
+	defaultTraceDuration = 15 * time.Minute
+	maxTraceDuration     = 2 * time.Hour
+)
+
+// traceInfo is GET /trace and traces/<id>/trace.json. The backend polls
+// GET /trace and writes its own log into Dir while Active is set.
+type traceInfo struct {
+	Active  bool      `json:"active"`
+	ID      string    `json:"trace_id,omitempty"`
+	Dir     string    `json:"dir,omitempty"`
+	Started time.Time `json:"started,omitempty"`
+	Until   time.Time `json:"until,omitempty"`
+}
+
+// traceRequest is POSTed to /trace:
+//
+//	{"action": "start", "duration": "30m"}
+//	{"action": "stop"}
+type traceRequest struct {
+	Action   string `json:"action"`
+	Duration string `json:"duration,omitempty"` // default 15m, at most 2h
+}
+
+// SetTraceRoot sets the directory traces are written to
+func (c *controlServer) SetTraceRoot(dir string) {
+	c.mu.Lock()
+	c.traceRoot = dir
+	c.mu.Unlock()
+}
+
+// currentTrace must be called with c.mu held
+func (c *controlServer) currentTrace() traceInfo {
+	if c.trace == nil {
+		return traceInfo{}
+	}
+	return *c.trace
+}
+
+// StartTrace starts a trace, or extends the running one, for d
+func (c *controlServer) StartTrace(d time.Duration) (traceInfo, error) {
+	d = min(d, maxTraceDuration)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.traceRoot == "" {
+		return traceInfo{}, errors.New("tracing is not available")
+	}
+	if c.trace != nil {
+		c.trace.Until = time.Now().Add(d)
+		c.traceTimer.Reset(d)
+		writeJSONFile(filepath.Join(c.trace.Dir, traceInfoName), c.trace)
+		logInfo("trace", "trace extended", "trace_id", c.trace.ID, "until", c.trace.Until)
+		return *c.trace, nil
+	}
+
+	suffix, err := randomToken()
+	if err != nil {
+		return traceInfo{}, err
+	}
+	now := time.Now()
+	trace := &traceInfo{Active: true, ID: now.Format("20060102-150405") + "-" + suffix[:6], Started: now, Until: now.Add(d)}
+	trace.Dir = filepath.Join(c.traceRoot, trace.ID)
+	if err := os.MkdirAll(trace.Dir, 0755); err != nil {
+		return traceInfo{}, fmt.Errorf("failed to create trace directory: %w", err)
+	}
+	file, err := os.Create(filepath.Join(trace.Dir, traceLauncherLogName))
+	if err != nil {
+		return traceInfo{}, fmt.Errorf("failed to create trace log: %w", err)
+	}
+	writeJSONFile(filepath.Join(trace.Dir, traceInfoName), trace)
+
+	c.trace = trace
+	c.traceFile = file
+	c.traceTimer = time.AfterFunc(d, c.StopTrace)
+	setLogTrace(file)
+	logInfo("trace", "trace started", "trace_id", trace.ID, "dir", trace.Dir, "until", trace.Until)
+	return *trace, nil
+}
+
+// StopTrace ends the running trace, if any
+func (c *controlServer) StopTrace() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.trace == nil {
+		return
+	}
+	logInfo("trace", "trace stopped", "trace_id", c.trace.ID)
+	c.traceTimer.Stop()
+	setLogTrace(nil)
+	c.traceFile.Close()
+	c.trace.Active = false
+	c.trace.Until = time.Now()
+	writeJSONFile(filepath.Join(c.trace.Dir, traceInfoName), c.trace)
+	c.trace = nil
+}
+
+func (c *controlServer) handleTrace(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var request traceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "expected {\"action\": \"start\" or \"stop\"}", http.StatusBadRequest)
+			return
+		}
+		switch request.Action {
+		case "start":
+			d := defaultTraceDuration
+			if request.Duration != "" {
+				parsed, err := time.ParseDuration(request.Duration)
+				if err != nil || parsed <= 0 {
+					http.Error(w, "invalid duration", http.StatusBadRequest)
+					return
+				}
+				d = parsed
+			}
+			if _, err := c.StartTrace(d); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case "stop":
+			c.StopTrace()
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.mu.Lock()
+	trace := c.currentTrace()
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trace)
+}
+
+// toggleTrace is the tray action: start a trace or stop the running one
+func toggleTrace(config *AppConfig, control *controlServer, tray *Tray) {
+	control.mu.Lock()
+	active := control.trace != nil
+	control.mu.Unlock()
+	if active {
+		control.StopTrace()
+		tray.Notify(config.AppName, tr("Diagnostic trace stopped."))
+		return
+	}
+	trace, err := control.StartTrace(defaultTraceDuration)
+	if err != nil {
+		tray.Notify(config.AppName, tr("Could not start a diagnostic trace: %v", err))
+		return
+	}
+	tray.Notify(config.AppName, tr("Diagnostic trace running until %s. Files: %s", trace.Until.Format("15:04"), trace.Dir))
+}
+
+type traceOptions struct {
+	duration time.Duration
+}
+
+func (o *traceOptions) register(fs *flag.FlagSet) {
+	fs.DurationVar(&o.duration, "for", defaultTraceDuration, "how long to trace (at most 2h)")
+}
+
+// runTraceCommand implements "launcher trace [start|stop|status] --for 30m"
+func runTraceCommand(config *AppConfig, args []string) int {
+	action := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	var opts traceOptions
+	fs := newCommandFlagSet("trace")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	method, body := http.MethodGet, []byte(nil)
+	switch action {
+	case "status":
+	case "start":
+		method = http.MethodPost
+		body, _ = json.Marshal(traceRequest{Action: action, Duration: opts.duration.String()})
+	case "stop":
+		method = http.MethodPost
+		body, _ = json.Marshal(traceRequest{Action: action})
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown action %q (expected start, stop or status)\n", action)
+		return exitUsage
+	}
+
+	resp, err := controlRequest(config, method, "/trace", body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		fmt.Fprintf(os.Stderr, "Trace failed: %s\n", strings.TrimSpace(string(message)))
+		return exitLauncherError
+	}
+	var trace traceInfo
+	if err := json.NewDecoder(resp.Body).Decode(&trace); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+
+	if action == "stop" {
+		fmt.Println("✓ Trace stopped")
+		return exitOK
+	}
+	if !trace.Active {
+		fmt.Println("No trace running")
+		return exitOK
+	}
+	fmt.Printf("✓ Trace %s running until %s\n", trace.ID, trace.Until.Format("15:04:05"))
+	fmt.Printf("  %s\n", trace.Dir)
+	return exitOK
+}
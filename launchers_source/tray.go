@@ -0,0 +1,243 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	nimAdd    = 0x0
+	nimModify = 0x1
+	nimDelete = 0x2
+
+	nifMessage = 0x1
+	nifIcon    = 0x2
+	nifTip     = 0x4
+	nifInfo    = 0x10
+
+	niifWarning = 0x2
+
+	mfString    = 0x0
+	mfGrayed    = 0x1
+	mfChecked   = 0x8
+	mfSeparator = 0x800
+
+	tpmRightButton = 0x2
+	tpmReturnCmd   = 0x100
+
+	wmTrayCallback = wmApp + 1
+	trayIconID     = 1
+)
+
+type guid struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+type notifyIconData struct {
+	Size             uint32
+	Wnd              uintptr
+	ID               uint32
+	Flags            uint32
+	CallbackMessage  uint32
+	Icon             uintptr
+	Tip              [128]uint16
+	State            uint32
+	StateMask        uint32
+	Info             [256]uint16
+	TimeoutOrVersion uint32
+	InfoTitle        [64]uint16
+	InfoFlags        uint32
+	GuidItem         guid
+	BalloonIcon      uintptr
+}
+
+type trayItem struct {
+	label   string
+	action  func()
+	checked func() bool // nil for a plain entry
+}
+
+// Tray is the launcher's notification area icon. All methods are safe to
+// call from any goroutine.
+type Tray struct {
+	mu      sync.Mutex
+	hwnd    uintptr
+	icon    uintptr
+	tooltip string
+	items   []trayItem
+	done    chan struct{}
+}
+
+// startTray shows the tray icon on a dedicated OS thread. The icon is taken
+// from wap.exe so it matches the application.
+func startTray(config *AppConfig, tooltip string) *Tray {
+	tray := &Tray{tooltip: tooltip, done: make(chan struct{})}
+	ready := make(chan struct{})
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(tray.done)
+
+		hwnd, err := createWindow("WAPLauncherTray", config.AppName, 0, 0, 0, 0, 0, 0, tray.handleMessage)
+		if err != nil {
+			logWarn("tray", "failed to create tray window", "error", err)
+			close(ready)
+			return
+		}
+
+		icon, _, _ := procExtractIconW.Call(moduleHandle(), uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(config.AppExe))), 0)
+		if icon <= 1 {
+			icon, _, _ = procLoadIconW.Call(0, idiApp)
+		}
+
+		tray.mu.Lock()
+		tray.hwnd = hwnd
+		tray.icon = icon
+		data := tray.iconData(nifMessage | nifIcon | nifTip)
+		tray.mu.Unlock()
+		procShellNotifyIconW.Call(nimAdd, uintptr(unsafe.Pointer(&data)))
+		close(ready)
+
+		runMessageLoop()
+	}()
+
+	<-ready
+	return tray
+}
+
+// iconData must be called with t.mu held
+func (t *Tray) iconData(flags uint32) notifyIconData {
+	data := notifyIconData{
+		Wnd:             t.hwnd,
+		ID:              trayIconID,
+		Flags:           flags,
+		CallbackMessage: wmTrayCallback,
+		Icon:            t.icon,
+	}
+	data.Size = uint32(unsafe.Sizeof(data))
+	copyUTF16(data.Tip[:], t.tooltip)
+	return data
+}
+
+// copyUTF16 copies s into a fixed-size, NUL-terminated buffer, truncating
+func copyUTF16(dst []uint16, s string) {
+	encoded, _ := syscall.UTF16FromString(s)
+	if len(encoded) > len(dst) {
+		encoded = encoded[:len(dst)]
+		encoded[len(encoded)-1] = 0
+	}
+	copy(dst, encoded)
+}
+
+func (t *Tray) SetTooltip(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tooltip = text
+	if t.hwnd == 0 {
+		return
+	}
+	data := t.iconData(nifTip)
+	procShellNotifyIconW.Call(nimModify, uintptr(unsafe.Pointer(&data)))
+}
+
+// Notify shows a warning balloon next to the tray icon
+func (t *Tray) Notify(title, text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hwnd == 0 {
+		return
+	}
+	data := t.iconData(nifInfo)
+	copyUTF16(data.InfoTitle[:], title)
+	copyUTF16(data.Info[:], text)
+	data.InfoFlags = niifWarning
+	procShellNotifyIconW.Call(nimModify, uintptr(unsafe.Pointer(&data)))
+}
+
+// AddMenuItem appends an entry to the right-click menu
+func (t *Tray) AddMenuItem(label string, action func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items = append(t.items, trayItem{label: label, action: action})
+}
+
+// AddCheckMenuItem appends an entry with a check mark while checked
+// returns true
+func (t *Tray) AddCheckMenuItem(label string, checked func() bool, action func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items = append(t.items, trayItem{label: label, action: action, checked: checked})
+}
+
+// Close removes the icon and stops the tray thread
+func (t *Tray) Close() {
+	t.mu.Lock()
+	hwnd := t.hwnd
+	t.mu.Unlock()
+	if hwnd == 0 {
+		return
+	}
+	postMessage(hwnd, wmClose, 0, 0)
+	<-t.done
+}
+
+func (t *Tray) handleMessage(hwnd uintptr, msg uint32, wparam, lparam uintptr) (uintptr, bool) {
+	switch msg {
+	case wmTrayCallback:
+		if lparam == wmRButtonUp || lparam == wmLButtonUp {
+			t.showMenu(hwnd)
+		}
+		return 0, true
+	case wmClose:
+		t.mu.Lock()
+		data := t.iconData(0)
+		t.hwnd = 0
+		t.mu.Unlock()
+		procShellNotifyIconW.Call(nimDelete, uintptr(unsafe.Pointer(&data)))
+		procDestroyWindow.Call(hwnd)
+		return 0, true
+	case wmDestroy:
+		procPostQuitMessage.Call(0)
+		return 0, true
+	}
+	return 0, false
+}
+
+func (t *Tray) showMenu(hwnd uintptr) {
+	t.mu.Lock()
+	items := append([]trayItem(nil), t.items...)
+	tooltip := t.tooltip
+	t.mu.Unlock()
+
+	menu, _, _ := procCreatePopupMenu.Call()
+	if menu == 0 {
+		return
+	}
+	defer procDestroyMenu.Call(menu)
+
+	// First line mirrors the tooltip (status, remaining demo time, ...)
+	procAppendMenuW.Call(menu, mfString|mfGrayed, 0, uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(tooltip))))
+	procAppendMenuW.Call(menu, mfSeparator, 0, 0)
+	for i, item := range items {
+		flags := uintptr(mfString)
+		if item.checked != nil && item.checked() {
+			flags |= mfChecked
+		}
+		procAppendMenuW.Call(menu, flags, uintptr(i+1), uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(item.label))))
+	}
+
+	var pt point
+	procGetCursorPos.Call(uintptr(unsafe.Pointer(&pt)))
+	// Required so the menu closes when the user clicks elsewhere
+	procSetForegroundWindow.Call(hwnd)
+	cmd, _, _ := procTrackPopupMenu.Call(menu, tpmRightButton|tpmReturnCmd, uintptr(pt.X), uintptr(pt.Y), 0, hwnd, 0)
+	if cmd > 0 && int(cmd) <= len(items) {
+		go items[cmd-1].action()
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// updatePublicKey is the base64 Ed25519 key release packages are signed
+// with, set at build time:
+//
+//	go build -ldflags "-X main.updatePublicKey=<base64 key>"
+var updatePublicKey string
+
+// UpdateSettings points the launcher at the release server. The manifest
+// for a channel is fetched from URL + "/" + channel + ".json".
+type UpdateSettings struct {
+	URL       string `json:"url"`
+	Channel   string `json:"channel"`    // "stable" (default) or "beta"
+	PublicKey string `json:"public_key"` // overrides the built-in key
+	AutoCheck bool   `json:"auto_check"` // check and stage updates in the background
+}
+
+// updateManifest describes the newest package on a channel. Signature is
+// an Ed25519 signature over "<version>\n<sha256>", followed by
+// "\n<file_manifest_sha256>" when the release supports delta updates.
+type updateManifest struct {
+	Version   string `json:"version"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+
+	// Optional file-level delta: the release's manifest.json and the base
+	// URL its files are served from
+	FileManifest       string `json:"file_manifest,omitempty"`
+	FileManifestSHA256 string `json:"file_manifest_sha256,omitempty"`
+	FilesURL           string `json:"files_url,omitempty"`
+}
+
+func (m *updateManifest) supportsDelta() bool {
+	return m.FileManifest != "" && m.FileManifestSHA256 != "" && m.FilesURL != ""
+}
+
+// updateTrial tracks the first launches of a freshly applied update so it
+// can be rolled back if it never starts successfully.
+type updateTrial struct {
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previous_version"`
+	Attempts        int    `json:"attempts"`
+	Failed          bool   `json:"failed"`
+}
+
+const (
+	updatesDirName   = "updates"
+	stagedBinName    = "bin.staged"
+	previousBinName  = "bin.previous"
+	pendingFileName  = "pending.json"
+	trialFileName    = "trial.json"
+	blockedFileName  = "blocked.json"
+	maxTrialAttempts = 2
+
+	installUpdateCommand = "__install-update" // the elevated swap for per-machine installs
+)
+
+func updatesDir(config *AppConfig) string {
+	return filepath.Join(config.RootDir, updatesDirName)
+}
+
+func installedVersion(config *AppConfig) string {
+	manifest, err := loadManifest(config.BinDir)
+	if err != nil || manifest == nil || manifest.Version == "" {
+		return "0"
+	}
+	return manifest.Version
+}
+
+// compareVersions compares dotted numeric versions ("1.10.2" > "1.9")
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func fetchUpdateManifest(settings UpdateSettings, channel string) (*updateManifest, error) {
+	if settings.URL == "" {
+		return nil, errors.New("no update.url configured")
+	}
+	url := strings.TrimRight(settings.URL, "/") + "/" + channel + ".json"
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("update server returned %s for %s", resp.Status, url)
+	}
+
+	var manifest updateManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid update manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+func verifyUpdateSignature(settings UpdateSettings, manifest *updateManifest) error {
+	encodedKey := settings.PublicKey
+	if encodedKey == "" {
+		encodedKey = updatePublicKey
+	}
+	if encodedKey == "" {
+		return errors.New("no update signing key configured, refusing to install unsigned updates")
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid update signing key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil {
+		return errors.New("malformed update signature")
+	}
+	message := manifest.Version + "\n" + strings.ToLower(manifest.SHA256)
+	if manifest.FileManifestSHA256 != "" {
+		message += "\n" + strings.ToLower(manifest.FileManifestSHA256)
+	}
+	if !ed25519.Verify(key, []byte(message), signature) {
+		return errors.New("update signature is not valid")
+	}
+	return nil
+}
+
+func isBlockedVersion(config *AppConfig, version string) bool {
+	var blocked []string
+	if data, err := os.ReadFile(filepath.Join(updatesDir(config), blockedFileName)); err == nil {
+		json.Unmarshal(data, &blocked)
+	}
+	for _, v := range blocked {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func blockVersion(config *AppConfig, version string) {
+	path := filepath.Join(updatesDir(config), blockedFileName)
+	var blocked []string
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &blocked)
+	}
+	blocked = append(blocked, version)
+	writeJSONFile(path, blocked)
+}
+
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	chaosSlowWrite()
+	return os.WriteFile(path, data, 0644)
+}
+
+func readJSONFile(path string, value interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// stageUpdate downloads, verifies and unpacks manifest's package into
+// bin.staged. It is swapped in on the next launch. Releases that support
+// it are staged as a delta, falling back to the full package.
+func stageUpdate(config *AppConfig, settings UpdateSettings, manifest *updateManifest) (err error) {
+	if err := verifyUpdateSignature(settings, manifest); err != nil {
+		return err
+	}
+	onTaskbar := startTaskbarProgress("update")
+	defer func() { onTaskbar.End(err) }()
+
+	dir := updatesDir(config)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	staged := filepath.Join(config.RootDir, stagedBinName)
+
+	stagedDelta := false
+	if manifest.supportsDelta() {
+		os.RemoveAll(staged)
+		downloaded, err := stageDeltaUpdate(config, manifest, staged, onTaskbar)
+		if err == nil {
+			logInfo("update", "staged delta update", "version", manifest.Version, "downloaded_bytes", downloaded)
+			stagedDelta = true
+		} else {
+			os.RemoveAll(staged)
+			if manifest.URL == "" {
+				return err
+			}
+			logWarn("update", "delta update failed, downloading full package", "version", manifest.Version, "error", err)
+		}
+	}
+	if !stagedDelta {
+		if err := stageFullUpdate(config, manifest, staged, onTaskbar); err != nil {
+			return err
+		}
+	}
+
+	if err := writeJSONFile(filepath.Join(dir, pendingFileName), manifest); err != nil {
+		return err
+	}
+	logInfo("update", "update staged", "version", manifest.Version)
+	return nil
+}
+
+func stageFullUpdate(config *AppConfig, manifest *updateManifest, staged string, onTaskbar *taskbarProgress) error {
+	archive := filepath.Join(updatesDir(config), "wap-"+manifest.Version+".zip")
+	consolePrintf("Downloading %s %s...\n", config.AppName, manifest.Version)
+	if err := downloadFileProgress(manifest.URL, archive, onTaskbar.Set); err != nil {
+		return fmt.Errorf("failed to download update: %w", err)
+	}
+	if err := verifyFileSHA256(archive, manifest.SHA256); err != nil {
+		os.Remove(archive)
+		return err
+	}
+	defer os.Remove(archive)
+
+	// Files unchanged between versions end up as hardlinks to one copy
+	store := openContentStore(config)
+	seedStoreFromInstall(config, store)
+
+	os.RemoveAll(staged)
+	if err := extractZip(archive, staged, extractOptions{Store: store, Progress: onTaskbar.Set}); err != nil {
+		os.RemoveAll(staged)
+		return err
+	}
+	return nil
+}
+
+// checkForUpdate returns the channel's manifest when it offers a newer,
+// non-blocked version than the installed one.
+func checkForUpdate(config *AppConfig, channel string) (*updateManifest, error) {
+	settings := config.Settings.Update
+	if channel == "" {
+		channel = settings.Channel
+	}
+	if channel == "" {
+		channel = "stable"
+	}
+
+	manifest, err := fetchUpdateManifest(settings, channel)
+	if err != nil {
+		return nil, err
+	}
+	if compareVersions(manifest.Version, installedVersion(config)) <= 0 || isBlockedVersion(config, manifest.Version) {
+		return nil, nil
+	}
+	return manifest, nil
+}
+
+// startBackgroundUpdateCheck stages new versions while the app runs
+func startBackgroundUpdateCheck(config *AppConfig) {
+	if !config.Settings.Update.AutoCheck || config.Settings.Update.URL == "" {
+		return
+	}
+	go func() {
+		manifest, err := checkForUpdate(config, "")
+		if err != nil {
+			logWarn("update", "update check failed", "error", err)
+			return
+		}
+		if manifest == nil {
+			return
+		}
+		if err := stageUpdate(config, config.Settings.Update, manifest); err != nil {
+			logWarn("update", "failed to stage update", "version", manifest.Version, "error", err)
+			return
+		}
+		logInfo("update", "update will be installed on next launch", "version", manifest.Version)
+		notifyToast(config, toastUpdateStaged, tr("%s %s is ready", config.AppName, manifest.Version),
+			tr("The update was downloaded and will be installed the next time %s starts.", config.AppName))
+	}()
+}
+
+// applyPendingUpdate runs before anything opens files in bin/. It swaps in
+// a staged update, or rolls back one that failed to start. The returned
+// message describes what happened, for logging once the log is open.
+func applyPendingUpdate(config *AppConfig) (string, error) {
+	dir := updatesDir(config)
+	trialPath := filepath.Join(dir, trialFileName)
+	previous := filepath.Join(config.RootDir, previousBinName)
+
+	var trial updateTrial
+	if readJSONFile(trialPath, &trial) == nil {
+		trial.Attempts++
+		if trial.Failed || trial.Attempts > maxTrialAttempts {
+			restore, err := carryUserFiles(config, previous)
+			if err != nil {
+				return "", fmt.Errorf("failed to roll back update %s: %w", trial.Version, err)
+			}
+			if err := swapDirs(previous, config.BinDir, filepath.Join(config.RootDir, "bin.failed")); err != nil {
+				restore()
+				return "", fmt.Errorf("failed to roll back update %s: %w", trial.Version, err)
+			}
+			os.RemoveAll(filepath.Join(config.RootDir, "bin.failed"))
+			os.Remove(trialPath)
+			blockVersion(config, trial.Version)
+			return fmt.Sprintf("update %s failed to start, rolled back to %s", trial.Version, trial.PreviousVersion), nil
+		}
+		writeJSONFile(trialPath, trial)
+		return "", nil
+	}
+
+	var pending updateManifest
+	pendingPath := filepath.Join(dir, pendingFileName)
+	staged := filepath.Join(config.RootDir, stagedBinName)
+	if readJSONFile(pendingPath, &pending) != nil {
+		return "", nil
+	}
+	if _, err := os.Stat(staged); err != nil {
+		os.Remove(pendingPath)
+		return "", nil
+	}
+
+	previousVersion := installedVersion(config)
+	if !canWrite(config.RootDir) && !isElevated() {
+		// A per-machine install: only the swap runs as administrator
+		consolePrintln("Windows will ask for permission to install the update...")
+		if err := runElevatedStep(installUpdateCommand); err != nil {
+			return "", fmt.Errorf("failed to install update %s: %w", pending.Version, err)
+		}
+	} else if err := installPendingUpdate(config, &pending, previousVersion); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("installed update %s (was %s)", pending.Version, previousVersion), nil
+}
+
+// installPendingUpdate swaps the staged bin/ in and starts its trial
+func installPendingUpdate(config *AppConfig, pending *updateManifest, previousVersion string) error {
+	dir := updatesDir(config)
+	previous := filepath.Join(config.RootDir, previousBinName)
+	staged := filepath.Join(config.RootDir, stagedBinName)
+	os.RemoveAll(previous)
+	restore, err := carryUserFiles(config, staged)
+	if err != nil {
+		return fmt.Errorf("failed to install update %s: %w", pending.Version, err)
+	}
+	if err := swapDirs(staged, config.BinDir, previous); err != nil {
+		restore()
+		return fmt.Errorf("failed to install update %s: %w", pending.Version, err)
+	}
+	os.Remove(filepath.Join(dir, pendingFileName))
+	os.WriteFile(filepath.Join(config.BinDir, payloadMarkerName), []byte(pending.Version), 0644)
+	writeJSONFile(filepath.Join(dir, trialFileName), updateTrial{Version: pending.Version, PreviousVersion: previousVersion, Attempts: 1})
+	return nil
+}
+
+// runInstallUpdateCommand is the elevated half of applyPendingUpdate
+func runInstallUpdateCommand(config *AppConfig, args []string) int {
+	var pending updateManifest
+	if err := readJSONFile(filepath.Join(updatesDir(config), pendingFileName), &pending); err != nil {
+		return exitUpdateFailed
+	}
+	if err := installPendingUpdate(config, &pending, installedVersion(config)); err != nil {
+		return exitUpdateFailed
+	}
+	return exitOK
+}
+
+// carryUserFiles moves what bin/ holds for its users into replacement,
+// the directory about to take its place: portable installs keep data, logs
+// and state in bin/. Everything at the top of bin/ that neither its
+// manifest nor replacement lists belongs to the users, and so does
+// bin\data when it is the live data directory rather than the seed
+// copied from. The returned function moves the files back if the swap
+// fails.
+func carryUserFiles(config *AppConfig, replacement string) (func(), error) {
+	appEntries := map[string]bool{strings.ToLower(manifestName): true}
+	if manifest, err := loadManifest(config.BinDir); err == nil && manifest != nil {
+		for rel := range manifest.Files {
+			appEntries[strings.ToLower(strings.SplitN(rel, "/", 2)[0])] = true
+		}
+	}
+	liveData := config.Portable && strings.EqualFold(config.DataDir, filepath.Join(config.BinDir, "data"))
+
+	entries, err := os.ReadDir(config.BinDir)
+	if err != nil {
+		return nil, err
+	}
+	var moved []string
+	restore := func() {
+		for _, name := range moved {
+			os.Rename(filepath.Join(replacement, name), filepath.Join(config.BinDir, name))
+		}
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		target := filepath.Join(replacement, name)
+		if liveData && strings.EqualFold(name, "data") {
+			// The release's seed data is only copied from on first run
+			os.RemoveAll(target)
+		} else if appEntries[strings.ToLower(name)] {
+			continue
+		} else if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(config.BinDir, name), target); err != nil {
+			restore()
+			return nil, fmt.Errorf("failed to keep %s: %w", name, err)
+		}
+		moved = append(moved, name)
+	}
+	if len(moved) > 0 {
+		logInfo("update", "kept the users' files in bin", "files", strings.Join(moved, ","))
+	}
+	return restore, nil
+}
+
+// swapDirs moves current to backup and replacement to current, undoing the
+// first rename if the second fails.
+func swapDirs(replacement, current, backup string) error {
+	if err := os.Rename(current, backup); err != nil {
+		return err
+	}
+	if err := os.Rename(replacement, current); err != nil {
+		os.Rename(backup, current)
+		return err
+	}
+	return nil
+}
+
+// confirmUpdateStarted ends the trial of a freshly installed update
+func confirmUpdateStarted(config *AppConfig) {
+	trialPath := filepath.Join(updatesDir(config), trialFileName)
+	var trial updateTrial
+	if readJSONFile(trialPath, &trial) != nil {
+		return
+	}
+	os.Remove(trialPath)
+	logInfo("update", "update confirmed", "version", trial.Version)
+}
+
+// rollBackFailedUpdate marks the running update as failed and relaunches
+// the launcher, which restores the previous version before starting. It
+// returns false when no update is on trial. Only an interactive launch
+// tells the user in a dialog.
+func rollBackFailedUpdate(config *AppConfig, interactive bool) bool {
+	trialPath := filepath.Join(updatesDir(config), trialFileName)
+	var trial updateTrial
+	if readJSONFile(trialPath, &trial) != nil {
+		return false
+	}
+	trial.Failed = true
+	if err := writeJSONFile(trialPath, trial); err != nil {
+		return false
+	}
+
+	logError("update", "update failed to start, rolling back", "version", trial.Version)
+	notifyWebhook(webhookUpdateFailed, fmt.Sprintf("Version %s failed to start and is being rolled back to %s", trial.Version, trial.PreviousVersion), launcherLogName)
+	message := tr("Version %s failed to start. The previous version (%s) will be restored.", trial.Version, trial.PreviousVersion)
+	if interactive && !machineOutput() {
+		messageBox(config.AppName, message, mbOK|mbIconWarning|mbTopmost)
+	} else {
+		consoleWarnf("%s", message)
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		exePath = os.Args[0]
+	}
+	relaunch := exec.Command(exePath, os.Args[1:]...)
+	relaunch.Env = append(os.Environ(), relaunchEnv+"=1")
+	if err := relaunch.Start(); err != nil {
+		logError("update", "failed to relaunch for rollback", "error", err)
+	}
+	return true
+}
+
+type updateOptions struct {
+	channel string
+}
+
+func (o *updateOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.channel, "channel", "", "release channel to use (stable or beta)")
+}
+
+// runUpdateCommand implements "launcher update [check] [--channel beta]"
+func runUpdateCommand(config *AppConfig, args []string) int {
+	checkOnly := len(args) > 0 && args[0] == "check"
+	if checkOnly {
+		args = args[1:]
+	}
+	var opts updateOptions
+	fs := newCommandFlagSet("update")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	fmt.Printf("Installed version: %s\n", installedVersion(config))
+	manifest, err := checkForUpdate(config, opts.channel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitLauncherError
+	}
+	if manifest == nil {
+		fmt.Println("✓ Already up to date")
+		return exitOK
+	}
+	fmt.Printf("Update available: %s\n", manifest.Version)
+	if checkOnly {
+		return exitOK
+	}
+
+	if err := stageUpdate(config, config.Settings.Update, manifest); err != nil {
+		fmt.Fprintf(os.Stderr, "Update failed: %v\n", err)
+		return exitUpdateFailed
+	}
+	fmt.Printf("✓ %s will be installed the next time the application starts\n", manifest.Version)
+	return exitOK
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Logs, data and runtime state live per user, so the application works
+// when installed under Program Files:
+//
+//	%LOCALAPPDATA%\WAP\          .control.json, LAN password, proxy certificate
+//	%LOCALAPPDATA%\WAP\logs\     launcher.log, python_server.log, ...
+//	%LOCALAPPDATA%\WAP\data\     the backend's data
+//
+// Portable installs ("portable": true in launcher.json, or --portable) keep
+// everything inside the install directory as before. A named profile
+// (profile.go) uses profiles\<name> below either, and on a shared machine
+// a portable install keeps each user's files in users\<name>
+// (terminalserver.go).
+const (
+	userDirName      = "WAP"
+	userLogsDirName  = "logs"
+	userDataDirName  = "data"
+	migratedFileName = ".migrated"
+)
+
+// Files from bin/ that are copied into the state directory once
+var migratedStateFiles = []string{lanPasswordName, proxyCertName, proxyKeyName}
+
+// setPortableDirs keeps logs, data and state inside bin/
+func setPortableDirs(config *AppConfig) {
+	config.Portable = true
+	config.StateDir = userDir(config, profileDir(config, config.BinDir))
+	config.DataDir = filepath.Join(config.StateDir, "data")
+	config.LogDir = config.StateDir
+}
+
+// setUserDirs moves logs, data and state under %LOCALAPPDATA%\WAP. Without
+// LOCALAPPDATA the launcher falls back to portable mode.
+func setUserDirs(config *AppConfig) {
+	localAppData := os.Getenv("LOCALAPPDATA")
+	if config.Settings.Portable || localAppData == "" {
+		setPortableDirs(config)
+		return
+	}
+	config.Portable = false
+	config.StateDir = profileDir(config, filepath.Join(localAppData, userDirName))
+	config.LogDir = filepath.Join(config.StateDir, userLogsDirName)
+	config.DataDir = filepath.Join(config.StateDir, userDataDirName)
+}
+
+// prepareUserDirs creates the per-user directories and, the first time,
+// copies bin\data and the state files into them. The originals stay in
+// place: bin/ may be read-only, and an update replaces it anyway.
+func prepareUserDirs(config *AppConfig) error {
+	for _, dir := range []string{config.StateDir, config.LogDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	// A portable profile or user still starts from a copy of bin\data
+	if config.Portable && config.StateDir == config.BinDir {
+		return nil
+	}
+
+	marker := filepath.Join(config.StateDir, migratedFileName)
+	if _, err := os.Stat(marker); err == nil {
+		return os.MkdirAll(config.DataDir, 0755)
+	}
+
+	oldData := filepath.Join(config.BinDir, "data")
+	copied := 0
+	if _, err := os.Stat(config.DataDir); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(oldData); err == nil {
+			// Copy next to the target first so an interrupted copy is
+			// retried rather than mistaken for finished
+			partial := config.DataDir + ".partial"
+			os.RemoveAll(partial)
+			n, err := copyTree(oldData, partial)
+			if err == nil {
+				err = os.Rename(partial, config.DataDir)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to copy %s to %s: %w", oldData, config.DataDir, err)
+			}
+			copied = n
+		}
+	}
+	for _, name := range migratedStateFiles {
+		source, target := filepath.Join(config.BinDir, name), filepath.Join(config.StateDir, name)
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+		if err := copyFile(source, target); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", source, err)
+		}
+		copied++
+	}
+	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", config.DataDir, err)
+	}
+	if copied > 0 {
+		consoleSuccessf("Moved %d files from %s to %s", copied, config.BinDir, config.StateDir)
+		logInfo("launcher", "migrated data to the user directory", "from", config.BinDir, "to", config.StateDir, "files", copied)
+	}
+	return os.WriteFile(marker, []byte(time.Now().Format(time.RFC3339)+"\n"), 0644)
+}
+
+// copyTree copies the files below source to target and returns how many
+func copyTree(source, target string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(target, rel), 0755)
+		}
+		count++
+		return copyFile(path, filepath.Join(target, rel))
+	})
+	return count, err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+)
+
+// Build metadata, set by the release build:
+//
+//	go build -ldflags "-X main.launcherVersion=1.4.0 -X main.gitCommit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	launcherVersion = "dev"
+	gitCommit       = "unknown"
+	buildDate       = "unknown"
+)
+
+// versionFile is bin/version.json, written by the release build next to
+// wap.exe with the versions of the bundled components.
+type versionFile struct {
+	App     string `json:"app"`
+	Backend string `json:"backend"`
+}
+
+const versionFileName = "version.json"
+
+func printVersion(config *AppConfig) {
+	var bundled versionFile
+	if err := readJSONFile(filepath.Join(config.BinDir, versionFileName), &bundled); err != nil {
+		bundled = versionFile{App: "unknown", Backend: "unknown"}
+	}
+
+	fmt.Printf("%s launcher %s (commit %s, built %s, %s %s/%s)\n",
+		config.AppName, launcherVersion, gitCommit, buildDate, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("Package:  %s\n", installedVersion(config))
+	fmt.Printf("wap.exe:  %s\n", bundled.App)
+	fmt.Printf("Backend:  %s\n", bundled.Backend)
+}
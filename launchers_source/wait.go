@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Conditions for "launcher wait --for"
+const (
+	waitReady   = "ready"   // the app window is up (or, headless, the backend is)
+	waitHealthy = "healthy" // the backend answers /health
+	waitStopped = "stopped" // no launcher is running
+)
+
+const waitPollInterval = 500 * time.Millisecond
+
+type waitOptions struct {
+	condition string
+	timeout   time.Duration
+	quiet     bool
+}
+
+func (o *waitOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.condition, "for", waitReady, "condition to wait for: ready, healthy or stopped")
+	fs.DurationVar(&o.timeout, "timeout", 2*time.Minute, "give up after this long")
+	fs.BoolVar(&o.quiet, "quiet", false, "print nothing, only set the exit code")
+}
+
+// runWaitCommand implements "launcher wait --for ready --timeout 120s". It
+// exits 0 once the condition holds and exitWaitTimeout if it never does.
+func runWaitCommand(config *AppConfig, args []string) int {
+	var opts waitOptions
+	fs := newCommandFlagSet("wait")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	switch opts.condition {
+	case waitReady, waitHealthy, waitStopped:
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown condition %q (expected ready, healthy or stopped)\n", opts.condition)
+		return exitUsage
+	}
+
+	start := time.Now()
+	deadline := start.Add(opts.timeout)
+	for {
+		met, state := checkWaitCondition(config, opts.condition)
+		if met {
+			if !opts.quiet {
+				fmt.Printf("✓ %s after %s\n", opts.condition, time.Since(start).Round(100*time.Millisecond))
+			}
+			return exitOK
+		}
+		if time.Now().After(deadline) {
+			if !opts.quiet {
+				fmt.Fprintf(os.Stderr, "❌ Not %s after %s (%s)\n", opts.condition, opts.timeout, state)
+			}
+			return exitWaitTimeout
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// checkWaitCondition reports whether condition holds, and otherwise a
+// short description of the current state for the timeout message
+func checkWaitCondition(config *AppConfig, condition string) (bool, string) {
+	status, err := fetchLauncherStatus(config)
+	if err != nil {
+		return condition == waitStopped, "launcher not running"
+	}
+
+	switch condition {
+	case waitReady:
+		return status.Stage.Name == stageReady, "stage " + status.Stage.Name
+	case waitHealthy:
+		if status.Backend == "" {
+			return false, "backend not started"
+		}
+		client := &http.Client{Timeout: 2 * time.Second}
+		resp, err := client.Get(healthURL(config, status.Backend))
+		if err != nil {
+			return false, "backend not answering"
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK, "backend returned " + resp.Status
+	}
+	return false, fmt.Sprintf("launcher running (PID %d)", status.PID)
+}
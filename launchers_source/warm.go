@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// WarmBackendSettings keeps the Python backend running after the Flutter
+// app closes, so the next launch reconnects to it instead of waiting for
+// the backend to load again. An idle backend is stopped after
+// idle_minutes by a small watcher process; "launcher stop-backend" stops
+// it at once. Not used with the proxy, LAN mode, a remote backend, --dev
+// or --headless.
+type WarmBackendSettings struct {
+	Enabled     bool `json:"enabled"`
+	IdleMinutes int  `json:"idle_minutes"` // default 30
+}
+
+const (
+	warmStateName       = "warm_backend.json"
+	defaultWarmIdle     = 30
+	warmWatchInterval   = time.Minute
+	warmWatchCommand    = "__warm-watch"
+	detachedProcessFlag = 0x00000008 // DETACHED_PROCESS
+)
+
+// warmState describes the backend that may outlive its launcher
+type warmState struct {
+	PID        int       `json:"pid"`
+	Created    uint64    `json:"created"` // process creation time, guards against PID reuse
+	URL        string    `json:"url"`
+	Python     string    `json:"python"`
+	Version    string    `json:"version"`
+	InUse      bool      `json:"in_use"` // a launcher is attached
+	LastUsed   time.Time `json:"last_used"`
+	WatcherPID int       `json:"watcher_pid,omitempty"`
+}
+
+func warmStatePath(config *AppConfig) string {
+	return filepath.Join(config.StateDir, warmStateName)
+}
+
+func warmBackendEnabled(config *AppConfig) bool {
+	return config.Settings.WarmBackend.Enabled && !config.RemoteBackend && !config.Dev && !proxyEnabled(config)
+}
+
+func readWarmState(config *AppConfig) (*warmState, error) {
+	var state warmState
+	if err := readJSONFile(warmStatePath(config), &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// processCreated returns the creation time of a running process
+func processCreated(pid int) (uint64, error) {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.CloseHandle(handle)
+	var created, exited, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &created, &exited, &kernel, &user); err != nil {
+		return 0, err
+	}
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err == nil && code != stillActive {
+		return 0, errors.New("process has exited")
+	}
+	return uint64(created.HighDateTime)<<32 | uint64(created.LowDateTime), nil
+}
+
+const stillActive = 259
+
+// alive reports whether the recorded process still runs
+func (s *warmState) alive() bool {
+	created, err := processCreated(s.PID)
+	return err == nil && created == s.Created
+}
+
+// recordWarmBackend notes a backend this launcher is attached to
+func recordWarmBackend(config *AppConfig, pid int) {
+	created, err := processCreated(pid)
+	if err != nil {
+		return
+	}
+	state := warmState{
+		PID:      pid,
+		Created:  created,
+		URL:      config.BackendURL,
+		Python:   backendProgram(config),
+		Version:  installedVersion(config),
+		InUse:    true,
+		LastUsed: time.Now(),
+	}
+	if err := writeJSONFile(warmStatePath(config), state); err != nil {
+		logWarn("backend", "failed to record warm backend", "error", err)
+	}
+}
+
+// adoptWarmBackend attaches the session to a backend left running by an
+// earlier launch. A backend that is gone, unhealthy or from another
+// version is stopped and forgotten.
+func adoptWarmBackend(config *AppConfig, session *Session) bool {
+	state, err := readWarmState(config)
+	if err != nil {
+		return false
+	}
+	reason := ""
+	switch {
+	case !state.alive():
+		reason = "not running"
+	case state.Version != installedVersion(config) || !sameFile(state.Python, backendProgram(config)):
+		reason = "different version"
+	default:
+		if info, err := fetchBackendInfo(state.URL); err != nil {
+			reason = "not healthy"
+		} else if info.PID != state.PID || info.mismatch(config) != "" {
+			reason = "another server answers on its port"
+		}
+	}
+	if reason != "" {
+		logInfo("backend", "discarding warm backend", "pid", state.PID, "reason", reason)
+		killWarmBackend(config, state)
+		return false
+	}
+
+	process, err := os.FindProcess(state.PID)
+	if err != nil {
+		killWarmBackend(config, state)
+		return false
+	}
+	config.BackendURL = state.URL
+	state.InUse = true
+	state.LastUsed = time.Now()
+	writeJSONFile(warmStatePath(config), state)
+	session.adoptBackend(process)
+	consoleSuccessf("Reusing the running backend (PID %d) at %s", state.PID, state.URL)
+	logInfo("backend", "reusing warm backend", "child_pid", state.PID, "url", state.URL)
+	return true
+}
+
+func sameFile(a, b string) bool {
+	ai, errA := os.Stat(a)
+	bi, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(ai, bi)
+}
+
+// keepBackendWarm detaches the session's backend when the app closes and
+// starts the idle watcher
+func keepBackendWarm(config *AppConfig, session *Session) {
+	pid := session.detachBackend()
+	if pid == 0 {
+		return
+	}
+	state, err := readWarmState(config)
+	if err != nil || state.PID != pid {
+		recordWarmBackend(config, pid)
+		if state, err = readWarmState(config); err != nil {
+			return
+		}
+	}
+	state.InUse = false
+	state.LastUsed = time.Now()
+
+	exePath, err := os.Executable()
+	if err != nil {
+		exePath = os.Args[0]
+	}
+	watcher := exec.Command(exePath, warmWatchCommand)
+	watcher.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: detachedProcessFlag | syscall.CREATE_NEW_PROCESS_GROUP}
+	if err := watcher.Start(); err != nil {
+		logWarn("backend", "failed to start the warm backend watcher, stopping the backend", "error", err)
+		killWarmBackend(config, state)
+		return
+	}
+	state.WatcherPID = watcher.Process.Pid
+	watcher.Process.Release()
+	writeJSONFile(warmStatePath(config), state)
+
+	idle := config.Settings.WarmBackend.IdleMinutes
+	if idle <= 0 {
+		idle = defaultWarmIdle
+	}
+	consoleSuccessf("Python backend kept running for %d minutes for the next launch", idle)
+	logInfo("backend", "keeping backend warm", "child_pid", pid, "idle_minutes", idle)
+}
+
+// killWarmBackend stops the recorded backend if it is still the same
+// process and forgets it
+func killWarmBackend(config *AppConfig, state *warmState) {
+	if state.alive() {
+		if process, err := os.FindProcess(state.PID); err == nil {
+			if err := process.Kill(); err != nil {
+				logWarn("backend", "failed to stop warm backend", "pid", state.PID, "error", err)
+			}
+			process.Wait()
+			process.Release()
+		}
+	}
+	os.Remove(warmStatePath(config))
+}
+
+// runWarmWatchCommand is the hidden watcher that stops a warm backend once
+// it has been idle long enough. Only the newest watcher keeps running.
+func runWarmWatchCommand(config *AppConfig, args []string) int {
+	idle := time.Duration(config.Settings.WarmBackend.IdleMinutes) * time.Minute
+	if idle <= 0 {
+		idle = defaultWarmIdle * time.Minute
+	}
+	for {
+		time.Sleep(warmWatchInterval)
+		state, err := readWarmState(config)
+		if err != nil || state.WatcherPID != os.Getpid() {
+			return exitOK
+		}
+		if !state.alive() {
+			os.Remove(warmStatePath(config))
+			return exitOK
+		}
+		if state.InUse || time.Since(state.LastUsed) < idle {
+			continue
+		}
+		killWarmBackend(config, state)
+		backupOnExit(config)
+		return exitOK
+	}
+}
+
+type stopBackendOptions struct {
+	force bool
+}
+
+func (o *stopBackendOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.force, "force", false, "stop the backend even while the application is using it")
+}
+
+// runStopBackendCommand implements "launcher stop-backend [--force]"
+func runStopBackendCommand(config *AppConfig, args []string) int {
+	var opts stopBackendOptions
+	fs := newCommandFlagSet("stop-backend")
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	state, err := readWarmState(config)
+	if err != nil || !state.alive() {
+		os.Remove(warmStatePath(config))
+		fmt.Println("No warm backend is running")
+		return exitOK
+	}
+	if state.InUse && !opts.force {
+		fmt.Fprintf(os.Stderr, "The backend (PID %d) is in use by the application; close it first or use --force\n", state.PID)
+		return exitLauncherError
+	}
+	killWarmBackend(config, state)
+	fmt.Printf("✓ Stopped the backend (PID %d)\n", state.PID)
+	backupOnExit(config)
+	return exitOK
+}
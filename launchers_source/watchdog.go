@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WindowWatchdogSettings catches a wap.exe that starts but never shows its
+// window, typically hung while a plugin initializes. The app is killed and
+// started once more; if the second attempt shows no window either, the
+// launcher gives up with a dialog pointing at flutter.log.
+type WindowWatchdogSettings struct {
+	TimeoutSeconds int  `json:"timeout_seconds"` // default 60
+	Disabled       bool `json:"disabled"`
+}
+
+const (
+	defaultWindowTimeout = 60 * time.Second
+	windowHangLogLines   = 8
+)
+
+var errFrontendNoWindow = errors.New("the application did not show a window")
+
+// windowTimeout is how long the app may take to show its first window
+func windowTimeout(config *AppConfig) time.Duration {
+	if seconds := config.Settings.WindowWatchdog.TimeoutSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultWindowTimeout
+}
+
+// killHungFrontend kills an app that never showed a window
+func killHungFrontend(config *AppConfig, launch *frontendLaunch) {
+	pid := launch.cmd.Process.Pid
+	logWarn("frontend", "flutter application showed no window, killing it", "child_pid", pid, "timeout", windowTimeout(config).String())
+	reportEvent(eventTypeWarning, eventIDFrontendHang,
+		fmt.Sprintf("The WAP application (PID %d) showed no window within %s and was terminated.", pid, windowTimeout(config)))
+	launch.cmd.Process.Kill()
+	<-launch.exited
+}
+
+// showFrontendHang explains a frontend that showed no window twice
+func showFrontendHang(config *AppConfig) {
+	logPath := filepath.Join(config.LogDir, frontendLogName)
+	message := tr("%s was started twice but did not open its window within %s.\n\nThis is usually a plugin or graphics driver hanging at startup. Update the graphics driver, or try Safe Mode (launcher --safe-mode).\n\nDetails are in %s",
+		config.AppName, windowTimeout(config), logPath)
+	if file, err := os.Open(logPath); err == nil {
+		var tail strings.Builder
+		printLastLines(file, windowHangLogLines, &tail)
+		file.Close()
+		if lines := strings.TrimSpace(tail.String()); lines != "" {
+			message += ":\n\n" + lines
+		}
+	}
+	showError("The application window did not appear", errors.New(message))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookSettings post failure events to a chat or monitoring system, for
+// machines nobody watches:
+//
+//	"webhook": {"url": "secret://it-webhook", "format": "teams", "events": ["crash_loop", "disk_full"]}
+//
+// format is "json" (the default, the webhookPayload below), "slack" or
+// "teams". Every event carries the hostname and the end of the relevant log.
+type WebhookSettings struct {
+	URL    string   `json:"url"`    // may be secret://name, as chat webhook URLs are credentials
+	Format string   `json:"format"` // json, slack or teams
+	Events []string `json:"events"` // default all
+}
+
+// Webhook events
+const (
+	webhookBackendCrash  = "backend_crash"
+	webhookCrashLoop     = "crash_loop"
+	webhookUpdateFailed  = "update_failed"
+	webhookDiskFull      = "disk_full"
+	webhookStartupFailed = "startup_failed"
+	webhookTampered      = "tampered"
+)
+
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookLogLines = 30
+)
+
+type webhookPayload struct {
+	Event      string    `json:"event"`
+	App        string    `json:"app"`
+	Hostname   string    `json:"hostname"`
+	Version    string    `json:"version"`
+	Time       time.Time `json:"time"`
+	Message    string    `json:"message"`
+	Log        string    `json:"log,omitempty"` // file name of the excerpt
+	LogExcerpt string    `json:"log_excerpt,omitempty"`
+}
+
+var webhooks struct {
+	mu       sync.Mutex
+	url      string
+	format   string
+	events   []string
+	appName  string
+	version  string
+	logDir   string
+	inFlight sync.WaitGroup
+}
+
+// configureWebhooks resolves the webhook URL once per launcher run
+func configureWebhooks(config *AppConfig) {
+	settings := config.Settings.Webhook
+	if settings.URL == "" {
+		return
+	}
+	target, err := resolveSecretValue(config, "webhook.url", settings.URL)
+	if err != nil {
+		logWarn("webhook", "webhook disabled", "error", err)
+		return
+	}
+	switch settings.Format {
+	case "", "json", "slack", "teams":
+	default:
+		logWarn("webhook", "unknown webhook.format, sending json", "format", settings.Format)
+	}
+	webhooks.mu.Lock()
+	webhooks.url = target
+	webhooks.format = settings.Format
+	webhooks.events = settings.Events
+	webhooks.appName = config.AppName
+	webhooks.version = installedVersion(config)
+	webhooks.logDir = config.LogDir
+	webhooks.mu.Unlock()
+}
+
+// notifyWebhook posts event in the background; logName names the log whose
+// end is attached, "" for none
+func notifyWebhook(event, message, logName string) {
+	webhooks.mu.Lock()
+	target, format, appName, version, logDir := webhooks.url, webhooks.format, webhooks.appName, webhooks.version, webhooks.logDir
+	wanted := len(webhooks.events) == 0 || slices.Contains(webhooks.events, event)
+	webhooks.mu.Unlock()
+	if target == "" || !wanted {
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	payload := webhookPayload{
+		Event:    event,
+		App:      appName,
+		Hostname: hostname,
+		Version:  version,
+		Time:     time.Now(),
+		Message:  message,
+	}
+	if logName != "" {
+		if file, err := os.Open(filepath.Join(logDir, logName)); err == nil {
+			var tail bytes.Buffer
+			printLastLines(file, webhookLogLines, &tail)
+			file.Close()
+			payload.Log = logName
+			payload.LogExcerpt = tail.String()
+		}
+	}
+
+	webhooks.inFlight.Add(1)
+	go func() {
+		defer webhooks.inFlight.Done()
+		if err := postWebhook(target, format, payload); err != nil {
+			logWarn("webhook", "failed to send webhook", "event", event, "error", err)
+		} else {
+			logInfo("webhook", "webhook sent", "event", event)
+		}
+	}()
+}
+
+// notifyStartupFailure reports a launch that failed before the app was ready
+func notifyStartupFailure(code int) {
+	if !sessionFailed(code) {
+		return
+	}
+	if report, ok := startupTimingReport(); ok && report.Ready {
+		return
+	}
+	message := lastNotedError()
+	if message == "" {
+		message = fmt.Sprintf("The launcher exited with code %d (%s)", code, exitCodeNames[code])
+	}
+	notifyWebhook(webhookStartupFailed, message, launcherLogName)
+}
+
+// waitForWebhooks lets events raised just before exit go out
+func waitForWebhooks() {
+	webhooks.inFlight.Wait()
+}
+
+func postWebhook(target, format string, payload webhookPayload) error {
+	title := fmt.Sprintf("%s on %s: %s", payload.App, payload.Hostname, payload.Event)
+	text := payload.Message
+	if payload.LogExcerpt != "" {
+		text += fmt.Sprintf("\n\nEnd of %s:\n```\n%s\n```", payload.Log, strings.TrimRight(payload.LogExcerpt, "\n"))
+	}
+	var body interface{} = payload
+	switch format {
+	case "slack":
+		body = map[string]string{"text": "*" + title + "*\n" + text}
+	case "teams":
+		body = map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  title,
+			"title":    title,
+			"text":     strings.ReplaceAll(text, "\n", "\n\n"),
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
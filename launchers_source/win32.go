@@ -0,0 +1,239 @@
+package main
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	shell32  = syscall.NewLazyDLL("shell32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procMessageBoxW              = user32.NewProc("MessageBoxW")
+	procRegisterClassExW         = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW          = user32.NewProc("CreateWindowExW")
+	procDefWindowProcW           = user32.NewProc("DefWindowProcW")
+	procDestroyWindow            = user32.NewProc("DestroyWindow")
+	procGetMessageW              = user32.NewProc("GetMessageW")
+	procTranslateMessage         = user32.NewProc("TranslateMessage")
+	procDispatchMessageW         = user32.NewProc("DispatchMessageW")
+	procPostMessageW             = user32.NewProc("PostMessageW")
+	procPostQuitMessage          = user32.NewProc("PostQuitMessage")
+	procLoadIconW                = user32.NewProc("LoadIconW")
+	procLoadCursorW              = user32.NewProc("LoadCursorW")
+	procCreatePopupMenu          = user32.NewProc("CreatePopupMenu")
+	procAppendMenuW              = user32.NewProc("AppendMenuW")
+	procTrackPopupMenu           = user32.NewProc("TrackPopupMenu")
+	procDestroyMenu              = user32.NewProc("DestroyMenu")
+	procGetCursorPos             = user32.NewProc("GetCursorPos")
+	procSetForegroundWindow      = user32.NewProc("SetForegroundWindow")
+	procEnumWindows              = user32.NewProc("EnumWindows")
+	procGetWindowThreadProcessId = user32.NewProc("GetWindowThreadProcessId")
+	procIsWindowVisible          = user32.NewProc("IsWindowVisible")
+
+	procShellNotifyIconW = shell32.NewProc("Shell_NotifyIconW")
+	procShellExecuteW    = shell32.NewProc("ShellExecuteW")
+	procExtractIconW     = shell32.NewProc("ExtractIconW")
+
+	procGetModuleHandleW = kernel32.NewProc("GetModuleHandleW")
+)
+
+const (
+	wmDestroy   = 0x0002
+	wmClose     = 0x0010
+	wmCommand   = 0x0111
+	wmLButtonUp = 0x0202
+	wmRButtonUp = 0x0205
+	wmApp       = 0x8000
+
+	mbOK              = 0x00000000
+	mbYesNoCancel     = 0x00000003
+	mbYesNo           = 0x00000004
+	mbIconError       = 0x00000010
+	mbIconQuestion    = 0x00000020
+	mbIconWarning     = 0x00000030
+	mbIconInformation = 0x00000040
+	mbTopmost         = 0x00040000
+	idCancel          = 2
+	idYes             = 6
+	idNo              = 7
+
+	swHide       = 0
+	swShowNormal = 1
+	idiApp       = 32512
+	idcArrow     = 32512
+)
+
+type point struct {
+	X, Y int32
+}
+
+type winMsg struct {
+	Hwnd    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      point
+	Private uint32
+}
+
+type wndClassEx struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   uintptr
+	Icon       uintptr
+	Cursor     uintptr
+	Background uintptr
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     uintptr
+}
+
+// windowHandler handles a message for one window; returning false falls
+// through to DefWindowProc.
+type windowHandler func(hwnd uintptr, msg uint32, wparam, lparam uintptr) (uintptr, bool)
+
+var (
+	windowHandlers   sync.Map // hwnd -> windowHandler
+	registeredMu     sync.Mutex
+	registeredClass  = map[string]bool{}
+	sharedWindowProc = syscall.NewCallback(dispatchWindowMessage)
+)
+
+func dispatchWindowMessage(hwnd, msg, wparam, lparam uintptr) uintptr {
+	if h, ok := windowHandlers.Load(hwnd); ok {
+		if result, handled := h.(windowHandler)(hwnd, uint32(msg), wparam, lparam); handled {
+			return result
+		}
+	}
+	if msg == wmDestroy {
+		windowHandlers.Delete(hwnd)
+	}
+	ret, _, _ := procDefWindowProcW.Call(hwnd, msg, wparam, lparam)
+	return ret
+}
+
+func moduleHandle() uintptr {
+	h, _, _ := procGetModuleHandleW.Call(0)
+	return h
+}
+
+// createWindow registers className on first use and creates a window whose
+// messages are routed to handler. It must be called from the thread that
+// will run the message loop.
+func createWindow(className, title string, exStyle, style uint32, x, y, w, h int32, handler windowHandler) (uintptr, error) {
+	registeredMu.Lock()
+	if !registeredClass[className] {
+		wc := wndClassEx{
+			WndProc:    sharedWindowProc,
+			Instance:   moduleHandle(),
+			Background: 6, // COLOR_WINDOW + 1
+			ClassName:  syscall.StringToUTF16Ptr(className),
+		}
+		wc.Size = uint32(unsafe.Sizeof(wc))
+		wc.Cursor, _, _ = procLoadCursorW.Call(0, idcArrow)
+		if ret, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 {
+			registeredMu.Unlock()
+			return 0, err
+		}
+		registeredClass[className] = true
+	}
+	registeredMu.Unlock()
+
+	hwnd, _, err := procCreateWindowExW.Call(
+		uintptr(exStyle),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(className))),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(title))),
+		uintptr(style),
+		uintptr(x), uintptr(y), uintptr(w), uintptr(h),
+		0, 0, moduleHandle(), 0,
+	)
+	if hwnd == 0 {
+		return 0, err
+	}
+	windowHandlers.Store(hwnd, handler)
+	return hwnd, nil
+}
+
+// runMessageLoop pumps messages for windows created on the current thread
+// until PostQuitMessage is called.
+func runMessageLoop() {
+	var m winMsg
+	for {
+		ret, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		if int32(ret) <= 0 {
+			return
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&m)))
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
+
+func postMessage(hwnd uintptr, msg uint32, wparam, lparam uintptr) {
+	procPostMessageW.Call(hwnd, uintptr(msg), wparam, lparam)
+}
+
+func messageBox(title, text string, flags uint32) int {
+	ret, _, _ := procMessageBoxW.Call(
+		0,
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(text))),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(title))),
+		uintptr(flags),
+	)
+	return int(ret)
+}
+
+func openURL(url string) error {
+	ret, _, err := procShellExecuteW.Call(
+		0,
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr("open"))),
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(url))),
+		0, 0, swShowNormal,
+	)
+	// ShellExecute returns a value greater than 32 on success
+	if ret <= 32 {
+		return err
+	}
+	return nil
+}
+
+var (
+	enumMu       sync.Mutex
+	enumPID      uint32
+	enumFound    []uintptr
+	enumCallback = syscall.NewCallback(func(hwnd, lparam uintptr) uintptr {
+		var pid uint32
+		procGetWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+		if pid == enumPID {
+			if visible, _, _ := procIsWindowVisible.Call(hwnd); visible != 0 {
+				enumFound = append(enumFound, hwnd)
+			}
+		}
+		return 1
+	})
+)
+
+// processWindows returns the visible top-level windows owned by pid
+func processWindows(pid int) []uintptr {
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	enumPID = uint32(pid)
+	enumFound = nil
+	procEnumWindows.Call(enumCallback, 0)
+	return enumFound
+}
+
+// closeProcessWindows asks a GUI process to exit by closing its windows
+func closeProcessWindows(pid int) bool {
+	windows := processWindows(pid)
+	for _, hwnd := range windows {
+		postMessage(hwnd, wmClose, 0, 0)
+	}
+	return len(windows) > 0
+}
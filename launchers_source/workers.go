@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerSettings run several backend processes instead of one, for
+// CPU-bound workloads, each on a port of its own. The launcher's proxy,
+// which workers turn on, hands requests to them in turn:
+//
+//	"workers": {"count": 4}
+//
+// The first worker is the usual backend. The others start once it is
+// healthy, get WAP_WORKER set to their number and are restarted on their
+// own when they crash or on a restart service command naming them, e.g.
+// "backend-2". Workers share
+// nothing but the data directory, so the backend must not keep state in
+// memory between requests.
+type WorkerSettings struct {
+	Count int `json:"count"` // backend processes, default 1
+}
+
+const (
+	workerRestartMin    = time.Second
+	workerRestartMax    = 30 * time.Second
+	workerStableRuntime = time.Minute
+)
+
+// workerCount is the number of backend processes to run
+func workerCount(config *AppConfig) int {
+	if config.RemoteBackend {
+		return 1
+	}
+	return max(config.Settings.Workers.Count, 1)
+}
+
+// proxyEnabled reports whether the launcher's proxy fronts the backend
+func proxyEnabled(config *AppConfig) bool {
+	s := config.Settings
+	return (s.Proxy.Enabled || s.LAN.Enabled || workerCount(config) > 1) && !config.RemoteBackend
+}
+
+// workerPool runs the backend processes besides the first
+type workerPool struct {
+	session *Session
+	workers []*backendWorker
+	next    atomic.Uint64
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      sync.WaitGroup
+}
+
+// backendWorker is one extra backend process, restarted whenever it exits
+type backendWorker struct {
+	number  int
+	name    string
+	config  AppConfig // the session's, with the worker's BackendURL
+	target  *url.URL
+	healthy atomic.Bool
+
+	mu         sync.Mutex
+	cmd        *exec.Cmd
+	restarting bool
+}
+
+// startWorkers starts the extra workers once the first backend is healthy
+// and hands them to the proxy. It returns nil without workers.
+func startWorkers(session *Session) *workerPool {
+	config := session.config
+	count := workerCount(config)
+	if count <= 1 || config.Proxy == nil {
+		return nil
+	}
+	pool := &workerPool{session: session, closed: make(chan struct{})}
+	for number := 2; number <= count; number++ {
+		w := &backendWorker{number: number, name: fmt.Sprintf("%s-%d", serviceBackend, number), config: *config}
+		w.config.Settings.Env = workerEnv(config, number)
+		if err := setBackendPort(&w.config, 0); err != nil {
+			logError("workers", "no port for a worker", "worker", w.name, "error", err)
+			continue
+		}
+		w.target, _ = url.Parse(w.config.BackendURL)
+		pool.workers = append(pool.workers, w)
+	}
+	logInfo("workers", "starting backend workers", "count", len(pool.workers)+1)
+	for _, w := range pool.workers {
+		pool.done.Add(1)
+		go pool.supervise(w)
+	}
+	config.Proxy.setWorkers(pool)
+	go func() {
+		select {
+		case <-session.Stopping():
+			pool.Close()
+		case <-pool.closed:
+		}
+	}()
+	return pool
+}
+
+// Close stops all workers and waits for them to exit
+func (p *workerPool) Close() {
+	if p == nil {
+		return
+	}
+	p.closeOnce.Do(func() {
+		p.session.config.Proxy.setWorkers(nil)
+		close(p.closed)
+	})
+	p.done.Wait()
+}
+
+// pick returns where the next request goes: the first backend or a
+// healthy worker, in turn
+func (p *workerPool) pick(first *url.URL) *url.URL {
+	targets := []*url.URL{first}
+	for _, w := range p.workers {
+		if w.healthy.Load() {
+			targets = append(targets, w.target)
+		}
+	}
+	return targets[p.next.Add(1)%uint64(len(targets))]
+}
+
+// find returns the worker called name
+func (p *workerPool) find(name string) *backendWorker {
+	if p == nil {
+		return nil
+	}
+	for _, w := range p.workers {
+		if w.name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// restart replaces one worker's process; supervise starts the new one
+func (p *workerPool) restart(name string) error {
+	w := p.find(name)
+	if w == nil {
+		return fmt.Errorf("unknown service %q", name)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cmd == nil {
+		return errors.New("the worker is not running")
+	}
+	logInfo("workers", "restarting worker", "worker", w.name)
+	w.restarting = true
+	return w.cmd.Process.Kill()
+}
+
+// supervise keeps one worker running until the pool is closed, backing
+// off while it keeps crashing right after starting
+func (p *workerPool) supervise(w *backendWorker) {
+	defer p.done.Done()
+	control := p.session.control
+	delay := workerRestartMin
+	for {
+		started := time.Now()
+		cmd, err := startBackendProcess(&w.config, control, w.name, workerLogName(w.number))
+		if err != nil {
+			logError("workers", "failed to start worker", "worker", w.name, "error", err)
+		} else {
+			err = p.run(w, cmd)
+			if p.isClosed() {
+				return
+			}
+		}
+
+		w.mu.Lock()
+		restarting := w.restarting
+		w.restarting = false
+		w.mu.Unlock()
+		if restarting {
+			delay = workerRestartMin
+			continue
+		}
+		if err != nil && cmd != nil {
+			logError("workers", "worker exited unexpectedly", "worker", w.name, "child_pid", cmd.Process.Pid, "error", err)
+			reportCrash(&w.config, w.name, err)
+			notifyToast(p.session.config, toastWorkerRestarted, tr("%s restarted a backend process", p.session.config.AppName),
+				tr("%s stopped unexpectedly and is being started again.", w.name))
+		}
+		if time.Since(started) > workerStableRuntime {
+			delay = workerRestartMin
+		} else {
+			delay = min(delay*2, workerRestartMax)
+		}
+		select {
+		case <-time.After(delay):
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// run watches a started worker until it exits or the pool is closed
+func (p *workerPool) run(w *backendWorker, cmd *exec.Cmd) error {
+	control := p.session.control
+	w.mu.Lock()
+	w.cmd = cmd
+	w.mu.Unlock()
+	control.serviceStarted(w.name, cmd.Process.Pid)
+
+	var err error
+	exited := make(chan struct{})
+	go func() {
+		err = cmd.Wait()
+		closeChildOutput(cmd.Stdout)
+		close(exited)
+	}()
+	go p.waitHealthy(w, exited)
+
+	reason := "crashed"
+	select {
+	case <-exited:
+		w.mu.Lock()
+		if w.restarting {
+			reason = "restarted"
+		}
+		w.mu.Unlock()
+	case <-p.closed:
+		cmd.Process.Kill()
+		<-exited
+		reason = "stopped"
+	}
+	w.healthy.Store(false)
+	w.mu.Lock()
+	w.cmd = nil
+	w.mu.Unlock()
+	control.serviceStopped(w.name, reason)
+	return err
+}
+
+// waitHealthy puts the worker into rotation once it answers
+func (p *workerPool) waitHealthy(w *backendWorker, exited <-chan struct{}) {
+	deadline := time.Now().Add(backendStartTimeout)
+	url := healthURL(&w.config, w.config.BackendURL)
+	for time.Now().Before(deadline) {
+		if backendHealthy(url) {
+			w.healthy.Store(true)
+			logInfo("workers", "worker ready", "worker", w.name, "url", w.config.BackendURL)
+			return
+		}
+		select {
+		case <-exited:
+			return
+		case <-p.closed:
+			return
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	logWarn("workers", "worker did not become healthy", "worker", w.name, "timeout", backendStartTimeout.String())
+}
+
+func (p *workerPool) isClosed() bool {
+	select {
+	case <-p.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// workerEnv adds WAP_WORKER to the worker's environment
+func workerEnv(config *AppConfig, number int) map[string]string {
+	env := make(map[string]string, len(config.Settings.Env)+1)
+	for name, value := range config.Settings.Env {
+		env[name] = value
+	}
+	env["WAP_WORKER"] = strconv.Itoa(number)
+	return env
+}
+
+// workerLogName is python_server_2.log for worker 2
+func workerLogName(number int) string {
+	base, _ := strings.CutSuffix(backendLogName, ".log")
+	return fmt.Sprintf("%s_%d.log", base, number)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the subset of YAML used by site.yaml: nested mappings,
+// lists of scalars ("- item" or [a, b]), quoted and plain scalars and
+// comments. The result has the same shape as json.Decoder with UseNumber,
+// so it can be merged with launcher.json.
+func parseYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, text := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text = stripYAMLComment(text)
+		if strings.TrimSpace(text) == "" || strings.TrimSpace(text) == "---" {
+			continue
+		}
+		if lead := text[:len(text)-len(strings.TrimLeft(text, " \t"))]; strings.Contains(lead, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		lines = append(lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: strings.TrimRight(trimmed, " \t")})
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	value, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	return value, nil
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses consecutive lines at exactly indent as a mapping or a list
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if strings.HasPrefix(p.lines[p.pos].text, "- ") || p.lines[p.pos].text == "-" {
+		return p.list(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	result := map[string]interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.number)
+		}
+		if _, dup := result[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.number, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseYAMLScalar(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.number, err)
+			}
+			result[key] = value
+			continue
+		}
+		// "key:" introduces a nested block, or is null when nothing follows
+		if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+			value, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		} else if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && strings.HasPrefix(p.lines[p.pos].text, "- ") {
+			// Lists may sit at the same indentation as their key
+			value, err := p.list(indent)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		} else {
+			result[key] = nil
+		}
+	}
+	return result, nil
+}
+
+func (p *yamlParser) list(indent int) (interface{}, error) {
+	result := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && (strings.HasPrefix(p.lines[p.pos].text, "- ") || p.lines[p.pos].text == "-") {
+		line := p.lines[p.pos]
+		item := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if _, _, isMap := splitYAMLKey(item); isMap && !strings.HasPrefix(item, "\"") && !strings.HasPrefix(item, "'") {
+			return nil, fmt.Errorf("line %d: lists of mappings are not supported", line.number)
+		}
+		value, err := parseYAMLScalar(item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+		result = append(result, value)
+		p.pos++
+	}
+	return result, nil
+}
+
+// splitYAMLKey splits "key: value"; the colon must be followed by a space
+// or end the line so URLs in plain scalars are not mistaken for keys.
+func splitYAMLKey(text string) (string, string, bool) {
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key := strings.TrimSpace(text[:i])
+			if unquoted, err := parseYAMLScalar(key); err == nil {
+				if s, ok := unquoted.(string); ok {
+					key = s
+				}
+			}
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+func stripYAMLComment(text string) string {
+	inSingle, inDouble := false, false
+	for i, c := range text {
+		switch {
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '#' && !inSingle && !inDouble && (i == 0 || text[i-1] == ' '):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// Versions such as 1.10 parse as numbers, so they need quotes in site.yaml
+var yamlNumber = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+func parseYAMLScalar(text string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, "\""):
+		return strconv.Unquote(text)
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("unterminated string %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("unterminated list %s", text)
+		}
+		items := []interface{}{}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		if inner == "" {
+			return items, nil
+		}
+		for _, part := range strings.Split(inner, ",") {
+			value, err := parseYAMLScalar(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("inline mappings are not supported")
+	}
+
+	// YAML 1.2 core schema: yes/no/on/off stay strings ("no" is Norwegian)
+	switch text {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case "null", "Null", "NULL", "~", "":
+		return nil, nil
+	}
+	if yamlNumber.MatchString(text) {
+		return json.Number(text), nil
+	}
+	return text, nil
+}
@@ -2,8 +2,10 @@ from flask import Flask, request, jsonify
 from flask_cors import CORS
 import cv2
 import os
+import sys
 import logging
 import main_function
+import launcher_trace
 from pathlib import Path
 
 import threading
@@ -16,6 +18,7 @@ LOGGER = logging.getLogger(__name__)
 
 app = Flask(__name__)
 CORS(app)
+launcher_trace.install(app)
 
 # Global variables for progress tracking
 processing_status = {
@@ -34,6 +37,18 @@ server_running = True
 def health_check():
     return jsonify({'status': 'healthy', 'message': 'Python server is running'})
 
+@app.route('/info', methods=['GET'])
+def backend_info():
+    """Identify this backend so the launcher can reuse it instead of starting another"""
+    return jsonify({
+        'app': 'wap',
+        'version': os.environ.get('WAP_VERSION', ''),
+        'pid': os.getpid(),
+        'launcher_pid': int(os.environ.get('WAP_LAUNCHER_PID', '0')),
+        'data_dir': os.environ.get('WAP_DATA_DIR', ''),
+        'python': sys.executable,
+    })
+
 @app.route('/batch_process', methods=['POST'])
 def batch_process_endpoint():
     """Start batch process and return immediately"""
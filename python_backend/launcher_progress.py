@@ -0,0 +1,38 @@
+"""Report startup stages and task progress to the WAP launcher.
+
+The launcher passes WAP_CONTROL_URL and WAP_CONTROL_TOKEN to the backend.
+When they are missing (e.g. running the server by hand) every call is a
+no-op, so backend code can report progress unconditionally.
+"""
+import json
+import os
+import urllib.request
+
+
+def _post(path, payload):
+    control_url = os.environ.get("WAP_CONTROL_URL")
+    if not control_url:
+        return
+    try:
+        req = urllib.request.Request(
+            control_url + path,
+            data=json.dumps(payload).encode("utf-8"),
+            headers={
+                "Content-Type": "application/json",
+                "X-WAP-Token": os.environ.get("WAP_CONTROL_TOKEN", ""),
+            },
+            method="POST",
+        )
+        urllib.request.urlopen(req, timeout=2).close()
+    except Exception as e:
+        print(f"Could not report {path} to launcher: {e}")
+
+
+def report_stage(stage, message=None):
+    """Report a boot stage such as 'loading_models' or 'migrating_data'"""
+    _post("/stage", {"stage": stage, "message": message})
+
+
+def report_progress(task, percent, message=None, done=False):
+    """Report progress (0-100) of a long startup task, e.g. a model load"""
+    _post("/progress", {"task": task, "percent": percent, "message": message, "done": done})
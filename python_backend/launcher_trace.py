@@ -0,0 +1,98 @@
+"""Follow diagnostic traces started from the WAP launcher.
+
+While a trace runs (tray menu or `launcher trace start`), the backend logs
+at DEBUG level and records every request into backend.log inside the
+trace directory the launcher reports on GET /trace. Without a launcher
+(WAP_CONTROL_URL unset) nothing happens.
+"""
+import json
+import logging
+import os
+import threading
+import time
+import urllib.request
+import uuid
+
+from flask import g, request
+
+POLL_SECONDS = 2
+
+LOGGER = logging.getLogger("trace")
+
+_handler = None
+_trace_id = None
+_previous_level = None
+
+
+def _current_trace():
+    control_url = os.environ.get("WAP_CONTROL_URL")
+    req = urllib.request.Request(
+        control_url + "/trace",
+        headers={"X-WAP-Token": os.environ.get("WAP_CONTROL_TOKEN", "")},
+    )
+    with urllib.request.urlopen(req, timeout=2) as resp:
+        return json.load(resp)
+
+
+def _start(trace):
+    global _handler, _trace_id, _previous_level
+    _stop()
+    handler = logging.FileHandler(os.path.join(trace["dir"], "backend.log"), encoding="utf-8")
+    handler.setFormatter(logging.Formatter("%(asctime)s %(levelname)-5s [%(name)s] %(message)s"))
+    handler.setLevel(logging.DEBUG)
+    root = logging.getLogger()
+    _previous_level = root.level
+    root.setLevel(logging.DEBUG)
+    root.addHandler(handler)
+    _handler, _trace_id = handler, trace["trace_id"]
+    LOGGER.info("trace %s started", _trace_id)
+
+
+def _stop():
+    global _handler, _trace_id
+    if _handler is None:
+        return
+    LOGGER.info("trace %s stopped", _trace_id)
+    root = logging.getLogger()
+    root.removeHandler(_handler)
+    root.setLevel(_previous_level)
+    _handler.close()
+    _handler, _trace_id = None, None
+
+
+def _watch():
+    while True:
+        try:
+            trace = _current_trace()
+            if trace.get("active") and trace.get("trace_id") != _trace_id:
+                _start(trace)
+            elif not trace.get("active"):
+                _stop()
+        except Exception:
+            # The launcher is gone or restarting, keep the current state
+            pass
+        time.sleep(POLL_SECONDS)
+
+
+def install(app):
+    """Register the request hooks and start following the launcher"""
+    if not os.environ.get("WAP_CONTROL_URL"):
+        return
+
+    @app.before_request
+    def _trace_begin():
+        g.trace_started = time.perf_counter()
+        g.request_id = request.headers.get("X-Request-ID") or uuid.uuid4().hex[:12]
+        if _trace_id:
+            LOGGER.debug("-> %s %s request_id=%s", request.method, request.full_path, g.request_id)
+
+    @app.after_request
+    def _trace_end(response):
+        if _trace_id:
+            elapsed = (time.perf_counter() - g.get("trace_started", time.perf_counter())) * 1000
+            LOGGER.info("<- %s %s %s %.1fms request_id=%s", request.method, request.full_path,
+                        response.status_code, elapsed, g.get("request_id"))
+            response.headers["X-WAP-Trace-ID"] = _trace_id
+        return response
+
+    threading.Thread(target=_watch, name="launcher-trace", daemon=True).start()
@@ -12,6 +12,26 @@ sys.path.insert(0, current_dir)
 sys.path.insert(0, embedded_site_packages)
 sys.path.insert(0, embedded_lib)
 
+from launcher_progress import report_progress, report_stage
+
+# Flags from "launcher --backend-args ..."; unknown ones are left in sys.argv
+# for the application code
+import argparse
+import logging
+parser = argparse.ArgumentParser(add_help=False)
+parser.add_argument("--log-level", default=None)
+parser.add_argument("--safe", action="store_true")
+# The backend has no authentication; only LAN mode's proxy exposes it
+parser.add_argument("--host", default=os.environ.get("WAP_HOST", "127.0.0.1"))
+args, sys.argv[1:] = parser.parse_known_args()
+if args.log_level:
+    logging.basicConfig(level=args.log_level.upper(), force=True)
+if args.safe:
+    # Safe Mode after repeated failed starts: optional plugins check this
+    # and stay off
+    os.environ["WAP_SAFE_MODE"] = "1"
+    print("Safe Mode: optional plugins are disabled")
+
 print("=== Starting Python Server ===")
 print(f"Python: {sys.executable}")
 print(f"Working dir: {os.getcwd()}")
@@ -23,6 +43,10 @@ for file in os.listdir(current_dir):
         print(f"  - {file}")
 
 try:
+    # Importing api_server pulls in OpenCV and the GIS stack, the slowest part
+    report_stage("loading_models", "Loading image and GIS libraries...")
+    report_progress("libraries", 0, "Loading image and GIS libraries")
+
     # Try different import methods
     try:
         # Method 1: Regular import
@@ -37,8 +61,12 @@ try:
         app = api_server.app
         print("API server imported using absolute path")
     
-    print("Starting server on http://0.0.0.0:5000")
-    app.run(host='0.0.0.0', port=5000, debug=False)
+    report_progress("libraries", 100, done=True)
+    report_stage("starting_backend", "Starting the API server...")
+    # The launcher passes the port, e.g. for --headless --port 0
+    port = int(os.environ.get("WAP_PORT", "5000"))
+    print(f"Starting server on http://{args.host}:{port}")
+    app.run(host=args.host, port=port, debug=False)
         
 except Exception as e:
     print(f"Error: {e}")
@@ -48,12 +48,16 @@ class _HomeScreenState extends State<HomeScreen> with WidgetsBindingObserver {
   }
 
   Future<void> _shutdownPythonServer() async {
+    if (PythonService.isRemote) {
+      return;
+    }
     try {
-      final client = HttpClient();
+      final client = PythonService.createHttpClient();
       // Set timeout on the client instead of the request
       client.connectionTimeout = const Duration(seconds: 2);
       
-      final request = await client.postUrl(Uri.parse('http://localhost:5000/shutdown'));
+      final request = await client.postUrl(Uri.parse('${PythonService.baseUrl}/shutdown'));
+      PythonService.authHeaders().forEach(request.headers.set);
       await request.close();
       print('Python server shutdown requested');
     } catch (e) {
@@ -2,14 +2,44 @@ import 'dart:async';
 import 'dart:convert';
 import 'dart:io';
 import 'package:http/http.dart' as http;
+import 'package:http/io_client.dart';
 
 class PythonService {
-  static const String baseUrl = 'http://localhost:5000';
+  // The launcher passes the backend URL, which points at a central server
+  // for thin-client installs
+  static final String baseUrl =
+      Platform.environment['WAP_BACKEND_URL'] ?? 'http://localhost:5000';
+
+  // A remote backend is shared, so this app must never shut it down
+  static final bool isRemote = Platform.environment['WAP_BACKEND_REMOTE'] == '1';
+
+  // Behind the launcher's proxy every request carries the session token,
+  // and the proxy's self-signed certificate is the only one trusted
+  static final String? _token = Platform.environment['WAP_BACKEND_TOKEN'];
+  static final http.Client _client = IOClient(createHttpClient());
+
+  static HttpClient createHttpClient() {
+    final certPath = Platform.environment['WAP_BACKEND_CERT'];
+    if (certPath == null || certPath.isEmpty) {
+      return HttpClient();
+    }
+    final context = SecurityContext(withTrustedRoots: false)
+      ..setTrustedCertificates(certPath);
+    return HttpClient(context: context);
+  }
+
+  static Map<String, String> authHeaders([Map<String, String>? headers]) {
+    final token = _token;
+    return {
+      ...?headers,
+      if (token != null && token.isNotEmpty) 'Authorization': 'Bearer $token',
+    };
+  }
   
   // Check if Python server is running
   static Future<bool> isServerRunning() async {
     try {
-      final response = await http.get(Uri.parse('$baseUrl/health'))
+      final response = await _client.get(Uri.parse('$baseUrl/health'), headers: authHeaders())
           .timeout(const Duration(seconds: 3));
       return response.statusCode == 200;
     } catch (e) {
@@ -24,9 +54,9 @@ class PythonService {
     required bool shouldRotate,
   }) async {
     try {
-      final response = await http.post(
+      final response = await _client.post(
         Uri.parse('$baseUrl/batch_process'),
-        headers: {'Content-Type': 'application/json'},
+        headers: authHeaders({'Content-Type': 'application/json'}),
         body: json.encode({
           'source': sourceDir,
           'dest': outputDir,
@@ -54,9 +84,9 @@ class PythonService {
     required String outputDir,
   }) async {
     try {
-      final response = await http.post(
+      final response = await _client.post(
         Uri.parse('$baseUrl/batch_rename'),
-        headers: {'Content-Type': 'application/json'},
+        headers: authHeaders({'Content-Type': 'application/json'}),
         body: json.encode({
           'source': sourceDir,
           'dest': outputDir,
@@ -83,9 +113,9 @@ class PythonService {
     required String outputDir,
   }) async {
     try {
-      final response = await http.post(
+      final response = await _client.post(
         Uri.parse('$baseUrl/batch_rotate'),
-        headers: {'Content-Type': 'application/json'},
+        headers: authHeaders({'Content-Type': 'application/json'}),
         body: json.encode({
           'source': sourceDir,
           'dest': outputDir,
@@ -114,9 +144,9 @@ class PythonService {
     required String outputFile,
   }) async {
     try {
-      final response = await http.post(
+      final response = await _client.post(
         Uri.parse('$baseUrl/search_off_point'),
-        headers: {'Content-Type': 'application/json'},
+        headers: authHeaders({'Content-Type': 'application/json'}),
         body: json.encode({
           'point_path': pointFile,
           'polygon_path': polygonFile,
@@ -146,9 +176,9 @@ class PythonService {
     required int targetDpi,
   }) async {
     try {
-      final response = await http.post(
+      final response = await _client.post(
         Uri.parse('$baseUrl/convert_dpi'),
-        headers: {'Content-Type': 'application/json'},
+        headers: authHeaders({'Content-Type': 'application/json'}),
         body: json.encode({
           'source_dir': sourceDir,
           'dest_dir': outputDir,
@@ -182,9 +212,9 @@ class PythonService {
     int portraitHeight = 3307,
   }) async {
     try {
-      final response = await http.post(
-        Uri.parse('http://localhost:5000/create_world_files'),
-        headers: {'Content-Type': 'application/json'},
+      final response = await _client.post(
+        Uri.parse('$baseUrl/create_world_files'),
+        headers: authHeaders({'Content-Type': 'application/json'}),
         body: jsonEncode({
           'geojson_path': geojsonPath,
           'output_dir': outputDir,
@@ -221,9 +251,9 @@ class PythonService {
     double sameIdThreshold = 0.1, // Add new parameter
   }) async {
     try {
-      final response = await http.post(
+      final response = await _client.post(
         Uri.parse('$baseUrl/evaluate_sipw'),
-        headers: {'Content-Type': 'application/json'},
+        headers: authHeaders({'Content-Type': 'application/json'}),
         body: json.encode({
           'sipw_path': sipwPath,
           'polygon_path': polygonPath,
@@ -249,9 +279,9 @@ class PythonService {
     String outputPath = 'SIPW_Report.xlsx',
   }) async {
     try {
-      final response = await http.post(
-        Uri.parse('http://localhost:5000/generate_sipw_report'),
-        headers: {'Content-Type': 'application/json'},
+      final response = await _client.post(
+        Uri.parse('$baseUrl/generate_sipw_report'),
+        headers: authHeaders({'Content-Type': 'application/json'}),
         body: jsonEncode({
           'sipw_path': sipwPath,
           'output_path': outputPath,
@@ -278,7 +308,7 @@ class PythonService {
       await Future.delayed(const Duration(seconds: 1));
       
       try {
-        final response = await http.get(Uri.parse('$baseUrl/progress'))
+        final response = await _client.get(Uri.parse('$baseUrl/progress'), headers: authHeaders())
             .timeout(const Duration(seconds: 5));
         
         if (response.statusCode == 200) {
@@ -294,7 +324,7 @@ class PythonService {
   // Test server connection
   static Future<Map<String, dynamic>> testConnection() async {
     try {
-      final response = await http.get(Uri.parse('$baseUrl/health'))
+      final response = await _client.get(Uri.parse('$baseUrl/health'), headers: authHeaders())
           .timeout(const Duration(seconds: 5));
 
       return {
@@ -323,7 +353,7 @@ class PythonService {
   // Download result file
   static Future<File?> downloadFile(String filename) async {
     try {
-      final response = await http.get(Uri.parse('$baseUrl/download/$filename'))
+      final response = await _client.get(Uri.parse('$baseUrl/download/$filename'), headers: authHeaders())
           .timeout(const Duration(seconds: 15));
       
       if (response.statusCode == 200) {